@@ -11,6 +11,9 @@ const (
 	//DefaultScopeSeparator is used to convert a list of scopes to a string
 	DefaultScopeSeparator = " "
 
+	//CodeChallengeMethodS256 is the PKCE code challenge method where the challenge is the SHA-256 hash of the verifier
+	CodeChallengeMethodS256 = "S256"
+
 	//IntervalAddition is used in device code requests to increase the polling interval if there is a slow down error
 	IntervalAddition = 5
 
@@ -24,26 +27,29 @@ const (
 	AppMetadataCacheID = "appmetadata"
 
 	//JSON Cache Keys
-	JSONHomeAccountID  = "home_account_id"
-	JSONEnvironment    = "environment"
-	JSONRealm          = "realm"
-	JSONLocalAccountID = "local_account_id"
-	JSONAuthorityType  = "authority_type"
-	JSONUsername       = "username"
-	JSONClientInfo     = "client_info"
-	JSONAlternativeID  = "alternative_account_id"
-	JSONGivenName      = "given_name"
-	JSONFamilyName     = "family_name"
-	JSONName           = "name"
-	JSONMiddleName     = "middle_name"
-	JSONClientID       = "client_id"
-	JSONCredentialType = "credential_type"
-	JSONSecret         = "secret"
-	JSONTarget         = "target"
-	JSONCachedAt       = "cached_at"
-	JSONExpiresOn      = "expires_on"
-	JSONExtExpiresOn   = "extended_expires_on"
-	JSONFamilyID       = "family_id"
+	JSONHomeAccountID   = "home_account_id"
+	JSONEnvironment     = "environment"
+	JSONRealm           = "realm"
+	JSONLocalAccountID  = "local_account_id"
+	JSONAuthorityType   = "authority_type"
+	JSONUsername        = "username"
+	JSONClientInfo      = "client_info"
+	JSONAlternativeID   = "alternative_account_id"
+	JSONGivenName       = "given_name"
+	JSONFamilyName      = "family_name"
+	JSONName            = "name"
+	JSONMiddleName      = "middle_name"
+	JSONClientID        = "client_id"
+	JSONCredentialType  = "credential_type"
+	JSONSecret          = "secret"
+	JSONTarget          = "target"
+	JSONCachedAt        = "cached_at"
+	JSONExpiresOn       = "expires_on"
+	JSONExtExpiresOn    = "extended_expires_on"
+	JSONFamilyID        = "family_id"
+	JSONTokenType       = "token_type"
+	JSONKeyID           = "key_id"
+	JSONRequestedTarget = "requested_target"
 
 	//Credential Types
 	CredentialTypeRefreshToken = "RefreshToken"
@@ -54,6 +60,10 @@ const (
 	MSSTS = "MSSTS"
 	ADFS  = "ADFS"
 	B2C   = "B2C"
+	//CIAM is the authority type of Microsoft Entra External ID tenants, whose authorities are on ciamlogin.com hosts
+	CIAM = "CIAM"
+	//ManagedIdentityAuthority is the authority type of tokens from a managed identity endpoint
+	ManagedIdentityAuthority = "ManagedIdentity"
 
 	//Grant Types
 	PasswordGrant         = "password"
@@ -64,19 +74,43 @@ const (
 	RefreshTokenGrant     = "refresh_token"
 	ClientCredentialGrant = "client_credentials"
 	ClientAssertionGrant  = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	JWTBearerGrant        = "urn:ietf:params:oauth:grant-type:jwt-bearer"
 
 	//Endpoints
 	AuthorizationEndpoint     = "https://%v/%v/oauth2/v2.0/authorize"
 	InstanceDiscoveryEndpoint = "https://%v/common/discovery/instance?%v"
 	DefaultHost               = "login.microsoftonline.com"
+	RegionalHost              = "login.microsoft.com"
+	IMDSRegionEndpoint        = "http://169.254.169.254/metadata/instance/compute/location?format=text&api-version=2021-01-01"
+	IMDSTokenEndpoint         = "http://169.254.169.254/metadata/identity/oauth2/token"
+	IMDSTokenAPIVersion       = "2018-02-01"
+	AppServiceTokenAPIVersion = "2019-08-01"
+
+	//Managed identity tokens are cached in the managed_identity tenant, with the client ID of the identity
+	ManagedIdentityTenant           = "managed_identity"
+	SystemAssignedManagedIdentityID = "system_assigned_managed_identity"
 
 	SoapActionWSTrust2005 = "http://schemas.xmlsoap.org/ws/2005/02/trust/RST/Issue"
 	SoapActionDefault     = "http://docs.oasis-open.org/ws-sx/ws-trust/200512/RST/Issue"
 
+	//BearerTokenType is the token type of access tokens that aren't bound to a key, it's assumed when the response has no token_type
+	BearerTokenType = "Bearer"
+
+	//LibraryVersion is the version of MSAL Go sent in the telemetry headers
+	LibraryVersion = "0.1.0"
+	//TelemetrySchemaVersion is the version of the format of the x-client-current-telemetry and x-client-last-telemetry headers
+	TelemetrySchemaVersion = "2"
+
 	//HTTP Headers
 	ProductHeaderName                    = "x-client-SKU"
 	ProductHeaderValue                   = "MSAL.Go"
+	VersionHeaderName                    = "x-client-Ver"
+	CPUHeaderName                        = "x-client-CPU"
 	OSHeaderName                         = "x-client-OS"
+	CurrentTelemetryHeaderName           = "x-client-current-telemetry"
+	LastTelemetryHeaderName              = "x-client-last-telemetry"
 	CorrelationIDHeaderName              = "client-request-id"
 	ReqCorrelationIDInResponseHeaderName = "return-client-request-id"
+	IdentityHeaderName                   = "X-IDENTITY-HEADER"
+	UserAgentHeaderName                  = "User-Agent"
 )
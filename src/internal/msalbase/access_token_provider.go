@@ -8,3 +8,10 @@ type accessTokenProvider interface {
 	GetExpiresOn() string
 	GetScopes() string
 }
+
+//accessTokenMetadataProvider is implemented by cached access tokens that keep their token type, extended expiry and requested scopes
+type accessTokenMetadataProvider interface {
+	GetTokenType() string
+	GetExtendedExpiresOn() string
+	GetRequestedScopes() string
+}
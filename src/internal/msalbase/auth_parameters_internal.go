@@ -3,7 +3,13 @@
 
 package msalbase
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"time"
+)
+
+//DefaultClockSkew is the difference allowed between the clocks of the authority and the client when the lifetime of a token is checked
+const DefaultClockSkew = 5 * time.Minute
 
 //AuthorizationType represents the type of token flow
 type AuthorizationType int
@@ -18,6 +24,8 @@ const (
 	AuthorizationTypeClientCredentials                       = iota
 	AuthorizationTypeDeviceCode                              = iota
 	AuthorizationTypeRefreshTokenExchange                    = iota
+	AuthorizationTypeOnBehalfOf                              = iota
+	AuthorizationTypeManagedIdentity                         = iota
 )
 
 //AuthParametersInternal represents the parameters used for authorization for token acquisition
@@ -30,13 +38,59 @@ type AuthParametersInternal struct {
 	HomeaccountID     string
 	Username          string
 	Password          string
+	UserAssertion     string
 	Scopes            []string
 	AuthorizationType AuthorizationType
+	//Claims is the claims challenge sent with the token request
+	Claims string
+	//ClientCapabilities are merged into the claims of the token request, e.g. cp1 for apps that handle claims challenges
+	ClientCapabilities []string
+	//Nonce is the nonce sent in the authorization request, the ID token of the response has to contain it
+	Nonce string
+	//IgnoreFamilyRefreshToken makes the cache only return the app's own refresh token, it's set when the authority rejected the family refresh token
+	IgnoreFamilyRefreshToken bool
+	//Region is the Azure region whose token endpoint is used, tokens are requested from the global endpoint when it's empty
+	Region string
+	//AllowExtendedExpiry lets cached access tokens be used until their extended expiry, it's set when the authority can't be reached
+	AllowExtendedExpiry bool
+	//SkipExpirationBuffer lets cached access tokens be used until they actually expire, instead of until the expiration buffer before that
+	SkipExpirationBuffer bool
+	//PoPKey is the key the access token is bound to, a bearer token is requested when it's nil
+	PoPKey *PoPKey
+	//PartitionKey selects the cache partition the tokens are read from and written to, the shared cache is used when it's empty
+	PartitionKey string
+	//ExtraQueryParameters are sent with the token requests, the parameters MSAL sends itself can't be replaced by them
+	ExtraQueryParameters map[string]string
+	//ManagedIdentity is the identity a managed identity token is requested for, the resource is the only scope
+	ManagedIdentity *ManagedIdentity
+	//ClockSkew is the difference allowed between the clocks of the authority and the client when the exp and nbf claims of the ID token are checked
+	ClockSkew time.Duration
+	//NormalizeCacheEnvironment caches the tokens under the preferred_cache alias of the authority host, instead of the host of the request
+	NormalizeCacheEnvironment bool
+}
+
+//CreateAuthParametersInternal creates an authorization parameters object, it fails if the correlation ID can't be read from the random source
+func CreateAuthParametersInternal(clientID string, authorityInfo *AuthorityInfo) (*AuthParametersInternal, error) {
+	corrID, err := NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	p := &AuthParametersInternal{ClientID: clientID, AuthorityInfo: authorityInfo, CorrelationID: corrID, ClockSkew: DefaultClockSkew}
+	return p, nil
+}
+
+//String formats the parameters for logging, secrets are never included and PII is redacted unless PII logging is enabled
+func (p *AuthParametersInternal) String() string {
+	return fmt.Sprintf(
+		"{AuthorityInfo:%v CorrelationID:%s Endpoints:%v ClientID:%s Redirecturi:%s HomeaccountID:%s Username:%s Password:%s UserAssertion:%s Scopes:%v AuthorizationType:%d}",
+		p.AuthorityInfo, p.CorrelationID, p.Endpoints, p.ClientID, p.Redirecturi,
+		PII(p.HomeaccountID), PII(p.Username), redactSecret(p.Password), redactSecret(p.UserAssertion), PIIList(p.Scopes), p.AuthorizationType,
+	)
 }
 
-//CreateAuthParametersInternal creates an authorization parameters object
-func CreateAuthParametersInternal(clientID string, authorityInfo *AuthorityInfo) *AuthParametersInternal {
-	corrID := uuid.New().String()
-	p := &AuthParametersInternal{ClientID: clientID, AuthorityInfo: authorityInfo, CorrelationID: corrID}
-	return p
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "<redacted>"
 }
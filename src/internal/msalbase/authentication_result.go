@@ -18,6 +18,14 @@ type AuthenticationResult struct {
 	ExpiresOn      time.Time
 	GrantedScopes  []string
 	DeclinedScopes []string
+	//CorrelationID is the ID the requests of the token acquisition were sent with
+	CorrelationID string
+	//TokenType is the type of the access token, e.g. Bearer or pop
+	TokenType string
+	//ExtExpiresOn is when the access token stops being used while the authority can't be reached
+	ExtExpiresOn time.Time
+	//SpaCode is the authorization code returned for a single-page app, it's never cached
+	SpaCode string
 }
 
 //CreateAuthenticationResultFromStorageTokenResponse creates an authenication result from a storage token response (which is generated from the cache)
@@ -28,7 +36,8 @@ func CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse *St
 	account := storageTokenResponse.account
 	var idToken *IDToken
 	accessToken := ""
-	var expiresOn time.Time
+	var expiresOn, extExpiresOn time.Time
+	tokenType := BearerTokenType
 	grantedScopes := []string{}
 	declinedScopes := []string{}
 	var err error
@@ -40,6 +49,18 @@ func CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse *St
 			return nil, errors.New("access token in cache expires at an invalid time")
 		}
 		grantedScopes = SplitScopes(storageTokenResponse.accessToken.GetScopes())
+		extExpiresOn = expiresOn
+		if metadata, ok := storageTokenResponse.accessToken.(accessTokenMetadataProvider); ok {
+			tokenType = metadata.GetTokenType()
+			// Tokens written by other MSALs may have no extended expiry, their expiry is used instead
+			if ext, err := ConvertStrUnixToUTCTime(metadata.GetExtendedExpiresOn()); err == nil {
+				extExpiresOn = ext
+			}
+			// The requested scopes the authority didn't grant are reported the way they were when the token was acquired
+			if requested := metadata.GetRequestedScopes(); requested != "" {
+				declinedScopes = findDeclinedScopes(SplitScopes(requested), grantedScopes)
+			}
+		}
 	} else {
 		return nil, errors.New("no access token present in cache")
 	}
@@ -50,22 +71,23 @@ func CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse *St
 			return nil, err
 		}
 	}
-	ar := &AuthenticationResult{account, idToken, accessToken, expiresOn, grantedScopes, declinedScopes}
+	ar := &AuthenticationResult{account, idToken, accessToken, expiresOn, grantedScopes, declinedScopes, "", tokenType, extExpiresOn, ""}
 	return ar, nil
 }
 
 // CreateAuthenticationResult creates an AuthenticationResult
 func CreateAuthenticationResult(tokenResponse *TokenResponse, account *Account) (*AuthenticationResult, error) {
 	grantedScopes := tokenResponse.GrantedScopes
+	//The user may consent to only some of the scopes, the token is still returned so the app can ask for the declined ones
 	declinedScopes := tokenResponse.declinedScopes
-	//Checking if there are any scopes that were declined, this would lead to an invalid access token
 	if len(declinedScopes) > 0 {
-		return nil, errors.New("token response failed because declined scopes are present")
+		GetLogger().Warnf("Scopes '%s' were declined, the token was granted for '%s'", ConcatenateScopes(declinedScopes), ConcatenateScopes(grantedScopes))
 	}
 	idToken := tokenResponse.IDToken
 	accessToken := tokenResponse.AccessToken
 	expiresOn := tokenResponse.ExpiresOn
-	ar := &AuthenticationResult{account, idToken, accessToken, expiresOn, grantedScopes, declinedScopes}
+	ar := &AuthenticationResult{account, idToken, accessToken, expiresOn, grantedScopes, declinedScopes, "",
+		tokenResponse.TokenType, tokenResponse.ExtExpiresOn, tokenResponse.SpaCode}
 	return ar, nil
 }
 
@@ -77,6 +99,62 @@ func (ar *AuthenticationResult) GetAccessToken() string {
 	return ar.AccessToken
 }
 
+//GetGrantedScopes returns the scopes the access token was granted, they may include more scopes than were requested
+func (ar *AuthenticationResult) GetGrantedScopes() []string {
+	if ar == nil {
+		return nil
+	}
+	return ar.GrantedScopes
+}
+
+//GetDeclinedScopes returns the requested scopes the access token wasn't granted, e.g. because the user didn't consent to them
+func (ar *AuthenticationResult) GetDeclinedScopes() []string {
+	if ar == nil {
+		return nil
+	}
+	return ar.DeclinedScopes
+}
+
+//GetTokenType returns the type of the access token, e.g. Bearer or pop
+func (ar *AuthenticationResult) GetTokenType() string {
+	if ar == nil {
+		return ""
+	}
+	return ar.TokenType
+}
+
+//GetExpiresOn returns when the access token expires
+func (ar *AuthenticationResult) GetExpiresOn() time.Time {
+	if ar == nil {
+		return time.Time{}
+	}
+	return ar.ExpiresOn
+}
+
+//GetExtExpiresOn returns the extended expiry of the access token, until which it's used when the authority can't be reached
+func (ar *AuthenticationResult) GetExtExpiresOn() time.Time {
+	if ar == nil {
+		return time.Time{}
+	}
+	return ar.ExtExpiresOn
+}
+
+//GetSpaCode returns the authorization code for a single-page app, it's empty unless the auth code request asked for one
+func (ar *AuthenticationResult) GetSpaCode() string {
+	if ar == nil {
+		return ""
+	}
+	return ar.SpaCode
+}
+
+//GetCorrelationID returns the correlation ID of the token acquisition, the authority's logs can be searched for it
+func (ar *AuthenticationResult) GetCorrelationID() string {
+	if ar == nil {
+		return ""
+	}
+	return ar.CorrelationID
+}
+
 //GetAccount returns the account of the authentication result
 func (ar *AuthenticationResult) GetAccount() *Account {
 	if ar == nil {
@@ -84,3 +162,54 @@ func (ar *AuthenticationResult) GetAccount() *Account {
 	}
 	return ar.Account
 }
+
+//GetRawIDToken returns the ID token as it was returned by the authority, it's empty when no ID token was returned
+func (ar *AuthenticationResult) GetRawIDToken() string {
+	return ar.getIDToken().RawToken
+}
+
+//GetIDTokenClaims returns all the claims of the ID token, it's empty when no ID token was returned
+func (ar *AuthenticationResult) GetIDTokenClaims() (map[string]interface{}, error) {
+	if ar == nil || ar.idToken == nil {
+		return map[string]interface{}{}, nil
+	}
+	return ar.idToken.GetClaims()
+}
+
+//GetTenantID returns the tid claim of the ID token
+func (ar *AuthenticationResult) GetTenantID() string {
+	return ar.getIDToken().TenantID
+}
+
+//GetObjectID returns the oid claim of the ID token
+func (ar *AuthenticationResult) GetObjectID() string {
+	return ar.getIDToken().Oid
+}
+
+//GetName returns the name claim of the ID token
+func (ar *AuthenticationResult) GetName() string {
+	return ar.getIDToken().Name
+}
+
+//GetEmail returns the email claim of the ID token
+func (ar *AuthenticationResult) GetEmail() string {
+	return ar.getIDToken().Email
+}
+
+//GetRoles returns the app roles of the user from the roles claim of the ID token
+func (ar *AuthenticationResult) GetRoles() []string {
+	return ar.getIDToken().Roles
+}
+
+//GetGroups returns the group IDs of the user from the groups claim of the ID token
+func (ar *AuthenticationResult) GetGroups() []string {
+	return ar.getIDToken().Groups
+}
+
+//getIDToken returns an empty ID token when there's no ID token, so absent claims are returned as zero values
+func (ar *AuthenticationResult) getIDToken() *IDToken {
+	if ar == nil || ar.idToken == nil {
+		return &IDToken{}
+	}
+	return ar.idToken
+}
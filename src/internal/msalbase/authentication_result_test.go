@@ -59,12 +59,34 @@ func TestCreateAuthenticationResult(t *testing.T) {
 	if !reflect.DeepEqual(actualDeclinedScopes, testDeclinedScopesWithoutError) {
 		t.Errorf("Actual declined scopes %v differ from expected declined scopes %v", actualDeclinedScopes, testDeclinedScopesWithoutError)
 	}
+	// Declined scopes don't fail the request, they are returned so the app can ask for them again
 	authResult, err = CreateAuthenticationResult(testTokenResponseWithError, testAccount)
-	if err == nil {
-		t.Error("Error should not be nil")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(authResult.GetDeclinedScopes(), testDeclinedScopesWithError) {
+		t.Errorf("Actual declined scopes %v differ from expected declined scopes %v", authResult.GetDeclinedScopes(), testDeclinedScopesWithError)
+	}
+}
+
+func TestCreateAuthenticationResultPartialConsent(t *testing.T) {
+	authParams := &AuthParametersInternal{Scopes: []string{"user.read", "mail.send"}}
+	tokenResponse, err := CreateTokenResponse(authParams, 200, `{"access_token":"secret","expires_in":3600,"scope":"User.Read"}`)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authResult, err := CreateAuthenticationResult(tokenResponse, &Account{})
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if authResult.GetAccessToken() != "secret" {
+		t.Errorf("The token for the granted scopes should be returned, instead the access token is %s", authResult.GetAccessToken())
+	}
+	if !reflect.DeepEqual(authResult.GetGrantedScopes(), []string{"user.read"}) {
+		t.Errorf("Granted scopes should be [user.read], instead they are %v", authResult.GetGrantedScopes())
 	}
-	if authResult != nil {
-		t.Errorf("Authentication result should be nil, not %v", authResult)
+	if !reflect.DeepEqual(authResult.GetDeclinedScopes(), []string{"mail.send"}) {
+		t.Errorf("Declined scopes should be [mail.send], instead they are %v", authResult.GetDeclinedScopes())
 	}
 }
 
@@ -101,3 +123,90 @@ func TestCreateAuthenticationResultFromStorageTokenResponse(t *testing.T) {
 		t.Errorf("Actual authentication result %+v differs from expected authentication result %+v", actualAuthResult, expAuthResult)
 	}
 }
+
+func TestGetIDTokenClaims(t *testing.T) {
+	// {"tid":"tenant","oid":"object","name":"Jane Doe","email":"jane@contoso.com","roles":["Admin","Reader"],"groups":["g1"],"ctry":"US","xms_pl":"en"}
+	idToken, err := CreateIDToken("eyJhbGciOiJub25lIn0.eyJ0aWQiOiJ0ZW5hbnQiLCJvaWQiOiJvYmplY3QiLCJuYW1lIjoiSmFuZSBEb2UiLCJlbWFpbCI6ImphbmVAY29udG9zby5jb20iLCJyb2xlcyI6WyJBZG1pbiIsIlJlYWRlciJdLCJncm91cHMiOlsiZzEiXSwiY3RyeSI6IlVTIiwieG1zX3BsIjoiZW4ifQ.")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authResult := &AuthenticationResult{idToken: idToken}
+	claims, err := authResult.GetIDTokenClaims()
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if claims["ctry"] != "US" || claims["xms_pl"] != "en" {
+		t.Errorf("Custom claims are missing from %v", claims)
+	}
+	if authResult.GetTenantID() != "tenant" || authResult.GetObjectID() != "object" ||
+		authResult.GetName() != "Jane Doe" || authResult.GetEmail() != "jane@contoso.com" {
+		t.Errorf("Actual claims %+v differ from the claims of the ID token", idToken)
+	}
+	if !reflect.DeepEqual(authResult.GetRoles(), []string{"Admin", "Reader"}) || !reflect.DeepEqual(authResult.GetGroups(), []string{"g1"}) {
+		t.Errorf("Actual roles %v and groups %v differ from the claims of the ID token", authResult.GetRoles(), authResult.GetGroups())
+	}
+}
+
+func TestGetIDTokenClaimsWithoutIDToken(t *testing.T) {
+	authResult := &AuthenticationResult{AccessToken: "secret"}
+	claims, err := authResult.GetIDTokenClaims()
+	if err != nil || len(claims) != 0 {
+		t.Errorf("Claims should be empty without an ID token, instead they are %v and the error is %v", claims, err)
+	}
+	if authResult.GetTenantID() != "" || authResult.GetRoles() != nil {
+		t.Error("Absent claims should be returned as zero values")
+	}
+}
+
+func TestCreateAuthenticationResultMetadata(t *testing.T) {
+	testAuthParams := &AuthParametersInternal{Scopes: []string{"user.read"}}
+	response := `{
+		"access_token": "secret",
+		"token_type": "Bearer",
+		"expires_in": 3600,
+		"ext_expires_in": 7200,
+		"scope": "User.Read Mail.Read openid profile",
+		"spa_code": "spaCode"
+	}`
+	tokenResponse, err := CreateTokenResponse(testAuthParams, 200, response)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authResult, err := CreateAuthenticationResult(tokenResponse, nil)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	// Partial or extra consent shows up as granted scopes that differ from the requested ones
+	expectedGrantedScopes := []string{"user.read", "mail.read", "openid", "profile"}
+	if !reflect.DeepEqual(authResult.GetGrantedScopes(), expectedGrantedScopes) {
+		t.Errorf("Actual granted scopes %v differ from expected granted scopes %v", authResult.GetGrantedScopes(), expectedGrantedScopes)
+	}
+	if authResult.GetTokenType() != "Bearer" {
+		t.Errorf("Actual token type %s differs from expected token type Bearer", authResult.GetTokenType())
+	}
+	if extExpiry := authResult.GetExtExpiresOn().Sub(authResult.GetExpiresOn()); extExpiry < 3599*time.Second || extExpiry > 3601*time.Second {
+		t.Errorf("Extended expiry should be an hour after the expiry, instead it's %v after it", extExpiry)
+	}
+	if authResult.GetSpaCode() != "spaCode" {
+		t.Errorf("Actual SPA code %s differs from expected SPA code spaCode", authResult.GetSpaCode())
+	}
+}
+
+func TestCreateAuthenticationResultFromStorageTokenResponseMetadata(t *testing.T) {
+	accessToken := new(MockAccessToken)
+	accessToken.On("GetSecret").Return("secret")
+	accessToken.On("GetExpiresOn").Return("1000")
+	accessToken.On("GetScopes").Return("user.read mail.read")
+	var idToken *MockCredential
+	authResult, err := CreateAuthenticationResultFromStorageTokenResponse(CreateStorageTokenResponse(accessToken, nil, idToken, nil))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(authResult.GetGrantedScopes(), []string{"user.read", "mail.read"}) {
+		t.Errorf("Granted scopes should be the cached scopes, instead they are %v", authResult.GetGrantedScopes())
+	}
+	// Tokens that don't keep their metadata are bearer tokens that can't be used past their expiry
+	if authResult.GetTokenType() != BearerTokenType || !authResult.GetExtExpiresOn().Equal(authResult.GetExpiresOn()) {
+		t.Errorf("Token type should be Bearer and the extended expiry the expiry, instead they are %s and %v", authResult.GetTokenType(), authResult.GetExtExpiresOn())
+	}
+}
@@ -12,16 +12,40 @@ import (
 type AuthorityEndpoints struct {
 	AuthorizationEndpoint string
 	TokenEndpoint         string
+	//JWKSURI is the endpoint of the keys that sign the tokens of the authority
+	JWKSURI               string
 	selfSignedJwtAudience string
 	authorityHost         string
 }
 
 //CreateAuthorityEndpoints creates an AuthorityEndpoints object
 func CreateAuthorityEndpoints(authorizationEndpoint string, tokenEndpoint string, selfSignedJwtAudience string, authorityHost string) *AuthorityEndpoints {
-	return &AuthorityEndpoints{authorizationEndpoint, tokenEndpoint, selfSignedJwtAudience, authorityHost}
+	return &AuthorityEndpoints{
+		AuthorizationEndpoint: authorizationEndpoint,
+		TokenEndpoint:         tokenEndpoint,
+		selfSignedJwtAudience: selfSignedJwtAudience,
+		authorityHost:         authorityHost,
+	}
+}
+
+//GetIssuer returns the issuer of the tokens of the authority, which is also the audience of self signed JWTs
+func (endpoints *AuthorityEndpoints) GetIssuer() string {
+	return endpoints.selfSignedJwtAudience
 }
 
 //GetUserRealmEndpoint returns the endpoint to get the user realm
 func (endpoints *AuthorityEndpoints) GetUserRealmEndpoint(username string) string {
 	return fmt.Sprintf("https://%s/common/UserRealm/%s?api-version=1.0", endpoints.authorityHost, url.PathEscape(username))
 }
+
+//GetRegionalEndpoints returns a copy of the endpoints whose token endpoint is the one of the region, the tenant of the path is kept
+func (endpoints *AuthorityEndpoints) GetRegionalEndpoints(region string) (*AuthorityEndpoints, error) {
+	tokenEndpoint, err := url.Parse(endpoints.TokenEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	tokenEndpoint.Host = GetRegionalHost(tokenEndpoint.Host, region)
+	regional := *endpoints
+	regional.TokenEndpoint = tokenEndpoint.String()
+	return &regional, nil
+}
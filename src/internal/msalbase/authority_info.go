@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -18,8 +19,51 @@ type AuthorityInfo struct {
 	UserRealmURIPrefix    string
 	ValidateAuthority     bool
 	Tenant                string
+	//Policy is the user flow of a B2C authority, it's empty for other authority types
+	Policy string
+	//Cloud is the Azure AD cloud the authority was created for, it's nil when the authority was given as a URL
+	Cloud *CloudInstance
+	//InstanceDiscoveryDisabled makes the host the only alias of the authority instead of asking instance discovery for its aliases
+	InstanceDiscoveryDisabled bool
+	//InstanceDiscoveryHeaders are sent with the instance discovery request only, e.g. credentials of a gateway in front of a private cloud
+	InstanceDiscoveryHeaders map[string]string
+	//KnownAuthorityHosts are the hosts that pass authority validation when instance discovery is disabled, any other host is rejected
+	KnownAuthorityHosts []string
 }
 
+//String formats the authority for logging, the values of the instance discovery headers may be credentials so only their names are included
+func (info *AuthorityInfo) String() string {
+	headerNames := make([]string, 0, len(info.InstanceDiscoveryHeaders))
+	for name := range info.InstanceDiscoveryHeaders {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	return fmt.Sprintf(
+		"{Host:%s CanonicalAuthorityURI:%s AuthorityType:%s ValidateAuthority:%t Tenant:%s Policy:%s InstanceDiscoveryDisabled:%t InstanceDiscoveryHeaders:%v KnownAuthorityHosts:%v}",
+		info.Host, info.CanonicalAuthorityURI, info.AuthorityType, info.ValidateAuthority, info.Tenant, info.Policy,
+		info.InstanceDiscoveryDisabled, headerNames, info.KnownAuthorityHosts,
+	)
+}
+
+//b2cPathSegment starts the path of B2C authorities in the https://<host>/tfp/<tenant>/<policy>/ format
+const b2cPathSegment = "tfp"
+
+//adfsPathSegment is the path of on-premises ADFS authorities, https://<host>/adfs/
+const adfsPathSegment = "adfs"
+
+//commonTenant and organizationsTenant are the tenants of multi-tenant authorities, the user's home tenant is only known after sign in
+const commonTenant = "common"
+const organizationsTenant = "organizations"
+
+//b2cHostSuffix is the host suffix of B2C authorities in the https://<tenant>.b2clogin.com/<tenant>/<policy>/ format
+const b2cHostSuffix = ".b2clogin.com"
+
+//ciamHostSuffix is the host suffix of CIAM authorities in the https://<tenant>.ciamlogin.com/ format
+const ciamHostSuffix = ".ciamlogin.com"
+
+//ciamDefaultTenantSuffix completes the tenant of a CIAM authority without a path, https://contoso.ciamlogin.com/ is the contoso.onmicrosoft.com tenant
+const ciamDefaultTenantSuffix = ".onmicrosoft.com"
+
 func canonicalizeAuthorityURI(input string) string {
 	val := input
 	// todo: ensure ends with /
@@ -39,12 +83,73 @@ func getFirstPathSegment(u *url.URL) (string, error) {
 	return "", errors.New("authority does not have two segments")
 }
 
+func getAuthorityType(u *url.URL) string {
+	firstPathSegment, err := getFirstPathSegment(u)
+	if (err == nil && firstPathSegment == b2cPathSegment) || strings.HasSuffix(u.Hostname(), b2cHostSuffix) {
+		return B2C
+	}
+	if err == nil && firstPathSegment == adfsPathSegment {
+		return ADFS
+	}
+	if strings.HasSuffix(u.Hostname(), ciamHostSuffix) {
+		return CIAM
+	}
+	return MSSTS
+}
+
+func createB2CAuthorityInfo(u *url.URL, validateAuthority bool) (*AuthorityInfo, error) {
+	host := u.Hostname()
+	pathParts := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	prefix := ""
+	if pathParts[0] == b2cPathSegment {
+		prefix = b2cPathSegment + "/"
+		pathParts = pathParts[1:]
+	}
+	if len(pathParts) < 2 || pathParts[0] == "" || pathParts[1] == "" {
+		return nil, errors.New("B2C authority must contain the tenant and the policy")
+	}
+	tenant := pathParts[0]
+	policy := pathParts[1]
+	return &AuthorityInfo{
+		Host:                  host,
+		CanonicalAuthorityURI: fmt.Sprintf("https://%v/%v%v/%v/", host, prefix, tenant, policy),
+		AuthorityType:         B2C,
+		UserRealmURIPrefix:    fmt.Sprintf("https://%v/common/userrealm/", host),
+		ValidateAuthority:     validateAuthority,
+		Tenant:                tenant,
+		Policy:                policy,
+	}, nil
+}
+
+//createCIAMAuthorityInfo creates the authority of a CIAM tenant, the tenant is taken from the host when the authority has no path
+func createCIAMAuthorityInfo(u *url.URL, validateAuthority bool) (*AuthorityInfo, error) {
+	host := u.Hostname()
+	tenant := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")[0]
+	if tenant == "" {
+		tenant = strings.TrimSuffix(host, ciamHostSuffix) + ciamDefaultTenantSuffix
+	}
+	return &AuthorityInfo{
+		Host:                  host,
+		CanonicalAuthorityURI: fmt.Sprintf("https://%v/%v/", host, tenant),
+		AuthorityType:         CIAM,
+		UserRealmURIPrefix:    fmt.Sprintf("https://%v/common/userrealm/", host),
+		ValidateAuthority:     validateAuthority,
+		Tenant:                tenant,
+	}, nil
+}
+
 func createAuthorityInfo(authorityType string, authorityURI string, validateAuthority bool) (*AuthorityInfo, error) {
 
 	u, err := url.Parse(authorityURI)
 	if err != nil {
 		return nil, err
 	}
+	if authorityType == B2C {
+		return createB2CAuthorityInfo(u, validateAuthority)
+	}
+	if authorityType == CIAM {
+		return createCIAMAuthorityInfo(u, validateAuthority)
+	}
 
 	host := u.Hostname()
 	userRealmURIPrefix := fmt.Sprintf("https://%v/common/userrealm/", host)
@@ -55,7 +160,17 @@ func createAuthorityInfo(authorityType string, authorityURI string, validateAuth
 
 	canonicalAuthorityURI := fmt.Sprintf("https://%v/%v/", host, tenant)
 
-	return &AuthorityInfo{host, canonicalAuthorityURI, authorityType, userRealmURIPrefix, validateAuthority, tenant}, nil
+	return &AuthorityInfo{host, canonicalAuthorityURI, authorityType, userRealmURIPrefix, validateAuthority, tenant, "", nil, false, nil, nil}, nil
+}
+
+//CreateAuthorityInfoFromCloudInstance creates an AuthorityInfo instance for a tenant of the cloud
+func CreateAuthorityInfoFromCloudInstance(cloud *CloudInstance, tenant string, validateAuthority bool) (*AuthorityInfo, error) {
+	authorityInfo, err := CreateAuthorityInfoFromAuthorityURI(fmt.Sprintf("https://%v/%v/", cloud.LoginHost, tenant), validateAuthority)
+	if err != nil {
+		return nil, err
+	}
+	authorityInfo.Cloud = cloud
+	return authorityInfo, nil
 }
 
 //CreateAuthorityInfoFromAuthorityURI creates an AuthorityInfo instance from the authority URL provided
@@ -66,8 +181,81 @@ func CreateAuthorityInfoFromAuthorityURI(authorityURI string, validateAuthority
 		return nil, err
 	}
 
+	u, err := url.Parse(canonicalURI)
+	if err != nil {
+		return nil, err
+	}
 	// todo: check for other authority types...
-	authorityType := MSSTS
+	authorityType := getAuthorityType(u)
 
 	return createAuthorityInfo(authorityType, canonicalURI, validateAuthority)
 }
+
+//WithTenant returns a copy of the /common or /organizations authority for the tenant, e.g. the tid claim of the user's ID token,
+//so later requests go to the user's home tenant. An authority that's already for a tenant can only be "rewritten" to that same tenant.
+func (info *AuthorityInfo) WithTenant(tenant string) (*AuthorityInfo, error) {
+	if info.AuthorityType != MSSTS {
+		return nil, fmt.Errorf("the tenant of a %s authority can't be changed", info.AuthorityType)
+	}
+	tenant = strings.ToLower(tenant)
+	if tenant == "" || strings.Contains(tenant, "/") {
+		return nil, fmt.Errorf("tenant '%s' isn't valid", tenant)
+	}
+	if info.Tenant != commonTenant && info.Tenant != organizationsTenant && info.Tenant != tenant {
+		return nil, fmt.Errorf("authority '%s' is already for tenant '%s', it can't be rewritten to tenant '%s'", info.CanonicalAuthorityURI, info.Tenant, tenant)
+	}
+	tenantAuthority := *info
+	tenantAuthority.Tenant = tenant
+	tenantAuthority.CanonicalAuthorityURI = fmt.Sprintf("https://%v/%v/", info.Host, tenant)
+	return &tenantAuthority, nil
+}
+
+//GetInstanceDiscoveryHost returns the host that serves instance discovery for the authority
+//Hosts that aren't known are discovered through the cloud of the authority, or the public cloud if there is none
+func (info *AuthorityInfo) GetInstanceDiscoveryHost(isTrustedHost bool) string {
+	if isTrustedHost {
+		return info.Host
+	}
+	if info.Cloud != nil {
+		return info.Cloud.LoginHost
+	}
+	return DefaultHost
+}
+
+//ValidateKnownHost rejects the authority when instance discovery is disabled and its host isn't one of the known authority hosts
+//Nothing is checked when there are no known hosts or authority validation is turned off, instance discovery validates the host otherwise
+func (info *AuthorityInfo) ValidateKnownHost() error {
+	if !info.ValidateAuthority || !info.InstanceDiscoveryDisabled || len(info.KnownAuthorityHosts) == 0 {
+		return nil
+	}
+	for _, host := range info.KnownAuthorityHosts {
+		if strings.EqualFold(host, info.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("authority host '%s' isn't one of the known authority hosts", info.Host)
+}
+
+//IsSameCloud checks that both authorities are in the same Azure AD cloud, authorities outside of the clouds are only the same when their hosts are
+func (info *AuthorityInfo) IsSameCloud(other *AuthorityInfo) bool {
+	if info.Host == other.Host {
+		return true
+	}
+	cloud := info.Cloud
+	if cloud == nil {
+		cloud = getCloudInstanceForHost(info.Host)
+	}
+	otherCloud := other.Cloud
+	if otherCloud == nil {
+		otherCloud = getCloudInstanceForHost(other.Host)
+	}
+	return cloud != nil && cloud == otherCloud
+}
+
+//GetRegionalHost returns the host of the regional token endpoint of the host, the public cloud's regional endpoints are served by login.microsoft.com
+func GetRegionalHost(host string, region string) string {
+	if host == DefaultHost || host == "login.windows.net" {
+		host = RegionalHost
+	}
+	return region + "." + host
+}
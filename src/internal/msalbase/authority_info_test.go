@@ -4,7 +4,9 @@
 package msalbase
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +28,211 @@ func TestCreateAuthorityInfoFromAuthorityUri(t *testing.T) {
 		t.Errorf("Actual authority info %+v differs from expected authority info %+v", actualAuthorityURI, expectedAuthorityURI)
 	}
 }
+
+func TestCreateAuthorityInfoFromB2CAuthorityUri(t *testing.T) {
+	tests := []struct {
+		authorityURI string
+		expected     *AuthorityInfo
+	}{
+		{
+			authorityURI: "https://login.microsoftonline.com/tfp/contoso.onmicrosoft.com/B2C_1_SignIn",
+			expected: &AuthorityInfo{
+				Host:                  "login.microsoftonline.com",
+				CanonicalAuthorityURI: "https://login.microsoftonline.com/tfp/contoso.onmicrosoft.com/b2c_1_signin/",
+				AuthorityType:         B2C,
+				UserRealmURIPrefix:    "https://login.microsoftonline.com/common/userrealm/",
+				ValidateAuthority:     true,
+				Tenant:                "contoso.onmicrosoft.com",
+				Policy:                "b2c_1_signin",
+			},
+		},
+		{
+			authorityURI: "https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/",
+			expected: &AuthorityInfo{
+				Host:                  "contoso.b2clogin.com",
+				CanonicalAuthorityURI: "https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/",
+				AuthorityType:         B2C,
+				UserRealmURIPrefix:    "https://contoso.b2clogin.com/common/userrealm/",
+				ValidateAuthority:     true,
+				Tenant:                "contoso.onmicrosoft.com",
+				Policy:                "b2c_1_signin",
+			},
+		},
+	}
+	for _, test := range tests {
+		actual, err := CreateAuthorityInfoFromAuthorityURI(test.authorityURI, true)
+		if err != nil {
+			t.Errorf("Error should be nil for %s, but it is %v", test.authorityURI, err)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Actual authority info %+v differs from expected authority info %+v", actual, test.expected)
+		}
+	}
+	_, err := CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/tfp/contoso.onmicrosoft.com/", true)
+	if err == nil {
+		t.Error("Error should not be nil when the B2C authority has no policy")
+	}
+}
+
+func TestCreateAuthorityInfoFromCIAMAuthorityUri(t *testing.T) {
+	tests := []struct {
+		authorityURI string
+		expected     *AuthorityInfo
+	}{
+		{
+			authorityURI: "https://contoso.ciamlogin.com/",
+			expected: &AuthorityInfo{
+				Host:                  "contoso.ciamlogin.com",
+				CanonicalAuthorityURI: "https://contoso.ciamlogin.com/contoso.onmicrosoft.com/",
+				AuthorityType:         CIAM,
+				UserRealmURIPrefix:    "https://contoso.ciamlogin.com/common/userrealm/",
+				ValidateAuthority:     true,
+				Tenant:                "contoso.onmicrosoft.com",
+			},
+		},
+		{
+			authorityURI: "https://contoso.ciamlogin.com/aaaabbbb-0000-cccc-1111-dddd2222eeee",
+			expected: &AuthorityInfo{
+				Host:                  "contoso.ciamlogin.com",
+				CanonicalAuthorityURI: "https://contoso.ciamlogin.com/aaaabbbb-0000-cccc-1111-dddd2222eeee/",
+				AuthorityType:         CIAM,
+				UserRealmURIPrefix:    "https://contoso.ciamlogin.com/common/userrealm/",
+				ValidateAuthority:     true,
+				Tenant:                "aaaabbbb-0000-cccc-1111-dddd2222eeee",
+			},
+		},
+	}
+	for _, test := range tests {
+		actual, err := CreateAuthorityInfoFromAuthorityURI(test.authorityURI, true)
+		if err != nil {
+			t.Errorf("Error should be nil for %s, but it is %v", test.authorityURI, err)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Actual authority info %+v differs from expected authority info %+v", actual, test.expected)
+		}
+	}
+}
+
+func TestCreateAuthorityInfoFromADFSAuthorityUri(t *testing.T) {
+	expected := &AuthorityInfo{
+		Host:                  "fs.contoso.com",
+		CanonicalAuthorityURI: "https://fs.contoso.com/adfs/",
+		AuthorityType:         ADFS,
+		UserRealmURIPrefix:    "https://fs.contoso.com/common/userrealm/",
+		ValidateAuthority:     true,
+		Tenant:                "adfs",
+	}
+	actual, err := CreateAuthorityInfoFromAuthorityURI("https://fs.contoso.com/adfs", true)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual authority info %+v differs from expected authority info %+v", actual, expected)
+	}
+}
+
+func TestGetRegionalHost(t *testing.T) {
+	tests := map[string]string{
+		"login.microsoftonline.com": "eastus.login.microsoft.com",
+		"login.windows.net":         "eastus.login.microsoft.com",
+		"login.microsoftonline.us":  "eastus.login.microsoftonline.us",
+	}
+	for host, expected := range tests {
+		if actual := GetRegionalHost(host, "eastus"); actual != expected {
+			t.Errorf("Regional host of %s should be %s, instead it is %s", host, expected, actual)
+		}
+	}
+}
+
+func TestGetRegionalEndpoints(t *testing.T) {
+	endpoints := CreateAuthorityEndpoints("https://login.microsoftonline.com/tenant/oauth2/v2.0/authorize",
+		"https://login.microsoftonline.com/tenant/oauth2/v2.0/token", "https://login.microsoftonline.com/tenant/v2.0", "login.microsoftonline.com")
+	regional, err := endpoints.GetRegionalEndpoints("westus2")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	expectedTokenEndpoint := "https://westus2.login.microsoft.com/tenant/oauth2/v2.0/token"
+	if regional.TokenEndpoint != expectedTokenEndpoint {
+		t.Errorf("Token endpoint should be %s, instead it is %s", expectedTokenEndpoint, regional.TokenEndpoint)
+	}
+	if regional.AuthorizationEndpoint != endpoints.AuthorizationEndpoint || regional.GetIssuer() != endpoints.GetIssuer() {
+		t.Errorf("Only the token endpoint should be regional, instead the endpoints are %+v", regional)
+	}
+	if endpoints.TokenEndpoint != "https://login.microsoftonline.com/tenant/oauth2/v2.0/token" {
+		t.Errorf("The global endpoints shouldn't change, instead the token endpoint is %s", endpoints.TokenEndpoint)
+	}
+}
+
+func TestWithTenant(t *testing.T) {
+	tenant := "72f988bf-86f1-41af-91ab-2d7cd011db47"
+	for _, authorityURI := range []string{"https://login.microsoftonline.com/common/", "https://login.microsoftonline.com/organizations/"} {
+		authorityInfo, err := CreateAuthorityInfoFromAuthorityURI(authorityURI, true)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		tenantAuthority, err := authorityInfo.WithTenant(tenant)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		expectedURI := "https://login.microsoftonline.com/" + tenant + "/"
+		if tenantAuthority.CanonicalAuthorityURI != expectedURI || tenantAuthority.Tenant != tenant {
+			t.Errorf("Authority should be %s for tenant %s, instead it is %s for tenant %s", expectedURI, tenant, tenantAuthority.CanonicalAuthorityURI, tenantAuthority.Tenant)
+		}
+		if authorityInfo.CanonicalAuthorityURI != authorityURI {
+			t.Errorf("The original authority shouldn't change, instead it is %s", authorityInfo.CanonicalAuthorityURI)
+		}
+		if _, err := tenantAuthority.WithTenant(tenant); err != nil {
+			t.Errorf("Rewriting an authority to its own tenant should succeed, instead the error is %v", err)
+		}
+		if _, err := tenantAuthority.WithTenant("contoso.onmicrosoft.com"); err == nil {
+			t.Error("Error should not be nil for rewriting a tenant-specific authority to another tenant")
+		}
+	}
+	b2cAuthority, err := CreateAuthorityInfoFromAuthorityURI("https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if _, err := b2cAuthority.WithTenant(tenant); err == nil {
+		t.Error("Error should not be nil for a B2C authority")
+	}
+}
+
+func TestValidateKnownHost(t *testing.T) {
+	tests := []struct {
+		desc      string
+		info      AuthorityInfo
+		expectErr bool
+	}{
+		{"known host", AuthorityInfo{Host: "login.private.contoso.com", ValidateAuthority: true, InstanceDiscoveryDisabled: true, KnownAuthorityHosts: []string{"Login.Private.Contoso.com"}}, false},
+		{"unknown host", AuthorityInfo{Host: "login.unknown.contoso.com", ValidateAuthority: true, InstanceDiscoveryDisabled: true, KnownAuthorityHosts: []string{"login.private.contoso.com"}}, true},
+		{"no known hosts", AuthorityInfo{Host: "login.unknown.contoso.com", ValidateAuthority: true, InstanceDiscoveryDisabled: true}, false},
+		{"instance discovery", AuthorityInfo{Host: "login.unknown.contoso.com", ValidateAuthority: true, KnownAuthorityHosts: []string{"login.private.contoso.com"}}, false},
+		{"validation off", AuthorityInfo{Host: "login.unknown.contoso.com", InstanceDiscoveryDisabled: true, KnownAuthorityHosts: []string{"login.private.contoso.com"}}, false},
+	}
+	for _, test := range tests {
+		err := test.info.ValidateKnownHost()
+		if test.expectErr && err == nil {
+			t.Errorf("Error should not be nil for the %s", test.desc)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("Error should be nil for the %s, but it is %v", test.desc, err)
+		}
+	}
+}
+
+func TestAuthorityInfoStringLeavesOutHeaderValues(t *testing.T) {
+	authorityInfo, err := CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/tenant/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authorityInfo.InstanceDiscoveryHeaders = map[string]string{"Authorization": "Bearer gatewaySecret"}
+	authParams, _ := CreateAuthParametersInternal("clientID", authorityInfo)
+	for _, formatted := range []string{authorityInfo.String(), authParams.String(), fmt.Sprintf("%v", authParams)} {
+		if strings.Contains(formatted, "gatewaySecret") {
+			t.Errorf("The values of the instance discovery headers shouldn't be formatted, but they are in %s", formatted)
+		}
+		if !strings.Contains(formatted, "Authorization") || !strings.Contains(formatted, "login.microsoftonline.com") {
+			t.Errorf("The header names and the host should be formatted, instead the authority is %s", formatted)
+		}
+	}
+}
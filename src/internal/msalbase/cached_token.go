@@ -0,0 +1,46 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import "time"
+
+//CachedAccessToken describes an access token of the cache for diagnostics, Secret is empty when secrets are redacted
+type CachedAccessToken struct {
+	HomeAccountID     string
+	Environment       string
+	Realm             string
+	ClientID          string
+	Scopes            []string
+	TokenType         string
+	CachedAt          time.Time
+	ExpiresOn         time.Time
+	ExtendedExpiresOn time.Time
+	//PartitionKey is the cache partition of the token, it's empty for tokens acquired without one
+	PartitionKey string
+	//Valid tells whether the cache returns the token, i.e. it doesn't expire within the expiration buffer of the cache
+	Valid  bool
+	Secret string
+}
+
+//CachedRefreshToken describes a refresh token of the cache for diagnostics, Secret is empty when secrets are redacted
+type CachedRefreshToken struct {
+	HomeAccountID string
+	Environment   string
+	ClientID      string
+	//FamilyID is set for family refresh tokens, which every app of the family redeems
+	FamilyID string
+	//PartitionKey is the cache partition of the token, it's empty for tokens acquired without one
+	PartitionKey string
+	Secret       string
+}
+
+//CacheStats counts the entities of the cache and of its partitions, for health checks
+type CacheStats struct {
+	Accounts      int
+	AccessTokens  int
+	RefreshTokens int
+	//ApproximateSize estimates the bytes of the counted accounts and tokens from the lengths of their keys and secrets,
+	//the field names and the other attributes that serializing them adds aren't counted
+	ApproximateSize int
+}
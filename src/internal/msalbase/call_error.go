@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+//maxBodySnippetLength bounds how much of a response body that isn't JSON is put in an error message, error pages of proxies can be large
+const maxBodySnippetLength = 256
+
+// CallError is returned when the authority answers a request with an error, it holds the error payload of the response
+type CallError struct {
+	//Code is the OAuth error code, e.g. invalid_grant or interaction_required
+	Code          string
+	SubError      string
+	Description   string
+	ErrorCodes    []int
+	CorrelationID string
+	Claims        string
+	StatusCode    int
+	//Body is the raw body of the response, kept for diagnostics
+	Body string
+}
+
+func createCallError(httpStatusCode int, responseData string, payload *OAuthResponseBase) *CallError {
+	callErr := &CallError{StatusCode: httpStatusCode, Body: responseData}
+	if payload != nil {
+		callErr.Code = payload.Error
+		callErr.SubError = payload.SubError
+		callErr.Description = payload.ErrorDescription
+		callErr.ErrorCodes = payload.ErrorCodes
+		callErr.CorrelationID = payload.CorrelationID
+		callErr.Claims = payload.Claims
+	}
+	return callErr
+}
+
+func (e *CallError) Error() string {
+	if e.Code != "" {
+		return e.Code
+	}
+	// A body that isn't JSON likely comes from a proxy or gateway in front of the authority, its start tells which one
+	if e.Body != "" && !json.Valid([]byte(e.Body)) {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, getBodySnippet(e.Body))
+	}
+	return fmt.Sprintf("HTTP %d", e.StatusCode)
+}
+
+//getBodySnippet returns the start of the response body, at most maxBodySnippetLength bytes of it
+func getBodySnippet(body string) string {
+	if len(body) <= maxBodySnippetLength {
+		return body
+	}
+	snippet := []rune(body[:maxBodySnippetLength])
+	// The cut may have split a multi-byte character, its leftover bytes are decoded as a single invalid rune
+	if len(snippet) > 0 && snippet[len(snippet)-1] == utf8.RuneError {
+		snippet = snippet[:len(snippet)-1]
+	}
+	return string(snippet) + "..."
+}
+
+// IsRetryable checks if the error is transient, so the same request can succeed when it's sent again
+func (e *CallError) IsRetryable() bool {
+	switch e.Code {
+	case "temporarily_unavailable", "server_error":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || (e.StatusCode >= 500 && e.StatusCode <= 599)
+}
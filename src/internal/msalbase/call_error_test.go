@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const invalidGrantResponse = `{
+	"error": "invalid_grant",
+	"error_description": "AADSTS70008: The provided authorization code or refresh token has expired due to inactivity.\r\nTrace ID: 0e7a5d0c-2c4e-4cf4-9e37-6a1bd5d1d101\r\nCorrelation ID: 9b0e2a4d-5a8e-4f29-8c1f-2bdc8e0e7d3f\r\nTimestamp: 2020-06-01 10:00:00Z",
+	"error_codes": [70008],
+	"timestamp": "2020-06-01 10:00:00Z",
+	"trace_id": "0e7a5d0c-2c4e-4cf4-9e37-6a1bd5d1d101",
+	"correlation_id": "9b0e2a4d-5a8e-4f29-8c1f-2bdc8e0e7d3f",
+	"suberror": "bad_token"
+}`
+
+func TestCreateOAuthResponseBaseCallError(t *testing.T) {
+	_, err := CreateOAuthResponseBase(400, invalidGrantResponse)
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("Expected a CallError, but the error is %v", err)
+	}
+	expectedErr := &CallError{
+		Code:          "invalid_grant",
+		SubError:      "bad_token",
+		Description:   "AADSTS70008: The provided authorization code or refresh token has expired due to inactivity.\r\nTrace ID: 0e7a5d0c-2c4e-4cf4-9e37-6a1bd5d1d101\r\nCorrelation ID: 9b0e2a4d-5a8e-4f29-8c1f-2bdc8e0e7d3f\r\nTimestamp: 2020-06-01 10:00:00Z",
+		ErrorCodes:    []int{70008},
+		CorrelationID: "9b0e2a4d-5a8e-4f29-8c1f-2bdc8e0e7d3f",
+		StatusCode:    400,
+		Body:          invalidGrantResponse,
+	}
+	if !reflect.DeepEqual(callErr, expectedErr) {
+		t.Errorf("Actual error %+v differs from expected error %+v", callErr, expectedErr)
+	}
+	if callErr.IsRetryable() {
+		t.Error("invalid_grant shouldn't be retryable")
+	}
+}
+
+func TestCreateOAuthResponseBaseNonJSONError(t *testing.T) {
+	_, err := CreateOAuthResponseBase(502, "<html>Bad Gateway</html>")
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("Expected a CallError, but the error is %v", err)
+	}
+	if callErr.StatusCode != 502 || callErr.Body != "<html>Bad Gateway</html>" || callErr.Error() != "HTTP 502: <html>Bad Gateway</html>" {
+		t.Errorf("Actual error %+v doesn't keep the status and body of the response", callErr)
+	}
+	if !callErr.IsRetryable() {
+		t.Error("HTTP 502 should be retryable")
+	}
+}
+
+func TestCallErrorBodySnippetIsBounded(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Request blocked by the firewall. ", 100) + "</body></html>"
+	_, err := CreateOAuthResponseBase(403, page)
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("Expected a CallError, but the error is %v", err)
+	}
+	if callErr.Body != page {
+		t.Error("The whole body should be kept in the error")
+	}
+	message := callErr.Error()
+	if !strings.HasPrefix(message, "HTTP 403: <html><body>Request blocked by the firewall.") || !strings.HasSuffix(message, "...") {
+		t.Errorf("The error should have the status and the start of the body, instead it is %s", message)
+	}
+	if len(message) > maxBodySnippetLength+len("HTTP 403: ...") {
+		t.Errorf("The error should have a bounded snippet of the body, instead it is %d bytes long", len(message))
+	}
+}
+
+func TestCreateOAuthResponseBaseNonJSONSuccess(t *testing.T) {
+	_, err := CreateOAuthResponseBase(200, "<html>Sign in to the proxy</html>")
+	if err == nil {
+		t.Fatal("Error should be returned for a body that isn't JSON")
+	}
+	if !strings.Contains(err.Error(), "HTTP 200") || !strings.Contains(err.Error(), "<html>Sign in to the proxy</html>") {
+		t.Errorf("The error should have the status and the body, instead it is %v", err)
+	}
+}
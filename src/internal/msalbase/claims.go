@@ -0,0 +1,41 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//accessTokenClaimsKey and clientCapabilitiesClaimsKey are where client capabilities go in the claims request parameter,
+//{"access_token":{"xms_cc":{"values":["cp1"]}}}
+const accessTokenClaimsKey = "access_token"
+const clientCapabilitiesClaimsKey = "xms_cc"
+
+//MergeClientCapabilities adds the client capabilities to the claims request parameter, the claims of a challenge are kept as they are
+func MergeClientCapabilities(claims string, capabilities []string) (string, error) {
+	if len(capabilities) == 0 {
+		return claims, nil
+	}
+	merged := map[string]interface{}{}
+	if claims != "" {
+		if err := json.Unmarshal([]byte(claims), &merged); err != nil {
+			return "", fmt.Errorf("claims aren't a JSON object, the client capabilities can't be added to them: %w", err)
+		}
+	}
+	accessTokenClaims, ok := merged[accessTokenClaimsKey].(map[string]interface{})
+	if !ok {
+		if _, exists := merged[accessTokenClaimsKey]; exists {
+			return "", fmt.Errorf("the %s claims aren't a JSON object, the client capabilities can't be added to them", accessTokenClaimsKey)
+		}
+		accessTokenClaims = map[string]interface{}{}
+		merged[accessTokenClaimsKey] = accessTokenClaims
+	}
+	accessTokenClaims[clientCapabilitiesClaimsKey] = map[string]interface{}{"values": capabilities}
+	mergedClaims, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(mergedClaims), nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergeClientCapabilities(t *testing.T) {
+	tests := []struct {
+		desc     string
+		claims   string
+		expected string
+	}{
+		{"without a challenge", "", `{"access_token":{"xms_cc":{"values":["cp1"]}}}`},
+		{"with an access token challenge", `{"access_token":{"nbf":{"essential":true,"value":"1604106651"}}}`,
+			`{"access_token":{"nbf":{"essential":true,"value":"1604106651"},"xms_cc":{"values":["cp1"]}}}`},
+		{"with an ID token challenge", `{"id_token":{"auth_time":{"essential":true}}}`,
+			`{"access_token":{"xms_cc":{"values":["cp1"]}},"id_token":{"auth_time":{"essential":true}}}`},
+	}
+	for _, test := range tests {
+		merged, err := MergeClientCapabilities(test.claims, []string{"cp1"})
+		if err != nil {
+			t.Errorf("Error should be nil %s, but it is %v", test.desc, err)
+			continue
+		}
+		var actual, expected interface{}
+		json.Unmarshal([]byte(merged), &actual)
+		json.Unmarshal([]byte(test.expected), &expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Merged claims %s should be %s, instead they are %s", test.desc, test.expected, merged)
+		}
+	}
+}
+
+func TestMergeClientCapabilitiesWithoutCapabilities(t *testing.T) {
+	claims := `{"access_token":{"nbf":{"essential":true}}}`
+	if merged, err := MergeClientCapabilities(claims, nil); err != nil || merged != claims {
+		t.Errorf("The claims should be kept as they are without capabilities, instead they are %s with error %v", merged, err)
+	}
+}
+
+func TestMergeClientCapabilitiesInvalidClaims(t *testing.T) {
+	for _, claims := range []string{"not json", `{"access_token":"not an object"}`} {
+		if _, err := MergeClientCapabilities(claims, []string{"cp1"}); err == nil {
+			t.Errorf("Error should not be nil for the claims %s", claims)
+		}
+	}
+}
@@ -3,13 +3,19 @@
 
 package msalbase
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 //ClientAssertion holds the assertion parameters required for token acquisition flows needing a client assertion.
-//This can be either a JWT or certificate.
+//This can be either a JWT, a certificate or a callback returning the JWT.
 type ClientAssertion struct {
 	ClientAssertionJWT string
 	ClientCertificate  *ClientCertificate
+	//AssertionCallback is invoked for every token request, the JWT it returns isn't stored
+	AssertionCallback func(ctx context.Context) (string, error)
 }
 
 //CreateClientAssertionFromJWT creates a ClientAssertion instance from a JWT
@@ -30,8 +36,23 @@ func CreateClientAssertionFromCertificateObject(cert *ClientCertificate) *Client
 	return assertion
 }
 
-//GetJWT gets the assertion JWT from either the certificate or the JWT passed in
-func (assertion *ClientAssertion) GetJWT(authParams *AuthParametersInternal) (string, error) {
+//CreateClientAssertionFromCallback creates a ClientAssertion instance from a callback returning the assertion JWT
+func CreateClientAssertionFromCallback(callback func(ctx context.Context) (string, error)) *ClientAssertion {
+	return &ClientAssertion{AssertionCallback: callback}
+}
+
+//GetJWT gets the assertion JWT from either the callback, the certificate or the JWT passed in
+func (assertion *ClientAssertion) GetJWT(ctx context.Context, authParams *AuthParametersInternal) (string, error) {
+	if assertion.AssertionCallback != nil {
+		jwt, err := assertion.AssertionCallback(ctx)
+		if err != nil {
+			return "", fmt.Errorf("client assertion callback failed: %w", err)
+		}
+		if jwt == "" {
+			return "", errors.New("client assertion callback returned a blank assertion")
+		}
+		return jwt, nil
+	}
 	if assertion.ClientAssertionJWT == "" {
 		if assertion.ClientCertificate == nil {
 			return "", errors.New("no assertion or certificate found")
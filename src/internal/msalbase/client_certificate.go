@@ -4,26 +4,24 @@
 package msalbase
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/pem"
-	"strconv"
+	"errors"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
-	uuid "github.com/google/uuid"
 )
 
-var certHeader = map[string]interface{}{
-	"alg": "RS256",
-	"typ": "JWT",
-}
-
 // ClientCertificate consists of the parameters to create a assertion from certificate parameters, which include a thumbprint and private key
 type ClientCertificate struct {
 	thumbprint string
 	key        []byte
+	privateKey crypto.PrivateKey
 	expiresOn  int64
 }
 
@@ -36,45 +34,81 @@ func CreateClientCertificate(thumbprint string, key []byte) *ClientCertificate {
 	return cert
 }
 
+// CreateClientCertificateFromX509 creates a ClientCertificate instance from an X.509 certificate and its private key
+// The thumbprint is the SHA-1 hash of the DER encoded certificate
+func CreateClientCertificateFromX509(certificate *x509.Certificate, privateKey crypto.PrivateKey) (*ClientCertificate, error) {
+	if certificate == nil || privateKey == nil {
+		return nil, errors.New("certificate and private key can't be nil")
+	}
+	if _, ok := privateKey.(*rsa.PrivateKey); !ok {
+		return nil, errors.New("private key of the certificate must be an RSA key")
+	}
+	thumbprint := sha1.Sum(certificate.Raw)
+	cert := &ClientCertificate{
+		thumbprint: hex.EncodeToString(thumbprint[:]),
+		privateKey: privateKey,
+	}
+	return cert, nil
+}
+
 // IsExpired checks if the JWT created from the certificate is expired
 func (cert *ClientCertificate) IsExpired() bool {
-	return time.Now().UTC().Unix() < cert.expiresOn
+	return time.Now().UTC().Unix() >= cert.expiresOn
 }
 
 // BuildJWT builds a JWT assertion using the client certificate parameters
 // The parameters of the JWT are described in https://docs.microsoft.com/azure/active-directory/develop/active-directory-certificate-credentials
 func (cert *ClientCertificate) BuildJWT(authParams *AuthParametersInternal) (string, error) {
-	now := time.Now().UTC().Unix()
-	expiresOn := now + CertificateExpirationTime
-	cert.expiresOn = expiresOn
-	//The thumbprint is hex encoded, so we need to encode this to base64
+	//The thumbprint is hex encoded, so we need to decode it before it's base64 encoded in the header
 	hexDecodedThumbprint, err := hex.DecodeString(cert.thumbprint)
 	if err != nil {
 		return "", err
 	}
-	//Updating the headers of the JWT
-	certHeader["x5t"] = base64.StdEncoding.EncodeToString(hexDecodedThumbprint)
-	//Adding the claims to the JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
-		"aud": authParams.Endpoints.TokenEndpoint,
-		"exp": strconv.FormatInt(expiresOn, 10),
-		"iss": authParams.ClientID,
-		"jti": uuid.New().String(),
-		"nbf": strconv.FormatInt(now, 10),
-		"sub": authParams.ClientID,
-	})
-	token.Header = certHeader
-	//Decoding the byte array of the private key to a PEM formatted block
-	block, _ := pem.Decode(cert.key)
-	//Parses a private key that can be used to sign the claims from the PEM block
-	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	privateKey, err := cert.getPrivateKey()
 	if err != nil {
 		return "", err
 	}
-	//Signing the claims using the private key
-	tokenString, err := token.SignedString(privateKey)
+	now := time.Now().UTC()
+	tokenString, err := createClientAssertionJWT(hexDecodedThumbprint, privateKey, authParams.Endpoints.TokenEndpoint, authParams.ClientID, now)
 	if err != nil {
 		return "", err
 	}
+	cert.expiresOn = now.Unix() + CertificateExpirationTime
 	return tokenString, nil
 }
+
+func (cert *ClientCertificate) getPrivateKey() (crypto.PrivateKey, error) {
+	if cert.privateKey != nil {
+		return cert.privateKey, nil
+	}
+	//Decoding the byte array of the private key to a PEM formatted block
+	block, _ := pem.Decode(cert.key)
+	if block == nil {
+		return nil, errors.New("private key isn't PEM encoded")
+	}
+	//Parses a private key that can be used to sign the claims from the PEM block
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+// createClientAssertionJWT creates a client assertion signed by the certificate's private key, valid from now for CertificateExpirationTime seconds
+func createClientAssertionJWT(thumbprint []byte, privateKey crypto.PrivateKey, audience string, clientID string, now time.Time) (string, error) {
+	jti, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"aud": audience,
+		"exp": now.Unix() + CertificateExpirationTime,
+		"iss": clientID,
+		"jti": jti,
+		"nbf": now.Unix(),
+		"sub": clientID,
+	})
+	token.Header = map[string]interface{}{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint),
+	}
+	//Signing the claims using the private key
+	return token.SignedString(privateKey)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func createTestCertificate(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "msal-go-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	return cert, privateKey
+}
+
+func TestCreateClientAssertionJWT(t *testing.T) {
+	cert, privateKey := createTestCertificate(t)
+	thumbprint := sha1.Sum(cert.Raw)
+	now := time.Unix(1600000000, 0)
+	assertion, err := createClientAssertionJWT(thumbprint[:], privateKey, "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", "clientID", now)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(assertion, func(token *jwt.Token) (interface{}, error) {
+		return &privateKey.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatalf("Assertion should be signed by the certificate's key, instead parsing failed with %v", err)
+	}
+	expectedX5t := base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	if token.Header["x5t"] != expectedX5t {
+		t.Errorf("x5t header should be %v, instead it is %v", expectedX5t, token.Header["x5t"])
+	}
+	if token.Header["alg"] != "RS256" {
+		t.Errorf("alg header should be RS256, instead it is %v", token.Header["alg"])
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience("https://login.microsoftonline.com/tenant/oauth2/v2.0/token", true) {
+		t.Errorf("aud claim is %v", claims["aud"])
+	}
+	if !claims.VerifyIssuer("clientID", true) || claims["sub"] != "clientID" {
+		t.Errorf("iss and sub claims should be the client ID, instead they are %v and %v", claims["iss"], claims["sub"])
+	}
+	if claims["nbf"] != float64(now.Unix()) {
+		t.Errorf("nbf claim should be now, instead it is %v", claims["nbf"])
+	}
+	if claims["exp"] != float64(now.Unix()+CertificateExpirationTime) {
+		t.Errorf("exp claim should be %v seconds from now, instead it is %v", CertificateExpirationTime, claims["exp"])
+	}
+	if claims["jti"] == "" {
+		t.Error("jti claim should be set")
+	}
+}
+
+func TestBuildJWTFromX509(t *testing.T) {
+	cert, privateKey := createTestCertificate(t)
+	clientCert, err := CreateClientCertificateFromX509(cert, privateKey)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	if !clientCert.IsExpired() {
+		t.Error("Certificate without an assertion should be treated as expired")
+	}
+	authParams := &AuthParametersInternal{
+		ClientID:  "clientID",
+		Endpoints: &AuthorityEndpoints{TokenEndpoint: "https://login.microsoftonline.com/tenant/oauth2/v2.0/token"},
+	}
+	assertion, err := clientCert.BuildJWT(authParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	if clientCert.IsExpired() {
+		t.Error("Assertion that was just built shouldn't be expired")
+	}
+	_, err = jwt.Parse(assertion, func(token *jwt.Token) (interface{}, error) {
+		return &privateKey.PublicKey, nil
+	})
+	if err != nil {
+		t.Errorf("Assertion should be valid, instead parsing failed with %v", err)
+	}
+	_, err = CreateClientCertificateFromX509(cert, nil)
+	if err == nil {
+		t.Error("Error should not be nil when the private key is missing")
+	}
+}
@@ -3,7 +3,10 @@
 
 package msalbase
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
 
 //ClientCredentialType refers to the type of credential used for confidential client flows
 type ClientCredentialType int
@@ -59,6 +62,17 @@ func CreateClientCredentialFromAssertion(assertion string) (*ClientCredential, e
 	}, nil
 }
 
+//CreateClientCredentialFromAssertionCallback creates a ClientCredential instance from a callback returning an assertion JWT
+func CreateClientCredentialFromAssertionCallback(callback func(ctx context.Context) (string, error)) (*ClientCredential, error) {
+	if callback == nil {
+		return nil, errors.New("assertion callback can't be nil")
+	}
+	return &ClientCredential{
+		clientAssertion: CreateClientAssertionFromCallback(callback),
+		credentialType:  ClientCredentialAssertion,
+	}, nil
+}
+
 //GetCredentialType returns the type of the ClientCredential
 func (cred *ClientCredential) GetCredentialType() ClientCredentialType {
 	return cred.credentialType
@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+//CloudInstance consists of the hosts of an Azure AD cloud
+type CloudInstance struct {
+	//LoginHost is the default authority host of the cloud, it also serves instance discovery
+	LoginHost string
+	//GraphHost is the host of Microsoft Graph in the cloud
+	GraphHost string
+	//Aliases are the other authority hosts of the cloud
+	Aliases []string
+}
+
+//The public and national Azure AD clouds
+var (
+	CloudInstancePublic = &CloudInstance{
+		LoginHost: DefaultHost,
+		GraphHost: "graph.microsoft.com",
+		Aliases:   []string{"login.windows.net", RegionalHost, "sts.windows.net"},
+	}
+	CloudInstanceUSGovernment = &CloudInstance{
+		LoginHost: "login.microsoftonline.us",
+		GraphHost: "graph.microsoft.us",
+		Aliases:   []string{"login.usgovcloudapi.net"},
+	}
+	CloudInstanceChina = &CloudInstance{
+		LoginHost: "login.chinacloudapi.cn",
+		GraphHost: "microsoftgraph.chinacloudapi.cn",
+		Aliases:   []string{"login.partner.microsoftonline.cn"},
+	}
+	CloudInstanceGermany = &CloudInstance{LoginHost: "login.microsoftonline.de", GraphHost: "graph.microsoft.de"}
+)
+
+//getCloudInstanceForHost returns the cloud the authority host belongs to, it's nil for hosts outside of the Azure AD clouds, e.g. B2C or ADFS hosts
+func getCloudInstanceForHost(host string) *CloudInstance {
+	for _, cloud := range []*CloudInstance{CloudInstancePublic, CloudInstanceUSGovernment, CloudInstanceChina, CloudInstanceGermany} {
+		if host == cloud.LoginHost {
+			return cloud
+		}
+		for _, alias := range cloud.Aliases {
+			if host == alias {
+				return cloud
+			}
+		}
+	}
+	return nil
+}
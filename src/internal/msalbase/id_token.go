@@ -12,22 +12,25 @@ import (
 // IDToken consists of all the information used to validate a user
 // https://docs.microsoft.com/azure/active-directory/develop/id-tokens
 type IDToken struct {
-	PreferredUsername string `json:"preferred_username,omitempty"`
-	GivenName         string `json:"given_name,omitempty"`
-	FamilyName        string `json:"family_name,omitempty"`
-	MiddleName        string `json:"middle_name,omitempty"`
-	Name              string `json:"name,omitempty"`
-	Oid               string `json:"oid,omitempty"`
-	TenantID          string `json:"tid,omitempty"`
-	Subject           string `json:"sub,omitempty"`
-	UPN               string `json:"upn,omitempty"`
-	Email             string `json:"email,omitempty"`
-	AlternativeID     string `json:"alternative_id,omitempty"`
-	Issuer            string `json:"iss,omitempty"`
-	Audience          string `json:"aud,omitempty"`
-	ExpirationTime    int64  `json:"exp,omitempty"`
-	IssuedAt          int64  `json:"iat,omitempty"`
-	NotBefore         int64  `json:"nbf,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	GivenName         string   `json:"given_name,omitempty"`
+	FamilyName        string   `json:"family_name,omitempty"`
+	MiddleName        string   `json:"middle_name,omitempty"`
+	Name              string   `json:"name,omitempty"`
+	Oid               string   `json:"oid,omitempty"`
+	TenantID          string   `json:"tid,omitempty"`
+	Subject           string   `json:"sub,omitempty"`
+	UPN               string   `json:"upn,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	AlternativeID     string   `json:"alternative_id,omitempty"`
+	Issuer            string   `json:"iss,omitempty"`
+	Audience          string   `json:"aud,omitempty"`
+	ExpirationTime    int64    `json:"exp,omitempty"`
+	IssuedAt          int64    `json:"iat,omitempty"`
+	NotBefore         int64    `json:"nbf,omitempty"`
+	Nonce             string   `json:"nonce,omitempty"`
+	Roles             []string `json:"roles,omitempty"`
+	Groups            []string `json:"groups,omitempty"`
 	RawToken          string
 }
 
@@ -51,6 +54,31 @@ func CreateIDToken(jwt string) (*IDToken, error) {
 	return idToken, nil
 }
 
+// GetClaims decodes all the claims of the ID token, including custom claims that have no field in IDToken
+func (idToken *IDToken) GetClaims() (map[string]interface{}, error) {
+	jwtArr := strings.Split(idToken.RawToken, ".")
+	if len(jwtArr) < 2 {
+		return nil, errors.New("id token returned from server is invalid")
+	}
+	jwtDecoded, err := DecodeJWT(jwtArr[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(jwtDecoded, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ValidateNonce checks the nonce claim of the ID token matches the nonce sent in the authorization request
+func (idToken *IDToken) ValidateNonce(nonce string) error {
+	if idToken.Nonce != nonce {
+		return errors.New("id token nonce doesn't match the nonce of the authorization request")
+	}
+	return nil
+}
+
 // GetLocalAccountID extracts an account's local account ID from an ID token
 func (idToken *IDToken) GetLocalAccountID() string {
 	if idToken.Oid != "" {
@@ -22,3 +22,17 @@ func TestGetLocalAccountID(t *testing.T) {
 		t.Errorf("Expected local account ID oid differs from actual local account ID %s", actualLID)
 	}
 }
+
+func TestValidateNonce(t *testing.T) {
+	// {"aud":"clientID","nonce":"nonce123"}
+	idToken, err := CreateIDToken("eyJhbGciOiJub25lIn0.eyJhdWQiOiJjbGllbnRJRCIsIm5vbmNlIjoibm9uY2UxMjMifQ.")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if err := idToken.ValidateNonce("nonce123"); err != nil {
+		t.Errorf("Error should be nil for a matching nonce, but it is %v", err)
+	}
+	if err := idToken.ValidateNonce("otherNonce"); err == nil {
+		t.Error("Error should be returned for a mismatching nonce")
+	}
+}
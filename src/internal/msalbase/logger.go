@@ -0,0 +1,90 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+//Logger is the interface the library writes its log messages to
+type Logger interface {
+	Trace(args ...interface{})
+	Tracef(format string, args ...interface{})
+	Info(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warn(args ...interface{})
+	Warnf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Trace(args ...interface{})                 {}
+func (noopLogger) Tracef(format string, args ...interface{}) {}
+func (noopLogger) Info(args ...interface{})                  {}
+func (noopLogger) Infof(format string, args ...interface{})  {}
+func (noopLogger) Warn(args ...interface{})                  {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
+func (noopLogger) Error(args ...interface{})                 {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
+
+var (
+	loggerLock        sync.RWMutex
+	logger            Logger = noopLogger{}
+	piiLoggingEnabled bool
+)
+
+//SetLogger replaces the logger used by the library, passing nil turns logging off
+func SetLogger(l Logger) {
+	if l == nil {
+		l = noopLogger{}
+	}
+	loggerLock.Lock()
+	logger = l
+	loggerLock.Unlock()
+}
+
+//GetLogger returns the logger used by the library, by default it discards every message
+func GetLogger() Logger {
+	loggerLock.RLock()
+	defer loggerLock.RUnlock()
+	return logger
+}
+
+//SetPIILoggingEnabled sets whether account identifiers, usernames, scopes and tokens are written to the log as they are
+func SetPIILoggingEnabled(enabled bool) {
+	loggerLock.Lock()
+	piiLoggingEnabled = enabled
+	loggerLock.Unlock()
+}
+
+//PIILoggingEnabled returns whether PII is written to the log, by default it isn't
+func PIILoggingEnabled() bool {
+	loggerLock.RLock()
+	defer loggerLock.RUnlock()
+	return piiLoggingEnabled
+}
+
+//PII returns the value to log for a piece of PII
+//Unless PII logging is enabled, this is a placeholder derived from a hash of the value,
+//so the same value can still be followed through the log without being revealed
+func PII(value string) string {
+	if value == "" || PIILoggingEnabled() {
+		return value
+	}
+	hash := sha256.Sum256([]byte(value))
+	return "pii-" + hex.EncodeToString(hash[:4])
+}
+
+//PIIList returns the values to log for a list of PII
+func PIIList(values []string) []string {
+	redacted := make([]string, len(values))
+	for i, value := range values {
+		redacted[i] = PII(value)
+	}
+	return redacted
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type fakeLogger struct {
+	noopLogger
+	lines []string
+}
+
+func (l *fakeLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSetLogger(t *testing.T) {
+	if _, ok := GetLogger().(noopLogger); !ok {
+		t.Errorf("Default logger should discard messages, instead it is %T", GetLogger())
+	}
+	fake := &fakeLogger{}
+	SetLogger(fake)
+	defer SetLogger(nil)
+	GetLogger().Infof("message %d", 1)
+	if len(fake.lines) != 1 || fake.lines[0] != "message 1" {
+		t.Errorf("Logged lines should be [message 1], instead they are %v", fake.lines)
+	}
+	SetLogger(nil)
+	if _, ok := GetLogger().(noopLogger); !ok {
+		t.Errorf("Setting a nil logger should restore the no-op logger, instead it is %T", GetLogger())
+	}
+}
+
+func TestPII(t *testing.T) {
+	redacted := PII("user@contoso.com")
+	if redacted == "user@contoso.com" || !strings.HasPrefix(redacted, "pii-") {
+		t.Errorf("PII should be redacted by default, instead it is %v", redacted)
+	}
+	if PII("user@contoso.com") != redacted {
+		t.Errorf("Redacted PII should be stable")
+	}
+	SetPIILoggingEnabled(true)
+	defer SetPIILoggingEnabled(false)
+	if PII("user@contoso.com") != "user@contoso.com" {
+		t.Errorf("PII should be logged as is when PII logging is enabled")
+	}
+}
+
+func TestAuthParametersInternalString(t *testing.T) {
+	authParams := &AuthParametersInternal{
+		HomeaccountID: "uid.utid",
+		Username:      "user@contoso.com",
+		Password:      "secretPassword",
+		Scopes:        []string{"user.read"},
+	}
+	formatted := fmt.Sprintf("%v", authParams)
+	for _, pii := range []string{"uid.utid", "user@contoso.com", "secretPassword", "user.read"} {
+		if strings.Contains(formatted, pii) {
+			t.Errorf("Formatted parameters %v shouldn't contain %v", formatted, pii)
+		}
+	}
+	SetPIILoggingEnabled(true)
+	defer SetPIILoggingEnabled(false)
+	formatted = fmt.Sprintf("%v", authParams)
+	if !strings.Contains(formatted, "user@contoso.com") || strings.Contains(formatted, "secretPassword") {
+		t.Errorf("Formatted parameters %v should contain the username but never the password", formatted)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ManagedIdentityIDType tells how the managed identity tokens are requested for is identified
+type ManagedIdentityIDType int
+
+//These are the values for ManagedIdentityIDType
+const (
+	ManagedIdentitySystemAssigned ManagedIdentityIDType = iota
+	ManagedIdentityClientID
+	ManagedIdentityResourceID
+	ManagedIdentityObjectID
+)
+
+//ManagedIdentity is the identity of the Azure resource tokens are requested for, the ID is empty for the system-assigned identity
+type ManagedIdentity struct {
+	IDType ManagedIdentityIDType
+	ID     string
+}
+
+//CreateSystemAssignedManagedIdentity creates a ManagedIdentity instance for the identity of the Azure resource itself
+func CreateSystemAssignedManagedIdentity() *ManagedIdentity {
+	return &ManagedIdentity{IDType: ManagedIdentitySystemAssigned}
+}
+
+//CreateUserAssignedManagedIdentity creates a ManagedIdentity instance for a user-assigned identity, identified by its client, resource or object ID
+func CreateUserAssignedManagedIdentity(idType ManagedIdentityIDType, id string) (*ManagedIdentity, error) {
+	if idType == ManagedIdentitySystemAssigned {
+		return nil, errors.New("a user-assigned managed identity needs a client, resource or object ID")
+	}
+	if id == "" {
+		return nil, errors.New("managed identity ID can't be blank")
+	}
+	return &ManagedIdentity{IDType: idType, ID: id}, nil
+}
+
+//GetCacheClientID returns the client ID the tokens of the identity are cached under
+func (identity *ManagedIdentity) GetCacheClientID() string {
+	if identity.IDType == ManagedIdentitySystemAssigned {
+		return SystemAssignedManagedIdentityID
+	}
+	return identity.ID
+}
+
+//CreateManagedIdentityAuthorityInfo creates the AuthorityInfo managed identity tokens are cached with.
+//Managed identity endpoints aren't authorities, so no instance discovery is done for it.
+func CreateManagedIdentityAuthorityInfo() *AuthorityInfo {
+	return &AuthorityInfo{Host: DefaultHost, Tenant: ManagedIdentityTenant, AuthorityType: ManagedIdentityAuthority}
+}
+
+type managedIdentityJSONPayload struct {
+	AccessToken string `json:"access_token"`
+	//ExpiresIn and ExpiresOn are sent as strings by IMDS and as numbers by some other endpoints
+	ExpiresIn json.RawMessage `json:"expires_in"`
+	ExpiresOn json.RawMessage `json:"expires_on"`
+	Resource  string          `json:"resource"`
+	TokenType string          `json:"token_type"`
+}
+
+//parseManagedIdentityInt parses a number that may be quoted, it returns false if the field is missing
+func parseManagedIdentityInt(raw json.RawMessage) (int64, bool) {
+	value, err := strconv.ParseInt(strings.Trim(string(raw), `"`), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+//CreateManagedIdentityTokenResponse creates a TokenResponse instance from the response of a managed identity endpoint.
+//Managed identity tokens can't be refreshed and have no ID token, the token is granted for the requested resource.
+func CreateManagedIdentityTokenResponse(authParameters *AuthParametersInternal, responseCode int, responseData string) (*TokenResponse, error) {
+	baseResponse, err := CreateOAuthResponseBase(responseCode, responseData)
+	if err != nil {
+		if callErr, ok := err.(*CallError); ok && callErr.CorrelationID == "" {
+			callErr.CorrelationID = authParameters.CorrelationID
+		}
+		return nil, err
+	}
+	if responseCode != 200 {
+		return nil, createCallError(responseCode, responseData, nil)
+	}
+	payload := &managedIdentityJSONPayload{}
+	if err := json.Unmarshal([]byte(responseData), payload); err != nil {
+		return nil, err
+	}
+	if payload.AccessToken == "" {
+		return nil, errors.New("response is missing access_token")
+	}
+	var expiresOn time.Time
+	if timestamp, ok := parseManagedIdentityInt(payload.ExpiresOn); ok {
+		expiresOn = time.Unix(timestamp, 0)
+	} else if expiresIn, ok := parseManagedIdentityInt(payload.ExpiresIn); ok {
+		expiresOn = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	} else {
+		return nil, errors.New("response is missing expires_on and expires_in")
+	}
+	tokenType := payload.TokenType
+	if tokenType == "" {
+		tokenType = BearerTokenType
+	}
+	return &TokenResponse{
+		baseResponse:   baseResponse,
+		AccessToken:    payload.AccessToken,
+		ExpiresOn:      expiresOn,
+		ExtExpiresOn:   expiresOn,
+		GrantedScopes:  authParameters.Scopes,
+		declinedScopes: []string{},
+		ClientInfo:     &ClientInfoJSONPayload{},
+		TokenType:      tokenType,
+	}, nil
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"sync"
+	"time"
+)
+
+//Metrics is the interface the library reports cache lookups and token requests to
+type Metrics interface {
+	OnCacheHit()
+	OnCacheMiss()
+	OnTokenRequest(duration time.Duration, success bool)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) OnCacheHit()                                         {}
+func (noopMetrics) OnCacheMiss()                                        {}
+func (noopMetrics) OnTokenRequest(duration time.Duration, success bool) {}
+
+var (
+	metricsLock sync.RWMutex
+	metrics     Metrics = noopMetrics{}
+)
+
+//SetMetrics replaces the metrics the library reports to, passing nil turns the reporting off
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metricsLock.Lock()
+	metrics = m
+	metricsLock.Unlock()
+}
+
+//GetMetrics returns the metrics the library reports to, by default nothing is recorded
+func GetMetrics() Metrics {
+	metricsLock.RLock()
+	defer metricsLock.RUnlock()
+	return metrics
+}
@@ -5,7 +5,7 @@ package msalbase
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 )
 
 //OAuthResponseBase stores common information when sending a request to get a token
@@ -26,18 +26,21 @@ var httpFailureCodes = map[int]string{
 //CreateOAuthResponseBase creates a OAuthResponseBase instance from the HTTP client's response
 func CreateOAuthResponseBase(httpStatusCode int, responseData string) (*OAuthResponseBase, error) {
 	// if the status code corresponds to an error, throw the error
-	if failMessage, ok := httpFailureCodes[httpStatusCode]; ok {
-		return nil, errors.New(failMessage)
+	if _, ok := httpFailureCodes[httpStatusCode]; ok {
+		return nil, createCallError(httpStatusCode, responseData, nil)
 	}
 
 	payload := &OAuthResponseBase{}
 	err := json.Unmarshal([]byte(responseData), payload)
 	if err != nil {
-		return nil, err
+		if httpStatusCode >= 400 {
+			return nil, createCallError(httpStatusCode, responseData, nil)
+		}
+		return nil, fmt.Errorf("HTTP %d response isn't valid JSON: %s: %w", httpStatusCode, getBodySnippet(responseData), err)
 	}
 	//If the response consists of an error, throw that error
 	if payload.Error != "" {
-		return nil, errors.New(payload.Error)
+		return nil, createCallError(httpStatusCode, responseData, payload)
 	}
 	return payload, nil
 }
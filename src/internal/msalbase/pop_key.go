@@ -0,0 +1,104 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+//PoPTokenType is the token type of access tokens bound to a proof-of-possession key
+const PoPTokenType = "pop"
+
+//popKeySize is the size of the ephemeral RSA keys, it's the size AAD uses for its own signing keys
+const popKeySize = 2048
+
+//PoPKey is the key pair a proof-of-possession access token is bound to
+//Every request the token is sent with has to be signed with the private key, so an intercepted token can't be replayed
+type PoPKey struct {
+	privateKey *rsa.PrivateKey
+	keyID      string
+}
+
+//CreatePoPKey generates an ephemeral key pair, it only lives in memory
+func CreatePoPKey() (*PoPKey, error) {
+	privateKey, err := rsa.GenerateKey(GetRandomSource(), popKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return createPoPKeyFromRSAKey(privateKey), nil
+}
+
+func createPoPKeyFromRSAKey(privateKey *rsa.PrivateKey) *PoPKey {
+	key := &PoPKey{privateKey: privateKey}
+	key.keyID = key.getThumbprint()
+	return key
+}
+
+//getJWK returns the public key as a JSON web key
+func (key *PoPKey) getJWK() map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.privateKey.E)).Bytes()),
+		"n":   base64.RawURLEncoding.EncodeToString(key.privateKey.N.Bytes()),
+	}
+}
+
+//getThumbprint returns the RFC 7638 thumbprint of the public key, the hash of its required members in lexicographic order
+func (key *PoPKey) getThumbprint() string {
+	jwk := key.getJWK()
+	canonical := `{"e":"` + jwk["e"] + `","kty":"RSA","n":"` + jwk["n"] + `"}`
+	hash := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+//GetKeyID returns the ID of the key, which is the thumbprint of its public key
+func (key *PoPKey) GetKeyID() string {
+	return key.keyID
+}
+
+//GetReqCnf returns the req_cnf parameter of the token request, it tells the authority which key to bind the token to
+func (key *PoPKey) GetReqCnf() (string, error) {
+	reqCnf, err := json.Marshal(map[string]string{"kid": key.keyID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(reqCnf), nil
+}
+
+//CreateAuthorizationHeader signs the HTTP request with the key and returns the value of its Authorization header
+//The signed HTTP request carries the access token, so the resource checks both the token and the key it's bound to
+func (key *PoPKey) CreateAuthorizationHeader(accessToken string, method string, uri string, timestamp time.Time) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := NewUUID()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"at":    accessToken,
+		"ts":    timestamp.Unix(),
+		"m":     method,
+		"u":     u.Host,
+		"p":     u.EscapedPath(),
+		"nonce": nonce,
+		"cnf":   map[string]interface{}{"jwk": key.getJWK()},
+	})
+	token.Header["typ"] = PoPTokenType
+	token.Header["kid"] = key.keyID
+	signedHTTPRequest, err := token.SignedString(key.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return "PoP " + signedHTTPRequest, nil
+}
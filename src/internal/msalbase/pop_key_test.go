@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func createTestPoPKey(t *testing.T) (*PoPKey, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	return createPoPKeyFromRSAKey(privateKey), privateKey
+}
+
+func TestPoPKeyReqCnf(t *testing.T) {
+	key, privateKey := createTestPoPKey(t)
+	// Marshaling a map sorts its keys, which gives the canonical JWK of RFC 7638
+	canonical, _ := json.Marshal(map[string]string{
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	})
+	hash := sha256.Sum256(canonical)
+	expectedThumbprint := base64.RawURLEncoding.EncodeToString(hash[:])
+	if key.GetKeyID() != expectedThumbprint {
+		t.Errorf("Key ID should be the JWK thumbprint %v, instead it is %v", expectedThumbprint, key.GetKeyID())
+	}
+	reqCnf, err := key.GetReqCnf()
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(reqCnf)
+	if err != nil {
+		t.Fatalf("req_cnf should be base64url encoded, instead decoding failed with %v", err)
+	}
+	cnf := map[string]string{}
+	if err := json.Unmarshal(decoded, &cnf); err != nil || cnf["kid"] != expectedThumbprint {
+		t.Errorf("req_cnf should name the key by its thumbprint, instead it is %s", decoded)
+	}
+}
+
+func TestPoPKeyCreateAuthorizationHeader(t *testing.T) {
+	key, privateKey := createTestPoPKey(t)
+	now := time.Unix(1600000000, 0)
+	header, err := key.CreateAuthorizationHeader("accessToken", "GET", "https://graph.microsoft.com/v1.0/me?$select=id", now)
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	if !strings.HasPrefix(header, "PoP ") {
+		t.Fatalf("Authorization header should use the PoP scheme, instead it is %v", header)
+	}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(strings.TrimPrefix(header, "PoP "), func(token *jwt.Token) (interface{}, error) {
+		return &privateKey.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatalf("Signed HTTP request should be signed by the key, instead parsing failed with %v", err)
+	}
+	if token.Header["alg"] != "RS256" || token.Header["typ"] != "pop" || token.Header["kid"] != key.GetKeyID() {
+		t.Errorf("Header should have alg RS256, typ pop and the key ID, instead it is %v", token.Header)
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["at"] != "accessToken" {
+		t.Errorf("at claim should be the access token, instead it is %v", claims["at"])
+	}
+	if claims["ts"] != float64(now.Unix()) {
+		t.Errorf("ts claim should be the timestamp, instead it is %v", claims["ts"])
+	}
+	if claims["m"] != "GET" || claims["u"] != "graph.microsoft.com" || claims["p"] != "/v1.0/me" {
+		t.Errorf("m, u and p claims should describe the request, instead they are %v, %v and %v", claims["m"], claims["u"], claims["p"])
+	}
+	if claims["nonce"] == "" {
+		t.Error("nonce claim should be set")
+	}
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	jwk, _ := cnf["jwk"].(map[string]interface{})
+	if jwk["kty"] != "RSA" || jwk["n"] != base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()) {
+		t.Errorf("cnf claim should hold the public key, instead it is %v", claims["cnf"])
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	randomLock   sync.Mutex
+	randomSource io.Reader = rand.Reader
+)
+
+//lockedRandomReader reads from the random source one caller at a time, the sources tests set like *math/rand.Rand aren't safe for concurrent use
+type lockedRandomReader struct{}
+
+func (lockedRandomReader) Read(b []byte) (int, error) {
+	randomLock.Lock()
+	defer randomLock.Unlock()
+	return randomSource.Read(b)
+}
+
+//SetRandomSource replaces the reader the library gets random bytes from, passing nil restores crypto/rand
+//It's the source of PKCE code verifiers, the jti of client assertions, the nonce of PoP headers and authorization code URLs, PoP keys and correlation IDs
+func SetRandomSource(reader io.Reader) {
+	if reader == nil {
+		reader = rand.Reader
+	}
+	randomLock.Lock()
+	randomSource = reader
+	randomLock.Unlock()
+}
+
+//GetRandomSource returns a reader of the random source, by default crypto/rand, the reads from it are serialized
+func GetRandomSource() io.Reader {
+	return lockedRandomReader{}
+}
+
+//ReadRandom fills the bytes from the random source, it fails if the source is exhausted
+func ReadRandom(b []byte) error {
+	_, err := io.ReadFull(GetRandomSource(), b)
+	return err
+}
+
+//NewUUID creates a version 4 UUID from the random source, as described in https://tools.ietf.org/html/rfc4122#section-4.4
+func NewUUID() (string, error) {
+	var id uuid.UUID
+	if err := ReadRandom(id[:]); err != nil {
+		return "", err
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return id.String(), nil
+}
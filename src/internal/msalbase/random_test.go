@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"bytes"
+	"crypto/sha1"
+	mathrand "math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestNewUUIDWithRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	SetRandomSource(bytes.NewReader(bytes.Repeat([]byte{0xff}, 16)))
+	id, err := NewUUID()
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	// The version and variant bits are set whatever the source returns
+	if id != "ffffffff-ffff-4fff-bfff-ffffffffffff" {
+		t.Errorf("UUID should be ffffffff-ffff-4fff-bfff-ffffffffffff, instead it is %s", id)
+	}
+	if _, err := NewUUID(); err == nil {
+		t.Error("An exhausted source should be an error")
+	}
+	SetRandomSource(nil)
+	id, err = NewUUID()
+	if err != nil {
+		t.Fatalf("Error should be nil, instead it is %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("UUID %s should be a version 4 UUID", id)
+	}
+}
+
+func TestClientAssertionJTIWithRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	cert, privateKey := createTestCertificate(t)
+	thumbprint := sha1.Sum(cert.Raw)
+	var jtis []interface{}
+	for i := 0; i < 2; i++ {
+		SetRandomSource(mathrand.New(mathrand.NewSource(42)))
+		assertion, err := createClientAssertionJWT(thumbprint[:], privateKey, "https://login.microsoftonline.com/tenant/oauth2/v2.0/token", "clientID", time.Now())
+		if err != nil {
+			t.Fatalf("Error should be nil, instead it is %v", err)
+		}
+		token, _, err := new(jwt.Parser).ParseUnverified(assertion, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("Error should be nil, instead it is %v", err)
+		}
+		jtis = append(jtis, token.Claims.(jwt.MapClaims)["jti"])
+	}
+	if jtis[0] != jtis[1] {
+		t.Errorf("The same seed should give the same jti, instead they are %v and %v", jtis[0], jtis[1])
+	}
+}
+
+func TestPoPNonceWithRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	key, _ := createTestPoPKey(t)
+	var nonces []interface{}
+	for i := 0; i < 2; i++ {
+		SetRandomSource(mathrand.New(mathrand.NewSource(42)))
+		header, err := key.CreateAuthorizationHeader("accessToken", "GET", "https://graph.microsoft.com/v1.0/me", time.Now())
+		if err != nil {
+			t.Fatalf("Error should be nil, instead it is %v", err)
+		}
+		token, _, err := new(jwt.Parser).ParseUnverified(strings.TrimPrefix(header, "PoP "), jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("Error should be nil, instead it is %v", err)
+		}
+		nonces = append(nonces, token.Claims.(jwt.MapClaims)["nonce"])
+	}
+	if nonces[0] != nonces[1] {
+		t.Errorf("The same seed should give the same nonce, instead they are %v and %v", nonces[0], nonces[1])
+	}
+}
+
+func TestCreateAuthParametersInternalWithExhaustedRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	SetRandomSource(bytes.NewReader(nil))
+	if _, err := CreateAuthParametersInternal("clientID", nil); err == nil {
+		t.Error("An exhausted source should be an error instead of a panic")
+	}
+}
+
+func TestConcurrentReadsFromRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	// *math/rand.Rand isn't safe for concurrent use, so this fails under -race unless the reads are serialized
+	SetRandomSource(mathrand.New(mathrand.NewSource(42)))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewUUID(); err != nil {
+				t.Errorf("Error should be nil, instead it is %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
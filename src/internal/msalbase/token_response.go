@@ -7,10 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
-
-	log "github.com/sirupsen/logrus"
 )
 
 type tokenResponseJSONPayload struct {
@@ -22,6 +19,8 @@ type tokenResponseJSONPayload struct {
 	Scope        string `json:"scope"`
 	IDToken      string `json:"id_token"`
 	ClientInfo   string `json:"client_info"`
+	TokenType    string `json:"token_type"`
+	SpaCode      string `json:"spa_code"`
 }
 
 //ClientInfoJSONPayload is used to create a Home Account ID for an account
@@ -43,6 +42,10 @@ type TokenResponse struct {
 	ExtExpiresOn   time.Time
 	rawClientInfo  string
 	ClientInfo     *ClientInfoJSONPayload
+	//TokenType is the type of the access token, e.g. Bearer or pop
+	TokenType string
+	//SpaCode is the authorization code a single-page app redeems itself, it's only returned when the app asked for one
+	SpaCode string
 }
 
 //HasAccessToken checks if the TokenResponse has an access token secret
@@ -67,6 +70,10 @@ func (tr *TokenResponse) GetHomeAccountIDFromClientInfo() string {
 func CreateTokenResponse(authParameters *AuthParametersInternal, responseCode int, responseData string) (*TokenResponse, error) {
 	baseResponse, err := CreateOAuthResponseBase(responseCode, responseData)
 	if err != nil {
+		// The authority doesn't always echo the correlation ID, the one the request was sent with is reported instead
+		if callErr, ok := err.(*CallError); ok && callErr.CorrelationID == "" {
+			callErr.CorrelationID = authParameters.CorrelationID
+		}
 		return nil, err
 	}
 	payload := &tokenResponseJSONPayload{}
@@ -108,14 +115,19 @@ func CreateTokenResponse(authParameters *AuthParametersInternal, responseCode in
 		// Link to spec: https://tools.ietf.org/html/rfc6749#section-3.3
 		grantedScopes = authParameters.Scopes
 	} else {
-		grantedScopes = SplitScopes(strings.ToLower(payload.Scope))
+		grantedScopes = NormalizeScopes(SplitScopes(payload.Scope))
 		declinedScopes = findDeclinedScopes(authParameters.Scopes, grantedScopes)
 	}
 
+	tokenType := payload.TokenType
+	if tokenType == "" {
+		tokenType = BearerTokenType
+	}
+
 	idToken, err := CreateIDToken(payload.IDToken)
 	if err != nil {
 		//ID tokens aren't always returned, so the error is just logged
-		log.Errorf("ID Token error: %v", err)
+		GetLogger().Errorf("ID Token error: %v", err)
 	}
 
 	tokenResponse := &TokenResponse{
@@ -130,6 +142,8 @@ func CreateTokenResponse(authParameters *AuthParametersInternal, responseCode in
 		declinedScopes: declinedScopes,
 		rawClientInfo:  rawClientInfo,
 		ClientInfo:     clientInfo,
+		TokenType:      tokenType,
+		SpaCode:        payload.SpaCode,
 	}
 	return tokenResponse, nil
 }
@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalbase
+
+import (
+	"context"
+	"sync"
+)
+
+// Names of the spans the library starts
+const (
+	AcquireTokenSilentSpan = "msal.AcquireTokenSilent"
+	InstanceDiscoverySpan  = "msal.InstanceDiscovery"
+	TokenRequestSpan       = "msal.TokenRequest"
+)
+
+// Attributes the library sets on its spans, none of them holds PII
+const (
+	AuthorityAttribute = "msal.authority"
+	ClientIDAttribute  = "msal.client_id"
+	CacheHitAttribute  = "msal.cache_hit"
+)
+
+// Tracer is the interface the library starts its spans with, the context it returns carries the span to the spans started within it
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation of the library, it's ended with the error the operation failed with or nil
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End(err error)                              {}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     Tracer = noopTracer{}
+)
+
+// SetTracer replaces the tracer the library starts its spans with, passing nil turns tracing off
+func SetTracer(t Tracer) {
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracerLock.Lock()
+	tracer = t
+	tracerLock.Unlock()
+}
+
+// GetTracer returns the tracer the library starts its spans with, by default no spans are recorded
+func GetTracer() Tracer {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+	return tracer
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts a span with the tracer, the returned context carries it so SpanFromContext finds it deeper in the operation
+func StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := GetTracer().StartSpan(ctx, name)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// SpanFromContext returns the span of the operation the context belongs to, a span that records nothing if there isn't one
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanContextKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
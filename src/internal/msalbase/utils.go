@@ -5,6 +5,7 @@ package msalbase
 
 import (
 	"encoding/base64"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,11 +20,64 @@ func ConvertStrUnixToUTCTime(unixTime string) (time.Time, error) {
 	return time.Unix(timeInt, 0).UTC(), nil
 }
 
+//defaultScopeSuffix makes a scope of all the permissions of the resource that the app was granted, it's how v1 resources are requested from the v2 endpoint
+const defaultScopeSuffix = "/.default"
+
+//GraphResource and ARMResource are the v1 resource IDs of Microsoft Graph and Azure Resource Manager
+const (
+	GraphResource = "https://graph.microsoft.com"
+	ARMResource   = "https://management.azure.com"
+)
+
+//GraphDefaultScope and ARMDefaultScope are the .default scopes of the resources, they're the scopes ResourceToScope returns for them
+const (
+	GraphDefaultScope = GraphResource + defaultScopeSuffix
+	ARMDefaultScope   = ARMResource + defaultScopeSuffix
+)
+
+//ResourceToScope returns the .default scope of a v1 resource, e.g. https://graph.microsoft.com/.default for https://graph.microsoft.com
+//The resource is kept as it is, the trailing slash of a resource like https://management.core.windows.net/ is part of its ID
+func ResourceToScope(resource string) string {
+	if strings.HasSuffix(resource, defaultScopeSuffix) {
+		return resource
+	}
+	return resource + defaultScopeSuffix
+}
+
 //ConcatenateScopes combines all scopes into one space-separated string
 func ConcatenateScopes(scopes []string) string {
 	return strings.Join(scopes, DefaultScopeSeparator)
 }
 
+//CanonicalScopes combines the normalized scopes into one space-separated string in sorted order, for keys that have to be the same whatever order the scopes come in.
+//Strings that are sent to the authority or shown to users keep the order of the scopes, so they use ConcatenateScopes instead.
+func CanonicalScopes(scopes []string) string {
+	// NormalizeScopes returns a new slice, so the scopes of the caller aren't reordered
+	canonical := NormalizeScopes(scopes)
+	sort.Strings(canonical)
+	return ConcatenateScopes(canonical)
+}
+
+//NormalizeScopes returns the canonical form of the scopes, which are compared without case, so the same request always has the same cache key.
+//Scopes are trimmed and lowercased, blank and duplicate scopes are dropped and the order of the first occurrences is kept.
+//The reserved openid, profile and offline_access scopes are kept too, the authority treats them like any other scope of the request.
+func NormalizeScopes(scopes []string) []string {
+	if len(scopes) == 0 {
+		return scopes
+	}
+	normalized := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+	return normalized
+}
+
 //SplitScopes splits a space-separated string of scopes to a list
 func SplitScopes(scopes string) []string {
 	return strings.Split(scopes, DefaultScopeSeparator)
@@ -54,6 +108,8 @@ func GetStringFromPointer(pointer *string) string {
 //DecodeJWT decodes a JWT and converts it to a byte array representing a JSON object
 //Adapted from MSAL Python and https://stackoverflow.com/a/31971780
 func DecodeJWT(data string) ([]byte, error) {
+	// JWT segments are base64url encoded, convert them to standard base64
+	data = strings.NewReplacer("-", "+", "_", "/").Replace(data)
 	if i := len(data) % 4; i != 0 {
 		data += strings.Repeat("=", 4-i)
 	}
@@ -16,6 +16,24 @@ func TestConcatenateScopes(t *testing.T) {
 	}
 }
 
+func TestCanonicalScopes(t *testing.T) {
+	expectedScopes := "openid profile user.read"
+	for _, scopes := range [][]string{
+		{"profile", "openid", "user.read"},
+		{"user.read", "profile", "openid"},
+		{"OpenID", "user.read", "profile", "openid"},
+	} {
+		if actualScopes := CanonicalScopes(scopes); actualScopes != expectedScopes {
+			t.Errorf("Canonical scopes of %v should be %s, instead they are %s", scopes, expectedScopes, actualScopes)
+		}
+	}
+	scopes := []string{"user.read", "openid"}
+	CanonicalScopes(scopes)
+	if !reflect.DeepEqual(scopes, []string{"user.read", "openid"}) {
+		t.Errorf("The scopes passed in shouldn't be reordered, instead they are %v", scopes)
+	}
+}
+
 func TestSplitScopes(t *testing.T) {
 	expectedScopes := []string{"profile", "openid", "user.read"}
 	actualScopes := SplitScopes("profile openid user.read")
@@ -35,3 +53,55 @@ func TestDecodeJWT(t *testing.T) {
 		t.Errorf("Actual decoded string %s differs from expected decoded string %s", actualString, expectedStr)
 	}
 }
+
+func TestDecodeJWTURLEncoding(t *testing.T) {
+	// "??>" encodes to "Pz8-" in base64url and "Pz8+" in standard base64
+	actualString, err := DecodeJWT("Pz8-")
+	if err != nil {
+		t.Errorf("Error should be nil but it is %v", err)
+	}
+	if string(actualString) != "??>" {
+		t.Errorf("Actual decoded string %s differs from expected decoded string ??>", actualString)
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	expectedScopes := []string{"user.read", "openid", "mail.send"}
+	actualScopes := NormalizeScopes([]string{"User.Read", " openid", "", "USER.READ", "Mail.Send ", "openid"})
+	if !reflect.DeepEqual(expectedScopes, actualScopes) {
+		t.Errorf("Expected scopes %v differ from actual scopes %v", expectedScopes, actualScopes)
+	}
+}
+
+func TestResourceToScope(t *testing.T) {
+	tests := map[string]string{
+		"https://graph.microsoft.com":          "https://graph.microsoft.com/.default",
+		"https://management.core.windows.net/": "https://management.core.windows.net//.default",
+		"https://graph.microsoft.com/.default": "https://graph.microsoft.com/.default",
+		"00000003-0000-0000-c000-000000000000": "00000003-0000-0000-c000-000000000000/.default",
+	}
+	for resource, expectedScope := range tests {
+		if actualScope := ResourceToScope(resource); actualScope != expectedScope {
+			t.Errorf("Expected scope %s of resource %s differs from actual scope %s", expectedScope, resource, actualScope)
+		}
+	}
+}
+
+func TestDefaultScopes(t *testing.T) {
+	tests := map[string]string{
+		GraphResource: GraphDefaultScope,
+		ARMResource:   ARMDefaultScope,
+	}
+	for resource, defaultScope := range tests {
+		if actualScope := ResourceToScope(resource); actualScope != defaultScope {
+			t.Errorf("Expected scope %s of resource %s differs from actual scope %s", defaultScope, resource, actualScope)
+		}
+		// The scopes are already normalized, so they're sent and cached exactly as they are
+		if normalized := NormalizeScopes([]string{defaultScope}); !reflect.DeepEqual(normalized, []string{defaultScope}) {
+			t.Errorf("Scope %s should be normalized to itself, instead it is %v", defaultScope, normalized)
+		}
+	}
+	if GraphDefaultScope != "https://graph.microsoft.com/.default" || ARMDefaultScope != "https://management.azure.com/.default" {
+		t.Errorf("Unexpected default scopes %s and %s", GraphDefaultScope, ARMDefaultScope)
+	}
+}
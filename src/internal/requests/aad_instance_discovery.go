@@ -4,16 +4,49 @@
 package requests
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
-var instanceDiscoveryCache map[string]*InstanceDiscoveryMetadata
-var instanceDiscoveryCacheInitOnce sync.Once
+//instanceDiscoveryCacheTTL is how long instance discovery metadata is served from memory, it follows the guidance of the service
+const instanceDiscoveryCacheTTL = 24 * time.Hour
 
-func initInstanceDiscoveryCache() {
-	instanceDiscoveryCache = make(map[string]*InstanceDiscoveryMetadata)
+type instanceDiscoveryCacheEntry struct {
+	metadata  *InstanceDiscoveryMetadata
+	expiresOn time.Time
+}
+
+//instanceDiscoveryCache holds the metadata of each authority host, it's shared by all the applications in the process
+var instanceDiscoveryCache = make(map[string]instanceDiscoveryCacheEntry)
+var instanceDiscoveryCacheLock sync.RWMutex
+
+//ResetInstanceDiscoveryCache removes all the cached instance discovery metadata, the next lookup of every host goes to the network
+func ResetInstanceDiscoveryCache() {
+	instanceDiscoveryCacheLock.Lock()
+	defer instanceDiscoveryCacheLock.Unlock()
+	instanceDiscoveryCache = make(map[string]instanceDiscoveryCacheEntry)
+}
+
+func readInstanceDiscoveryCache(host string) (*InstanceDiscoveryMetadata, bool) {
+	instanceDiscoveryCacheLock.RLock()
+	defer instanceDiscoveryCacheLock.RUnlock()
+	entry, ok := instanceDiscoveryCache[host]
+	if !ok || !time.Now().Before(entry.expiresOn) {
+		return nil, false
+	}
+	return entry.metadata, true
+}
+
+//isDiscoveredAuthorityType checks whether the instance discovery endpoint knows the authorities of the type
+//B2C, CIAM and ADFS authorities aren't known to it, so their host is their only alias and their tokens are never cached under the aliases of Azure AD.
+//Managed identity tokens don't come from the authority at all.
+func isDiscoveredAuthorityType(authorityType string) bool {
+	return authorityType != msalbase.B2C && authorityType != msalbase.CIAM && authorityType != msalbase.ADFS &&
+		authorityType != msalbase.ManagedIdentityAuthority
 }
 
 type AadInstanceDiscovery struct {
@@ -21,35 +54,70 @@ type AadInstanceDiscovery struct {
 }
 
 func CreateAadInstanceDiscovery(webRequestManager WebRequestManager) *AadInstanceDiscovery {
-	instanceDiscoveryCacheInitOnce.Do(initInstanceDiscoveryCache)
 	return &AadInstanceDiscovery{webRequestManager: webRequestManager}
 }
 
-func (d *AadInstanceDiscovery) doInstanceDiscoveryAndCache(authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryMetadata, error) {
-	discoveryResponse, err := d.webRequestManager.GetAadinstanceDiscoveryResponse(authorityInfo)
+func (d *AadInstanceDiscovery) doInstanceDiscoveryAndCache(ctx context.Context, authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryMetadata, error) {
+	discoveryResponse, err := d.webRequestManager.GetAadinstanceDiscoveryResponse(ctx, authorityInfo)
 	if err != nil {
 		return nil, err
 	}
 
+	instanceDiscoveryCacheLock.Lock()
+	defer instanceDiscoveryCacheLock.Unlock()
+	expiresOn := time.Now().Add(instanceDiscoveryCacheTTL)
 	for _, metadataEntry := range discoveryResponse.Metadata {
-		metadataEntry.TenantDiscoveryEndpoint = discoveryResponse.TenantDiscoveryEndpoint
 		for _, aliasedAuthority := range metadataEntry.Aliases {
-			instanceDiscoveryCache[aliasedAuthority] = metadataEntry
+			instanceDiscoveryCache[aliasedAuthority] = instanceDiscoveryCacheEntry{metadataEntry, expiresOn}
 		}
 	}
 	if _, ok := instanceDiscoveryCache[authorityInfo.Host]; !ok {
-		instanceDiscoveryCache[authorityInfo.Host] = createInstanceDiscoveryMetadata(authorityInfo.Host, authorityInfo.Host)
+		metadata := createInstanceDiscoveryMetadata(authorityInfo.Host, authorityInfo.Host)
+		instanceDiscoveryCache[authorityInfo.Host] = instanceDiscoveryCacheEntry{metadata, expiresOn}
 	}
-	return instanceDiscoveryCache[authorityInfo.Host], nil
+	return instanceDiscoveryCache[authorityInfo.Host].metadata, nil
 }
 
-func (d *AadInstanceDiscovery) GetMetadataEntry(authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryMetadata, error) {
-	if metadata, ok := instanceDiscoveryCache[authorityInfo.Host]; ok {
+func (d *AadInstanceDiscovery) GetMetadataEntry(ctx context.Context, authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryMetadata, error) {
+	if !isDiscoveredAuthorityType(authorityInfo.AuthorityType) {
+		return createSingleHostMetadata(authorityInfo.Host), nil
+	}
+	// The metadata isn't cached when discovery is disabled, other applications of the process may discover the same host
+	if authorityInfo.InstanceDiscoveryDisabled {
+		return createSingleHostMetadata(authorityInfo.Host), nil
+	}
+	if metadata, ok := readInstanceDiscoveryCache(authorityInfo.Host); ok {
 		return metadata, nil
 	}
-	metadata, err := d.doInstanceDiscoveryAndCache(authorityInfo)
+	metadata, err := d.doInstanceDiscoveryAndCache(ctx, authorityInfo)
 	if err != nil {
 		return nil, err
 	}
 	return metadata, nil
 }
+
+//GetCachedMetadataEntry returns the metadata an earlier lookup of the authority cached, without going to the network
+//The host is its own only alias when it hasn't been looked up, the same way it is for authorities that aren't discovered
+func GetCachedMetadataEntry(authorityInfo *msalbase.AuthorityInfo) *InstanceDiscoveryMetadata {
+	if !isDiscoveredAuthorityType(authorityInfo.AuthorityType) || authorityInfo.InstanceDiscoveryDisabled {
+		return createSingleHostMetadata(authorityInfo.Host)
+	}
+	if metadata, ok := readInstanceDiscoveryCache(authorityInfo.Host); ok {
+		return metadata
+	}
+	return createSingleHostMetadata(authorityInfo.Host)
+}
+
+//ValidateAuthority checks that the authority host is one of the aliases returned by instance discovery, so tokens are never requested from an unknown host
+func (d *AadInstanceDiscovery) ValidateAuthority(ctx context.Context, authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryMetadata, error) {
+	metadata, err := d.GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		return nil, err
+	}
+	for _, alias := range metadata.Aliases {
+		if alias == authorityInfo.Host {
+			return metadata, nil
+		}
+	}
+	return nil, fmt.Errorf("authority host '%s' isn't known to instance discovery, authority validation can be turned off for trusted hosts", authorityInfo.Host)
+}
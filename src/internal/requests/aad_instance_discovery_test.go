@@ -4,8 +4,10 @@
 package requests
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
@@ -24,7 +26,7 @@ func TestGetMetadataEntry(t *testing.T) {
 		Metadata:                []*InstanceDiscoveryMetadata{metEntry},
 	}
 	mockWRM.On("GetAadinstanceDiscoveryResponse", authInfo).Return(instanceResp, nil)
-	actualMet, err := instanceDisc.GetMetadataEntry(authInfo)
+	actualMet, err := instanceDisc.GetMetadataEntry(context.Background(), authInfo)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -32,3 +34,67 @@ func TestGetMetadataEntry(t *testing.T) {
 		t.Errorf("Actual metadata entry %+v differs from expected metadata entry %+v", actualMet, metEntry)
 	}
 }
+
+func TestGetMetadataEntryExpires(t *testing.T) {
+	ResetInstanceDiscoveryCache()
+	defer ResetInstanceDiscoveryCache()
+	authInfo := &msalbase.AuthorityInfo{
+		Host: "expiry.microsoft.com",
+	}
+	mockWRM := new(MockWebRequestManager)
+	instanceResp := &InstanceDiscoveryResponse{
+		Metadata: []*InstanceDiscoveryMetadata{{Aliases: []string{"expiry.microsoft.com"}}},
+	}
+	mockWRM.On("GetAadinstanceDiscoveryResponse", authInfo).Return(instanceResp, nil)
+	instanceDisc := CreateAadInstanceDiscovery(mockWRM)
+	for i := 0; i < 2; i++ {
+		if _, err := instanceDisc.GetMetadataEntry(context.Background(), authInfo); err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+	}
+	mockWRM.AssertNumberOfCalls(t, "GetAadinstanceDiscoveryResponse", 1)
+	entry := instanceDiscoveryCache["expiry.microsoft.com"]
+	entry.expiresOn = time.Now().Add(-time.Second)
+	instanceDiscoveryCache["expiry.microsoft.com"] = entry
+	if _, err := instanceDisc.GetMetadataEntry(context.Background(), authInfo); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM.AssertNumberOfCalls(t, "GetAadinstanceDiscoveryResponse", 2)
+}
+
+func TestGetMetadataEntrySkipsInstanceDiscovery(t *testing.T) {
+	authInfos := []*msalbase.AuthorityInfo{
+		{Host: "contoso.b2clogin.com", AuthorityType: msalbase.B2C},
+		{Host: "contoso.ciamlogin.com", AuthorityType: msalbase.CIAM, Tenant: "contoso.onmicrosoft.com"},
+		{Host: "fs.contoso.com", AuthorityType: msalbase.ADFS, Tenant: "adfs"},
+	}
+	for _, authInfo := range authInfos {
+		mockWRM := new(MockWebRequestManager)
+		actualMet, err := CreateAadInstanceDiscovery(mockWRM).GetMetadataEntry(context.Background(), authInfo)
+		if err != nil {
+			t.Errorf("Error should be nil, but it is %v", err)
+		}
+		if !reflect.DeepEqual(actualMet.Aliases, []string{authInfo.Host}) {
+			t.Errorf("The host should be the only %s alias, instead the aliases are %v", authInfo.AuthorityType, actualMet.Aliases)
+		}
+		mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authInfo)
+	}
+}
+
+func TestGetMetadataEntryInstanceDiscoveryDisabled(t *testing.T) {
+	ResetInstanceDiscoveryCache()
+	defer ResetInstanceDiscoveryCache()
+	authInfo := &msalbase.AuthorityInfo{Host: "login.private.contoso.com", AuthorityType: msalbase.MSSTS, Tenant: "tenant", InstanceDiscoveryDisabled: true}
+	mockWRM := new(MockWebRequestManager)
+	actualMet, err := CreateAadInstanceDiscovery(mockWRM).GetMetadataEntry(context.Background(), authInfo)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(actualMet.Aliases, []string{authInfo.Host}) {
+		t.Errorf("The host should be the only alias when instance discovery is disabled, instead the aliases are %v", actualMet.Aliases)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authInfo)
+	if _, ok := readInstanceDiscoveryCache(authInfo.Host); ok {
+		t.Error("The metadata of an authority without instance discovery shouldn't be cached for the process")
+	}
+}
@@ -4,6 +4,7 @@
 package requests
 
 import (
+	"context"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
@@ -40,9 +41,9 @@ func CreateAuthCodeRequest(
 }
 
 //Execute performs the token acquisition request and returns a token response or an error
-func (req *AuthCodeRequest) Execute() (*msalbase.TokenResponse, error) {
+func (req *AuthCodeRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
 	resolutionManager := CreateAuthorityEndpointResolutionManager(req.webRequestManager)
-	endpoints, err := resolutionManager.ResolveEndpoints(req.authParameters.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +53,7 @@ func (req *AuthCodeRequest) Execute() (*msalbase.TokenResponse, error) {
 		if req.ClientCredential.GetCredentialType() == msalbase.ClientCredentialSecret {
 			params["client_secret"] = req.ClientCredential.GetSecret()
 		} else {
-			jwt, err := req.ClientCredential.GetAssertion().GetJWT(req.authParameters)
+			jwt, err := req.ClientCredential.GetAssertion().GetJWT(ctx, req.authParameters)
 			if err != nil {
 				return nil, err
 			}
@@ -60,7 +61,7 @@ func (req *AuthCodeRequest) Execute() (*msalbase.TokenResponse, error) {
 			params["client_assertion_type"] = msalbase.ClientAssertionGrant
 		}
 	}
-	tokenResponse, err := req.webRequestManager.GetAccessTokenFromAuthCode(req.authParameters, req.Code, req.CodeChallenge, params)
+	tokenResponse, err := req.webRequestManager.GetAccessTokenFromAuthCode(ctx, req.authParameters, req.Code, req.CodeChallenge, params)
 	if err != nil {
 		return nil, err
 	}
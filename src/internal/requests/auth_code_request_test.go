@@ -4,6 +4,7 @@
 package requests
 
 import (
+	"context"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -14,7 +15,7 @@ var testAuthorityEndpoints = msalbase.CreateAuthorityEndpoints("https://login.mi
 	"https://login.microsoftonline.com/v2.0",
 	"https://login.microsoftonline.com")
 var testAuthorityInfo, err = msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
-var testAuthParams = msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+var testAuthParams, _ = msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
 
 func TestAuthCodeReqExecutePublic(t *testing.T) {
 	var wrm = new(MockWebRequestManager)
@@ -34,7 +35,7 @@ func TestAuthCodeReqExecutePublic(t *testing.T) {
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
 	wrm.On("GetAccessTokenFromAuthCode", authCodeRequest.authParameters, authCodeRequest.Code,
 		authCodeRequest.CodeChallenge, make(map[string]string)).Return(actualTokenResp, nil)
-	_, err := authCodeRequest.Execute()
+	_, err := authCodeRequest.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error is supposed to be nil, instead it is %v", err)
 	}
@@ -64,7 +65,7 @@ func TestAuthCodeReqExecuteAssertion(t *testing.T) {
 			"client_assertion":      "hello",
 			"client_assertion_type": msalbase.ClientAssertionGrant,
 		}).Return(actualTokenResp, nil)
-	_, err := authCodeRequest.Execute()
+	_, err := authCodeRequest.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error is supposed to be nil, instead it is %v", err)
 	}
@@ -93,7 +94,7 @@ func TestAuthCodeReqExecuteSecret(t *testing.T) {
 		authCodeRequest.CodeChallenge, map[string]string{
 			"client_secret": "secret",
 		}).Return(actualTokenResp, nil)
-	_, err := authCodeRequest.Execute()
+	_, err := authCodeRequest.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error is supposed to be nil, instead it is %v", err)
 	}
@@ -4,10 +4,10 @@
 package requests
 
 import (
+	"context"
 	"errors"
 	"strings"
-
-	log "github.com/sirupsen/logrus"
+	"sync"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
@@ -21,7 +21,10 @@ func createAuthorityEndpointCacheEntry(endpoints *msalbase.AuthorityEndpoints) *
 	return &authorityEndpointCacheEntry{endpoints, make(map[string]bool)}
 }
 
+//endpointCacheEntries holds the endpoints of each authority, it's shared by all the applications in the process
+//An entry is never changed once it's cached, adding domains to an ADFS entry replaces it
 var endpointCacheEntries = map[string]*authorityEndpointCacheEntry{}
+var endpointCacheLock sync.RWMutex
 
 //AuthorityEndpointResolutionManager handles getting the correct endpoints from the authority for auth and token acquisition
 type AuthorityEndpointResolutionManager struct {
@@ -43,9 +46,11 @@ func getAdfsDomainFromUpn(userPrincipalName string) (string, error) {
 }
 
 func (m *AuthorityEndpointResolutionManager) tryGetCachedEndpoints(authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) *msalbase.AuthorityEndpoints {
-
+	endpointCacheLock.RLock()
+	defer endpointCacheLock.RUnlock()
 	if cacheEntry, ok := endpointCacheEntries[authorityInfo.CanonicalAuthorityURI]; ok {
-		if authorityInfo.AuthorityType == msalbase.ADFS {
+		// ADFS endpoints looked up for a user are only reused for users of the same domain
+		if authorityInfo.AuthorityType == msalbase.ADFS && userPrincipalName != "" {
 			domain, err := getAdfsDomainFromUpn(userPrincipalName)
 			if err == nil {
 				if _, ok := cacheEntry.ValidForDomainsInList[domain]; ok {
@@ -61,6 +66,8 @@ func (m *AuthorityEndpointResolutionManager) tryGetCachedEndpoints(authorityInfo
 
 func (m *AuthorityEndpointResolutionManager) addCachedEndpoints(authorityInfo *msalbase.AuthorityInfo, userPrincipalName string, endpoints *msalbase.AuthorityEndpoints) {
 	updatedCacheEntry := createAuthorityEndpointCacheEntry(endpoints)
+	endpointCacheLock.Lock()
+	defer endpointCacheLock.Unlock()
 
 	if authorityInfo.AuthorityType == msalbase.ADFS {
 		// Since we're here, we've made a call to the backend.  We want to ensure we're caching
@@ -80,31 +87,31 @@ func (m *AuthorityEndpointResolutionManager) addCachedEndpoints(authorityInfo *m
 }
 
 //ResolveEndpoints gets the authorization and token endpoints and creates an AuthorityEndpoints instance
-func (m *AuthorityEndpointResolutionManager) ResolveEndpoints(authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (*msalbase.AuthorityEndpoints, error) {
-
-	if authorityInfo.AuthorityType == msalbase.ADFS && len(userPrincipalName) == 0 {
-		return nil, errors.New("UPN required for authority validation for ADFS")
+func (m *AuthorityEndpointResolutionManager) ResolveEndpoints(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (*msalbase.AuthorityEndpoints, error) {
+	// The host is checked before the cached endpoints are used, they may have been resolved by an app that trusts other hosts
+	if err := authorityInfo.ValidateKnownHost(); err != nil {
+		return nil, err
 	}
 
 	endpoints := m.tryGetCachedEndpoints(authorityInfo, userPrincipalName)
 	if endpoints != nil {
-		log.Info("Resolving authority endpoints. Using cached value")
+		msalbase.GetLogger().Info("Resolving authority endpoints. Using cached value")
 		return endpoints, nil
 	}
 
-	log.Info("Resolving authority endpoints. No cached value.  Performing lookup.")
-	endpointManager, err := createOpenIDConfigurationEndpointManager(authorityInfo)
+	msalbase.GetLogger().Info("Resolving authority endpoints. No cached value.  Performing lookup.")
+	endpointManager, err := createOpenIDConfigurationEndpointManager(authorityInfo, m.webRequestManager)
 	if err != nil {
 		return nil, err
 	}
 
-	openIDConfigurationEndpoint, err := endpointManager.getOpenIDConfigurationEndpoint(authorityInfo, userPrincipalName)
+	openIDConfigurationEndpoint, err := endpointManager.getOpenIDConfigurationEndpoint(ctx, authorityInfo, userPrincipalName)
 	if err != nil {
 		return nil, err
 	}
 
 	// Discover endpoints via openid-configuration
-	tenantDiscoveryResponse, err := m.webRequestManager.GetTenantDiscoveryResponse(openIDConfigurationEndpoint)
+	tenantDiscoveryResponse, err := m.webRequestManager.GetTenantDiscoveryResponse(ctx, openIDConfigurationEndpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +133,7 @@ func (m *AuthorityEndpointResolutionManager) ResolveEndpoints(authorityInfo *msa
 		strings.Replace(tenantDiscoveryResponse.TokenEndpoint, "{tenant}", tenant, -1),
 		strings.Replace(tenantDiscoveryResponse.Issuer, "{tenant}", tenant, -1),
 		authorityInfo.Host)
+	endpoints.JWKSURI = strings.Replace(tenantDiscoveryResponse.JWKSURI, "{tenant}", tenant, -1)
 
 	m.addCachedEndpoints(authorityInfo, userPrincipalName, endpoints)
 
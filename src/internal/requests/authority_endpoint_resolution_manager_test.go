@@ -0,0 +1,214 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+func TestResolveEndpointsB2C(t *testing.T) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/tfp/contoso.onmicrosoft.com/b2c_1_signin/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.microsoftonline.com/{tenant}/b2c_1_signin/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://login.microsoftonline.com/{tenant}/b2c_1_signin/oauth2/v2.0/token",
+		Issuer:                "https://login.microsoftonline.com/{tenant}/v2.0/",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/tfp/contoso.onmicrosoft.com/b2c_1_signin/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	endpoints, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	expectedTokenEndpoint := "https://login.microsoftonline.com/contoso.onmicrosoft.com/b2c_1_signin/oauth2/v2.0/token"
+	if endpoints.TokenEndpoint != expectedTokenEndpoint {
+		t.Errorf("Token endpoint should be %s, but it is %s", expectedTokenEndpoint, endpoints.TokenEndpoint)
+	}
+	expectedAuthorizationEndpoint := "https://login.microsoftonline.com/contoso.onmicrosoft.com/b2c_1_signin/oauth2/v2.0/authorize"
+	if endpoints.AuthorizationEndpoint != expectedAuthorizationEndpoint {
+		t.Errorf("Authorization endpoint should be %s, but it is %s", expectedAuthorizationEndpoint, endpoints.AuthorizationEndpoint)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+}
+
+func TestResolveEndpointsCIAM(t *testing.T) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://contoso.ciamlogin.com/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://contoso.ciamlogin.com/{tenant}/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://contoso.ciamlogin.com/{tenant}/oauth2/v2.0/token",
+		Issuer:                "https://{tenant}.ciamlogin.com/{tenant}/v2.0",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse",
+		"https://contoso.ciamlogin.com/contoso.onmicrosoft.com/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	endpoints, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	expectedTokenEndpoint := "https://contoso.ciamlogin.com/contoso.onmicrosoft.com/oauth2/v2.0/token"
+	if endpoints.TokenEndpoint != expectedTokenEndpoint {
+		t.Errorf("Token endpoint should be %s, but it is %s", expectedTokenEndpoint, endpoints.TokenEndpoint)
+	}
+	expectedAuthorizationEndpoint := "https://contoso.ciamlogin.com/contoso.onmicrosoft.com/oauth2/v2.0/authorize"
+	if endpoints.AuthorizationEndpoint != expectedAuthorizationEndpoint {
+		t.Errorf("Authorization endpoint should be %s, but it is %s", expectedAuthorizationEndpoint, endpoints.AuthorizationEndpoint)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+}
+
+func TestResolveEndpointsOfTenantsSharingHost(t *testing.T) {
+	ResetInstanceDiscoveryCache()
+	defer ResetInstanceDiscoveryCache()
+	resolutionManager := CreateAuthorityEndpointResolutionManager(nil)
+	for _, tenant := range []string{"tenantone", "tenanttwo"} {
+		authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.tenants.contoso.com/"+tenant+"/", true)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		mockWRM := new(MockWebRequestManager)
+		resolutionManager.webRequestManager = mockWRM
+		// The host's metadata is cached by the lookup of the first tenant, the second tenant is validated with it
+		mockWRM.On("GetAadinstanceDiscoveryResponse", authorityInfo).Return(&InstanceDiscoveryResponse{
+			TenantDiscoveryEndpoint: "https://login.tenants.contoso.com/tenantone/v2.0/.well-known/openid-configuration",
+			Metadata:                []*InstanceDiscoveryMetadata{{Aliases: []string{"login.tenants.contoso.com"}}},
+		}, nil)
+		tenantEndpoint := "https://login.tenants.contoso.com/" + tenant + "/v2.0/.well-known/openid-configuration"
+		mockWRM.On("GetTenantDiscoveryResponse", tenantEndpoint).Return(&TenantDiscoveryResponse{
+			AuthorizationEndpoint: "https://login.tenants.contoso.com/" + tenant + "/oauth2/v2.0/authorize",
+			TokenEndpoint:         "https://login.tenants.contoso.com/" + tenant + "/oauth2/v2.0/token",
+			Issuer:                "https://login.tenants.contoso.com/" + tenant + "/v2.0",
+		}, nil)
+		endpoints, err := resolutionManager.ResolveEndpoints(context.Background(), authorityInfo, "")
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		if endpoints.TokenEndpoint != "https://login.tenants.contoso.com/"+tenant+"/oauth2/v2.0/token" {
+			t.Errorf("Token endpoint should be the one of %s, but it is %s", tenant, endpoints.TokenEndpoint)
+		}
+		mockWRM.AssertCalled(t, "GetTenantDiscoveryResponse", tenantEndpoint)
+		if tenant == "tenanttwo" {
+			mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+		}
+	}
+}
+
+func TestResolveEndpointsADFS(t *testing.T) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://fs.contoso.com/adfs", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://fs.contoso.com/adfs/oauth2/authorize/",
+		TokenEndpoint:         "https://fs.contoso.com/adfs/oauth2/token/",
+		Issuer:                "https://fs.contoso.com/adfs",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse", "https://fs.contoso.com/adfs/.well-known/openid-configuration").Return(tdr, nil)
+	endpoints, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if endpoints.TokenEndpoint != "https://fs.contoso.com/adfs/oauth2/token/" {
+		t.Errorf("Token endpoint should be the one of the ADFS server, but it is %s", endpoints.TokenEndpoint)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+}
+
+func TestResolveEndpointsRejectsUnknownHost(t *testing.T) {
+	ResetInstanceDiscoveryCache()
+	defer ResetInstanceDiscoveryCache()
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.contoso.com/common/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	instanceResp := &InstanceDiscoveryResponse{
+		TenantDiscoveryEndpoint: "https://login.contoso.com/common/v2.0/.well-known/openid-configuration",
+		Metadata:                []*InstanceDiscoveryMetadata{{Aliases: []string{"login.microsoftonline.com"}}},
+	}
+	mockWRM.On("GetAadinstanceDiscoveryResponse", authorityInfo).Return(instanceResp, nil)
+	_, err = CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, "")
+	if err == nil {
+		t.Error("Error should not be nil when the authority host isn't an instance discovery alias")
+	}
+	mockWRM.AssertNotCalled(t, "GetTenantDiscoveryResponse", instanceResp.TenantDiscoveryEndpoint)
+}
+
+func TestResolveEndpointsWithoutAuthorityValidation(t *testing.T) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.private.contoso.com/common/", false)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.private.contoso.com/{tenant}/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://login.private.contoso.com/{tenant}/oauth2/v2.0/token",
+		Issuer:                "https://login.private.contoso.com/{tenant}/v2.0",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse", "https://login.private.contoso.com/common/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	if _, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, ""); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+}
+
+func TestResolveEndpointsWithTenant(t *testing.T) {
+	commonAuthority, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/common/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	tenant := "8eaef023-2b34-4da1-9baa-8bc8c9d6a490"
+	authorityInfo, err := commonAuthority.WithTenant(tenant)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.microsoftonline.com/{tenant}/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token",
+		Issuer:                "https://login.microsoftonline.com/{tenant}/v2.0",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/"+tenant+"/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	endpoints, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	expectedTokenEndpoint := "https://login.microsoftonline.com/" + tenant + "/oauth2/v2.0/token"
+	if endpoints.TokenEndpoint != expectedTokenEndpoint {
+		t.Errorf("Token endpoint should be %s, but it is %s", expectedTokenEndpoint, endpoints.TokenEndpoint)
+	}
+	expectedIssuer := "https://login.microsoftonline.com/" + tenant + "/v2.0"
+	if endpoints.GetIssuer() != expectedIssuer {
+		t.Errorf("Issuer should be %s, but it is %s", expectedIssuer, endpoints.GetIssuer())
+	}
+}
+
+func TestResolveEndpointsInstanceDiscoveryDisabled(t *testing.T) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.nodiscovery.contoso.com/tenant/", true)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authorityInfo.InstanceDiscoveryDisabled = true
+	mockWRM := new(MockWebRequestManager)
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.nodiscovery.contoso.com/tenant/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://login.nodiscovery.contoso.com/tenant/oauth2/v2.0/token",
+		Issuer:                "https://login.nodiscovery.contoso.com/tenant/v2.0",
+	}
+	mockWRM.On("GetTenantDiscoveryResponse", "https://login.nodiscovery.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	if _, err := CreateAuthorityEndpointResolutionManager(mockWRM).ResolveEndpoints(context.Background(), authorityInfo, ""); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	mockWRM.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authorityInfo)
+}
@@ -3,14 +3,31 @@
 
 package requests
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"context"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 //CacheManager is the interface for the handling of caching operations
 type CacheManager interface {
-	TryReadCache(authParameters *msalbase.AuthParametersInternal, webRequestManager WebRequestManager) (*msalbase.StorageTokenResponse, error)
+	TryReadCache(ctx context.Context, authParameters *msalbase.AuthParametersInternal, webRequestManager WebRequestManager) (*msalbase.StorageTokenResponse, error)
 	CacheTokenResponse(authParameters *msalbase.AuthParametersInternal, tokenResponse *msalbase.TokenResponse) (*msalbase.Account, error)
 	DeleteCachedRefreshToken(authParameters *msalbase.AuthParametersInternal) error
 	GetAllAccounts() []*msalbase.Account
-	Serialize() (string, error)
-	Deserialize(data []byte) error
+	GetAccount(ctx context.Context, homeAccountID string, authorityInfo *msalbase.AuthorityInfo, webRequestManager WebRequestManager) (*msalbase.Account, error)
+	RemoveAccount(ctx context.Context, account *msalbase.Account, webRequestManager WebRequestManager) error
+	DeleteAccessTokens(ctx context.Context, account *msalbase.Account, scopes []string, webRequestManager WebRequestManager) (int, error)
+	RemoveExpiredAccessTokens() (int, error)
+	GetAllAccessTokens(redactSecrets bool) []*msalbase.CachedAccessToken
+	GetAllRefreshTokens(redactSecrets bool) []*msalbase.CachedRefreshToken
+	GetStats() (*msalbase.CacheStats, error)
+	ExportRefreshToken(ctx context.Context, account *msalbase.Account, clientID string, webRequestManager WebRequestManager) (string, error)
+	ImportRefreshToken(authParameters *msalbase.AuthParametersInternal, homeAccountID string, refreshToken string, familyID string) error
+	Clear() error
+	SetMaxAccounts(maxAccounts int)
+	SetTokenExpirationOffset(offset time.Duration)
+	SerializeCache() ([]byte, error)
+	DeserializeCache(data []byte) error
 }
@@ -3,7 +3,11 @@
 
 package requests
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"context"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 //ClientCredentialRequest stores the values required to acquire a token from the authority using a client credentials grant
 type ClientCredentialRequest struct {
@@ -21,22 +25,28 @@ func CreateClientCredentialRequest(
 }
 
 //Execute performs the token acquisition request and returns a token response or an error
-func (req *ClientCredentialRequest) Execute() (*msalbase.TokenResponse, error) {
+func (req *ClientCredentialRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
 	resolutionManager := CreateAuthorityEndpointResolutionManager(req.webRequestManager)
-	endpoints, err := resolutionManager.ResolveEndpoints(req.authParameters.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
 	if err != nil {
 		return nil, err
 	}
+	if req.authParameters.Region != "" {
+		endpoints, err = endpoints.GetRegionalEndpoints(req.authParameters.Region)
+		if err != nil {
+			return nil, err
+		}
+	}
 	req.authParameters.Endpoints = endpoints
 	var tokenResponse *msalbase.TokenResponse
 	if req.clientCredential.GetCredentialType() == msalbase.ClientCredentialSecret {
-		tokenResponse, err = req.webRequestManager.GetAccessTokenWithClientSecret(req.authParameters, req.clientCredential.GetSecret())
+		tokenResponse, err = req.webRequestManager.GetAccessTokenWithClientSecret(ctx, req.authParameters, req.clientCredential.GetSecret())
 	} else {
-		jwt, err := req.clientCredential.GetAssertion().GetJWT(req.authParameters)
+		jwt, err := req.clientCredential.GetAssertion().GetJWT(ctx, req.authParameters)
 		if err != nil {
 			return nil, err
 		}
-		tokenResponse, err = req.webRequestManager.GetAccessTokenWithAssertion(req.authParameters, jwt)
+		tokenResponse, err = req.webRequestManager.GetAccessTokenWithAssertion(ctx, req.authParameters, jwt)
 		if err != nil {
 			return nil, err
 		}
@@ -4,6 +4,8 @@
 package requests
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -11,7 +13,7 @@ import (
 
 func TestClientCredentialReqExecuteWithAssertion(t *testing.T) {
 	testAuthorityInfo, _ := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
-	testAuthParams := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
 	wrm := new(MockWebRequestManager)
 	cred, _ := msalbase.CreateClientCredentialFromAssertion("hello")
 	req := &ClientCredentialRequest{
@@ -28,7 +30,7 @@ func TestClientCredentialReqExecuteWithAssertion(t *testing.T) {
 	wrm.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
 	wrm.On("GetAccessTokenWithAssertion", testAuthParams, "hello").Return(actualTokenResp, nil)
-	_, err := req.Execute()
+	_, err := req.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -36,7 +38,7 @@ func TestClientCredentialReqExecuteWithAssertion(t *testing.T) {
 
 func TestClientCredentialReqExecuteWithSecret(t *testing.T) {
 	testAuthorityInfo, _ := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
-	testAuthParams := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
 	wrm := new(MockWebRequestManager)
 	cred, _ := msalbase.CreateClientCredentialFromSecret("hello")
 	req := &ClientCredentialRequest{
@@ -53,8 +55,68 @@ func TestClientCredentialReqExecuteWithSecret(t *testing.T) {
 	wrm.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
 	wrm.On("GetAccessTokenWithClientSecret", testAuthParams, "hello").Return(actualTokenResp, nil)
-	_, err := req.Execute()
+	_, err := req.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestClientCredentialReqExecuteWithAssertionCallback(t *testing.T) {
+	testAuthorityInfo, _ := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	wrm := new(MockWebRequestManager)
+	calls := 0
+	cred, _ := msalbase.CreateClientCredentialFromAssertionCallback(func(ctx context.Context) (string, error) {
+		calls++
+		return "canned", nil
+	})
+	req := &ClientCredentialRequest{
+		webRequestManager: wrm,
+		authParameters:    testAuthParams,
+		clientCredential:  cred,
+	}
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.microsoftonline.com/v2.0/authorize",
+		TokenEndpoint:         "https://login.microsoftonline.com/v2.0/token",
+		Issuer:                "https://login.microsoftonline.com/v2.0",
+	}
+	actualTokenResp := &msalbase.TokenResponse{}
+	wrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	wrm.On("GetAccessTokenWithAssertion", testAuthParams, "canned").Return(actualTokenResp, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := req.Execute(context.Background()); err != nil {
+			t.Errorf("Error should be nil, but it is %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Callback should be invoked for every request, instead it was invoked %d times", calls)
+	}
+}
+
+func TestClientCredentialReqExecuteWithFailingAssertionCallback(t *testing.T) {
+	testAuthorityInfo, _ := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	wrm := new(MockWebRequestManager)
+	callbackErr := errors.New("key vault is unavailable")
+	cred, _ := msalbase.CreateClientCredentialFromAssertionCallback(func(ctx context.Context) (string, error) {
+		return "", callbackErr
+	})
+	req := &ClientCredentialRequest{
+		webRequestManager: wrm,
+		authParameters:    testAuthParams,
+		clientCredential:  cred,
+	}
+	tdr := &TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.microsoftonline.com/v2.0/authorize",
+		TokenEndpoint:         "https://login.microsoftonline.com/v2.0/token",
+		Issuer:                "https://login.microsoftonline.com/v2.0",
+	}
+	wrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	_, err := req.Execute(context.Background())
+	if !errors.Is(err, callbackErr) {
+		t.Errorf("Error should wrap the callback error, but it is %v", err)
+	}
+	wrm.AssertNotCalled(t, "GetAccessTokenWithAssertion", testAuthParams, "")
+}
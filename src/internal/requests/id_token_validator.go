@@ -0,0 +1,107 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+var (
+	jsonWebKeySetCache     = map[string]*JSONWebKeySet{}
+	jsonWebKeySetCacheLock sync.RWMutex
+)
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+//ValidateIDToken checks the RS256 signature of the ID token against the signing keys of the authority,
+//and its iss, aud, exp and nbf claims. The signing keys are cached per jwks_uri and fetched again when a key is rotated.
+func ValidateIDToken(ctx context.Context, webRequestManager WebRequestManager, authParameters *msalbase.AuthParametersInternal, idToken *msalbase.IDToken) error {
+	segments := strings.Split(idToken.RawToken, ".")
+	if len(segments) != 3 {
+		return errors.New("id token isn't a signed JWT")
+	}
+	headerData, err := msalbase.DecodeJWT(segments[0])
+	if err != nil {
+		return err
+	}
+	header := &jwtHeader{}
+	if err := json.Unmarshal(headerData, header); err != nil {
+		return err
+	}
+	if header.Algorithm != "RS256" {
+		return fmt.Errorf("id token is signed with unsupported algorithm '%s'", header.Algorithm)
+	}
+	if authParameters.Endpoints == nil || authParameters.Endpoints.JWKSURI == "" {
+		return errors.New("jwks_uri was not found in the openid configuration")
+	}
+	key, err := getSigningKey(ctx, webRequestManager, authParameters.Endpoints.JWKSURI, header.KeyID)
+	if err != nil {
+		return err
+	}
+	signature, err := msalbase.DecodeJWT(segments[2])
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(segments[0] + "." + segments[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return errors.New("id token signature is invalid")
+	}
+	return validateIDTokenClaims(authParameters, idToken, time.Now())
+}
+
+func validateIDTokenClaims(authParameters *msalbase.AuthParametersInternal, idToken *msalbase.IDToken, now time.Time) error {
+	// Issuers of multi-tenant authorities have a placeholder for the tenant the user signed in to
+	issuer := strings.Replace(authParameters.Endpoints.GetIssuer(), "{tenantid}", idToken.TenantID, -1)
+	if idToken.Issuer != issuer {
+		return fmt.Errorf("id token issuer '%s' doesn't match the authority issuer '%s'", idToken.Issuer, issuer)
+	}
+	if idToken.Audience != authParameters.ClientID {
+		return fmt.Errorf("id token audience '%s' doesn't match the client ID", idToken.Audience)
+	}
+	// Tokens that expired or become valid within the clock skew are accepted, the clocks of the authority and the client are never exactly in sync
+	clockSkew := authParameters.ClockSkew
+	if now.Add(-clockSkew).After(time.Unix(idToken.ExpirationTime, 0)) {
+		return errors.New("id token has expired")
+	}
+	if idToken.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(idToken.NotBefore, 0)) {
+		return errors.New("id token isn't valid yet")
+	}
+	return nil
+}
+
+func getSigningKey(ctx context.Context, webRequestManager WebRequestManager, jwksURI string, keyID string) (*rsa.PublicKey, error) {
+	jsonWebKeySetCacheLock.RLock()
+	keySet, ok := jsonWebKeySetCache[jwksURI]
+	jsonWebKeySetCacheLock.RUnlock()
+	if !ok || keySet.getKey(keyID) == nil {
+		// The key isn't known yet, so the keys were rotated since they were cached
+		var err error
+		keySet, err = webRequestManager.GetJSONWebKeySet(ctx, jwksURI)
+		if err != nil {
+			return nil, err
+		}
+		jsonWebKeySetCacheLock.Lock()
+		jsonWebKeySetCache[jwksURI] = keySet
+		jsonWebKeySetCacheLock.Unlock()
+	}
+	key := keySet.getKey(keyID)
+	if key == nil {
+		return nil, fmt.Errorf("signing key '%s' of the id token was not found", keyID)
+	}
+	return key.getRSAPublicKey()
+}
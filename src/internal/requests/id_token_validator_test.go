@@ -0,0 +1,173 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+const testIssuer = "https://login.microsoftonline.com/{tenantid}/v2.0"
+
+func createTestSigningKey(t *testing.T, keyID string) (*rsa.PrivateKey, JSONWebKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := JSONWebKey{
+		KeyID:   keyID,
+		KeyType: "RSA",
+		Use:     "sig",
+		N:       base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	}
+	return privateKey, key
+}
+
+func createSignedTestIDToken(t *testing.T, privateKey *rsa.PrivateKey, keyID string, claims map[string]interface{}) *msalbase.IDToken {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	idToken, err := msalbase.CreateIDToken(signingInput + "." + base64.RawURLEncoding.EncodeToString(signature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idToken
+}
+
+func createTestIDTokenClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss": "https://login.microsoftonline.com/tid/v2.0",
+		"aud": "clientID",
+		"tid": "tid",
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+	}
+}
+
+func createIDTokenTestAuthParams(jwksURI string) *msalbase.AuthParametersInternal {
+	endpoints := msalbase.CreateAuthorityEndpoints("https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+		"https://login.microsoftonline.com/common/oauth2/v2.0/token", testIssuer, "login.microsoftonline.com")
+	endpoints.JWKSURI = jwksURI
+	return &msalbase.AuthParametersInternal{ClientID: "clientID", Endpoints: endpoints, ClockSkew: msalbase.DefaultClockSkew}
+}
+
+func TestValidateIDToken(t *testing.T) {
+	jwksURI := "https://login.microsoftonline.com/validate/discovery/v2.0/keys"
+	privateKey, key := createTestSigningKey(t, "key1")
+	wrm := new(MockWebRequestManager)
+	wrm.On("GetJSONWebKeySet", jwksURI).Return(&JSONWebKeySet{Keys: []JSONWebKey{key}}, nil)
+	authParams := createIDTokenTestAuthParams(jwksURI)
+	idToken := createSignedTestIDToken(t, privateKey, "key1", createTestIDTokenClaims())
+	if err := ValidateIDToken(context.Background(), wrm, authParams, idToken); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	// The signing keys are cached
+	if err := ValidateIDToken(context.Background(), wrm, authParams, idToken); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	wrm.AssertNumberOfCalls(t, "GetJSONWebKeySet", 1)
+}
+
+func TestValidateIDTokenTampered(t *testing.T) {
+	jwksURI := "https://login.microsoftonline.com/tampered/discovery/v2.0/keys"
+	privateKey, key := createTestSigningKey(t, "key1")
+	wrm := new(MockWebRequestManager)
+	wrm.On("GetJSONWebKeySet", jwksURI).Return(&JSONWebKeySet{Keys: []JSONWebKey{key}}, nil)
+	idToken := createSignedTestIDToken(t, privateKey, "key1", createTestIDTokenClaims())
+	segments := strings.Split(idToken.RawToken, ".")
+	tamperedClaims := createTestIDTokenClaims()
+	tamperedClaims["aud"] = "otherClientID"
+	payload, _ := json.Marshal(tamperedClaims)
+	idToken.RawToken = segments[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + segments[2]
+	if err := ValidateIDToken(context.Background(), wrm, createIDTokenTestAuthParams(jwksURI), idToken); err == nil {
+		t.Error("Error should be returned for a tampered id token")
+	}
+}
+
+func TestValidateIDTokenRotatedKey(t *testing.T) {
+	jwksURI := "https://login.microsoftonline.com/rotated/discovery/v2.0/keys"
+	_, oldKey := createTestSigningKey(t, "oldKey")
+	privateKey, newKey := createTestSigningKey(t, "newKey")
+	wrm := new(MockWebRequestManager)
+	wrm.On("GetJSONWebKeySet", jwksURI).Return(&JSONWebKeySet{Keys: []JSONWebKey{oldKey}}, nil).Once()
+	wrm.On("GetJSONWebKeySet", jwksURI).Return(&JSONWebKeySet{Keys: []JSONWebKey{oldKey, newKey}}, nil).Once()
+	authParams := createIDTokenTestAuthParams(jwksURI)
+	if _, err := getSigningKey(context.Background(), wrm, jwksURI, "oldKey"); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	idToken := createSignedTestIDToken(t, privateKey, "newKey", createTestIDTokenClaims())
+	if err := ValidateIDToken(context.Background(), wrm, authParams, idToken); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	wrm.AssertNumberOfCalls(t, "GetJSONWebKeySet", 2)
+}
+
+func TestValidateIDTokenClaims(t *testing.T) {
+	now := time.Now()
+	authParams := createIDTokenTestAuthParams("")
+	tests := []struct {
+		desc    string
+		idToken *msalbase.IDToken
+		valid   bool
+	}{
+		{"valid", &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "clientID", TenantID: "tid", ExpirationTime: now.Add(time.Hour).Unix()}, true},
+		{"within clock skew", &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "clientID", TenantID: "tid", ExpirationTime: now.Add(-time.Minute).Unix()}, true},
+		{"wrong issuer", &msalbase.IDToken{Issuer: "https://evil.example.com/tid/v2.0", Audience: "clientID", TenantID: "tid", ExpirationTime: now.Add(time.Hour).Unix()}, false},
+		{"wrong audience", &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "otherClientID", TenantID: "tid", ExpirationTime: now.Add(time.Hour).Unix()}, false},
+		{"expired", &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "clientID", TenantID: "tid", ExpirationTime: now.Add(-time.Hour).Unix()}, false},
+		{"not valid yet", &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "clientID", TenantID: "tid", ExpirationTime: now.Add(2 * time.Hour).Unix(), NotBefore: now.Add(time.Hour).Unix()}, false},
+	}
+	for _, test := range tests {
+		err := validateIDTokenClaims(authParams, test.idToken, now)
+		if test.valid && err != nil {
+			t.Errorf("%s: error should be nil, but it is %v", test.desc, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: error should be returned", test.desc)
+		}
+	}
+}
+
+func TestValidateIDTokenClaimsClockSkew(t *testing.T) {
+	now := time.Now()
+	authParams := createIDTokenTestAuthParams("")
+	// The clock of the authority is a few seconds ahead of the client's
+	idToken := &msalbase.IDToken{Issuer: "https://login.microsoftonline.com/tid/v2.0", Audience: "clientID", TenantID: "tid",
+		ExpirationTime: now.Add(time.Hour).Unix(), NotBefore: now.Add(10 * time.Second).Unix()}
+	authParams.ClockSkew = 30 * time.Second
+	if err := validateIDTokenClaims(authParams, idToken, now); err != nil {
+		t.Errorf("An id token that is valid within the clock skew should be accepted, instead the error is %v", err)
+	}
+	authParams.ClockSkew = 5 * time.Second
+	if err := validateIDTokenClaims(authParams, idToken, now); err == nil {
+		t.Error("An id token that isn't valid yet beyond the clock skew should be rejected")
+	}
+	idToken.NotBefore = 0
+	idToken.ExpirationTime = now.Add(-10 * time.Second).Unix()
+	if err := validateIDTokenClaims(authParams, idToken, now); err == nil {
+		t.Error("An id token that expired beyond the clock skew should be rejected")
+	}
+	authParams.ClockSkew = 30 * time.Second
+	if err := validateIDTokenClaims(authParams, idToken, now); err != nil {
+		t.Errorf("An id token that expired within the clock skew should be accepted, instead the error is %v", err)
+	}
+}
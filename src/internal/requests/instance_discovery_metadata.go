@@ -17,3 +17,12 @@ func createInstanceDiscoveryMetadata(preferredNetwork string, preferredCache str
 		Aliases:          []string{},
 	}
 }
+
+//createSingleHostMetadata creates the metadata of an authority whose host has no other aliases
+func createSingleHostMetadata(host string) *InstanceDiscoveryMetadata {
+	return &InstanceDiscoveryMetadata{
+		PreferredNetwork: host,
+		PreferredCache:   host,
+		Aliases:          []string{host},
+	}
+}
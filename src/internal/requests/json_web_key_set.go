@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// JSONWebKey is a public key the authority signs tokens with
+type JSONWebKey struct {
+	KeyID   string `json:"kid"`
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// JSONWebKeySet consists of the signing keys from the jwks_uri of the authority
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+//CreateJSONWebKeySet creates a JSON web key set instance from an HTTP response
+func CreateJSONWebKeySet(responseCode int, responseData string) (*JSONWebKeySet, error) {
+	if _, err := msalbase.CreateOAuthResponseBase(responseCode, responseData); err != nil {
+		return nil, err
+	}
+	keySet := &JSONWebKeySet{}
+	if err := json.Unmarshal([]byte(responseData), keySet); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+func (s *JSONWebKeySet) getKey(keyID string) *JSONWebKey {
+	for i := range s.Keys {
+		if s.Keys[i].KeyID == keyID {
+			return &s.Keys[i]
+		}
+	}
+	return nil
+}
+
+func (k *JSONWebKey) getRSAPublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, errors.New("signing key isn't an RSA key")
+	}
+	n, err := msalbase.DecodeJWT(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := msalbase.DecodeJWT(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("exponent of the signing key is invalid")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
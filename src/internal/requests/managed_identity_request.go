@@ -0,0 +1,27 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"context"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+//ManagedIdentityRequest stores the values required to acquire a token from the managed identity endpoint of the Azure resource the app runs on
+type ManagedIdentityRequest struct {
+	webRequestManager WebRequestManager
+	authParameters    *msalbase.AuthParametersInternal
+}
+
+//CreateManagedIdentityRequest creates an instance of ManagedIdentityRequest
+func CreateManagedIdentityRequest(wrm WebRequestManager, authParams *msalbase.AuthParametersInternal) *ManagedIdentityRequest {
+	return &ManagedIdentityRequest{wrm, authParams}
+}
+
+//Execute performs the token acquisition request and returns a token response or an error
+//No authority endpoints are resolved, the managed identity endpoint issues the token
+func (req *ManagedIdentityRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
+	return req.webRequestManager.GetAccessTokenFromManagedIdentity(ctx, req.authParameters)
+}
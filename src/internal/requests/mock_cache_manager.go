@@ -4,6 +4,9 @@
 package requests
 
 import (
+	"context"
+	"time"
+
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,7 +16,7 @@ type MockCacheManager struct {
 	mock.Mock
 }
 
-func (mock *MockCacheManager) TryReadCache(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockCacheManager) TryReadCache(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	webRequestManager WebRequestManager) (*msalbase.StorageTokenResponse, error) {
 	args := mock.Called(authParameters, webRequestManager)
 	return args.Get(0).(*msalbase.StorageTokenResponse), args.Error(1)
@@ -35,12 +38,70 @@ func (mock *MockCacheManager) GetAllAccounts() []*msalbase.Account {
 	return args.Get(0).([]*msalbase.Account)
 }
 
-func (mock *MockCacheManager) Serialize() (string, error) {
+func (mock *MockCacheManager) GetAccount(ctx context.Context, homeAccountID string, authorityInfo *msalbase.AuthorityInfo, webRequestManager WebRequestManager) (*msalbase.Account, error) {
+	args := mock.Called(homeAccountID, authorityInfo, webRequestManager)
+	return args.Get(0).(*msalbase.Account), args.Error(1)
+}
+
+func (mock *MockCacheManager) RemoveAccount(ctx context.Context, account *msalbase.Account, webRequestManager WebRequestManager) error {
+	args := mock.Called(account, webRequestManager)
+	return args.Error(0)
+}
+
+func (mock *MockCacheManager) DeleteAccessTokens(ctx context.Context, account *msalbase.Account, scopes []string, webRequestManager WebRequestManager) (int, error) {
+	args := mock.Called(account, scopes, webRequestManager)
+	return args.Int(0), args.Error(1)
+}
+
+func (mock *MockCacheManager) RemoveExpiredAccessTokens() (int, error) {
+	args := mock.Called()
+	return args.Int(0), args.Error(1)
+}
+
+func (mock *MockCacheManager) GetAllAccessTokens(redactSecrets bool) []*msalbase.CachedAccessToken {
+	args := mock.Called(redactSecrets)
+	return args.Get(0).([]*msalbase.CachedAccessToken)
+}
+
+func (mock *MockCacheManager) GetAllRefreshTokens(redactSecrets bool) []*msalbase.CachedRefreshToken {
+	args := mock.Called(redactSecrets)
+	return args.Get(0).([]*msalbase.CachedRefreshToken)
+}
+
+func (mock *MockCacheManager) GetStats() (*msalbase.CacheStats, error) {
 	args := mock.Called()
+	return args.Get(0).(*msalbase.CacheStats), args.Error(1)
+}
+
+func (mock *MockCacheManager) SetMaxAccounts(maxAccounts int) {
+	mock.Called(maxAccounts)
+}
+
+func (mock *MockCacheManager) SetTokenExpirationOffset(offset time.Duration) {
+	mock.Called(offset)
+}
+
+func (mock *MockCacheManager) ExportRefreshToken(ctx context.Context, account *msalbase.Account, clientID string, webRequestManager WebRequestManager) (string, error) {
+	args := mock.Called(account, clientID, webRequestManager)
 	return args.String(0), args.Error(1)
 }
 
-func (mock *MockCacheManager) Deserialize(data []byte) error {
+func (mock *MockCacheManager) ImportRefreshToken(authParameters *msalbase.AuthParametersInternal, homeAccountID string, refreshToken string, familyID string) error {
+	args := mock.Called(authParameters, homeAccountID, refreshToken, familyID)
+	return args.Error(0)
+}
+
+func (mock *MockCacheManager) Clear() error {
+	args := mock.Called()
+	return args.Error(0)
+}
+
+func (mock *MockCacheManager) SerializeCache() ([]byte, error) {
+	args := mock.Called()
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (mock *MockCacheManager) DeserializeCache(data []byte) error {
 	args := mock.Called(data)
 	return args.Error(0)
 }
@@ -4,6 +4,7 @@
 package requests
 
 import (
+	"context"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/stretchr/testify/mock"
 )
@@ -12,7 +13,7 @@ type MockTokenRequest struct {
 	mock.Mock
 }
 
-func (mock *MockTokenRequest) Execute() (*msalbase.TokenResponse, error) {
+func (mock *MockTokenRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
 	args := mock.Called()
 	if args.Get(0) != nil {
 		return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
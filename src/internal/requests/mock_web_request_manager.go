@@ -4,6 +4,8 @@
 package requests
 
 import (
+	"context"
+
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/wstrust"
 	"github.com/stretchr/testify/mock"
@@ -13,35 +15,35 @@ type MockWebRequestManager struct {
 	mock.Mock
 }
 
-func (mock *MockWebRequestManager) GetUserRealm(authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error) {
+func (mock *MockWebRequestManager) GetUserRealm(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error) {
 	args := mock.Called(authParameters)
 	return args.Get(0).(*msalbase.UserRealm), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetMex(federationMetadataURL string) (*wstrust.MexDocument, error) {
+func (mock *MockWebRequestManager) GetMex(ctx context.Context, federationMetadataURL string) (*wstrust.MexDocument, error) {
 	args := mock.Called(federationMetadataURL)
 	return args.Get(0).(*wstrust.MexDocument), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetWsTrustResponse(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetWsTrustResponse(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	cloudAudienceURN string,
 	endpoint *wstrust.Endpoint) (*wstrust.Response, error) {
 	args := mock.Called(authParameters, cloudAudienceURN, endpoint)
 	return args.Get(0).(*wstrust.Response), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenFromSamlGrant(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenFromSamlGrant(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	samlGrant *wstrust.SamlTokenInfo) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters, samlGrant)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenFromUsernamePassword(authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error) {
+func (mock *MockWebRequestManager) GetAccessTokenFromUsernamePassword(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenFromAuthCode(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenFromAuthCode(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	authCode string,
 	codeVerifier string,
 	params map[string]string) (*msalbase.TokenResponse, error) {
@@ -49,42 +51,63 @@ func (mock *MockWebRequestManager) GetAccessTokenFromAuthCode(authParameters *ms
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenFromRefreshToken(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenFromRefreshToken(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	refreshToken string,
 	params map[string]string) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters, refreshToken, params)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenWithClientSecret(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenWithClientSecret(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	clientSecret string) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters, clientSecret)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenWithAssertion(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenWithAssertion(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	assertion string) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters, assertion)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetDeviceCodeResult(authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error) {
+func (mock *MockWebRequestManager) GetAccessTokenOnBehalfOf(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
+	userAssertion string, params map[string]string) (*msalbase.TokenResponse, error) {
+	args := mock.Called(authParameters, userAssertion, params)
+	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
+}
+
+func (mock *MockWebRequestManager) GetDeviceCodeResult(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error) {
 	args := mock.Called(authParameters)
 	return args.Get(0).(*msalbase.DeviceCodeResult), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAccessTokenFromDeviceCodeResult(authParameters *msalbase.AuthParametersInternal,
+func (mock *MockWebRequestManager) GetAccessTokenFromDeviceCodeResult(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error) {
 	args := mock.Called(authParameters, deviceCodeResult)
 	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetTenantDiscoveryResponse(openIDConfigurationEndpoint string) (*TenantDiscoveryResponse, error) {
+func (mock *MockWebRequestManager) GetTenantDiscoveryResponse(ctx context.Context, openIDConfigurationEndpoint string) (*TenantDiscoveryResponse, error) {
 	args := mock.Called(openIDConfigurationEndpoint)
 	return args.Get(0).(*TenantDiscoveryResponse), args.Error(1)
 }
 
-func (mock *MockWebRequestManager) GetAadinstanceDiscoveryResponse(authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryResponse, error) {
+func (mock *MockWebRequestManager) GetAadinstanceDiscoveryResponse(ctx context.Context, authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryResponse, error) {
 	args := mock.Called(authorityInfo)
 	return args.Get(0).(*InstanceDiscoveryResponse), args.Error(1)
 }
+
+func (mock *MockWebRequestManager) GetJSONWebKeySet(ctx context.Context, jwksURI string) (*JSONWebKeySet, error) {
+	args := mock.Called(jwksURI)
+	return args.Get(0).(*JSONWebKeySet), args.Error(1)
+}
+
+func (mock *MockWebRequestManager) GetAzureRegion(ctx context.Context) (string, error) {
+	args := mock.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (mock *MockWebRequestManager) GetAccessTokenFromManagedIdentity(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error) {
+	args := mock.Called(authParameters)
+	return args.Get(0).(*msalbase.TokenResponse), args.Error(1)
+}
@@ -4,13 +4,14 @@
 package requests
 
 import (
+	"context"
 	"errors"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 type openIDConfigurationEndpointManager interface {
-	getOpenIDConfigurationEndpoint(authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error)
+	getOpenIDConfigurationEndpoint(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error)
 }
 
 type aadOpenIDConfigurationEndpointManager struct {
@@ -40,22 +41,50 @@ func IsInTrustedHostList(host string) bool {
 	return false
 }
 
-func (m *aadOpenIDConfigurationEndpointManager) getOpenIDConfigurationEndpoint(authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error) {
-	if authorityInfo.ValidateAuthority && !IsInTrustedHostList(authorityInfo.Host) {
-		discoveryResponse, err := m.aadInstanceDiscovery.GetMetadataEntry(authorityInfo)
-		if err != nil {
+func (m *aadOpenIDConfigurationEndpointManager) getOpenIDConfigurationEndpoint(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error) {
+	if authorityInfo.ValidateAuthority && !authorityInfo.InstanceDiscoveryDisabled && !IsInTrustedHostList(authorityInfo.Host) {
+		if _, err := m.aadInstanceDiscovery.ValidateAuthority(ctx, authorityInfo); err != nil {
 			return "", err
 		}
-
-		return discoveryResponse.TenantDiscoveryEndpoint, nil
 	}
+	// The endpoint is built from the authority even when it was validated, the metadata of a host is shared by all of its tenants
+	// so the tenant discovery endpoint of the lookup that cached it may be for another authority
+	return authorityInfo.CanonicalAuthorityURI + "v2.0/.well-known/openid-configuration", nil
+}
+
+//b2cOpenIDConfigurationEndpointManager gets the configuration from the policy of the authority, B2C doesn't support instance discovery
+type b2cOpenIDConfigurationEndpointManager struct{}
+
+func (m *b2cOpenIDConfigurationEndpointManager) getOpenIDConfigurationEndpoint(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error) {
+	return authorityInfo.CanonicalAuthorityURI + "v2.0/.well-known/openid-configuration", nil
+}
 
+//ciamOpenIDConfigurationEndpointManager gets the configuration from the tenant of the authority, CIAM doesn't support instance discovery
+type ciamOpenIDConfigurationEndpointManager struct{}
+
+func (m *ciamOpenIDConfigurationEndpointManager) getOpenIDConfigurationEndpoint(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error) {
 	return authorityInfo.CanonicalAuthorityURI + "v2.0/.well-known/openid-configuration", nil
 }
 
-func createOpenIDConfigurationEndpointManager(authorityInfo *msalbase.AuthorityInfo) (openIDConfigurationEndpointManager, error) {
+//adfsOpenIDConfigurationEndpointManager gets the configuration from the ADFS server, which has no tenants and isn't known to instance discovery
+type adfsOpenIDConfigurationEndpointManager struct{}
+
+func (m *adfsOpenIDConfigurationEndpointManager) getOpenIDConfigurationEndpoint(ctx context.Context, authorityInfo *msalbase.AuthorityInfo, userPrincipalName string) (string, error) {
+	return authorityInfo.CanonicalAuthorityURI + ".well-known/openid-configuration", nil
+}
+
+func createOpenIDConfigurationEndpointManager(authorityInfo *msalbase.AuthorityInfo, webRequestManager WebRequestManager) (openIDConfigurationEndpointManager, error) {
 	if authorityInfo.AuthorityType == msalbase.MSSTS {
-		return &aadOpenIDConfigurationEndpointManager{}, nil
+		return createAadOpenIDConfigurationEndpointManager(CreateAadInstanceDiscovery(webRequestManager)), nil
+	}
+	if authorityInfo.AuthorityType == msalbase.B2C {
+		return &b2cOpenIDConfigurationEndpointManager{}, nil
+	}
+	if authorityInfo.AuthorityType == msalbase.CIAM {
+		return &ciamOpenIDConfigurationEndpointManager{}, nil
+	}
+	if authorityInfo.AuthorityType == msalbase.ADFS {
+		return &adfsOpenIDConfigurationEndpointManager{}, nil
 	}
 
 	return nil, errors.New("unsupported authority type for createOpenIdConfigurationEndpointManager: " + string(authorityInfo.AuthorityType))
@@ -0,0 +1,47 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package requests
+
+import (
+	"context"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+//OnBehalfOfRequest stores the values required to exchange a user's access token for a token to a downstream API
+type OnBehalfOfRequest struct {
+	webRequestManager WebRequestManager
+	authParameters    *msalbase.AuthParametersInternal
+	clientCredential  *msalbase.ClientCredential
+}
+
+//CreateOnBehalfOfRequest creates an instance of OnBehalfOfRequest, the user assertion is taken from the authentication parameters
+func CreateOnBehalfOfRequest(
+	wrm WebRequestManager,
+	authParams *msalbase.AuthParametersInternal,
+	clientCred *msalbase.ClientCredential) *OnBehalfOfRequest {
+	return &OnBehalfOfRequest{wrm, authParams, clientCred}
+}
+
+//Execute performs the token acquisition request and returns a token response or an error
+func (req *OnBehalfOfRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
+	resolutionManager := CreateAuthorityEndpointResolutionManager(req.webRequestManager)
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
+	if err != nil {
+		return nil, err
+	}
+	req.authParameters.Endpoints = endpoints
+	params := make(map[string]string)
+	if req.clientCredential.GetCredentialType() == msalbase.ClientCredentialSecret {
+		params["client_secret"] = req.clientCredential.GetSecret()
+	} else {
+		jwt, err := req.clientCredential.GetAssertion().GetJWT(ctx, req.authParameters)
+		if err != nil {
+			return nil, err
+		}
+		params["client_assertion"] = jwt
+		params["client_assertion_type"] = msalbase.ClientAssertionGrant
+	}
+	return req.webRequestManager.GetAccessTokenOnBehalfOf(ctx, req.authParameters, req.authParameters.UserAssertion, params)
+}
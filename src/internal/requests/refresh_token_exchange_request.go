@@ -4,6 +4,7 @@
 package requests
 
 import (
+	"context"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
@@ -41,9 +42,9 @@ func CreateRefreshTokenExchangeRequest(
 }
 
 //Execute performs the token acquisition request and returns a token response or an error
-func (req *RefreshTokenExchangeRequest) Execute() (*msalbase.TokenResponse, error) {
+func (req *RefreshTokenExchangeRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
 	resolutionManager := CreateAuthorityEndpointResolutionManager(req.webRequestManager)
-	endpoints, err := resolutionManager.ResolveEndpoints(req.authParameters.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +54,7 @@ func (req *RefreshTokenExchangeRequest) Execute() (*msalbase.TokenResponse, erro
 		if req.ClientCredential.GetCredentialType() == msalbase.ClientCredentialSecret {
 			params["client_secret"] = req.ClientCredential.GetSecret()
 		} else {
-			jwt, err := req.ClientCredential.GetAssertion().GetJWT(req.authParameters)
+			jwt, err := req.ClientCredential.GetAssertion().GetJWT(ctx, req.authParameters)
 			if err != nil {
 				return nil, err
 			}
@@ -61,5 +62,5 @@ func (req *RefreshTokenExchangeRequest) Execute() (*msalbase.TokenResponse, erro
 			params["client_assertion_type"] = msalbase.ClientAssertionGrant
 		}
 	}
-	return req.webRequestManager.GetAccessTokenFromRefreshToken(req.authParameters, req.refreshToken.GetSecret(), params)
+	return req.webRequestManager.GetAccessTokenFromRefreshToken(ctx, req.authParameters, req.refreshToken.GetSecret(), params)
 }
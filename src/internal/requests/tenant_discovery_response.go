@@ -15,6 +15,7 @@ type TenantDiscoveryResponse struct {
 	AuthorizationEndpoint string `json:"authorization_endpoint"`
 	TokenEndpoint         string `json:"token_endpoint"`
 	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
 }
 
 func (r *TenantDiscoveryResponse) hasAuthorizationEndpoint() bool {
@@ -3,9 +3,13 @@
 
 package requests
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"context"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 //TokenRequester is an interface that handles all token acquisition requests
 type TokenRequester interface {
-	Execute() (*msalbase.TokenResponse, error)
+	Execute(ctx context.Context) (*msalbase.TokenResponse, error)
 }
@@ -4,6 +4,7 @@
 package requests
 
 import (
+	"context"
 	"errors"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -24,35 +25,36 @@ func CreateUsernamePasswordRequest(
 }
 
 // Execute stuff
-func (req *UsernamePasswordRequest) Execute() (*msalbase.TokenResponse, error) {
+func (req *UsernamePasswordRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
 
 	resolutionManager := CreateAuthorityEndpointResolutionManager(req.webRequestManager)
-	endpoints, err := resolutionManager.ResolveEndpoints(req.authParameters.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
 	if err != nil {
 		return nil, err
 	}
 
 	req.authParameters.Endpoints = endpoints
+	msalbase.GetLogger().Tracef("Acquiring a token by username/password with parameters %v", req.authParameters)
 
-	userRealm, err := req.webRequestManager.GetUserRealm(req.authParameters)
+	userRealm, err := req.webRequestManager.GetUserRealm(ctx, req.authParameters)
 	if err != nil {
 		return nil, err
 	}
 
 	switch accountType := userRealm.GetAccountType(); accountType {
 	case msalbase.Federated:
-		if mexDoc, err := req.webRequestManager.GetMex(userRealm.FederationMetadataURL); err == nil {
+		if mexDoc, err := req.webRequestManager.GetMex(ctx, userRealm.FederationMetadataURL); err == nil {
 			wsTrustEndpoint := mexDoc.UsernamePasswordEndpoint
-			if wsTrustResponse, err := req.webRequestManager.GetWsTrustResponse(req.authParameters, userRealm.CloudAudienceURN, &wsTrustEndpoint); err == nil {
+			if wsTrustResponse, err := req.webRequestManager.GetWsTrustResponse(ctx, req.authParameters, userRealm.CloudAudienceURN, &wsTrustEndpoint); err == nil {
 				if samlGrant, err := wsTrustResponse.GetSAMLAssertion(&wsTrustEndpoint); err == nil {
-					return req.webRequestManager.GetAccessTokenFromSamlGrant(req.authParameters, samlGrant)
+					return req.webRequestManager.GetAccessTokenFromSamlGrant(ctx, req.authParameters, samlGrant)
 				}
 			}
 		}
 		// todo: check for ui interaction in api result...
 		return nil, err
 	case msalbase.Managed:
-		return req.webRequestManager.GetAccessTokenFromUsernamePassword(req.authParameters)
+		return req.webRequestManager.GetAccessTokenFromUsernamePassword(ctx, req.authParameters)
 	default:
 		return nil, errors.New("unknown account type")
 	}
@@ -3,6 +3,7 @@
 package requests
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -12,7 +13,7 @@ import (
 )
 
 var testUPAuthorityInfo, _ = msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
-var testUPAuthParams = msalbase.CreateAuthParametersInternal("clientID", testUPAuthorityInfo)
+var testUPAuthParams, _ = msalbase.CreateAuthParametersInternal("clientID", testUPAuthorityInfo)
 var upWRM = new(MockWebRequestManager)
 var usernamePassRequest = &UsernamePasswordRequest{
 	authParameters: testUPAuthParams,
@@ -46,7 +47,7 @@ func TestUsernamePassExecuteWithFederated(t *testing.T) {
 	upWRM.On("GetMex", "fedMetaURL").Return(mexDoc, nil)
 	wsTrustResp := &wstrust.Response{}
 	upWRM.On("GetWsTrustResponse", testUPAuthParams, "", &wsEndpoint).Return(wsTrustResp, nil)
-	_, err := usernamePassRequest.Execute()
+	_, err := usernamePassRequest.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error should be nil, but is %v", err)
 	}
@@ -60,7 +61,7 @@ func TestUsernamePassExecuteWithManaged(t *testing.T) {
 	upWRM.On("GetUserRealm", usernamePassRequest.authParameters).Return(managedUserRealm, nil)
 	actualTokenResp := &msalbase.TokenResponse{}
 	upWRM.On("GetAccessTokenFromUsernamePassword", usernamePassRequest.authParameters).Return(actualTokenResp, nil)
-	_, err := usernamePassRequest.Execute()
+	_, err := usernamePassRequest.Execute(context.Background())
 	if err != nil {
 		t.Errorf("Error is supposed to be nil, instead it is %v", err)
 	}
@@ -72,7 +73,7 @@ func TestUsernamePassExecuteWithAcctError(t *testing.T) {
 	newUpWRM.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
 	newUpWRM.On("GetUserRealm", usernamePassRequest.authParameters).Return(errorUserRealm, nil)
-	_, acctError := usernamePassRequest.Execute()
+	_, acctError := usernamePassRequest.Execute(context.Background())
 	expectedErrorMessage := "unknown account type"
 	if acctError == nil {
 		t.Errorf("Error is nil, should be %v", errors.New(expectedErrorMessage))
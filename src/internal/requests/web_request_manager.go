@@ -4,23 +4,28 @@
 package requests
 
 import (
+	"context"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/wstrust"
 )
 
 // WebRequestManager interface
 type WebRequestManager interface {
-	GetUserRealm(authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error)
-	GetMex(federationMetadataURL string) (*wstrust.MexDocument, error)
-	GetWsTrustResponse(authParameters *msalbase.AuthParametersInternal, cloudAudienceURN string, endpoint *wstrust.Endpoint) (*wstrust.Response, error)
-	GetAccessTokenFromSamlGrant(authParameters *msalbase.AuthParametersInternal, samlGrant *wstrust.SamlTokenInfo) (*msalbase.TokenResponse, error)
-	GetAccessTokenFromUsernamePassword(authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error)
-	GetAccessTokenFromAuthCode(authParameters *msalbase.AuthParametersInternal, authCode string, codeVerifier string, params map[string]string) (*msalbase.TokenResponse, error)
-	GetAccessTokenFromRefreshToken(authParameters *msalbase.AuthParametersInternal, refreshToken string, params map[string]string) (*msalbase.TokenResponse, error)
-	GetAccessTokenWithClientSecret(authParameters *msalbase.AuthParametersInternal, clientSecret string) (*msalbase.TokenResponse, error)
-	GetAccessTokenWithAssertion(authParameters *msalbase.AuthParametersInternal, assertion string) (*msalbase.TokenResponse, error)
-	GetDeviceCodeResult(authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error)
-	GetAccessTokenFromDeviceCodeResult(authParameters *msalbase.AuthParametersInternal, deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error)
-	GetTenantDiscoveryResponse(openIDConfigurationEndpoint string) (*TenantDiscoveryResponse, error)
-	GetAadinstanceDiscoveryResponse(authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryResponse, error)
+	GetUserRealm(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error)
+	GetMex(ctx context.Context, federationMetadataURL string) (*wstrust.MexDocument, error)
+	GetWsTrustResponse(ctx context.Context, authParameters *msalbase.AuthParametersInternal, cloudAudienceURN string, endpoint *wstrust.Endpoint) (*wstrust.Response, error)
+	GetAccessTokenFromSamlGrant(ctx context.Context, authParameters *msalbase.AuthParametersInternal, samlGrant *wstrust.SamlTokenInfo) (*msalbase.TokenResponse, error)
+	GetAccessTokenFromUsernamePassword(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error)
+	GetAccessTokenFromAuthCode(ctx context.Context, authParameters *msalbase.AuthParametersInternal, authCode string, codeVerifier string, params map[string]string) (*msalbase.TokenResponse, error)
+	GetAccessTokenFromRefreshToken(ctx context.Context, authParameters *msalbase.AuthParametersInternal, refreshToken string, params map[string]string) (*msalbase.TokenResponse, error)
+	GetAccessTokenWithClientSecret(ctx context.Context, authParameters *msalbase.AuthParametersInternal, clientSecret string) (*msalbase.TokenResponse, error)
+	GetAccessTokenWithAssertion(ctx context.Context, authParameters *msalbase.AuthParametersInternal, assertion string) (*msalbase.TokenResponse, error)
+	GetAccessTokenOnBehalfOf(ctx context.Context, authParameters *msalbase.AuthParametersInternal, userAssertion string, params map[string]string) (*msalbase.TokenResponse, error)
+	GetDeviceCodeResult(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error)
+	GetAccessTokenFromDeviceCodeResult(ctx context.Context, authParameters *msalbase.AuthParametersInternal, deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error)
+	GetTenantDiscoveryResponse(ctx context.Context, openIDConfigurationEndpoint string) (*TenantDiscoveryResponse, error)
+	GetAadinstanceDiscoveryResponse(ctx context.Context, authorityInfo *msalbase.AuthorityInfo) (*InstanceDiscoveryResponse, error)
+	GetJSONWebKeySet(ctx context.Context, jwksURI string) (*JSONWebKeySet, error)
+	GetAzureRegion(ctx context.Context) (string, error)
+	GetAccessTokenFromManagedIdentity(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error)
 }
@@ -22,6 +22,9 @@ type accessTokenCacheItem struct {
 	ExpiresOnUnixTimestamp         *string `json:"expires_on,omitempty"`
 	ExtendedExpiresOnUnixTimestamp *string `json:"extended_expires_on,omitempty"`
 	CachedAt                       *string `json:"cached_at,omitempty"`
+	TokenType                      *string `json:"token_type,omitempty"`
+	KeyID                          *string `json:"key_id,omitempty"`
+	RequestedScopes                *string `json:"requested_target,omitempty"`
 	additionalFields               map[string]interface{}
 }
 
@@ -61,6 +64,10 @@ func (s *accessTokenCacheItem) CreateKey() string {
 		msalbase.GetStringFromPointer(s.ClientID),
 		msalbase.GetStringFromPointer(s.Realm),
 		msalbase.GetStringFromPointer(s.Scopes)}
+	// A token bound to a proof-of-possession key doesn't replace the bearer token or tokens bound to other keys
+	if keyID := msalbase.GetStringFromPointer(s.KeyID); keyID != "" {
+		keyParts = append(keyParts, keyID)
+	}
 	return strings.Join(keyParts, msalbase.CacheKeySeparator)
 }
 
@@ -76,6 +83,34 @@ func (s *accessTokenCacheItem) GetScopes() string {
 	return msalbase.GetStringFromPointer(s.Scopes)
 }
 
+//GetTokenType returns the type of the access token, tokens cached without one are bearer tokens
+func (s *accessTokenCacheItem) GetTokenType() string {
+	if tokenType := msalbase.GetStringFromPointer(s.TokenType); tokenType != "" {
+		return tokenType
+	}
+	return msalbase.BearerTokenType
+}
+
+//GetRequestedScopes returns the scopes the token was requested for, they are the granted scopes when none were kept
+func (s *accessTokenCacheItem) GetRequestedScopes() string {
+	if s.RequestedScopes == nil {
+		return s.GetScopes()
+	}
+	return *s.RequestedScopes
+}
+
+func (s *accessTokenCacheItem) GetExtendedExpiresOn() string {
+	return msalbase.GetStringFromPointer(s.ExtendedExpiresOnUnixTimestamp)
+}
+
+//bindToKey marks the access token as a proof-of-possession token bound to the key
+func (s *accessTokenCacheItem) bindToKey(key *msalbase.PoPKey) {
+	tokenType := msalbase.PoPTokenType
+	keyID := key.GetKeyID()
+	s.TokenType = &tokenType
+	s.KeyID = &keyID
+}
+
 func (s *accessTokenCacheItem) populateFromJSONMap(j map[string]interface{}) error {
 
 	s.HomeAccountID = msalbase.ExtractStringPointerForCache(j, msalbase.JSONHomeAccountID)
@@ -88,6 +123,9 @@ func (s *accessTokenCacheItem) populateFromJSONMap(j map[string]interface{}) err
 	s.CachedAt = msalbase.ExtractStringPointerForCache(j, msalbase.JSONCachedAt)
 	s.ExpiresOnUnixTimestamp = msalbase.ExtractStringPointerForCache(j, msalbase.JSONExpiresOn)
 	s.ExtendedExpiresOnUnixTimestamp = msalbase.ExtractStringPointerForCache(j, msalbase.JSONExtExpiresOn)
+	s.TokenType = msalbase.ExtractStringPointerForCache(j, msalbase.JSONTokenType)
+	s.KeyID = msalbase.ExtractStringPointerForCache(j, msalbase.JSONKeyID)
+	s.RequestedScopes = msalbase.ExtractStringPointerForCache(j, msalbase.JSONRequestedTarget)
 	s.additionalFields = j
 	return nil
 }
@@ -5,10 +5,56 @@ package tokencache
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
+//cacheSchemaVersionKey is the top level key of the schema version, caches serialized before it was added have none and are version 1
+const cacheSchemaVersionKey = "CacheSchemaVersion"
+
+//cacheSchemaVersion is the version the cache is serialized with, a newer minor version only adds entries that older readers keep as they are
+const (
+	cacheSchemaMajorVersion = 2
+	cacheSchemaVersion      = "2.0"
+)
+
+//ErrUnsupportedCacheVersion is returned when a serialized cache has a major schema version newer than this library reads
+var ErrUnsupportedCacheVersion = errors.New("serialized cache has an unsupported schema version")
+
+//getCacheSchemaMajorVersion returns the major schema version of the serialized cache, 1 if it has no version
+func getCacheSchemaMajorVersion(j map[string]interface{}) (int, error) {
+	value, ok := j[cacheSchemaVersionKey]
+	if !ok {
+		return 1, nil
+	}
+	version, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("%w: %v isn't a version", ErrUnsupportedCacheVersion, value)
+	}
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s isn't a version", ErrUnsupportedCacheVersion, version)
+	}
+	if major < 1 || major > cacheSchemaMajorVersion {
+		return 0, fmt.Errorf("%w: version %s can't be read, the newest supported major version is %d", ErrUnsupportedCacheVersion, version, cacheSchemaMajorVersion)
+	}
+	return major, nil
+}
+
+//migrateFromVersion1 fills in what version 1 caches may lack, access tokens written without an extended expiry expire at their expiry
+func (s *cacheSerializationContract) migrateFromVersion1() {
+	for _, accessToken := range s.AccessTokens {
+		if accessToken.ExtendedExpiresOnUnixTimestamp == nil && accessToken.ExpiresOnUnixTimestamp != nil {
+			extendedExpiresOn := *accessToken.ExpiresOnUnixTimestamp
+			accessToken.ExtendedExpiresOnUnixTimestamp = &extendedExpiresOn
+		}
+	}
+}
+
 type cacheSerializationContract struct {
 	AccessTokens  map[string]*accessTokenCacheItem  `json:"AccessToken"`
 	RefreshTokens map[string]*refreshTokenCacheItem `json:"RefreshToken"`
@@ -41,6 +87,11 @@ func (s *cacheSerializationContract) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+	version, err := getCacheSchemaMajorVersion(j)
+	if err != nil {
+		return err
+	}
+	delete(j, cacheSchemaVersionKey)
 	for jsonKey := range j {
 		if jsonKey == "AccessToken" {
 			if accessTokens, ok := j["AccessToken"].(map[string]interface{}); ok {
@@ -96,11 +147,20 @@ func (s *cacheSerializationContract) UnmarshalJSON(data []byte) error {
 			s.snapshot[jsonKey] = j[jsonKey]
 		}
 	}
+	if version == 1 {
+		s.migrateFromVersion1()
+	}
 	return nil
 }
 
+//MarshalJSON serializes the cache with its entities and their fields in the sorted order of their keys,
+//encoding/json sorts the keys of maps so the same cache is always the same bytes and files of it diff cleanly.
+//The entities are converted to maps for that reason too, instead of being marshaled in the order of their struct fields.
 func (s *cacheSerializationContract) MarshalJSON() ([]byte, error) {
-	j := s.snapshot
+	j := make(map[string]interface{})
+	for k, v := range s.snapshot {
+		j[k] = v
+	}
 	accessTokens := make(map[string]interface{})
 	for k, v := range s.AccessTokens {
 		jsonNode, err := v.convertToJSONMap()
@@ -141,5 +201,6 @@ func (s *cacheSerializationContract) MarshalJSON() ([]byte, error) {
 		}
 	}
 	j["AppMetadata"] = appMetadatas
+	j[cacheSchemaVersionKey] = cacheSchemaVersion
 	return json.Marshal(j)
 }
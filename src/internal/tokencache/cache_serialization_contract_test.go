@@ -4,9 +4,12 @@
 package tokencache
 
 import (
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -181,3 +184,121 @@ func TestCacheSerializationContractMarshalJSON(t *testing.T) {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestCacheSerializationContractVersion1(t *testing.T) {
+	v1 := `{
+		"AccessToken": {
+			"uid.utid-login.windows.net-accesstoken-my_client_id-contoso-s1": {
+				"home_account_id": "uid.utid", "environment": "login.windows.net", "credential_type": "AccessToken",
+				"client_id": "my_client_id", "realm": "contoso", "target": "s1", "secret": "an access token",
+				"cached_at": "1000", "expires_on": "4600"
+			}
+		},
+		"unknownEntity": {"field1": "1"}
+	}`
+	contract := createCacheSerializationContract()
+	if err := contract.UnmarshalJSON([]byte(v1)); err != nil {
+		t.Fatalf("Error is supposed to be nil, but it is %v", err)
+	}
+	accessToken := contract.AccessTokens["uid.utid-login.windows.net-accesstoken-my_client_id-contoso-s1"]
+	if accessToken == nil || accessToken.GetExtendedExpiresOn() != "4600" {
+		t.Fatalf("The access token of a version 1 cache should expire at its expiry when it has no extended expiry, instead it is %+v", accessToken)
+	}
+	data, err := contract.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	current := createCacheSerializationContract()
+	if err := current.UnmarshalJSON(data); err != nil {
+		t.Fatalf("Error is supposed to be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(current.AccessTokens, contract.AccessTokens) {
+		t.Errorf("Expected access tokens %+v differ from actual access tokens %+v", contract.AccessTokens, current.AccessTokens)
+	}
+	if !reflect.DeepEqual(current.snapshot, map[string]interface{}{"unknownEntity": map[string]interface{}{"field1": "1"}}) {
+		t.Errorf("Unknown entities should round trip, instead the snapshot is %+v", current.snapshot)
+	}
+	if _, ok := current.snapshot[cacheSchemaVersionKey]; ok {
+		t.Error("The schema version shouldn't be kept as an unknown entity")
+	}
+}
+
+func TestCacheSerializationContractCurrentVersion(t *testing.T) {
+	contract := createCacheSerializationContract()
+	data, err := contract.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !strings.Contains(string(data), `"CacheSchemaVersion":"2.0"`) {
+		t.Errorf("The cache should be serialized with its schema version, instead it is %s", data)
+	}
+	tests := []struct {
+		version   string
+		supported bool
+	}{
+		{`"2.0"`, true},
+		{`"2.3"`, true},
+		{`"3.0"`, false},
+		{`"x"`, false},
+		{`2`, false},
+	}
+	for _, test := range tests {
+		blob := `{"CacheSchemaVersion":` + test.version + `,"AccessToken":{}}`
+		err := createCacheSerializationContract().UnmarshalJSON([]byte(blob))
+		if test.supported && err != nil {
+			t.Errorf("Version %s should be read, but the error is %v", test.version, err)
+		}
+		if !test.supported && !errors.Is(err, ErrUnsupportedCacheVersion) {
+			t.Errorf("Version %s should be rejected with ErrUnsupportedCacheVersion, instead the error is %v", test.version, err)
+		}
+	}
+}
+
+func TestCacheSerializationContractMarshalJSONDeterministic(t *testing.T) {
+	createStorageManager := func(homeAccountIDs []string) StorageManager {
+		storageManager := CreateStorageManager()
+		for _, homeAccountID := range homeAccountIDs {
+			storageManager.WriteAccessToken(createAccessTokenCacheItem(homeAccountID, "env", "realm", "cid", 1, 2, 3, "openid", "secret-"+homeAccountID))
+			storageManager.WriteRefreshToken(createRefreshTokenCacheItem(homeAccountID, "env", "cid", "secret-"+homeAccountID, ""))
+			storageManager.WriteIDToken(createIDTokenCacheItem(homeAccountID, "env", "realm", "cid", "secret-"+homeAccountID))
+			storageManager.WriteAccount(msalbase.CreateAccount(homeAccountID, "env", "realm", "lid", msalbase.MSSTS, homeAccountID+"@contoso.com"))
+		}
+		storageManager.WriteAppMetadata(createAppMetadata("", "cid", "env"))
+		return storageManager
+	}
+	homeAccountIDs := []string{"c.utid", "a.utid", "e.utid", "b.utid", "d.utid"}
+	storageManager := createStorageManager(homeAccountIDs)
+	expected, err := storageManager.Serialize()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		actual, err := storageManager.Serialize()
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Fatalf("Serializing the same cache again should be byte-identical, instead %s differs from %s", actual, expected)
+		}
+	}
+	// The order the entities were written in doesn't matter either
+	reversed := make([]string, len(homeAccountIDs))
+	for i, homeAccountID := range homeAccountIDs {
+		reversed[len(homeAccountIDs)-1-i] = homeAccountID
+	}
+	actual, err := createStorageManager(reversed).Serialize()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Serializing the same entities written in another order should be byte-identical, instead %s differs from %s", actual, expected)
+	}
+	previous := -1
+	for _, key := range []string{`"AccessToken"`, `"Account"`, `"AppMetadata"`, `"CacheSchemaVersion"`, `"IdToken"`, `"RefreshToken"`} {
+		index := bytes.Index(expected, []byte(key))
+		if index <= previous {
+			t.Errorf("The key %s should come after the keys sorted before it", key)
+		}
+		previous = index
+	}
+}
@@ -4,108 +4,573 @@
 package tokencache
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
-
-	log "github.com/sirupsen/logrus"
 )
 
+//ErrCacheKeyIncomplete is returned when the cache is skipped because one of its primary keys is empty
+//Callers can treat it as a cache miss rather than a failure
+var ErrCacheKeyIncomplete = errors.New("one of the primary keys of the cache is empty")
+
+//CacheKeyError names the primary key that was empty, it matches ErrCacheKeyIncomplete with errors.Is
+type CacheKeyError struct {
+	Key string
+}
+
+func (e *CacheKeyError) Error() string {
+	return fmt.Sprintf("the cache primary key '%s' is empty", e.Key)
+}
+
+//Unwrap returns ErrCacheKeyIncomplete
+func (e *CacheKeyError) Unwrap() error {
+	return ErrCacheKeyIncomplete
+}
+
+//ClearError holds every error of deleting the entities of the cache, the other entities were still deleted
+type ClearError struct {
+	Errors []error
+}
+
+func (e *ClearError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return "failed to clear the cache: " + strings.Join(messages, "; ")
+}
+
+//defaultExpirationBuffer is how long before it expires an access token is no longer returned from the cache
+const defaultExpirationBuffer = 5 * time.Minute
+
+//defaultCacheManager is safe for concurrent use, lookups share its lock while writes and removals hold it exclusively
 type defaultCacheManager struct {
+	//lock makes sure the tokens of an account are never read while they are partially written
+	lock           sync.RWMutex
 	storageManager StorageManager
+	//partitions holds the tokens written with a partition key, the tokens without one are in storageManager
+	partitions map[string]StorageManager
+	//expirationBuffer is subtracted from the expiry of an access token when it's validated
+	expirationBuffer time.Duration
+	//expirationOffset is added to the current time when the expiry of a cached access token is checked on a read, it ages the tokens for tests of refresh paths
+	expirationOffset time.Duration
+	//nowFunc returns the current time, every time read of the cache manager goes through it so tests can pin the clock
+	nowFunc func() time.Time
+	//maxAccounts is how many accounts each partition holds before the least recently used one is evicted, 0 doesn't limit them
+	maxAccounts int
+	//accessLock guards the access tracking, which lookups update while they share lock
+	accessLock sync.Mutex
+	//accountAccess orders the accounts by when their tokens were last read or written, it's only tracked when maxAccounts is set
+	accountAccess map[accountAccessKey]uint64
+	accessCount   uint64
+}
+
+//accountAccessKey identifies an account within a partition of the cache
+type accountAccessKey struct {
+	partitionKey  string
+	homeAccountID string
 }
 
-//CreateCacheManager creates a defaultCacheManager instance
+//CreateCacheManager creates a defaultCacheManager instance, the cache manager is safe for concurrent use
 func CreateCacheManager(storageManager StorageManager) requests.CacheManager {
-	cache := &defaultCacheManager{storageManager: storageManager}
+	cache := &defaultCacheManager{storageManager: storageManager, expirationBuffer: defaultExpirationBuffer, nowFunc: time.Now}
 	return cache
 }
 
-func isAccessTokenValid(accessToken *accessTokenCacheItem) bool {
+//getPartition returns the storage manager of the partition, only writes create a missing partition so it's nil for reads of an empty partition
+//The caller holds the lock, exclusively when create is set
+func (m *defaultCacheManager) getPartition(partitionKey string, create bool) StorageManager {
+	if partitionKey == "" {
+		return m.storageManager
+	}
+	partition, ok := m.partitions[partitionKey]
+	if !ok && create {
+		if m.partitions == nil {
+			m.partitions = make(map[string]StorageManager)
+		}
+		partition = CreateStorageManager()
+		m.partitions[partitionKey] = partition
+	}
+	return partition
+}
+
+//SetTokenExpirationOffset adds the offset to the current time when the expiry of a cached access token is read, so tokens are treated as older than they are
+//Unlike the expiration buffer it's a shift of the clock, the time a token was cached at is still checked against the actual time
+//Tokens are still written when they expire within the offset, so a test can age the token it just acquired
+func (m *defaultCacheManager) SetTokenExpirationOffset(offset time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.expirationOffset = offset
+}
+
+//SetMaxAccounts limits how many accounts each partition of the cache holds, 0 or less doesn't limit them
+//The limit is applied as tokens are written, so a cache that already holds more accounts shrinks on its next write
+func (m *defaultCacheManager) SetMaxAccounts(maxAccounts int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if maxAccounts < 0 {
+		maxAccounts = 0
+	}
+	m.maxAccounts = maxAccounts
+	if maxAccounts == 0 {
+		m.accessLock.Lock()
+		m.accountAccess = nil
+		m.accessLock.Unlock()
+	}
+}
+
+//recordAccountAccess marks the account as the most recently used one of the partition
+//The caller holds the lock, shared or exclusively
+func (m *defaultCacheManager) recordAccountAccess(partitionKey string, homeAccountID string) {
+	if m.maxAccounts == 0 || homeAccountID == "" {
+		return
+	}
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+	if m.accountAccess == nil {
+		m.accountAccess = make(map[accountAccessKey]uint64)
+	}
+	m.accessCount++
+	m.accountAccess[accountAccessKey{partitionKey: partitionKey, homeAccountID: homeAccountID}] = m.accessCount
+}
+
+//evictAccounts deletes the least recently used accounts of the partition until it holds no more than maxAccounts,
+//with all of their access, refresh and ID tokens. Accounts that were never used since they were loaded are evicted first.
+//The caller holds the lock exclusively
+func (m *defaultCacheManager) evictAccounts(storageManager StorageManager, partitionKey string) error {
+	if m.maxAccounts == 0 {
+		return nil
+	}
+	environments := getAccountEnvironments(storageManager)
+	if len(environments) <= m.maxAccounts {
+		return nil
+	}
+	homeAccountIDs := make([]string, 0, len(environments))
+	for homeAccountID := range environments {
+		homeAccountIDs = append(homeAccountIDs, homeAccountID)
+	}
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+	lastAccess := func(homeAccountID string) uint64 {
+		return m.accountAccess[accountAccessKey{partitionKey: partitionKey, homeAccountID: homeAccountID}]
+	}
+	sort.Slice(homeAccountIDs, func(i, j int) bool {
+		if lastAccess(homeAccountIDs[i]) != lastAccess(homeAccountIDs[j]) {
+			return lastAccess(homeAccountIDs[i]) < lastAccess(homeAccountIDs[j])
+		}
+		return homeAccountIDs[i] < homeAccountIDs[j]
+	})
+	credentialTypes := map[string]bool{
+		msalbase.CredentialTypeAccessToken:  true,
+		msalbase.CredentialTypeRefreshToken: true,
+		msalbase.CredentialTypeIDToken:      true,
+	}
+	for _, homeAccountID := range homeAccountIDs[:len(homeAccountIDs)-m.maxAccounts] {
+		aliases := environments[homeAccountID]
+		msalbase.GetLogger().Infof("Evicting the least recently used account with homeAccountId '%s' environments '%v' from the cache", msalbase.PII(homeAccountID), aliases)
+		if err := storageManager.DeleteCredentials(homeAccountID, aliases, "", credentialTypes); err != nil {
+			return err
+		}
+		if err := storageManager.DeleteAccounts(homeAccountID, aliases); err != nil && err != errAccountNotFound {
+			return err
+		}
+		delete(m.accountAccess, accountAccessKey{partitionKey: partitionKey, homeAccountID: homeAccountID})
+	}
+	return nil
+}
+
+//getAccountEnvironments returns the environments that each account of the storage has an account entity or tokens in,
+//app tokens have no home account ID so they don't count as an account
+func getAccountEnvironments(storageManager StorageManager) map[string][]string {
+	environments := make(map[string][]string)
+	add := func(homeAccountID *string, environment *string) {
+		id := msalbase.GetStringFromPointer(homeAccountID)
+		if id == "" {
+			return
+		}
+		env := msalbase.GetStringFromPointer(environment)
+		if !checkAlias(env, environments[id]) {
+			environments[id] = append(environments[id], env)
+		}
+	}
+	for _, account := range storageManager.ReadAllAccounts() {
+		add(account.HomeAccountID, account.Environment)
+	}
+	for _, accessToken := range storageManager.ReadAllAccessTokens() {
+		add(accessToken.HomeAccountID, accessToken.Environment)
+	}
+	for _, refreshToken := range storageManager.ReadAllRefreshTokens() {
+		add(refreshToken.HomeAccountID, refreshToken.Environment)
+	}
+	for _, idToken := range storageManager.ReadAllIDTokens() {
+		add(idToken.HomeAccountID, idToken.Environment)
+	}
+	return environments
+}
+
+func (m *defaultCacheManager) now() time.Time {
+	if m.nowFunc == nil {
+		return time.Now()
+	}
+	return m.nowFunc()
+}
+
+//isAccessTokenValid checks that the access token doesn't expire within the expiration buffer
+//When allowExtendedExpiry is set, the extended expiry of the token is used instead, this is only done when the authority can't be reached
+func (m *defaultCacheManager) isAccessTokenValid(accessToken *accessTokenCacheItem, allowExtendedExpiry bool) bool {
+	return m.isAccessTokenValidWithBuffer(accessToken, allowExtendedExpiry, m.expirationBuffer)
+}
+
+//isAccessTokenValidWithBuffer checks that the access token doesn't expire within the buffer, a zero buffer checks the actual expiry
+func (m *defaultCacheManager) isAccessTokenValidWithBuffer(accessToken *accessTokenCacheItem, allowExtendedExpiry bool, expirationBuffer time.Duration) bool {
 	cachedAt, err := strconv.ParseInt(*accessToken.CachedAt, 10, 64)
 	if err != nil {
-		log.Info("This access token isn't valid, it was cached at an invalid time.")
+		msalbase.GetLogger().Info("This access token isn't valid, it was cached at an invalid time.")
 		return false
 	}
-	now := time.Now().Unix()
+	now := m.now().Unix()
 	if cachedAt > now {
-		log.Info("This access token isn't valid, it was cached at an invalid time.")
+		msalbase.GetLogger().Info("This access token isn't valid, it was cached at an invalid time.")
+		return false
+	}
+	expiresOnTimestamp := accessToken.ExpiresOnUnixTimestamp
+	if allowExtendedExpiry {
+		expiresOnTimestamp = accessToken.ExtendedExpiresOnUnixTimestamp
+	}
+	if expiresOnTimestamp == nil {
+		msalbase.GetLogger().Info("This access token isn't valid, it expires at an invalid time.")
 		return false
 	}
-	expiresOn, err := strconv.ParseInt(*accessToken.ExpiresOnUnixTimestamp, 10, 64)
+	expiresOn, err := strconv.ParseInt(*expiresOnTimestamp, 10, 64)
 	if err != nil {
-		log.Info("This access token isn't valid, it expires at an invalid time.")
+		msalbase.GetLogger().Info("This access token isn't valid, it expires at an invalid time.")
 		return false
 	}
-	if expiresOn <= now+300 {
-		log.Info("This access token is expired")
+	if expiresOn <= now+int64(expirationBuffer/time.Second) {
+		msalbase.GetLogger().Info("This access token is expired")
 		return false
 	}
 	return true
 }
 
+//getCacheRealm returns the realm that tokens from the authority are cached under
+//B2C tokens include the policy so that the tokens of different user flows in a tenant are kept apart
+//ADFS has no tenants, so its tokens are cached without a realm
+func getCacheRealm(authorityInfo *msalbase.AuthorityInfo) string {
+	if authorityInfo.AuthorityType == msalbase.B2C {
+		return authorityInfo.Tenant + "/" + authorityInfo.Policy
+	}
+	if authorityInfo.AuthorityType == msalbase.ADFS {
+		return ""
+	}
+	return authorityInfo.Tenant
+}
+
+//getCacheEnvironment returns the environment tokens are written with, tokens of a regional endpoint are kept apart from the global ones
+func getCacheEnvironment(authParameters *msalbase.AuthParametersInternal) string {
+	if authParameters.Region != "" && authParameters.AuthorityInfo.Host != "" {
+		return msalbase.GetRegionalHost(authParameters.AuthorityInfo.Host, authParameters.Region)
+	}
+	// The metadata is the one the lookups of the request cached, the host is kept when the authority wasn't discovered
+	if authParameters.NormalizeCacheEnvironment && authParameters.AuthorityInfo.Host != "" {
+		if preferredCache := requests.GetCachedMetadataEntry(authParameters.AuthorityInfo).PreferredCache; preferredCache != "" {
+			return preferredCache
+		}
+	}
+	return authParameters.AuthorityInfo.Host
+}
+
+//getCacheAliases returns the environments a cache lookup for the host matches, the host is included even when instance discovery doesn't list it
+func getCacheAliases(metadata *requests.InstanceDiscoveryMetadata, host string) []string {
+	if checkAlias(host, metadata.Aliases) {
+		return metadata.Aliases
+	}
+	return append([]string{host}, metadata.Aliases...)
+}
+
+//checkCacheKeys checks the primary keys that every cache lookup and write needs
+func checkCacheKeys(environment string, clientID string) error {
+	if environment == "" {
+		return &CacheKeyError{Key: "environment"}
+	}
+	if clientID == "" {
+		return &CacheKeyError{Key: "clientID"}
+	}
+	return nil
+}
+
+//GetAllAccounts returns the accounts of the cache and of its partitions
 func (m *defaultCacheManager) GetAllAccounts() []*msalbase.Account {
-	return m.storageManager.ReadAllAccounts()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.readAllAccounts()
+}
+
+//readAllAccounts returns the accounts of the cache and of its partitions, an account written to several of them is returned once
+//The caller holds the lock
+func (m *defaultCacheManager) readAllAccounts() []*msalbase.Account {
+	accounts := m.storageManager.ReadAllAccounts()
+	if len(m.partitions) == 0 {
+		return accounts
+	}
+	seen := map[string]bool{}
+	for _, account := range accounts {
+		seen[account.CreateKey()] = true
+	}
+	partitionKeys := make([]string, 0, len(m.partitions))
+	for partitionKey := range m.partitions {
+		partitionKeys = append(partitionKeys, partitionKey)
+	}
+	sort.Strings(partitionKeys)
+	for _, partitionKey := range partitionKeys {
+		for _, account := range m.partitions[partitionKey].ReadAllAccounts() {
+			if key := account.CreateKey(); !seen[key] {
+				seen[key] = true
+				accounts = append(accounts, account)
+			}
+		}
+	}
+	return accounts
+}
+
+//GetAccount returns the account with the home account ID in any of the environment aliases of the authority,
+//it returns nil if there's no such account
+func (m *defaultCacheManager) GetAccount(ctx context.Context, homeAccountID string, authorityInfo *msalbase.AuthorityInfo, webRequestManager requests.WebRequestManager) (*msalbase.Account, error) {
+	aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
+	metadata, err := aadInstanceDiscovery.GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		return nil, err
+	}
+	aliases := getCacheAliases(metadata, authorityInfo.Host)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, account := range m.readAllAccounts() {
+		if account.GetHomeAccountID() == homeAccountID && checkAlias(account.GetEnvironment(), aliases) {
+			return account, nil
+		}
+	}
+	return nil, nil
 }
 
-func (m *defaultCacheManager) Serialize() (string, error) {
-	return m.storageManager.Serialize()
+//partitionsKey is the top level key of the partitions in the serialized cache, each partition is a cache of its own in the unified format
+const partitionsKey = "Partitions"
+
+//SerializeCache converts all the cached entries to the unified MSAL JSON cache format, the partitions are written under partitionsKey
+func (m *defaultCacheManager) SerializeCache() ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	data, err := m.storageManager.Serialize()
+	if err != nil || len(m.partitions) == 0 {
+		return data, err
+	}
+	j := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	partitions := make(map[string]json.RawMessage, len(m.partitions))
+	for partitionKey, partition := range m.partitions {
+		partitionData, err := partition.Serialize()
+		if err != nil {
+			return nil, fmt.Errorf("partition %s can't be serialized: %w", msalbase.PII(partitionKey), err)
+		}
+		partitions[partitionKey] = partitionData
+	}
+	if j[partitionsKey], err = json.Marshal(partitions); err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
 }
 
-func (m *defaultCacheManager) Deserialize(data []byte) error {
-	return m.storageManager.Deserialize(data)
+//DeserializeCache merges the entries of a unified MSAL JSON cache into the existing cache, including the entries of its partitions
+func (m *defaultCacheManager) DeserializeCache(data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	j := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &j); err != nil {
+		// The storage manager returns the error of the malformed cache
+		return m.storageManager.Deserialize(data)
+	}
+	partitionsData, ok := j[partitionsKey]
+	if !ok {
+		return m.storageManager.Deserialize(data)
+	}
+	partitions := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(partitionsData, &partitions); err != nil {
+		return fmt.Errorf("the partitions of the serialized cache can't be read: %w", err)
+	}
+	// The partitions aren't kept as an unknown entry of the cache, they're written from the partitions on the next serialization
+	delete(j, partitionsKey)
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	if err := m.storageManager.Deserialize(data); err != nil {
+		return err
+	}
+	for partitionKey, partitionData := range partitions {
+		if err := m.getPartition(partitionKey, true).Deserialize(partitionData); err != nil {
+			return fmt.Errorf("partition %s can't be deserialized: %w", msalbase.PII(partitionKey), err)
+		}
+	}
+	return nil
 }
 
-func (m *defaultCacheManager) TryReadCache(authParameters *msalbase.AuthParametersInternal, webRequestManager requests.WebRequestManager) (*msalbase.StorageTokenResponse, error) {
+func (m *defaultCacheManager) TryReadCache(ctx context.Context, authParameters *msalbase.AuthParametersInternal, webRequestManager requests.WebRequestManager) (*msalbase.StorageTokenResponse, error) {
 	homeAccountID := authParameters.HomeaccountID
-	realm := authParameters.AuthorityInfo.Tenant
+	realm := getCacheRealm(authParameters.AuthorityInfo)
 	clientID := authParameters.ClientID
-	scopes := authParameters.Scopes
-	aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
-	metadata, err := aadInstanceDiscovery.GetMetadataEntry(authParameters.AuthorityInfo)
-	if err != nil {
+	scopes := msalbase.NormalizeScopes(authParameters.Scopes)
+	if err := checkCacheKeys(authParameters.AuthorityInfo.Host, clientID); err != nil {
+		msalbase.GetLogger().Warnf("Skipping the tokens cache lookup: %v", err)
 		return nil, err
 	}
-	log.Infof("Querying the cache for homeAccountId '%s' environments '%v' realm '%s' clientId '%s' scopes:'%v'", homeAccountID, metadata.Aliases, realm, clientID, scopes)
+	var aliases []string
+	if authParameters.Region != "" {
+		// The regional host isn't an alias of the authority, so only its own tokens are read
+		aliases = []string{getCacheEnvironment(authParameters)}
+	} else {
+		aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
+		metadata, err := aadInstanceDiscovery.GetMetadataEntry(ctx, authParameters.AuthorityInfo)
+		if err != nil {
+			return nil, err
+		}
+		aliases = getCacheAliases(metadata, authParameters.AuthorityInfo.Host)
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	msalbase.GetLogger().Infof("Querying the cache for homeAccountId '%s' environments '%v' realm '%s' clientId '%s' scopes:'%v'", msalbase.PII(homeAccountID), aliases, msalbase.PII(realm), clientID, msalbase.PIIList(scopes))
 
-	accessToken := m.storageManager.ReadAccessToken(homeAccountID, metadata.Aliases, realm, clientID, scopes)
+	storageManager := m.getPartition(authParameters.PartitionKey, false)
+	if storageManager == nil {
+		msalbase.GetMetrics().OnCacheMiss()
+		return msalbase.CreateStorageTokenResponse((*accessTokenCacheItem)(nil), (*refreshTokenCacheItem)(nil), (*idTokenCacheItem)(nil), nil), nil
+	}
+	keyID := ""
+	if authParameters.PoPKey != nil {
+		keyID = authParameters.PoPKey.GetKeyID()
+	}
+	accessToken := storageManager.ReadAccessToken(homeAccountID, aliases, realm, clientID, scopes, keyID)
 	if accessToken != nil {
-		if !isAccessTokenValid(accessToken) {
+		expirationBuffer := m.expirationBuffer
+		if authParameters.SkipExpirationBuffer {
+			expirationBuffer = 0
+		}
+		// The expiration offset only ages the tokens that are read, a token that's written is checked against its actual expiry
+		if !m.isAccessTokenValidWithBuffer(accessToken, authParameters.AllowExtendedExpiry, expirationBuffer+m.expirationOffset) {
 			accessToken = nil
 		}
 	}
-	idToken := m.storageManager.ReadIDToken(homeAccountID, metadata.Aliases, realm, clientID)
+	if accessToken != nil {
+		msalbase.GetMetrics().OnCacheHit()
+	} else {
+		msalbase.GetMetrics().OnCacheMiss()
+	}
+	// Tokens acquired without a user, e.g. with the client credentials grant, are only cached as access tokens for the app
+	if homeAccountID == "" {
+		return msalbase.CreateStorageTokenResponse(accessToken, (*refreshTokenCacheItem)(nil), (*idTokenCacheItem)(nil), nil), nil
+	}
+	m.recordAccountAccess(authParameters.PartitionKey, homeAccountID)
+	idToken := storageManager.ReadIDToken(homeAccountID, aliases, realm, clientID)
+	refreshToken := readRefreshToken(storageManager, homeAccountID, aliases, clientID, authParameters.IgnoreFamilyRefreshToken)
+	account := storageManager.ReadAccount(homeAccountID, aliases, realm)
+	return msalbase.CreateStorageTokenResponse(accessToken, refreshToken, idToken, account), nil
+}
+
+//readRefreshToken returns the refresh token the app redeems for the account, or nil if there's none
+func readRefreshToken(storageManager StorageManager, homeAccountID string, aliases []string, clientID string, ignoreFamilyRefreshToken bool) *refreshTokenCacheItem {
 	var familyID string
-	appMetadata := m.storageManager.ReadAppMetadata(metadata.Aliases, clientID)
-	if appMetadata == nil {
+	appMetadata := storageManager.ReadAppMetadata(aliases, clientID)
+	if appMetadata == nil || ignoreFamilyRefreshToken {
 		familyID = ""
 	} else {
 		familyID = msalbase.GetStringFromPointer(appMetadata.FamilyID)
 	}
-	refreshToken := m.storageManager.ReadRefreshToken(homeAccountID, metadata.Aliases, familyID, clientID)
-	account := m.storageManager.ReadAccount(homeAccountID, metadata.Aliases, realm)
-	return msalbase.CreateStorageTokenResponse(accessToken, refreshToken, idToken, account), nil
+	// The app's own refresh token is read first, without a family ID ReadRefreshToken still falls back to any family token so that one is dropped
+	refreshToken := storageManager.ReadRefreshToken(homeAccountID, aliases, "", clientID)
+	if refreshToken != nil && msalbase.GetStringFromPointer(refreshToken.ClientID) != clientID {
+		refreshToken = nil
+	}
+	// Apps in a family of client IDs (FOCI) can redeem the family refresh token when they don't have their own,
+	// an app is only known to be in the family once its app metadata has the family ID
+	if refreshToken == nil && familyID != "" {
+		refreshToken = storageManager.ReadRefreshToken(homeAccountID, aliases, familyID, clientID)
+	}
+	return refreshToken
+}
+
+//cacheWrite tracks the entities a token response wrote to the cache, so they're deleted again when a later write fails
+//Without it a failed write would leave a partial cache, such as a refresh token without its account
+type cacheWrite struct {
+	undos []func() error
+}
+
+//do makes the write and keeps its undo, or rolls back the earlier writes when it fails
+func (w *cacheWrite) do(write func() error, undo func() error) error {
+	if err := write(); err != nil {
+		w.rollback()
+		return err
+	}
+	w.undos = append(w.undos, undo)
+	return nil
+}
+
+//rollback undoes the writes in reverse order, an undo that fails is logged since the write already failed
+func (w *cacheWrite) rollback() {
+	for i := len(w.undos) - 1; i >= 0; i-- {
+		if err := w.undos[i](); err != nil {
+			msalbase.GetLogger().Errorf("A cache write couldn't be rolled back: %v", err)
+		}
+	}
+	w.undos = nil
 }
 
 func (m *defaultCacheManager) CacheTokenResponse(authParameters *msalbase.AuthParametersInternal, tokenResponse *msalbase.TokenResponse) (*msalbase.Account, error) {
-	var err error
-	authParameters.HomeaccountID = tokenResponse.GetHomeAccountIDFromClientInfo()
+	// There is no user in the client credentials grant or for a managed identity, so the tokens belong to the app
+	appOnly := authParameters.AuthorizationType == msalbase.AuthorizationTypeClientCredentials ||
+		authParameters.AuthorizationType == msalbase.AuthorizationTypeManagedIdentity
+	if appOnly {
+		authParameters.HomeaccountID = ""
+		// On-behalf-of tokens are cached for the user the incoming assertion was issued to
+	} else if authParameters.AuthorizationType != msalbase.AuthorizationTypeOnBehalfOf {
+		// A refresh response without client info belongs to the account whose refresh token was redeemed,
+		// so its rotated refresh token replaces the old one instead of being cached without an account
+		if homeAccountID := tokenResponse.GetHomeAccountIDFromClientInfo(); homeAccountID != "" ||
+			authParameters.AuthorizationType != msalbase.AuthorizationTypeRefreshTokenExchange {
+			authParameters.HomeaccountID = homeAccountID
+		}
+	}
 	homeAccountID := authParameters.HomeaccountID
-	environment := authParameters.AuthorityInfo.Host
-	realm := authParameters.AuthorityInfo.Tenant
+	environment := getCacheEnvironment(authParameters)
+	realm := getCacheRealm(authParameters.AuthorityInfo)
 	clientID := authParameters.ClientID
-	target := msalbase.ConcatenateScopes(tokenResponse.GrantedScopes)
+	// The authority doesn't always grant the scopes in the same order, so they are sorted to keep a single access token per set of scopes
+	target := msalbase.CanonicalScopes(tokenResponse.GrantedScopes)
+	if err := checkCacheKeys(environment, clientID); err != nil {
+		msalbase.GetLogger().Warnf("Skipping writing the tokens to the cache: %v", err)
+		return nil, err
+	}
 
-	log.Infof("Writing to the cache for homeAccountId '%s' environment '%s' realm '%s' clientId '%s' target '%s'", homeAccountID, environment, realm, clientID, target)
+	msalbase.GetLogger().Infof("Writing to the cache for homeAccountId '%s' environment '%s' realm '%s' clientId '%s' target '%s'", msalbase.PII(homeAccountID), environment, msalbase.PII(realm), clientID, msalbase.PII(target))
 
-	cachedAt := time.Now().Unix()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	storageManager := m.getPartition(authParameters.PartitionKey, true)
+	cachedAt := m.now().Unix()
 
-	if tokenResponse.HasRefreshToken() {
+	write := &cacheWrite{}
+	if tokenResponse.HasRefreshToken() && !appOnly {
 		refreshToken := createRefreshTokenCacheItem(homeAccountID, environment, clientID, tokenResponse.RefreshToken, tokenResponse.FamilyID)
-		err = m.storageManager.WriteRefreshToken(refreshToken)
+		err := write.do(func() error { return storageManager.WriteRefreshToken(refreshToken) },
+			func() error { return storageManager.DeleteRefreshToken(refreshToken) })
 		if err != nil {
 			return nil, err
 		}
@@ -123,8 +588,16 @@ func (m *defaultCacheManager) CacheTokenResponse(authParameters *msalbase.AuthPa
 			extendedExpiresOn,
 			target,
 			tokenResponse.AccessToken)
-		if isAccessTokenValid(accessToken) {
-			err = m.storageManager.WriteAccessToken(accessToken)
+		if authParameters.PoPKey != nil {
+			accessToken.bindToKey(authParameters.PoPKey)
+		}
+		// The requested scopes are kept next to the granted ones, so a cache hit still reports the scopes that were declined
+		if requested := msalbase.CanonicalScopes(authParameters.Scopes); requested != "" && requested != target {
+			accessToken.RequestedScopes = &requested
+		}
+		if m.isAccessTokenValid(accessToken, false) {
+			err := write.do(func() error { return storageManager.WriteAccessToken(accessToken) },
+				func() error { return storageManager.DeleteAccessToken(accessToken) })
 			if err != nil {
 				return nil, err
 			}
@@ -133,10 +606,10 @@ func (m *defaultCacheManager) CacheTokenResponse(authParameters *msalbase.AuthPa
 	var account *msalbase.Account
 	idTokenJwt := tokenResponse.IDToken
 
-	if idTokenJwt != nil {
+	if idTokenJwt != nil && !appOnly {
 		idToken := createIDTokenCacheItem(homeAccountID, environment, realm, clientID, idTokenJwt.RawToken)
-		err = m.storageManager.WriteIDToken(idToken)
-
+		err := write.do(func() error { return storageManager.WriteIDToken(idToken) },
+			func() error { return storageManager.DeleteIDToken(idToken) })
 		if err != nil {
 			return nil, err
 		}
@@ -152,23 +625,396 @@ func (m *defaultCacheManager) CacheTokenResponse(authParameters *msalbase.AuthPa
 			authorityType,
 			idTokenJwt.PreferredUsername,
 		)
-		err = m.storageManager.WriteAccount(account)
-
+		err = write.do(func() error { return storageManager.WriteAccount(account) },
+			func() error { return storageManager.DeleteAccount(account) })
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	appMetadata := createAppMetadata(tokenResponse.FamilyID, clientID, environment)
-
-	err = m.storageManager.WriteAppMetadata(appMetadata)
-
+	err := write.do(func() error { return storageManager.WriteAppMetadata(appMetadata) },
+		func() error { return storageManager.DeleteAppMetadata(appMetadata) })
 	if err != nil {
 		return nil, err
 	}
+
+	// A family refresh token supersedes the one the app had for itself, it's keyed by the family instead of the client
+	// The app's token is deleted last, a deletion can't be rolled back so it's only made once everything else is written
+	if tokenResponse.HasRefreshToken() && !appOnly && tokenResponse.FamilyID != "" {
+		appRefreshToken := createRefreshTokenCacheItem(homeAccountID, environment, clientID, "", "")
+		if err := storageManager.DeleteRefreshToken(appRefreshToken); err != nil {
+			write.rollback()
+			return nil, err
+		}
+	}
+	m.recordAccountAccess(authParameters.PartitionKey, homeAccountID)
+	if err := m.evictAccounts(storageManager, authParameters.PartitionKey); err != nil {
+		return nil, err
+	}
 	return account, nil
 }
 
+//ExportRefreshToken returns the refresh token the app would redeem for the account, or an empty string if there's none
+//Only the tokens without a partition key are exported, since those are the ones of signed in users
+func (m *defaultCacheManager) ExportRefreshToken(ctx context.Context, account *msalbase.Account, clientID string, webRequestManager requests.WebRequestManager) (string, error) {
+	authorityInfo := &msalbase.AuthorityInfo{
+		Host:          account.GetEnvironment(),
+		Tenant:        msalbase.GetStringFromPointer(account.Realm),
+		AuthorityType: msalbase.GetStringFromPointer(account.AuthorityType),
+	}
+	aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
+	metadata, err := aadInstanceDiscovery.GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		return "", err
+	}
+	aliases := getCacheAliases(metadata, authorityInfo.Host)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	msalbase.GetLogger().Warnf("Exporting the refresh token of the account with homeAccountId '%s' for clientId '%s'", msalbase.PII(account.GetHomeAccountID()), clientID)
+	refreshToken := readRefreshToken(m.storageManager, account.GetHomeAccountID(), aliases, clientID, false)
+	if refreshToken == nil {
+		return "", nil
+	}
+	return refreshToken.GetSecret(), nil
+}
+
+//ImportRefreshToken writes a refresh token that was issued to the app outside of this cache, keyed the way CacheTokenResponse keys them
+//The app metadata is written with the family ID too, so the token is found as the family one when the app is in a family
+func (m *defaultCacheManager) ImportRefreshToken(authParameters *msalbase.AuthParametersInternal, homeAccountID string, refreshToken string, familyID string) error {
+	environment := getCacheEnvironment(authParameters)
+	clientID := authParameters.ClientID
+	if err := checkCacheKeys(environment, clientID); err != nil {
+		return err
+	}
+	if homeAccountID == "" {
+		return &CacheKeyError{Key: "homeAccountID"}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	msalbase.GetLogger().Warnf("Importing a refresh token for homeAccountId '%s' environment '%s' clientId '%s'", msalbase.PII(homeAccountID), environment, clientID)
+	storageManager := m.getPartition(authParameters.PartitionKey, true)
+	if err := storageManager.WriteRefreshToken(createRefreshTokenCacheItem(homeAccountID, environment, clientID, refreshToken, familyID)); err != nil {
+		return err
+	}
+	return storageManager.WriteAppMetadata(createAppMetadata(familyID, clientID, environment))
+}
+
+//RemoveAccount deletes the account and all of its access, refresh and ID tokens from the cache and from its partitions
+//App metadata is left in place since it is shared by all the accounts of an application
+func (m *defaultCacheManager) RemoveAccount(ctx context.Context, account *msalbase.Account, webRequestManager requests.WebRequestManager) error {
+	homeAccountID := account.GetHomeAccountID()
+	authorityInfo := &msalbase.AuthorityInfo{
+		Host:          account.GetEnvironment(),
+		Tenant:        msalbase.GetStringFromPointer(account.Realm),
+		AuthorityType: msalbase.GetStringFromPointer(account.AuthorityType),
+	}
+	aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
+	metadata, err := aadInstanceDiscovery.GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		return err
+	}
+	aliases := getCacheAliases(metadata, authorityInfo.Host)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	msalbase.GetLogger().Infof("Removing the account with homeAccountId '%s' environments '%v' from the cache", msalbase.PII(homeAccountID), aliases)
+	credentialTypes := map[string]bool{
+		msalbase.CredentialTypeAccessToken:  true,
+		msalbase.CredentialTypeRefreshToken: true,
+		msalbase.CredentialTypeIDToken:      true,
+	}
+	if err := removeAccount(m.storageManager, homeAccountID, aliases, credentialTypes); err != nil {
+		return err
+	}
+	m.forgetAccountAccess("", homeAccountID)
+	for partitionKey, partition := range m.partitions {
+		if err := removeAccount(partition, homeAccountID, aliases, credentialTypes); err != nil {
+			return err
+		}
+		m.forgetAccountAccess(partitionKey, homeAccountID)
+	}
+	return nil
+}
+
+//forgetAccountAccess drops the last access of an account removed from the partition, an account added again starts as the least recently used
+func (m *defaultCacheManager) forgetAccountAccess(partitionKey string, homeAccountID string) {
+	m.accessLock.Lock()
+	defer m.accessLock.Unlock()
+	delete(m.accountAccess, accountAccessKey{partitionKey: partitionKey, homeAccountID: homeAccountID})
+}
+
+func removeAccount(storageManager StorageManager, homeAccountID string, envAliases []string, credentialTypes map[string]bool) error {
+	if err := storageManager.DeleteCredentials(homeAccountID, envAliases, "", credentialTypes); err != nil {
+		return err
+	}
+	// Removing an account that isn't in the cache or the partition is not an error
+	if err := storageManager.DeleteAccounts(homeAccountID, envAliases); err != nil && err != errAccountNotFound {
+		return err
+	}
+	return nil
+}
+
+//DeleteAccessTokens deletes the access tokens of the account that were granted for all of the scopes, in every partition of the cache
+//The refresh and ID tokens are kept, so new access tokens can still be acquired silently
+func (m *defaultCacheManager) DeleteAccessTokens(ctx context.Context, account *msalbase.Account, scopes []string, webRequestManager requests.WebRequestManager) (int, error) {
+	homeAccountID := account.GetHomeAccountID()
+	authorityInfo := &msalbase.AuthorityInfo{
+		Host:          account.GetEnvironment(),
+		Tenant:        msalbase.GetStringFromPointer(account.Realm),
+		AuthorityType: msalbase.GetStringFromPointer(account.AuthorityType),
+	}
+	aadInstanceDiscovery := requests.CreateAadInstanceDiscovery(webRequestManager)
+	metadata, err := aadInstanceDiscovery.GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		return 0, err
+	}
+	aliases := getCacheAliases(metadata, authorityInfo.Host)
+	scopes = msalbase.NormalizeScopes(scopes)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	msalbase.GetLogger().Infof("Deleting the access tokens for scopes '%s' of the account with homeAccountId '%s' from the cache",
+		msalbase.PII(msalbase.ConcatenateScopes(scopes)), msalbase.PII(homeAccountID))
+	removed, err := deleteAccessTokens(m.storageManager, homeAccountID, aliases, scopes)
+	if err != nil {
+		return removed, err
+	}
+	for _, partition := range m.partitions {
+		removedFromPartition, err := deleteAccessTokens(partition, homeAccountID, aliases, scopes)
+		removed += removedFromPartition
+		if err != nil {
+			return removed, err
+		}
+	}
+	// No token matching the scopes is not an error, there is simply nothing to delete
+	msalbase.GetLogger().Infof("Deleted %d access tokens from the cache", removed)
+	return removed, nil
+}
+
+func deleteAccessTokens(storageManager StorageManager, homeAccountID string, envAliases []string, scopes []string) (int, error) {
+	removed := 0
+	for _, accessToken := range storageManager.ReadAllAccessTokens() {
+		if msalbase.GetStringFromPointer(accessToken.HomeAccountID) != homeAccountID ||
+			!checkAlias(msalbase.GetStringFromPointer(accessToken.Environment), envAliases) ||
+			!isMatchingScopes(scopes, msalbase.GetStringFromPointer(accessToken.Scopes)) {
+			continue
+		}
+		if err := storageManager.DeleteAccessToken(accessToken); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+//RemoveExpiredAccessTokens deletes the access tokens that have expired and returns how many were removed
+//No expiration buffer is applied, and tokens whose expiry can't be parsed are left in the cache
+func (m *defaultCacheManager) RemoveExpiredAccessTokens() (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.now().Unix()
+	removed, err := removeExpiredAccessTokens(m.storageManager, now)
+	if err != nil {
+		return removed, err
+	}
+	for _, partition := range m.partitions {
+		removedFromPartition, err := removeExpiredAccessTokens(partition, now)
+		removed += removedFromPartition
+		if err != nil {
+			return removed, err
+		}
+	}
+	msalbase.GetLogger().Infof("Removed %d expired access tokens from the cache", removed)
+	return removed, nil
+}
+
+//GetAllAccessTokens lists the access tokens of the cache and of its partitions, with whether each one is still returned by the cache
+func (m *defaultCacheManager) GetAllAccessTokens(redactSecrets bool) []*msalbase.CachedAccessToken {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	accessTokens := m.listAccessTokens(m.storageManager, "", redactSecrets)
+	for partitionKey, partition := range m.partitions {
+		accessTokens = append(accessTokens, m.listAccessTokens(partition, partitionKey, redactSecrets)...)
+	}
+	return accessTokens
+}
+
+func (m *defaultCacheManager) listAccessTokens(storageManager StorageManager, partitionKey string, redactSecrets bool) []*msalbase.CachedAccessToken {
+	accessTokens := []*msalbase.CachedAccessToken{}
+	for _, at := range storageManager.ReadAllAccessTokens() {
+		cached := &msalbase.CachedAccessToken{
+			HomeAccountID:     msalbase.GetStringFromPointer(at.HomeAccountID),
+			Environment:       msalbase.GetStringFromPointer(at.Environment),
+			Realm:             msalbase.GetStringFromPointer(at.Realm),
+			ClientID:          msalbase.GetStringFromPointer(at.ClientID),
+			Scopes:            msalbase.SplitScopes(at.GetScopes()),
+			TokenType:         at.GetTokenType(),
+			CachedAt:          parseUnixTimestamp(at.CachedAt),
+			ExpiresOn:         parseUnixTimestamp(at.ExpiresOnUnixTimestamp),
+			ExtendedExpiresOn: parseUnixTimestamp(at.ExtendedExpiresOnUnixTimestamp),
+			PartitionKey:      partitionKey,
+			Valid:             m.isAccessTokenValidWithBuffer(at, false, m.expirationBuffer+m.expirationOffset),
+		}
+		if !redactSecrets {
+			cached.Secret = at.GetSecret()
+		}
+		accessTokens = append(accessTokens, cached)
+	}
+	return accessTokens
+}
+
+//GetAllRefreshTokens lists the refresh tokens of the cache and of its partitions
+func (m *defaultCacheManager) GetAllRefreshTokens(redactSecrets bool) []*msalbase.CachedRefreshToken {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	refreshTokens := listRefreshTokens(m.storageManager, "", redactSecrets)
+	for partitionKey, partition := range m.partitions {
+		refreshTokens = append(refreshTokens, listRefreshTokens(partition, partitionKey, redactSecrets)...)
+	}
+	return refreshTokens
+}
+
+//GetStats counts the entities of the cache and of its partitions and estimates their size, the cache isn't serialized for it
+func (m *defaultCacheManager) GetStats() (*msalbase.CacheStats, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	stats := &msalbase.CacheStats{}
+	addStats(stats, m.storageManager)
+	for _, partition := range m.partitions {
+		addStats(stats, partition)
+	}
+	return stats, nil
+}
+
+func addStats(stats *msalbase.CacheStats, storageManager StorageManager) {
+	accounts := storageManager.ReadAllAccounts()
+	stats.Accounts += len(accounts)
+	for _, account := range accounts {
+		stats.ApproximateSize += len(account.CreateKey())
+	}
+	accessTokens := storageManager.ReadAllAccessTokens()
+	stats.AccessTokens += len(accessTokens)
+	for _, at := range accessTokens {
+		stats.ApproximateSize += len(at.CreateKey()) + len(msalbase.GetStringFromPointer(at.Secret))
+	}
+	refreshTokens := storageManager.ReadAllRefreshTokens()
+	stats.RefreshTokens += len(refreshTokens)
+	for _, rt := range refreshTokens {
+		stats.ApproximateSize += len(rt.CreateKey()) + len(msalbase.GetStringFromPointer(rt.Secret))
+	}
+}
+
+func listRefreshTokens(storageManager StorageManager, partitionKey string, redactSecrets bool) []*msalbase.CachedRefreshToken {
+	refreshTokens := []*msalbase.CachedRefreshToken{}
+	for _, rt := range storageManager.ReadAllRefreshTokens() {
+		cached := &msalbase.CachedRefreshToken{
+			HomeAccountID: msalbase.GetStringFromPointer(rt.HomeAccountID),
+			Environment:   msalbase.GetStringFromPointer(rt.Environment),
+			ClientID:      msalbase.GetStringFromPointer(rt.ClientID),
+			FamilyID:      msalbase.GetStringFromPointer(rt.FamilyID),
+			PartitionKey:  partitionKey,
+		}
+		if !redactSecrets {
+			cached.Secret = rt.GetSecret()
+		}
+		refreshTokens = append(refreshTokens, cached)
+	}
+	return refreshTokens
+}
+
+//parseUnixTimestamp converts a timestamp of the cache to a time, invalid timestamps are the zero time
+func parseUnixTimestamp(timestamp *string) time.Time {
+	seconds, err := strconv.ParseInt(msalbase.GetStringFromPointer(timestamp), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func removeExpiredAccessTokens(storageManager StorageManager, now int64) (int, error) {
+	removed := 0
+	for _, accessToken := range storageManager.ReadAllAccessTokens() {
+		expiresOn, err := strconv.ParseInt(msalbase.GetStringFromPointer(accessToken.ExpiresOnUnixTimestamp), 10, 64)
+		if err != nil {
+			msalbase.GetLogger().Info("Skipping an access token that expires at an invalid time.")
+			continue
+		}
+		if expiresOn > now {
+			continue
+		}
+		if err := storageManager.DeleteAccessToken(accessToken); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+//Clear deletes every account, access token, refresh token, ID token and app metadata from the cache
+//A failed deletion doesn't stop the others, all the failures are returned in a ClearError
+func (m *defaultCacheManager) Clear() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var errs []error
+	for _, accessToken := range m.storageManager.ReadAllAccessTokens() {
+		if err := m.storageManager.DeleteAccessToken(accessToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, refreshToken := range m.storageManager.ReadAllRefreshTokens() {
+		if err := m.storageManager.DeleteRefreshToken(refreshToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, idToken := range m.storageManager.ReadAllIDTokens() {
+		if err := m.storageManager.DeleteIDToken(idToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, account := range m.storageManager.ReadAllAccounts() {
+		if err := m.storageManager.DeleteAccount(account); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, appMetadata := range m.storageManager.ReadAllAppMetadata() {
+		if err := m.storageManager.DeleteAppMetadata(appMetadata); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The partitions are dropped rather than emptied, so none are serialized after the cache is cleared
+	m.partitions = nil
+	m.accessLock.Lock()
+	m.accountAccess = nil
+	m.accessLock.Unlock()
+	if len(errs) > 0 {
+		return &ClearError{Errors: errs}
+	}
+	msalbase.GetLogger().Info("Cleared the cache")
+	return nil
+}
+
+//DeleteCachedRefreshToken removes the refresh token issued to the client for the account in the request
+//It is used to revoke a refresh token that the authority has rejected
 func (m *defaultCacheManager) DeleteCachedRefreshToken(authParameters *msalbase.AuthParametersInternal) error {
-	return errors.New("Not implemented")
+	homeAccountID := authParameters.HomeaccountID
+	environment := getCacheEnvironment(authParameters)
+	clientID := authParameters.ClientID
+	msalbase.GetLogger().Infof("Deleting refresh token from the cache for homeAccountId '%s' environment '%s' clientId '%s'", msalbase.PII(homeAccountID), environment, clientID)
+	var keyErr error
+	if homeAccountID == "" {
+		keyErr = &CacheKeyError{Key: "homeAccountID"}
+	} else if environment == "" {
+		keyErr = &CacheKeyError{Key: "environment"}
+	}
+	if keyErr != nil {
+		msalbase.GetLogger().Warn("Failed to delete refresh token from the cache, one of the primary keys is empty")
+		return fmt.Errorf("failed to delete refresh token from the cache: %w", keyErr)
+	}
+	credentialTypes := map[string]bool{msalbase.CredentialTypeRefreshToken: true}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	storageManager := m.getPartition(authParameters.PartitionKey, false)
+	if storageManager == nil {
+		return nil
+	}
+	return storageManager.DeleteCredentials(homeAccountID, []string{environment}, clientID, credentialTypes)
 }
@@ -4,17 +4,26 @@
 package tokencache
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestIsAccessTokenValid(t *testing.T) {
 	errorTimestamp := "TIMESTAMP_SHOULD_BE_INT"
+	manager := CreateCacheManager(nil).(*defaultCacheManager)
 	accessTokenCacheItem := createAccessTokenCacheItem(
 		"hid",
 		"env",
@@ -26,34 +35,176 @@ func TestIsAccessTokenValid(t *testing.T) {
 		"openid",
 		"secret",
 	)
-	validity := isAccessTokenValid(accessTokenCacheItem)
+	validity := manager.isAccessTokenValid(accessTokenCacheItem, false)
 	if !validity {
 		t.Errorf("Access token should be valid")
 	}
 	expiresOn := strconv.FormatInt(time.Now().Unix()+200, 10)
 	accessTokenCacheItem.ExpiresOnUnixTimestamp = &expiresOn
-	validity = isAccessTokenValid(accessTokenCacheItem)
+	validity = manager.isAccessTokenValid(accessTokenCacheItem, false)
 	if validity {
 		t.Errorf("Access token shouldn't be valid")
 	}
 	accessTokenCacheItem.ExpiresOnUnixTimestamp = &errorTimestamp
-	validity = isAccessTokenValid(accessTokenCacheItem)
+	validity = manager.isAccessTokenValid(accessTokenCacheItem, false)
 	if validity {
 		t.Errorf("Access token shouldn't be valid")
 	}
 	accessTokenCacheItem.CachedAt = &errorTimestamp
-	validity = isAccessTokenValid(accessTokenCacheItem)
+	validity = manager.isAccessTokenValid(accessTokenCacheItem, false)
 	if validity {
 		t.Errorf("Access token shouldn't be valid")
 	}
 	actualCachedAt := strconv.FormatInt(time.Now().Unix()+500, 10)
 	accessTokenCacheItem.CachedAt = &actualCachedAt
-	validity = isAccessTokenValid(accessTokenCacheItem)
+	validity = manager.isAccessTokenValid(accessTokenCacheItem, false)
 	if validity {
 		t.Errorf("Access token shouldn't be valid")
 	}
 }
 
+func TestIsAccessTokenValidExpirationBuffer(t *testing.T) {
+	manager := &defaultCacheManager{expirationBuffer: time.Minute}
+	accessTokenCacheItem := createAccessTokenCacheItem(
+		"hid",
+		"env",
+		"realm",
+		"cid",
+		time.Now().Unix(),
+		time.Now().Unix()+200,
+		time.Now().Unix()+200,
+		"openid",
+		"secret",
+	)
+	if !manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token should be valid with a one minute buffer")
+	}
+	manager.expirationBuffer = 10 * time.Minute
+	if manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token shouldn't be valid with a ten minute buffer")
+	}
+}
+
+func TestTokenExpirationOffset(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	manager := CreateCacheManager(storageManager).(*defaultCacheManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "offset.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"offset.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"openid"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "offsetSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	readSecret := func() string {
+		storageTokenResponse, err := manager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+		if err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+		result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+		if err != nil {
+			return ""
+		}
+		return result.GetAccessToken()
+	}
+	// The offset only ages the tokens that are read, the token it pushes past its expiry is still written
+	manager.SetTokenExpirationOffset(2 * time.Hour)
+	if _, err := manager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if len(storageManager.ReadAllAccessTokens()) != 1 {
+		t.Errorf("The access token should be written whatever the offset, instead the cache has %d", len(storageManager.ReadAllAccessTokens()))
+	}
+	if secret := readSecret(); secret != "" {
+		t.Errorf("The access token shouldn't be read once the offset pushes past its expiry, instead %s was read", secret)
+	}
+	if accessTokens := manager.GetAllAccessTokens(true); len(accessTokens) != 1 || accessTokens[0].Valid {
+		t.Errorf("The listed access token shouldn't be valid once the offset pushes past its expiry, instead it is %+v", accessTokens)
+	}
+	manager.SetTokenExpirationOffset(0)
+	if secret := readSecret(); secret != "offsetSecret" {
+		t.Errorf("The access token should be read without an offset, instead %s was read", secret)
+	}
+	// The offset shifts the clock, it doesn't make the token look cached in the future
+	manager.SetTokenExpirationOffset(-2 * time.Hour)
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("", "offset.env", "realm", "cid",
+		time.Now().Unix()-3600, time.Now().Unix()-60, time.Now().Unix()-60, "openid", "expiredSecret"))
+	if secret := readSecret(); secret != "expiredSecret" {
+		t.Errorf("An expired access token should be read when a negative offset moves the clock back, instead %s was read", secret)
+	}
+}
+
+func TestIsAccessTokenValidExtendedExpiry(t *testing.T) {
+	manager := CreateCacheManager(nil).(*defaultCacheManager)
+	accessTokenCacheItem := createAccessTokenCacheItem(
+		"hid",
+		"env",
+		"realm",
+		"cid",
+		time.Now().Unix()-3600,
+		time.Now().Unix()-60,
+		time.Now().Unix()+3600,
+		"openid",
+		"secret",
+	)
+	if manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token past its expiry shouldn't be valid in normal mode")
+	}
+	if !manager.isAccessTokenValid(accessTokenCacheItem, true) {
+		t.Errorf("Access token within its extended expiry should be valid when extended expiry is allowed")
+	}
+	extendedExpiresOn := strconv.FormatInt(time.Now().Unix()-60, 10)
+	accessTokenCacheItem.ExtendedExpiresOnUnixTimestamp = &extendedExpiresOn
+	if manager.isAccessTokenValid(accessTokenCacheItem, true) {
+		t.Errorf("Access token past its extended expiry shouldn't be valid")
+	}
+	accessTokenCacheItem.ExtendedExpiresOnUnixTimestamp = nil
+	if manager.isAccessTokenValid(accessTokenCacheItem, true) {
+		t.Errorf("Access token without an extended expiry shouldn't be valid when extended expiry is allowed")
+	}
+}
+
+func TestIsAccessTokenValidWithFakeClock(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	manager := CreateCacheManager(nil).(*defaultCacheManager)
+	manager.nowFunc = func() time.Time { return now }
+	buffer := int64(defaultExpirationBuffer / time.Second)
+	accessTokenCacheItem := createAccessTokenCacheItem(
+		"hid",
+		"env",
+		"realm",
+		"cid",
+		now.Unix(),
+		now.Unix()+buffer,
+		now.Unix()+buffer,
+		"openid",
+		"secret",
+	)
+	if manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token expiring exactly at the end of the buffer shouldn't be valid")
+	}
+	expiresOn := strconv.FormatInt(now.Unix()+buffer+1, 10)
+	accessTokenCacheItem.ExpiresOnUnixTimestamp = &expiresOn
+	if !manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token expiring one second after the buffer should be valid")
+	}
+	cachedAt := strconv.FormatInt(now.Unix()+1, 10)
+	accessTokenCacheItem.CachedAt = &cachedAt
+	if manager.isAccessTokenValid(accessTokenCacheItem, false) {
+		t.Errorf("Access token cached after the current time shouldn't be valid")
+	}
+}
+
 func TestGetAllAccounts(t *testing.T) {
 	accHidOne := "hid"
 	accEnvOne := "env"
@@ -81,6 +232,55 @@ func TestGetAllAccounts(t *testing.T) {
 	}
 }
 
+func TestGetAllAccountsFromPartitions(t *testing.T) {
+	cacheManager := &defaultCacheManager{storageManager: CreateStorageManager()}
+	shared := msalbase.CreateAccount("sharedHid", "env", "realm", "lid", msalbase.MSSTS, "shared")
+	partitioned := msalbase.CreateAccount("partitionedHid", "env", "realm", "lid", msalbase.MSSTS, "partitioned")
+	cacheManager.storageManager.WriteAccount(shared)
+	// An account written to the cache and to partitions is returned once
+	for _, partitionKey := range []string{"userA", "userB"} {
+		partition := cacheManager.getPartition(partitionKey, true)
+		partition.WriteAccount(shared)
+		partition.WriteAccount(partitioned)
+	}
+	accounts := cacheManager.GetAllAccounts()
+	if len(accounts) != 2 || accounts[0].GetHomeAccountID() != "sharedHid" || accounts[1].GetHomeAccountID() != "partitionedHid" {
+		t.Errorf("The accounts of the cache and of its partitions should be returned once, instead they are %v", accounts)
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	aliasAccount := msalbase.CreateAccount("hid", "getaccount-alias.env", "realm", "lid", msalbase.MSSTS, "username")
+	otherAccount := msalbase.CreateAccount("otherHid", "getaccount.env", "realm", "lid", msalbase.MSSTS, "other")
+	otherCloudAccount := msalbase.CreateAccount("cloudHid", "getaccount.cloud", "realm", "lid", msalbase.MSSTS, "username")
+	for _, acc := range []*msalbase.Account{aliasAccount, otherAccount, otherCloudAccount} {
+		storageManager.WriteAccount(acc)
+	}
+	authInfo := &msalbase.AuthorityInfo{Host: "getaccount.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"getaccount.env", "getaccount-alias.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	account, err := cacheManager.GetAccount(context.Background(), "hid", authInfo, mockWebRequestManager)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(account, aliasAccount) {
+		t.Errorf("Actual account %v differs from expected account %v", account, aliasAccount)
+	}
+	account, err = cacheManager.GetAccount(context.Background(), "cloudHid", authInfo, mockWebRequestManager)
+	if err != nil || account != nil {
+		t.Errorf("Accounts outside of the environment aliases shouldn't be returned, instead got %v and error %v", account, err)
+	}
+	account, err = cacheManager.GetAccount(context.Background(), "missingHid", authInfo, mockWebRequestManager)
+	if err != nil || account != nil {
+		t.Errorf("Account should be nil when it isn't in the cache, instead got %v and error %v", account, err)
+	}
+}
+
 func TestTryReadCache(t *testing.T) {
 	mockStorageManager := new(MockStorageManager)
 	mockWebRequestManager := new(requests.MockWebRequestManager)
@@ -123,7 +323,8 @@ func TestTryReadCache(t *testing.T) {
 		[]string{"env", "alias2"},
 		"realm",
 		"cid",
-		[]string{"openid", "profile"}).Return(accessTokenCacheItem)
+		[]string{"openid", "profile"},
+		"").Return(accessTokenCacheItem)
 	testIDToken := createIDTokenCacheItem(
 		"hid",
 		"env",
@@ -145,15 +346,16 @@ func TestTryReadCache(t *testing.T) {
 		"secret",
 		"fid",
 	)
+	// The app's own refresh token is read first, the family one is only read when the app doesn't have one
 	mockStorageManager.On("ReadRefreshToken",
 		"hid",
 		[]string{"env", "alias2"},
-		"fid",
+		"",
 		"cid").Return(testRefreshToken)
 	testAccount := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
 	mockStorageManager.On("ReadAccount", "hid", []string{"env", "alias2"}, "realm").Return(testAccount)
 	expectedStorageToken := msalbase.CreateStorageTokenResponse(accessTokenCacheItem, testRefreshToken, testIDToken, testAccount)
-	actualStorageToken, err := cacheManager.TryReadCache(authParameters, mockWebRequestManager)
+	actualStorageToken, err := cacheManager.TryReadCache(context.Background(), authParameters, mockWebRequestManager)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
@@ -162,71 +364,1830 @@ func TestTryReadCache(t *testing.T) {
 	}
 }
 
-func TestCacheTokenResponse(t *testing.T) {
+func TestTryReadCacheReusesInstanceDiscovery(t *testing.T) {
+	requests.ResetInstanceDiscoveryCache()
+	defer requests.ResetInstanceDiscoveryCache()
 	mockStorageManager := new(MockStorageManager)
-	cacheManager := &defaultCacheManager{storageManager: mockStorageManager}
-	clientInfo := &msalbase.ClientInfoJSONPayload{
-		UID:  "testUID",
-		Utid: "testUtid",
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(mockStorageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "discovery.env", Tenant: "realm"}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"openid"},
 	}
-	idToken := &msalbase.IDToken{
-		RawToken:          "idToken",
-		Oid:               "lid",
-		PreferredUsername: "username",
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"discovery.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	var accessToken *accessTokenCacheItem
+	mockStorageManager.On("ReadAccessToken", "", []string{"discovery.env"}, "realm", "cid", []string{"openid"}, "").Return(accessToken)
+	for i := 0; i < 2; i++ {
+		if _, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager); err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+	}
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetAadinstanceDiscoveryResponse", 1)
+}
+
+func TestCacheManagerConcurrentAccess(t *testing.T) {
+	requests.ResetInstanceDiscoveryCache()
+	defer requests.ResetInstanceDiscoveryCache()
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "concurrent.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"concurrent.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			authParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid", Scopes: []string{"openid"}}
+			tokenResponse := &msalbase.TokenResponse{
+				AccessToken:   "accessToken",
+				RefreshToken:  "refreshToken",
+				ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+				GrantedScopes: []string{"openid"},
+				ExpiresOn:     time.Now().Add(time.Hour),
+				ExtExpiresOn:  time.Now().Add(time.Hour),
+			}
+			if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+				t.Errorf("Error should be nil; instead, it is %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			authParams := &msalbase.AuthParametersInternal{HomeaccountID: "uid.utid", AuthorityInfo: authInfo, ClientID: "cid", Scopes: []string{"openid"}}
+			if _, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager); err != nil {
+				t.Errorf("Error should be nil; instead, it is %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	authParams := &msalbase.AuthParametersInternal{HomeaccountID: "uid.utid", AuthorityInfo: authInfo, ClientID: "cid", Scopes: []string{"openid"}}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if storageTokenResponse.RefreshToken.GetSecret() != "refreshToken" {
+		t.Errorf("Refresh token should have been cached")
+	}
+}
+
+func TestTryReadCacheB2CPolicies(t *testing.T) {
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "contoso.b2clogin.com", Tenant: "contoso", AuthorityType: msalbase.B2C, Policy: "b2c_1_signin"}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
 	}
-	expiresOn := time.Unix(time.Now().Unix()+1000, 0).UTC()
 	tokenResponse := &msalbase.TokenResponse{
-		AccessToken:   "accessToken",
-		RefreshToken:  "refreshToken",
-		IDToken:       idToken,
-		FamilyID:      "fid",
-		ClientInfo:    clientInfo,
-		GrantedScopes: []string{"openid", "profile"},
-		ExpiresOn:     expiresOn,
-		ExtExpiresOn:  time.Now(),
+		AccessToken:   "signinToken",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
 	}
-	authInfo := &msalbase.AuthorityInfo{Host: "env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	authParams.Scopes = []string{"openid"}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, new(requests.MockWebRequestManager))
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "signinToken" {
+		t.Errorf("The access token of the policy should be found, instead the error is %v", err)
+	}
+	authInfo.Policy = "b2c_1_edit"
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, new(requests.MockWebRequestManager))
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if _, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Errorf("The access token of another policy shouldn't be found")
+	}
+}
+
+func TestTryReadCacheFamilyRefreshToken(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	// Only another app of the family signed the user in, so there's a family refresh token but no refresh token of the app
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "foci.env", "otherCid", "familySecret", "1"))
+	storageManager.WriteAppMetadata(createAppMetadata("1", "cid", "foci.env"))
+	authInfo := &msalbase.AuthorityInfo{Host: "foci.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"foci.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		HomeaccountID: "hid",
+		Scopes:        []string{"openid"},
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() || storageTokenResponse.RefreshToken.GetSecret() != "familySecret" {
+		t.Errorf("The family refresh token should be returned, instead the refresh token is %v", storageTokenResponse.RefreshToken)
+	}
+	authParams.IgnoreFamilyRefreshToken = true
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if !reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() {
+		t.Errorf("The family refresh token shouldn't be returned once it's ignored, instead the refresh token is %v", storageTokenResponse.RefreshToken)
+	}
+}
+
+func TestTryReadCacheWithoutAppMetadata(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	// There's no app metadata, so the app isn't known to be in the family of the other app's refresh token
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "nometadata.env", "otherCid", "familySecret", "1"))
+	authInfo := &msalbase.AuthorityInfo{Host: "nometadata.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"nometadata.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
 	authParams := &msalbase.AuthParametersInternal{
 		AuthorityInfo: authInfo,
 		ClientID:      "cid",
+		HomeaccountID: "hid",
+		Scopes:        []string{"openid"},
 	}
-	testRefreshToken := createRefreshTokenCacheItem(
-		"testUID.testUtid",
-		"env",
-		"cid",
-		"refreshToken",
-		"fid",
-	)
-	mockStorageManager.On("WriteRefreshToken", testRefreshToken).Return(nil)
-	accessTokenCacheItem := createAccessTokenCacheItem(
-		"testUID.testUtid",
-		"env",
-		"realm",
-		"cid",
-		time.Now().Unix(),
-		time.Now().Unix()+1000,
-		time.Now().Unix(),
-		"openid profile",
-		"accessToken",
-	)
-	mockStorageManager.On("WriteAccessToken", accessTokenCacheItem).Return(nil)
-	testIDToken := createIDTokenCacheItem(
-		"testUID.testUtid",
-		"env",
-		"realm",
-		"cid",
-		"idToken",
-	)
-	mockStorageManager.On("WriteIDToken", testIDToken).Return(nil)
-	testAccount := msalbase.CreateAccount("testUID.testUtid", "env", "realm", "lid", msalbase.MSSTS, "username")
-	mockStorageManager.On("WriteAccount", testAccount).Return(nil)
-	testAppMeta := createAppMetadata("fid", "cid", "env")
-	mockStorageManager.On("WriteAppMetadata", testAppMeta).Return(nil)
-	actualAccount, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
 	if err != nil {
-		t.Errorf("Error should be nil; instead, it is %v", err)
+		t.Fatalf("Error should be nil; instead, it is %v", err)
 	}
-	if !reflect.DeepEqual(actualAccount, testAccount) {
-		t.Errorf("Actual account %+v differs from expected account %+v", actualAccount, testAccount)
+	if !reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() {
+		t.Errorf("The family refresh token shouldn't be returned without app metadata, instead the refresh token is %v", storageTokenResponse.RefreshToken)
+	}
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "nometadata.env", "cid", "clientSecret", ""))
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() || storageTokenResponse.RefreshToken.GetSecret() != "clientSecret" {
+		t.Errorf("The refresh token of the app should be returned, instead the refresh token is %v", storageTokenResponse.RefreshToken)
+	}
+}
+
+type recordingMetrics struct {
+	hits   int
+	misses int
+}
+
+func (m *recordingMetrics) OnCacheHit()                                         { m.hits++ }
+func (m *recordingMetrics) OnCacheMiss()                                        { m.misses++ }
+func (m *recordingMetrics) OnTokenRequest(duration time.Duration, success bool) {}
+
+func TestTryReadCacheMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	msalbase.SetMetrics(metrics)
+	defer msalbase.SetMetrics(nil)
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	now := time.Now()
+	cacheManager := &defaultCacheManager{storageManager: storageManager, expirationBuffer: defaultExpirationBuffer, nowFunc: func() time.Time { return now }}
+	authInfo := &msalbase.AuthorityInfo{Host: "metrics.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"metrics.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"user.read"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	readCache := func() {
+		if _, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager); err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+	}
+	readCache()
+	if metrics.hits != 0 || metrics.misses != 1 {
+		t.Errorf("Reading an empty cache should be a miss, instead there are %d hits and %d misses", metrics.hits, metrics.misses)
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     now.Add(time.Hour),
+		ExtExpiresOn:  now.Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	readCache()
+	readCache()
+	if metrics.hits != 2 || metrics.misses != 1 {
+		t.Errorf("Reading the cached token should be a hit, instead there are %d hits and %d misses", metrics.hits, metrics.misses)
+	}
+	now = now.Add(2 * time.Hour)
+	readCache()
+	if metrics.hits != 2 || metrics.misses != 2 {
+		t.Errorf("Reading an expired token should be a miss, instead there are %d hits and %d misses", metrics.hits, metrics.misses)
+	}
+}
+
+func TestTryReadCacheRegional(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "regional.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"regional.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	regionalParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"openid"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+		Region:            "eastus",
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "regionalSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(regionalParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	globalParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"openid"},
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), globalParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Errorf("A token of the regional endpoint shouldn't be read for the global endpoint, instead it is %v", result.GetAccessToken())
+	}
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), regionalParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "regionalSecret" {
+		t.Errorf("The token of the regional endpoint should be read, instead the error is %v", err)
+	}
+}
+
+func TestTryReadCacheProofOfPossession(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "pop.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"pop.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	key, err := msalbase.CreatePoPKey()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	popParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"openid"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+		PoPKey:            key,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "popSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(popParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	cached := storageManager.ReadAllAccessTokens()
+	if len(cached) != 1 || msalbase.GetStringFromPointer(cached[0].TokenType) != "pop" || msalbase.GetStringFromPointer(cached[0].KeyID) != key.GetKeyID() {
+		t.Fatalf("The access token should be cached with the key binding, instead the cache has %v", cached)
+	}
+	bearerParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"openid"},
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), bearerParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Errorf("A proof-of-possession token shouldn't be read as a bearer token, instead it is %v", result.GetAccessToken())
+	}
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), popParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "popSecret" {
+		t.Fatalf("The token bound to the key should be read, instead the error is %v", err)
+	}
+	if result.GetTokenType() != msalbase.PoPTokenType {
+		t.Errorf("The token type of the cached token should be pop, instead it is %v", result.GetTokenType())
+	}
+}
+
+func TestCachePartitions(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "partition.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"partition.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	createParams := func(partitionKey string) *msalbase.AuthParametersInternal {
+		return &msalbase.AuthParametersInternal{
+			AuthorityInfo:     authInfo,
+			ClientID:          "cid",
+			Scopes:            []string{"openid"},
+			AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+			PartitionKey:      partitionKey,
+		}
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "partitionSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(createParams("userA"), tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for _, partitionKey := range []string{"userB", ""} {
+		storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), createParams(partitionKey), mockWebRequestManager)
+		if err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+		if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+			t.Errorf("A token of partition userA shouldn't be read from partition '%s', instead it is %v", partitionKey, result.GetAccessToken())
+		}
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), createParams("userA"), mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "partitionSecret" {
+		t.Errorf("The token of partition userA should be read, instead the error is %v", err)
+	}
+	if err := cacheManager.Clear(); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), createParams("userA"), mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Errorf("Clear should remove the partitions, instead the token %v was read", result.GetAccessToken())
+	}
+}
+
+func TestSerializeCachePartitions(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "partition.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"partition.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	createParams := func(partitionKey string) *msalbase.AuthParametersInternal {
+		return &msalbase.AuthParametersInternal{
+			AuthorityInfo:     authInfo,
+			ClientID:          "cid",
+			Scopes:            []string{"openid"},
+			AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+			PartitionKey:      partitionKey,
+		}
+	}
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	for _, partitionKey := range []string{"userA", ""} {
+		tokenResponse := &msalbase.TokenResponse{
+			AccessToken:   "secret" + partitionKey,
+			GrantedScopes: []string{"openid"},
+			ExpiresOn:     time.Now().Add(time.Hour),
+			ExtExpiresOn:  time.Now().Add(time.Hour),
+		}
+		if _, err := cacheManager.CacheTokenResponse(createParams(partitionKey), tokenResponse); err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+	}
+	data, err := cacheManager.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	// The partitions are persisted with the cache, so a new process reads them back
+	deserialized := CreateCacheManager(CreateStorageManager())
+	if err := deserialized.DeserializeCache(data); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for _, test := range []struct{ partitionKey, secret string }{{"userA", "secretuserA"}, {"", "secret"}, {"userB", ""}} {
+		storageTokenResponse, err := deserialized.TryReadCache(context.Background(), createParams(test.partitionKey), mockWebRequestManager)
+		if err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+		result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+		if test.secret == "" && err == nil {
+			t.Errorf("No token should be read from partition '%s', instead it is %v", test.partitionKey, result.GetAccessToken())
+		}
+		if test.secret != "" && (err != nil || result.GetAccessToken() != test.secret) {
+			t.Errorf("The token of partition '%s' should be read after deserializing, instead the error is %v", test.partitionKey, err)
+		}
+	}
+	reserialized, err := deserialized.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if string(reserialized) != string(data) {
+		t.Errorf("The deserialized cache should serialize to the same bytes, instead it is %s, want %s", reserialized, data)
+	}
+}
+
+func TestRemoveExpiredAccessTokensFromPartitions(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cacheManager := &defaultCacheManager{storageManager: CreateStorageManager(), nowFunc: func() time.Time { return now }}
+	partition := cacheManager.getPartition("hid", true)
+	partition.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix()-3600, now.Unix(), now.Unix(), "expired", "secret"))
+	partition.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix(), now.Unix()+3600, now.Unix()+3600, "fresh", "secret"))
+	removed, err := cacheManager.RemoveExpiredAccessTokens()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if removed != 1 || len(partition.ReadAllAccessTokens()) != 1 {
+		t.Errorf("The expired token of the partition should be removed, instead %d were removed and %d are left", removed, len(partition.ReadAllAccessTokens()))
+	}
+}
+
+func TestTryReadCacheAcrossAliases(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	writeAuthInfo := &msalbase.AuthorityInfo{Host: "login.alias-one.test", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	readAuthInfo := &msalbase.AuthorityInfo{Host: "login.alias-two.test", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"login.alias-one.test", "login.alias-two.test"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", mock.Anything).Return(mockInstDiscResponse, nil)
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "aliasSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	writeParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     writeAuthInfo,
+		ClientID:          "cid",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	if _, err := cacheManager.CacheTokenResponse(writeParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "login.alias-one.test", "cid", "refreshSecret", ""))
+	storageManager.WriteAccount(msalbase.CreateAccount("hid", "login.alias-one.test", "realm", "lid", msalbase.MSSTS, "username"))
+	readParams := &msalbase.AuthParametersInternal{AuthorityInfo: readAuthInfo, ClientID: "cid", Scopes: []string{"openid"}}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "aliasSecret" {
+		t.Errorf("The access token written under another alias should be read, instead the error is %v", err)
+	}
+	readParams.HomeaccountID = "hid"
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if storageTokenResponse.RefreshToken == nil || storageTokenResponse.RefreshToken.GetSecret() != "refreshSecret" {
+		t.Error("The refresh token written under another alias should be read")
+	}
+	account, err := cacheManager.GetAccount(context.Background(), "hid", readAuthInfo, mockWebRequestManager)
+	if err != nil || account == nil {
+		t.Errorf("The account written under another alias should be found, instead the error is %v", err)
+	}
+}
+
+func TestTryReadCacheUndiscoveredHost(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "undiscovered.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	// Instance discovery doesn't list the host, which happens when authority validation is turned off
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"other.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"openid"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "undiscoveredSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "undiscoveredSecret" {
+		t.Errorf("The token written under the host should be read, instead the error is %v", err)
+	}
+}
+
+func TestCacheTokenResponseNormalizesEnvironment(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "login.alias.normalize.test", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{
+			PreferredCache: "login.preferred.normalize.test",
+			Aliases:        []string{"login.preferred.normalize.test", "login.alias.normalize.test"},
+		}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:             authInfo,
+		ClientID:                  "cid",
+		Scopes:                    []string{"openid"},
+		NormalizeCacheEnvironment: true,
+	}
+	// The cache lookup of the request discovers the aliases of the host
+	if _, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		IDToken:       &msalbase.IDToken{RawToken: "idToken", Oid: "lid", PreferredUsername: "username"},
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	account, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if account.GetEnvironment() != "login.preferred.normalize.test" {
+		t.Errorf("Account environment should be the preferred cache alias, instead it is %s", account.GetEnvironment())
+	}
+	for _, at := range storageManager.ReadAllAccessTokens() {
+		if *at.Environment != "login.preferred.normalize.test" {
+			t.Errorf("Access token environment should be the preferred cache alias, instead it is %s", *at.Environment)
+		}
+	}
+	for _, rt := range storageManager.ReadAllRefreshTokens() {
+		if *rt.Environment != "login.preferred.normalize.test" {
+			t.Errorf("Refresh token environment should be the preferred cache alias, instead it is %s", *rt.Environment)
+		}
+	}
+	for _, idt := range storageManager.ReadAllIDTokens() {
+		if *idt.Environment != "login.preferred.normalize.test" {
+			t.Errorf("ID token environment should be the preferred cache alias, instead it is %s", *idt.Environment)
+		}
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if storageTokenResponse.RefreshToken == nil || storageTokenResponse.RefreshToken.GetSecret() != "refreshToken" {
+		t.Error("The refresh token written under the preferred cache alias should be read")
+	}
+}
+
+func TestTryReadCacheInstanceDiscoveryDisabled(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "login.nodiscovery-two.test", Tenant: "realm", AuthorityType: msalbase.MSSTS, InstanceDiscoveryDisabled: true}
+	// The token of the other alias would be found with instance discovery, without it the host is the only alias
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("", "login.nodiscovery-one.test", "realm", "cid",
+		time.Now().Unix(), time.Now().Add(time.Hour).Unix(), time.Now().Add(time.Hour).Unix(), "openid", "aliasSecret"))
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"openid"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil && result.GetAccessToken() != "" {
+		t.Errorf("The token of another alias shouldn't be read without instance discovery, instead %s is", result.GetAccessToken())
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "hostSecret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "hostSecret" {
+		t.Errorf("The token written under the host should be read, instead the error is %v", err)
+	}
+	mockWebRequestManager.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", authInfo)
+}
+
+func TestCacheADFSTokenResponse(t *testing.T) {
+	mockStorageManager := new(MockStorageManager)
+	cacheManager := CreateCacheManager(mockStorageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "fs.contoso.com", Tenant: "adfs", AuthorityType: msalbase.ADFS}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	expiresOn := time.Now().Add(time.Hour)
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "adfsToken",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     expiresOn,
+		ExtExpiresOn:  expiresOn,
+	}
+	mockStorageManager.On("WriteAccessToken", mock.MatchedBy(func(at *accessTokenCacheItem) bool {
+		return *at.Realm == "" && *at.Environment == "fs.contoso.com"
+	})).Return(nil)
+	mockStorageManager.On("WriteAppMetadata", mock.AnythingOfType("*tokencache.appMetadata")).Return(nil)
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	var accessToken *accessTokenCacheItem
+	mockStorageManager.On("ReadAccessToken", "", []string{"fs.contoso.com"}, "", "cid", []string(nil), "").Return(accessToken)
+	if _, err := cacheManager.TryReadCache(context.Background(), authParams, new(requests.MockWebRequestManager)); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	mockStorageManager.AssertExpectations(t)
+}
+
+func TestCacheTokenResponse(t *testing.T) {
+	mockStorageManager := new(MockStorageManager)
+	now := time.Now()
+	cacheManager := &defaultCacheManager{storageManager: mockStorageManager, nowFunc: func() time.Time { return now }}
+	clientInfo := &msalbase.ClientInfoJSONPayload{
+		UID:  "testUID",
+		Utid: "testUtid",
+	}
+	idToken := &msalbase.IDToken{
+		RawToken:          "idToken",
+		Oid:               "lid",
+		PreferredUsername: "username",
+	}
+	expiresOn := time.Unix(now.Unix()+1000, 0).UTC()
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		IDToken:       idToken,
+		FamilyID:      "fid",
+		ClientInfo:    clientInfo,
+		GrantedScopes: []string{"openid", "profile"},
+		ExpiresOn:     expiresOn,
+		ExtExpiresOn:  now,
+	}
+	authInfo := &msalbase.AuthorityInfo{Host: "env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+	}
+	testRefreshToken := createRefreshTokenCacheItem(
+		"testUID.testUtid",
+		"env",
+		"cid",
+		"refreshToken",
+		"fid",
+	)
+	mockStorageManager.On("WriteRefreshToken", testRefreshToken).Return(nil)
+	mockStorageManager.On("DeleteRefreshToken", createRefreshTokenCacheItem("testUID.testUtid", "env", "cid", "", "")).Return(nil)
+	accessTokenCacheItem := createAccessTokenCacheItem(
+		"testUID.testUtid",
+		"env",
+		"realm",
+		"cid",
+		now.Unix(),
+		now.Unix()+1000,
+		now.Unix(),
+		"openid profile",
+		"accessToken",
+	)
+	mockStorageManager.On("WriteAccessToken", accessTokenCacheItem).Return(nil)
+	testIDToken := createIDTokenCacheItem(
+		"testUID.testUtid",
+		"env",
+		"realm",
+		"cid",
+		"idToken",
+	)
+	mockStorageManager.On("WriteIDToken", testIDToken).Return(nil)
+	testAccount := msalbase.CreateAccount("testUID.testUtid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	mockStorageManager.On("WriteAccount", testAccount).Return(nil)
+	testAppMeta := createAppMetadata("fid", "cid", "env")
+	mockStorageManager.On("WriteAppMetadata", testAppMeta).Return(nil)
+	actualAccount, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if !reflect.DeepEqual(actualAccount, testAccount) {
+		t.Errorf("Actual account %+v differs from expected account %+v", actualAccount, testAccount)
+	}
+}
+
+func TestCacheTokenResponseRollsBackOnFailedWrite(t *testing.T) {
+	mockStorageManager := new(MockStorageManager)
+	now := time.Now()
+	cacheManager := &defaultCacheManager{storageManager: mockStorageManager, nowFunc: func() time.Time { return now }}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		IDToken:       &msalbase.IDToken{RawToken: "idToken", Oid: "lid", PreferredUsername: "username"},
+		FamilyID:      "fid",
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "testUID", Utid: "testUtid"},
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     now.Add(time.Hour),
+		ExtExpiresOn:  now.Add(time.Hour),
+	}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: &msalbase.AuthorityInfo{Host: "env", Tenant: "realm", AuthorityType: msalbase.MSSTS},
+		ClientID:      "cid",
+	}
+	testRefreshToken := createRefreshTokenCacheItem("testUID.testUtid", "env", "cid", "refreshToken", "fid")
+	mockStorageManager.On("WriteRefreshToken", testRefreshToken).Return(nil)
+	mockStorageManager.On("WriteAccessToken", mock.AnythingOfType("*tokencache.accessTokenCacheItem")).Return(nil)
+	testIDToken := createIDTokenCacheItem("testUID.testUtid", "env", "realm", "cid", "idToken")
+	mockStorageManager.On("WriteIDToken", testIDToken).Return(nil)
+	mockStorageManager.On("WriteAccount", mock.AnythingOfType("*msalbase.Account")).Return(errors.New("account not written"))
+	mockStorageManager.On("DeleteIDToken", testIDToken).Return(nil)
+	mockStorageManager.On("DeleteAccessToken", mock.MatchedBy(func(at *accessTokenCacheItem) bool {
+		return at.GetSecret() == "accessToken"
+	})).Return(nil)
+	mockStorageManager.On("DeleteRefreshToken", testRefreshToken).Return(nil)
+	account, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err == nil {
+		t.Fatal("Error should be the failed write of the account, instead it is nil")
+	}
+	if account != nil {
+		t.Errorf("Account should be nil, instead it is %+v", account)
+	}
+	mockStorageManager.AssertExpectations(t)
+	mockStorageManager.AssertNotCalled(t, "WriteAppMetadata", mock.Anything)
+	mockStorageManager.AssertNumberOfCalls(t, "DeleteRefreshToken", 1)
+}
+
+func TestCacheTokenResponseScopeOrder(t *testing.T) {
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     &msalbase.AuthorityInfo{Host: "env", Tenant: "realm", AuthorityType: msalbase.MSSTS},
+		ClientID:          "cid",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	for _, grantedScopes := range [][]string{
+		{"user.read", "mail.read", "openid"},
+		{"openid", "Mail.Read", "user.read"},
+		{"mail.read", "openid", "user.read"},
+	} {
+		tokenResponse := &msalbase.TokenResponse{
+			AccessToken:   "accessToken",
+			GrantedScopes: grantedScopes,
+			ExpiresOn:     time.Now().Add(time.Hour),
+			ExtExpiresOn:  time.Now().Add(time.Hour),
+		}
+		if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+	}
+	if len(storageManager.accessTokens) != 1 {
+		t.Fatalf("The tokens for the same scopes should have one cache key; instead, the keys are %v", storageManager.accessTokens)
+	}
+	for _, at := range storageManager.accessTokens {
+		if at.GetScopes() != "mail.read openid user.read" {
+			t.Errorf("The cached scopes should be sorted; instead, they are %s", at.GetScopes())
+		}
+	}
+}
+
+func TestCacheTokenResponseKeepsRequestedScopes(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager, expirationBuffer: defaultExpirationBuffer}
+	authInfo := &msalbase.AuthorityInfo{Host: "requested.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"requested.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"user.read", "mail.read"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for _, at := range storageManager.accessTokens {
+		if at.GetScopes() != "user.read" || at.GetRequestedScopes() != "mail.read user.read" {
+			t.Errorf("The granted scopes should be user.read and the requested ones mail.read user.read; instead, they are %s and %s", at.GetScopes(), at.GetRequestedScopes())
+		}
+	}
+	// A later lookup of the granted scopes reports the scope declined when the token was acquired
+	readParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid", Scopes: []string{"user.read"}}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if !reflect.DeepEqual(result.GetGrantedScopes(), []string{"user.read"}) || !reflect.DeepEqual(result.GetDeclinedScopes(), []string{"mail.read"}) {
+		t.Errorf("The cached token should be granted user.read and declined mail.read; instead, it is granted %v and declined %v", result.GetGrantedScopes(), result.GetDeclinedScopes())
+	}
+
+	// The requested scopes aren't written when they were all granted
+	storageManager = CreateStorageManager().(*defaultStorageManager)
+	cacheManager.storageManager = storageManager
+	tokenResponse.GrantedScopes = []string{"mail.read", "user.read"}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for _, at := range storageManager.accessTokens {
+		if at.RequestedScopes != nil {
+			t.Errorf("The requested scopes shouldn't be kept when they were granted; instead, they are %s", *at.RequestedScopes)
+		}
+	}
+}
+
+func TestCacheTokenResponseRotatesRefreshToken(t *testing.T) {
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	authInfo := &msalbase.AuthorityInfo{Host: "env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	authParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid", AuthorizationType: msalbase.AuthorizationTypeAuthCode}
+	firstResponse := &msalbase.TokenResponse{
+		RefreshToken: "firstRefreshToken",
+		ClientInfo:   &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, firstResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	// The refresh response has no client info, the rotated token still belongs to the account of the redeemed one
+	refreshParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		HomeaccountID:     "uid.utid",
+		AuthorizationType: msalbase.AuthorizationTypeRefreshTokenExchange,
+	}
+	if _, err := cacheManager.CacheTokenResponse(refreshParams, &msalbase.TokenResponse{RefreshToken: "rotatedRefreshToken", ClientInfo: &msalbase.ClientInfoJSONPayload{}}); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if len(storageManager.refreshTokens) != 1 {
+		t.Fatalf("The rotated refresh token should replace the old one; instead, the cached refresh tokens are %v", storageManager.refreshTokens)
+	}
+	for _, rt := range storageManager.refreshTokens {
+		if rt.GetSecret() != "rotatedRefreshToken" || msalbase.GetStringFromPointer(rt.HomeAccountID) != "uid.utid" {
+			t.Errorf("The cached refresh token should be the rotated one of uid.utid; instead, it is %s of %s", rt.GetSecret(), msalbase.GetStringFromPointer(rt.HomeAccountID))
+		}
+	}
+	if _, err := cacheManager.CacheTokenResponse(refreshParams, &msalbase.TokenResponse{RefreshToken: "familyRefreshToken", FamilyID: "1", ClientInfo: &msalbase.ClientInfoJSONPayload{}}); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if len(storageManager.refreshTokens) != 1 {
+		t.Fatalf("The family refresh token should supersede the app's own; instead, the cached refresh tokens are %v", storageManager.refreshTokens)
+	}
+}
+
+func TestCacheTokenResponseEvictsLeastRecentlyUsedAccount(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager, expirationBuffer: defaultExpirationBuffer}
+	authInfo := &msalbase.AuthorityInfo{Host: "eviction.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"eviction.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	cacheAccount := func(uid string) {
+		authParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid", AuthorizationType: msalbase.AuthorizationTypeAuthCode}
+		tokenResponse := &msalbase.TokenResponse{
+			AccessToken:   uid + "AccessToken",
+			RefreshToken:  uid + "RefreshToken",
+			GrantedScopes: []string{"user.read"},
+			ExpiresOn:     time.Now().Add(time.Hour),
+			ExtExpiresOn:  time.Now().Add(time.Hour),
+			ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: uid, Utid: "utid"},
+		}
+		if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+	}
+	cachedAccounts := func() map[string]bool {
+		accounts := map[string]bool{}
+		for _, rt := range storageManager.ReadAllRefreshTokens() {
+			accounts[msalbase.GetStringFromPointer(rt.HomeAccountID)] = true
+		}
+		for _, at := range storageManager.ReadAllAccessTokens() {
+			accounts[msalbase.GetStringFromPointer(at.HomeAccountID)] = true
+		}
+		return accounts
+	}
+
+	// Without a limit no account is evicted
+	for _, uid := range []string{"first", "second", "third"} {
+		cacheAccount(uid)
+	}
+	if len(cachedAccounts()) != 3 {
+		t.Fatalf("The cache shouldn't evict accounts without a limit; instead, it holds %v", cachedAccounts())
+	}
+	if err := cacheManager.Clear(); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+
+	cacheManager.SetMaxAccounts(2)
+	cacheAccount("first")
+	cacheAccount("second")
+	// Reading the tokens of the first account makes the second one the least recently used
+	readParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid", HomeaccountID: "first.utid", Scopes: []string{"user.read"}}
+	if _, err := cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	cacheAccount("third")
+	expected := map[string]bool{"first.utid": true, "third.utid": true}
+	if actual := cachedAccounts(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("The least recently used account should be evicted; expected the tokens of %v, instead the cache holds %v", expected, actual)
+	}
+	if len(storageManager.ReadAllRefreshTokens()) != 2 || len(storageManager.ReadAllAccessTokens()) != 2 {
+		t.Errorf("All the tokens of the evicted account should be deleted; instead, the cache holds %d refresh tokens and %d access tokens",
+			len(storageManager.ReadAllRefreshTokens()), len(storageManager.ReadAllAccessTokens()))
+	}
+}
+
+func TestClear(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	if err := cacheManager.Clear(); err != nil {
+		t.Errorf("Clearing an empty cache should succeed, instead the error is %v", err)
+	}
+	authInfo := &msalbase.AuthorityInfo{Host: "clear.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"clear.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"openid"},
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		IDToken:       &msalbase.IDToken{RawToken: "idToken", Oid: "lid", PreferredUsername: "username"},
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(authParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if err := cacheManager.Clear(); err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if accounts := cacheManager.GetAllAccounts(); len(accounts) != 0 {
+		t.Errorf("The cache should have no accounts, instead it has %v", accounts)
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if _, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Error("The access token should be removed from the cache")
+	}
+	if !reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() {
+		t.Errorf("The refresh token should be removed from the cache, instead it is %v", storageTokenResponse.RefreshToken)
+	}
+	if len(storageManager.ReadAllAccessTokens()) != 0 || len(storageManager.ReadAllRefreshTokens()) != 0 ||
+		len(storageManager.ReadAllIDTokens()) != 0 || len(storageManager.ReadAllAppMetadata()) != 0 {
+		t.Error("Every entity should be removed from the cache")
+	}
+}
+
+func TestClearAggregatesErrors(t *testing.T) {
+	mockStorageManager := new(MockStorageManager)
+	cacheManager := CreateCacheManager(mockStorageManager)
+	accessToken := createAccessTokenCacheItem("hid", "env", "realm", "cid", 0, 0, 0, "openid", "secret")
+	refreshToken := createRefreshTokenCacheItem("hid", "env", "cid", "secret", "")
+	idToken := createIDTokenCacheItem("hid", "env", "realm", "cid", "secret")
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	appMeta := createAppMetadata("", "cid", "env")
+	mockStorageManager.On("ReadAllAccessTokens").Return([]*accessTokenCacheItem{accessToken})
+	mockStorageManager.On("DeleteAccessToken", accessToken).Return(errors.New("access token not deleted"))
+	mockStorageManager.On("ReadAllRefreshTokens").Return([]*refreshTokenCacheItem{refreshToken})
+	mockStorageManager.On("DeleteRefreshToken", refreshToken).Return(nil)
+	mockStorageManager.On("ReadAllIDTokens").Return([]*idTokenCacheItem{idToken})
+	mockStorageManager.On("DeleteIDToken", idToken).Return(errors.New("id token not deleted"))
+	mockStorageManager.On("ReadAllAccounts").Return([]*msalbase.Account{account})
+	mockStorageManager.On("DeleteAccount", account).Return(nil)
+	mockStorageManager.On("ReadAllAppMetadata").Return([]*appMetadata{appMeta})
+	mockStorageManager.On("DeleteAppMetadata", appMeta).Return(nil)
+	err := cacheManager.Clear()
+	var clearErr *ClearError
+	if !errors.As(err, &clearErr) || len(clearErr.Errors) != 2 {
+		t.Fatalf("Both failures should be returned in a ClearError, instead the error is %v", err)
+	}
+	mockStorageManager.AssertCalled(t, "DeleteRefreshToken", refreshToken)
+	mockStorageManager.AssertCalled(t, "DeleteAccount", account)
+	mockStorageManager.AssertCalled(t, "DeleteAppMetadata", appMeta)
+}
+
+func TestSerializeDeserializeCache(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "serialize.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"openid", "profile"},
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		IDToken:       &msalbase.IDToken{RawToken: idSecret, Oid: "lid", PreferredUsername: "username"},
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "testUID", Utid: "testUtid"},
+		GrantedScopes: []string{"openid", "profile"},
+		ExpiresOn:     time.Unix(time.Now().Unix()+1000, 0).UTC(),
+		ExtExpiresOn:  time.Now(),
+	}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"serialize.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	_, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	expectedStorageToken, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	data, err := cacheManager.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	newCacheManager := CreateCacheManager(CreateStorageManager())
+	err = newCacheManager.DeserializeCache(data)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	actualStorageToken, err := newCacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	expectedResult, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(expectedStorageToken)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	actualResult, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(actualStorageToken)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if expectedResult.GetAccessToken() != actualResult.GetAccessToken() {
+		t.Errorf("Expected access token %s differs from actual access token %s", expectedResult.GetAccessToken(), actualResult.GetAccessToken())
+	}
+	if expectedResult.GetAccount().CreateKey() != actualResult.GetAccount().CreateKey() {
+		t.Errorf("Expected account %s differs from actual account %s", expectedResult.GetAccount().CreateKey(), actualResult.GetAccount().CreateKey())
+	}
+	if expectedStorageToken.RefreshToken.GetSecret() != actualStorageToken.RefreshToken.GetSecret() {
+		t.Errorf("Expected refresh token %s differs from actual refresh token %s",
+			expectedStorageToken.RefreshToken.GetSecret(), actualStorageToken.RefreshToken.GetSecret())
+	}
+	reserializedData, err := newCacheManager.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if string(data) != string(reserializedData) {
+		t.Errorf("Reserialized cache %s differs from original cache %s", reserializedData, data)
+	}
+}
+
+func TestDeserializeCacheMerges(t *testing.T) {
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	existingAccount := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	err := storageManager.WriteAccount(existingAccount)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	jsonFile, err := os.Open(testFile)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	testCache, err := ioutil.ReadAll(jsonFile)
+	jsonFile.Close()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	err = cacheManager.DeserializeCache(testCache)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	accounts := cacheManager.GetAllAccounts()
+	if len(accounts) != 2 {
+		t.Errorf("Cache should have 2 accounts after merging; instead, it has %d", len(accounts))
+	}
+	data, err := cacheManager.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if !strings.Contains(string(data), "unknownEntity") {
+		t.Errorf("Unknown entities should be preserved in the serialized cache %s", data)
+	}
+}
+
+func TestRemoveAccount(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	accountOne := msalbase.CreateAccount("hid", "remove.env", "realm1", "lid", msalbase.MSSTS, "username")
+	accountTwo := msalbase.CreateAccount("hid", "remove.env", "realm2", "lid", msalbase.MSSTS, "username")
+	otherAccount := msalbase.CreateAccount("otherHid", "remove.env", "realm1", "lid", msalbase.MSSTS, "other")
+	for _, acc := range []*msalbase.Account{accountOne, accountTwo, otherAccount} {
+		realm := msalbase.GetStringFromPointer(acc.Realm)
+		storageManager.WriteAccount(acc)
+		storageManager.WriteAccessToken(createAccessTokenCacheItem(acc.GetHomeAccountID(), "remove.env", realm, "cid", 1, 1, 1, "openid", "secret"))
+		storageManager.WriteIDToken(createIDTokenCacheItem(acc.GetHomeAccountID(), "remove.env", realm, "cid", "secret"))
+	}
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "remove.env", "cid", "secret", ""))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("otherHid", "remove.env", "cid", "secret", ""))
+	storageManager.WriteAppMetadata(createAppMetadata("", "cid", "remove.env"))
+	authInfo := &msalbase.AuthorityInfo{Host: "remove.env", Tenant: "realm1", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"remove.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	err := cacheManager.RemoveAccount(context.Background(), accountOne, mockWebRequestManager)
+	if err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if len(storageManager.accounts) != 1 || storageManager.accounts[otherAccount.CreateKey()] == nil {
+		t.Errorf("Only the other account should remain in the cache, instead the cache has %v", storageManager.accounts)
+	}
+	if len(storageManager.accessTokens) != 1 || len(storageManager.idTokens) != 1 || len(storageManager.refreshTokens) != 1 {
+		t.Errorf("Only the other account's tokens should remain in the cache")
+	}
+	for _, rt := range storageManager.refreshTokens {
+		if rt.GetSecret() != "secret" || msalbase.GetStringFromPointer(rt.HomeAccountID) != "otherHid" {
+			t.Errorf("Refresh token %v shouldn't remain in the cache", rt)
+		}
+	}
+	if len(storageManager.appMetadatas) != 1 {
+		t.Errorf("App metadata shouldn't be removed along with the account")
+	}
+	err = cacheManager.RemoveAccount(context.Background(), accountOne, mockWebRequestManager)
+	if err != nil {
+		t.Errorf("Removing an absent account should return nil; instead, it returns %v", err)
+	}
+}
+
+func TestRemoveAccountFromPartitions(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := &defaultCacheManager{storageManager: CreateStorageManager()}
+	account := msalbase.CreateAccount("hid", "removepartition.env", "realm", "lid", msalbase.MSSTS, "username")
+	otherAccount := msalbase.CreateAccount("otherHid", "removepartition.env", "realm", "lid", msalbase.MSSTS, "other")
+	for _, partitionKey := range []string{"hid", "otherHid"} {
+		partition := cacheManager.getPartition(partitionKey, true).(*defaultStorageManager)
+		for _, acc := range []*msalbase.Account{account, otherAccount} {
+			partition.WriteAccount(acc)
+			partition.WriteAccessToken(createAccessTokenCacheItem(acc.GetHomeAccountID(), "removepartition.env", "realm", "cid", 1, 1, 1, "openid", "secret"))
+			partition.WriteRefreshToken(createRefreshTokenCacheItem(acc.GetHomeAccountID(), "removepartition.env", "cid", "secret", ""))
+			partition.WriteIDToken(createIDTokenCacheItem(acc.GetHomeAccountID(), "removepartition.env", "realm", "cid", "secret"))
+		}
+	}
+	cacheManager.maxAccounts = 2
+	for _, partitionKey := range []string{"", "hid", "otherHid"} {
+		cacheManager.recordAccountAccess(partitionKey, "hid")
+		cacheManager.recordAccountAccess(partitionKey, "otherHid")
+	}
+	authInfo := &msalbase.AuthorityInfo{Host: "removepartition.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"removepartition.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	if err := cacheManager.RemoveAccount(context.Background(), account, mockWebRequestManager); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for partitionKey, p := range cacheManager.partitions {
+		partition := p.(*defaultStorageManager)
+		if len(partition.accounts) != 1 || partition.accounts[otherAccount.CreateKey()] == nil {
+			t.Errorf("Only the other account should remain in partition '%s', instead it has %v", partitionKey, partition.accounts)
+		}
+		if len(partition.accessTokens) != 1 || len(partition.refreshTokens) != 1 || len(partition.idTokens) != 1 {
+			t.Errorf("Only the other account's tokens should remain in partition '%s'", partitionKey)
+		}
+	}
+	if accounts := cacheManager.GetAllAccounts(); len(accounts) != 1 || accounts[0].GetHomeAccountID() != "otherHid" {
+		t.Errorf("Only the other account should be returned, instead the accounts are %v", accounts)
+	}
+	// The accesses of the removed account are forgotten in every partition, the other account's are kept
+	for key := range cacheManager.accountAccess {
+		if key.homeAccountID != "otherHid" {
+			t.Errorf("The access of the removed account in partition '%s' should be forgotten", key.partitionKey)
+		}
+	}
+	if len(cacheManager.accountAccess) != 3 {
+		t.Errorf("The accesses of the other account in the 3 partitions should be kept, instead there are %v", cacheManager.accountAccess)
+	}
+}
+
+func TestDeleteAccessTokens(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	account := msalbase.CreateAccount("hid", "deleteat.env", "realm", "lid", msalbase.MSSTS, "username")
+	storageManager.WriteAccount(account)
+	revoked := createAccessTokenCacheItem("hid", "deleteat.env", "realm", "cid", 1, 1, 1, "openid user.read", "revokedSecret")
+	kept := createAccessTokenCacheItem("hid", "deleteat.env", "realm", "cid", 1, 1, 1, "openid mail.read", "keptSecret")
+	otherAccount := createAccessTokenCacheItem("otherHid", "deleteat.env", "realm", "cid", 1, 1, 1, "user.read", "otherSecret")
+	for _, at := range []*accessTokenCacheItem{revoked, kept, otherAccount} {
+		storageManager.WriteAccessToken(at)
+	}
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "deleteat.env", "cid", "secret", ""))
+	storageManager.WriteIDToken(createIDTokenCacheItem("hid", "deleteat.env", "realm", "cid", "secret"))
+	partitioned := createAccessTokenCacheItem("hid", "deleteat.env", "realm", "cid", 1, 1, 1, "user.read", "partitionedSecret")
+	cacheManager.getPartition("partitionKey", true).WriteAccessToken(partitioned)
+	authInfo := &msalbase.AuthorityInfo{Host: "deleteat.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"deleteat.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	removed, err := cacheManager.DeleteAccessTokens(context.Background(), account, []string{"User.Read"}, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("2 access tokens should be deleted; instead, %d are", removed)
+	}
+	if storageManager.accessTokens[revoked.CreateKey()] != nil || len(cacheManager.partitions["partitionKey"].ReadAllAccessTokens()) != 0 {
+		t.Error("The access tokens for the scope should be deleted")
+	}
+	if storageManager.accessTokens[kept.CreateKey()] == nil || storageManager.accessTokens[otherAccount.CreateKey()] == nil {
+		t.Error("The access tokens for other scopes and accounts should be kept")
+	}
+	if len(storageManager.refreshTokens) != 1 || len(storageManager.idTokens) != 1 || len(storageManager.accounts) != 1 {
+		t.Error("The refresh token, ID token and account should be kept")
+	}
+	removed, err = cacheManager.DeleteAccessTokens(context.Background(), account, []string{"user.read"}, mockWebRequestManager)
+	if err != nil || removed != 0 {
+		t.Errorf("Deleting absent tokens should remove nothing and return nil; instead, it removes %d and returns %v", removed, err)
+	}
+}
+
+func TestDeleteCachedRefreshToken(t *testing.T) {
+	storageManager := CreateStorageManager()
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "env", "cid", "secret", ""))
+	authParameters := &msalbase.AuthParametersInternal{
+		HomeaccountID: "hid",
+		AuthorityInfo: &msalbase.AuthorityInfo{Host: "env", Tenant: "realm"},
+		ClientID:      "cid",
+	}
+	err := cacheManager.DeleteCachedRefreshToken(authParameters)
+	if err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if rt := storageManager.ReadRefreshToken("hid", []string{"env"}, "", "cid"); rt != nil {
+		t.Errorf("Refresh token should have been deleted, instead it is %v", rt)
+	}
+	authParameters.HomeaccountID = ""
+	err = cacheManager.DeleteCachedRefreshToken(authParameters)
+	if err == nil {
+		t.Error("Error should not be nil when the home account ID is empty")
+	}
+}
+
+func TestCacheKeyIncomplete(t *testing.T) {
+	cacheManager := CreateCacheManager(new(MockStorageManager))
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	tests := []struct {
+		desc          string
+		homeAccountID string
+		host          string
+		clientID      string
+		key           string
+	}{
+		{"empty environment", "hid", "", "cid", "environment"},
+		{"empty client ID", "hid", "env", "", "clientID"},
+	}
+	for _, test := range tests {
+		authParams := &msalbase.AuthParametersInternal{
+			HomeaccountID: test.homeAccountID,
+			AuthorityInfo: &msalbase.AuthorityInfo{Host: test.host, Tenant: "realm"},
+			ClientID:      test.clientID,
+		}
+		_, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+		checkCacheKeyError(t, "TryReadCache with "+test.desc, err, test.key)
+		_, err = cacheManager.CacheTokenResponse(authParams, &msalbase.TokenResponse{ClientInfo: &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"}})
+		checkCacheKeyError(t, "CacheTokenResponse with "+test.desc, err, test.key)
+	}
+	authParams := &msalbase.AuthParametersInternal{AuthorityInfo: &msalbase.AuthorityInfo{Host: "env"}, ClientID: "cid"}
+	err := cacheManager.DeleteCachedRefreshToken(authParams)
+	checkCacheKeyError(t, "DeleteCachedRefreshToken with empty home account ID", err, "homeAccountID")
+	authParams = &msalbase.AuthParametersInternal{HomeaccountID: "hid", AuthorityInfo: &msalbase.AuthorityInfo{}, ClientID: "cid"}
+	err = cacheManager.DeleteCachedRefreshToken(authParams)
+	checkCacheKeyError(t, "DeleteCachedRefreshToken with empty environment", err, "environment")
+}
+
+func checkCacheKeyError(t *testing.T, desc string, err error, key string) {
+	if !errors.Is(err, ErrCacheKeyIncomplete) {
+		t.Errorf("%s: error should match ErrCacheKeyIncomplete, instead it is %v", desc, err)
+	}
+	var keyErr *CacheKeyError
+	if !errors.As(err, &keyErr) || keyErr.Key != key {
+		t.Errorf("%s: error should name the key %s, instead it is %v", desc, key, err)
+	}
+}
+
+type fakeLogger struct {
+	lines []string
+}
+
+func (l *fakeLogger) log(level string, msg string) {
+	l.lines = append(l.lines, level+": "+msg)
+}
+func (l *fakeLogger) Trace(args ...interface{}) { l.log("trace", fmt.Sprint(args...)) }
+func (l *fakeLogger) Tracef(format string, args ...interface{}) {
+	l.log("trace", fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Info(args ...interface{}) { l.log("info", fmt.Sprint(args...)) }
+func (l *fakeLogger) Infof(format string, args ...interface{}) {
+	l.log("info", fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Warn(args ...interface{}) { l.log("warn", fmt.Sprint(args...)) }
+func (l *fakeLogger) Warnf(format string, args ...interface{}) {
+	l.log("warn", fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Error(args ...interface{}) { l.log("error", fmt.Sprint(args...)) }
+func (l *fakeLogger) Errorf(format string, args ...interface{}) {
+	l.log("error", fmt.Sprintf(format, args...))
+}
+
+func TestRemoveExpiredAccessTokens(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	storageManager := CreateStorageManager()
+	cacheManager := &defaultCacheManager{storageManager: storageManager, nowFunc: func() time.Time { return now }}
+	fresh := createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix(), now.Unix()+1, now.Unix()+1, "fresh", "secret")
+	expired := createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix()-3600, now.Unix(), now.Unix(), "expired", "secret")
+	olderExpired := createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix()-7200, now.Unix()-3600, now.Unix()-3600, "older", "secret")
+	invalid := createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix(), 0, 0, "invalid", "secret")
+	errorTimestamp := "TIMESTAMP_SHOULD_BE_INT"
+	invalid.ExpiresOnUnixTimestamp = &errorTimestamp
+	missing := createAccessTokenCacheItem("hid", "env", "realm", "cid", now.Unix(), 0, 0, "missing", "secret")
+	missing.ExpiresOnUnixTimestamp = nil
+	for _, at := range []*accessTokenCacheItem{fresh, expired, olderExpired, invalid, missing} {
+		storageManager.WriteAccessToken(at)
+	}
+	removed, err := cacheManager.RemoveExpiredAccessTokens()
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Two access tokens should have been removed, instead %d were", removed)
+	}
+	remaining := map[string]bool{}
+	for _, at := range storageManager.ReadAllAccessTokens() {
+		remaining[*at.Scopes] = true
+	}
+	expected := map[string]bool{"fresh": true, "invalid": true, "missing": true}
+	if !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("Remaining access tokens %v differ from expected access tokens %v", remaining, expected)
+	}
+}
+
+func TestCacheManagerLogsThroughLogger(t *testing.T) {
+	logger := &fakeLogger{}
+	msalbase.SetLogger(logger)
+	defer msalbase.SetLogger(nil)
+	cacheManager := &defaultCacheManager{storageManager: CreateStorageManager()}
+	authParameters := &msalbase.AuthParametersInternal{
+		AuthorityInfo: &msalbase.AuthorityInfo{Host: "env", Tenant: "realm"},
+		ClientID:      "cid",
+	}
+	cacheManager.DeleteCachedRefreshToken(authParameters)
+	expectedLines := []string{
+		"info: Deleting refresh token from the cache for homeAccountId '' environment 'env' clientId 'cid'",
+		"warn: Failed to delete refresh token from the cache, one of the primary keys is empty",
+	}
+	if !reflect.DeepEqual(logger.lines, expectedLines) {
+		t.Errorf("Logged lines should be %v, instead they are %v", expectedLines, logger.lines)
+	}
+}
+
+func TestCacheAppTokenResponse(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	authInfo := &msalbase.AuthorityInfo{Host: "app.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"https://graph.microsoft.com/.default"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{},
+		GrantedScopes: []string{"https://graph.microsoft.com/.default"},
+		ExpiresOn:     time.Unix(time.Now().Unix()+1000, 0).UTC(),
+		ExtExpiresOn:  time.Now(),
+	}
+	account, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if account != nil {
+		t.Errorf("No account should be cached for an app token, instead %v is", account)
+	}
+	if len(storageManager.accessTokens) != 1 || len(storageManager.appMetadatas) != 1 {
+		t.Errorf("The access token and app metadata should be cached")
+	}
+	if len(storageManager.accounts) != 0 || len(storageManager.idTokens) != 0 || len(storageManager.refreshTokens) != 0 {
+		t.Errorf("No user-centric entries should be cached for an app token")
+	}
+	for _, at := range storageManager.accessTokens {
+		if msalbase.GetStringFromPointer(at.HomeAccountID) != "" || msalbase.GetStringFromPointer(at.ClientID) != "cid" {
+			t.Errorf("App token should be keyed on the client ID only, instead its key is %v", at.CreateKey())
+		}
+	}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"app.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result.GetAccessToken() != "accessToken" {
+		t.Errorf("Access token should be accessToken, instead it is %v", result.GetAccessToken())
+	}
+}
+
+func TestCacheOnBehalfOfTokenResponse(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	cacheManager := &defaultCacheManager{storageManager: storageManager}
+	authInfo := &msalbase.AuthorityInfo{Host: "obo.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		HomeaccountID:     "userSubject",
+		Scopes:            []string{"api://downstream/.default"},
+		AuthorizationType: msalbase.AuthorizationTypeOnBehalfOf,
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+		GrantedScopes: []string{"api://downstream/.default"},
+		ExpiresOn:     time.Unix(time.Now().Unix()+1000, 0).UTC(),
+		ExtExpiresOn:  time.Now(),
+	}
+	_, err := cacheManager.CacheTokenResponse(authParams, tokenResponse)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	for _, at := range storageManager.accessTokens {
+		if msalbase.GetStringFromPointer(at.HomeAccountID) != "userSubject" {
+			t.Errorf("On-behalf-of token should be keyed on the assertion subject, instead its key is %v", at.CreateKey())
+		}
+	}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"obo.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "accessToken" {
+		t.Errorf("Cached on-behalf-of token should be returned, instead the result is %v and the error is %v", result, err)
+	}
+}
+
+func TestTryReadCacheNormalizesScopes(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "scopes.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"scopes.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "scopesSecret",
+		GrantedScopes: []string{"User.Read", " Mail.Send", "user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	writeParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	if _, err := cacheManager.CacheTokenResponse(writeParams, tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	serialized, _ := cacheManager.SerializeCache()
+	if !strings.Contains(string(serialized), `"target":"mail.send user.read"`) {
+		t.Errorf("The token should be cached for the canonical scopes, instead the cache is %s", serialized)
+	}
+	readParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo: authInfo,
+		ClientID:      "cid",
+		Scopes:        []string{"MAIL.SEND", "mail.send ", "User.Read"},
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil || result.GetAccessToken() != "scopesSecret" {
+		t.Errorf("The token should be read for scopes in another case, instead the error is %v", err)
+	}
+}
+
+func TestTryReadCacheScopeSubset(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	cacheManager := CreateCacheManager(CreateStorageManager())
+	authInfo := &msalbase.AuthorityInfo{Host: "subset.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"subset.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	createParams := func(scopes ...string) *msalbase.AuthParametersInternal {
+		return &msalbase.AuthParametersInternal{
+			AuthorityInfo:     authInfo,
+			ClientID:          "cid",
+			Scopes:            scopes,
+			AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+		}
+	}
+	tokenResponse := &msalbase.TokenResponse{
+		AccessToken:   "subsetSecret",
+		GrantedScopes: []string{"user.read", "mail.send"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	if _, err := cacheManager.CacheTokenResponse(createParams("user.read", "mail.send"), tokenResponse); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), createParams("mail.send"), mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	if err != nil {
+		t.Fatalf("The token should be read for a subset of its scopes, instead the error is %v", err)
+	}
+	// The result reports the scopes the token was granted, sorted as they are cached, not only the requested ones
+	if !reflect.DeepEqual(result.GrantedScopes, []string{"mail.send", "user.read"}) {
+		t.Errorf("Granted scopes should be the scopes of the cached token, instead they are %v", result.GrantedScopes)
+	}
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), createParams("mail.send", "files.read"), mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil {
+		t.Errorf("The token shouldn't be read for more scopes than it was granted, instead %v is read", result.GetAccessToken())
+	}
+}
+
+func TestImportRefreshToken(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	cacheManager := CreateCacheManager(storageManager)
+	authInfo := &msalbase.AuthorityInfo{Host: "migration.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"migration.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	authParams := &msalbase.AuthParametersInternal{AuthorityInfo: authInfo, ClientID: "cid"}
+	if err := cacheManager.ImportRefreshToken(authParams, "", "importedSecret", "1"); err == nil {
+		t.Error("Error should not be nil for a refresh token without a home account ID")
+	}
+	if err := cacheManager.ImportRefreshToken(authParams, "hid", "importedSecret", "1"); err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	// The app that imported the token and the other apps of its family both redeem it
+	storageManager.WriteAppMetadata(createAppMetadata("1", "familyCid", "migration.env"))
+	for _, clientID := range []string{"cid", "familyCid"} {
+		readParams := &msalbase.AuthParametersInternal{
+			AuthorityInfo: authInfo,
+			ClientID:      clientID,
+			HomeaccountID: "hid",
+			Scopes:        []string{"openid"},
+		}
+		storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), readParams, mockWebRequestManager)
+		if err != nil {
+			t.Fatalf("Error should be nil; instead, it is %v", err)
+		}
+		if reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() || storageTokenResponse.RefreshToken.GetSecret() != "importedSecret" {
+			t.Errorf("The imported refresh token should be read for client %s, instead the refresh token is %v", clientID, storageTokenResponse.RefreshToken)
+		}
+	}
+	account := msalbase.CreateAccount("hid", "migration.env", "realm", "", msalbase.MSSTS, "")
+	exported, err := cacheManager.ExportRefreshToken(context.Background(), account, "cid", mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if exported != "importedSecret" {
+		t.Errorf("The exported refresh token should be importedSecret, instead it is %s", exported)
+	}
+	exported, err = cacheManager.ExportRefreshToken(context.Background(), account, "unrelatedCid", mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if exported != "" {
+		t.Errorf("No refresh token should be exported for an app outside of the family, instead %s is", exported)
+	}
+}
+
+func TestTryReadCacheSkipExpirationBuffer(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	storageManager := CreateStorageManager()
+	now := time.Now()
+	cacheManager := &defaultCacheManager{storageManager: storageManager, expirationBuffer: defaultExpirationBuffer, nowFunc: func() time.Time { return now }}
+	authInfo := &msalbase.AuthorityInfo{Host: "skipbuffer.env", Tenant: "realm", AuthorityType: msalbase.MSSTS}
+	mockInstDiscResponse := &requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"skipbuffer.env"}}},
+	}
+	mockWebRequestManager.On("GetAadinstanceDiscoveryResponse", authInfo).Return(mockInstDiscResponse, nil)
+	// The token expires within the expiration buffer, but hasn't expired yet
+	expiresOn := now.Add(2 * time.Minute).Unix()
+	accessToken := createAccessTokenCacheItem("", "skipbuffer.env", "realm", "cid", now.Unix(), expiresOn, expiresOn, "user.read", "secret")
+	storageManager.WriteAccessToken(accessToken)
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     authInfo,
+		ClientID:          "cid",
+		Scopes:            []string{"user.read"},
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	storageTokenResponse, err := cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil && result.GetAccessToken() != "" {
+		t.Error("A token within the expiration buffer shouldn't be returned by default")
+	}
+	authParams.SkipExpirationBuffer = true
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err != nil || result.GetAccessToken() != "secret" {
+		t.Fatalf("The token should be returned until it expires when the buffer is skipped, instead the result is %+v", result)
+	}
+	cached := storageManager.ReadAllAccessTokens()
+	if len(cached) != 1 || *cached[0].ExpiresOnUnixTimestamp != strconv.FormatInt(expiresOn, 10) {
+		t.Errorf("The cached token shouldn't be changed, instead the cache has %+v", cached)
+	}
+	now = now.Add(3 * time.Minute)
+	storageTokenResponse, err = cacheManager.TryReadCache(context.Background(), authParams, mockWebRequestManager)
+	if err != nil {
+		t.Fatalf("Error should be nil; instead, it is %v", err)
+	}
+	if result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse); err == nil && result.GetAccessToken() != "" {
+		t.Error("An expired token shouldn't be returned even when the buffer is skipped")
+	}
+}
+
+func TestGetAllAccessTokens(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	storageManager := CreateStorageManager()
+	manager := CreateCacheManager(storageManager).(*defaultCacheManager)
+	manager.nowFunc = func() time.Time { return now }
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid",
+		now.Unix(), now.Add(time.Hour).Unix(), now.Add(time.Hour).Unix(), "openid user.read", "validSecret"))
+	// The token hasn't expired yet, but it expires within the expiration buffer so the cache doesn't return it
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid",
+		now.Unix(), now.Add(time.Minute).Unix(), now.Add(time.Minute).Unix(), "mail.read", "expiringSecret"))
+	manager.getPartition("partitionKey", true).WriteAccessToken(createAccessTokenCacheItem("", "env", "realm", "cid",
+		now.Unix(), now.Add(time.Hour).Unix(), now.Add(time.Hour).Unix(), "files.read", "partitionSecret"))
+	accessTokens := manager.GetAllAccessTokens(false)
+	if len(accessTokens) != 3 {
+		t.Fatalf("The three cached access tokens should be listed, instead %d are", len(accessTokens))
+	}
+	bySecret := map[string]*msalbase.CachedAccessToken{}
+	for _, at := range accessTokens {
+		bySecret[at.Secret] = at
+	}
+	valid := bySecret["validSecret"]
+	if valid == nil || !valid.Valid || !reflect.DeepEqual(valid.Scopes, []string{"openid", "user.read"}) || !valid.ExpiresOn.Equal(now.Add(time.Hour)) {
+		t.Errorf("The valid access token should be listed with its scopes and expiry, instead it is %+v", valid)
+	}
+	if expiring := bySecret["expiringSecret"]; expiring == nil || expiring.Valid {
+		t.Errorf("The access token expiring within the buffer should be listed as not valid, instead it is %+v", expiring)
+	}
+	if partitioned := bySecret["partitionSecret"]; partitioned == nil || partitioned.PartitionKey != "partitionKey" {
+		t.Errorf("The access token of the partition should be listed with its partition key, instead it is %+v", partitioned)
+	}
+	for _, at := range manager.GetAllAccessTokens(true) {
+		if at.Secret != "" {
+			t.Errorf("The secrets should be redacted, instead %s is listed", at.Secret)
+		}
+	}
+}
+
+func TestGetAllRefreshTokens(t *testing.T) {
+	storageManager := CreateStorageManager()
+	manager := CreateCacheManager(storageManager).(*defaultCacheManager)
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "env", "cid", "appSecret", ""))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("otherHid", "env", "cid", "familySecret", "1"))
+	refreshTokens := manager.GetAllRefreshTokens(false)
+	if len(refreshTokens) != 2 {
+		t.Fatalf("The two cached refresh tokens should be listed, instead %d are", len(refreshTokens))
+	}
+	for _, rt := range refreshTokens {
+		expected := &msalbase.CachedRefreshToken{HomeAccountID: "hid", Environment: "env", ClientID: "cid", Secret: "appSecret"}
+		if rt.FamilyID != "" {
+			expected = &msalbase.CachedRefreshToken{HomeAccountID: "otherHid", Environment: "env", ClientID: "cid", FamilyID: "1", Secret: "familySecret"}
+		}
+		if !reflect.DeepEqual(rt, expected) {
+			t.Errorf("Actual refresh token %+v differs from expected refresh token %+v", rt, expected)
+		}
+	}
+	for _, rt := range manager.GetAllRefreshTokens(true) {
+		if rt.Secret != "" {
+			t.Errorf("The secrets should be redacted, instead %s is listed", rt.Secret)
+		}
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	storageManager := CreateStorageManager()
+	manager := CreateCacheManager(storageManager).(*defaultCacheManager)
+	for _, hid := range []string{"hid", "otherHid"} {
+		storageManager.WriteAccount(msalbase.CreateAccount(hid, "env", "realm", "lid", msalbase.MSSTS, "username"))
+		storageManager.WriteRefreshToken(createRefreshTokenCacheItem(hid, "env", "cid", "secret", ""))
+		storageManager.WriteAccessToken(createAccessTokenCacheItem(hid, "env", "realm", "cid", 1, 2, 3, "openid", "secret"))
+		storageManager.WriteAccessToken(createAccessTokenCacheItem(hid, "env", "realm", "cid", 1, 2, 3, "user.read", "secret"))
+	}
+	partition := manager.getPartition("partition", true)
+	partition.WriteAccessToken(createAccessTokenCacheItem("", "env", "realm", "cid", 1, 2, 3, "openid", "appSecret"))
+	stats, err := manager.GetStats()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	// The partition's token counts towards the size like the other entities
+	size := len("appSecret") + len(partition.ReadAllAccessTokens()[0].CreateKey())
+	for _, account := range storageManager.ReadAllAccounts() {
+		size += len(account.CreateKey())
+	}
+	for _, at := range storageManager.ReadAllAccessTokens() {
+		size += len("secret") + len(at.CreateKey())
+	}
+	for _, rt := range storageManager.ReadAllRefreshTokens() {
+		size += len("secret") + len(rt.CreateKey())
+	}
+	expected := &msalbase.CacheStats{Accounts: 2, AccessTokens: 5, RefreshTokens: 2, ApproximateSize: size}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("Actual stats %+v differ from expected stats %+v", stats, expected)
 	}
 }
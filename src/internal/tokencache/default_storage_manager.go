@@ -5,14 +5,19 @@ package tokencache
 
 import (
 	"errors"
+	"strconv"
 	"sync"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
-var lock sync.RWMutex
+var errAccountNotFound = errors.New("Can't find account")
 
+//defaultStorageManager keeps the cache in memory, it's safe for concurrent use
+//The entities are keyed by their cache keys, lookups match the environment of an entity against all the aliases of the authority
 type defaultStorageManager struct {
+	//lock guards the maps of this storage manager, every partition of the cache has its own
+	lock          sync.RWMutex
 	accessTokens  map[string]*accessTokenCacheItem
 	refreshTokens map[string]*refreshTokenCacheItem
 	idTokens      map[string]*idTokenCacheItem
@@ -43,45 +48,79 @@ func checkAlias(alias string, aliases []string) bool {
 	return false
 }
 
+//isMatchingScopes checks that the cached scopes include all the requested scopes, so a token granted for more scopes is reused for a subset of them
 func isMatchingScopes(scopesOne []string, scopesTwo string) bool {
-	newScopesTwo := msalbase.SplitScopes(scopesTwo)
-	scopeCounter := 0
+	// Other MSALs sharing the cache may have written the scopes in another case
+	cachedScopes := map[string]bool{}
+	for _, scope := range msalbase.NormalizeScopes(msalbase.SplitScopes(scopesTwo)) {
+		cachedScopes[scope] = true
+	}
 	for _, scope := range scopesOne {
-		for _, otherScope := range newScopesTwo {
-			if scope == otherScope {
-				scopeCounter++
-				continue
-			}
+		if !cachedScopes[scope] {
+			return false
 		}
 	}
-	return scopeCounter == len(scopesOne)
+	return true
 }
 
+//ReadAccessToken returns an access token whose scopes include the requested scopes.
+//When several match, e.g. tokens for "a b" and "a c" are both read for "a", the one expiring last is returned, so a valid token is found before an expired one.
 func (m *defaultStorageManager) ReadAccessToken(
 	homeAccountID string,
 	envAliases []string,
 	realm string,
 	clientID string,
-	scopes []string) *accessTokenCacheItem {
-	lock.RLock()
-	defer lock.RUnlock()
+	scopes []string,
+	keyID string) *accessTokenCacheItem {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var match *accessTokenCacheItem
 	for _, at := range m.accessTokens {
 		if msalbase.GetStringFromPointer(at.HomeAccountID) == homeAccountID &&
 			checkAlias(msalbase.GetStringFromPointer(at.Environment), envAliases) &&
 			msalbase.GetStringFromPointer(at.Realm) == realm &&
 			msalbase.GetStringFromPointer(at.ClientID) == clientID &&
-			isMatchingScopes(scopes, msalbase.GetStringFromPointer(at.Scopes)) {
-			return at
+			isMatchingScopes(scopes, msalbase.GetStringFromPointer(at.Scopes)) &&
+			msalbase.GetStringFromPointer(at.KeyID) == keyID {
+			if match == nil || getExpiresOn(at) > getExpiresOn(match) {
+				match = at
+			}
 		}
 	}
-	return nil
+	return match
+}
+
+//getExpiresOn returns the expiry of the access token as a Unix timestamp, it's zero when the token has no valid expiry
+func getExpiresOn(accessToken *accessTokenCacheItem) int64 {
+	expiresOn, err := strconv.ParseInt(msalbase.GetStringFromPointer(accessToken.ExpiresOnUnixTimestamp), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return expiresOn
 }
 
 func (m *defaultStorageManager) WriteAccessToken(accessToken *accessTokenCacheItem) error {
-	lock.Lock()
+	m.lock.Lock()
 	key := accessToken.CreateKey()
 	m.accessTokens[key] = accessToken
-	lock.Unlock()
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *defaultStorageManager) ReadAllAccessTokens() []*accessTokenCacheItem {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	accessTokens := []*accessTokenCacheItem{}
+	for _, at := range m.accessTokens {
+		accessTokens = append(accessTokens, at)
+	}
+	return accessTokens
+}
+
+func (m *defaultStorageManager) DeleteAccessToken(accessToken *accessTokenCacheItem) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.accessTokens, accessToken.CreateKey())
 	return nil
 }
 
@@ -92,8 +131,8 @@ func (m *defaultStorageManager) ReadRefreshToken(
 	clientID string,
 ) *refreshTokenCacheItem {
 
-	lock.RLock()
-	defer lock.RUnlock()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	if familyID != "" {
 		for _, rt := range m.refreshTokens {
 			if matchFamilyRefreshToken(rt, homeAccountID, envAliases) {
@@ -134,10 +173,27 @@ func matchClientIDRefreshToken(rt *refreshTokenCacheItem, homeAccountID string,
 }
 
 func (m *defaultStorageManager) WriteRefreshToken(refreshToken *refreshTokenCacheItem) error {
-	lock.Lock()
+	m.lock.Lock()
 	key := refreshToken.CreateKey()
 	m.refreshTokens[key] = refreshToken
-	lock.Unlock()
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *defaultStorageManager) ReadAllRefreshTokens() []*refreshTokenCacheItem {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	refreshTokens := []*refreshTokenCacheItem{}
+	for _, rt := range m.refreshTokens {
+		refreshTokens = append(refreshTokens, rt)
+	}
+	return refreshTokens
+}
+
+func (m *defaultStorageManager) DeleteRefreshToken(refreshToken *refreshTokenCacheItem) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.refreshTokens, refreshToken.CreateKey())
 	return nil
 }
 
@@ -147,8 +203,8 @@ func (m *defaultStorageManager) ReadIDToken(
 	realm string,
 	clientID string,
 ) *idTokenCacheItem {
-	lock.RLock()
-	defer lock.RUnlock()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	for _, idt := range m.idTokens {
 		if msalbase.GetStringFromPointer(idt.HomeAccountID) == homeAccountID &&
 			checkAlias(msalbase.GetStringFromPointer(idt.Environment), envAliases) &&
@@ -161,70 +217,136 @@ func (m *defaultStorageManager) ReadIDToken(
 }
 
 func (m *defaultStorageManager) WriteIDToken(idToken *idTokenCacheItem) error {
-	lock.Lock()
+	m.lock.Lock()
 	key := idToken.CreateKey()
 	m.idTokens[key] = idToken
-	lock.Unlock()
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *defaultStorageManager) ReadAllIDTokens() []*idTokenCacheItem {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	idTokens := []*idTokenCacheItem{}
+	for _, idt := range m.idTokens {
+		idTokens = append(idTokens, idt)
+	}
+	return idTokens
+}
+
+func (m *defaultStorageManager) DeleteIDToken(idToken *idTokenCacheItem) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.idTokens, idToken.CreateKey())
 	return nil
 }
 
 func (m *defaultStorageManager) ReadAllAccounts() []*msalbase.Account {
-	lock.RLock()
+	m.lock.RLock()
 	accounts := []*msalbase.Account{}
 	for _, v := range m.accounts {
 		accounts = append(accounts, v)
 	}
-	lock.RUnlock()
+	m.lock.RUnlock()
 	return accounts
 }
 
+//ReadAccount returns the account of the realm, when the realm is empty because the tenant of a /common or /organizations
+//authority isn't resolved yet, an account of the home account ID in any realm is returned instead
 func (m *defaultStorageManager) ReadAccount(homeAccountID string, envAliases []string, realm string) *msalbase.Account {
-	lock.RLock()
-	defer lock.RUnlock()
-	for _, acc := range m.accounts {
-		if msalbase.GetStringFromPointer(acc.HomeAccountID) == homeAccountID &&
-			checkAlias(msalbase.GetStringFromPointer(acc.Environment), envAliases) &&
-			msalbase.GetStringFromPointer(acc.Realm) == realm {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var fallback *msalbase.Account
+	for key, acc := range m.accounts {
+		if msalbase.GetStringFromPointer(acc.HomeAccountID) != homeAccountID ||
+			!checkAlias(msalbase.GetStringFromPointer(acc.Environment), envAliases) {
+			continue
+		}
+		if msalbase.GetStringFromPointer(acc.Realm) == realm {
 			return acc
 		}
+		// The lowest key is kept so the same account is returned every time
+		if realm == "" && (fallback == nil || key < fallback.CreateKey()) {
+			fallback = acc
+		}
 	}
-	return nil
+	return fallback
 }
 
 func (m *defaultStorageManager) WriteAccount(account *msalbase.Account) error {
-	lock.Lock()
+	m.lock.Lock()
 	key := account.CreateKey()
 	m.accounts[key] = account
-	lock.Unlock()
+	m.lock.Unlock()
 	return nil
 }
 
 func (m *defaultStorageManager) DeleteAccounts(
 	homeAccountID string,
 	envAliases []string) error {
-	keys := []string{}
-	lock.RLock()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	found := false
 	for key, acc := range m.accounts {
 		if msalbase.GetStringFromPointer(acc.HomeAccountID) == homeAccountID &&
 			checkAlias(msalbase.GetStringFromPointer(acc.Environment), envAliases) {
-			keys = append(keys, key)
+			delete(m.accounts, key)
+			found = true
 		}
 	}
-	lock.RUnlock()
-	if len(keys) == 0 {
-		return errors.New("Can't find account")
+	if !found {
+		return errAccountNotFound
 	}
-	lock.Lock()
-	for _, key := range keys {
-		delete(m.accounts, key)
+	return nil
+}
+
+func (m *defaultStorageManager) DeleteAccount(account *msalbase.Account) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.accounts, account.CreateKey())
+	return nil
+}
+
+func matchCredential(homeAccountID string, envAliases []string, clientID string, credHomeAccountID, credEnvironment, credClientID *string) bool {
+	return msalbase.GetStringFromPointer(credHomeAccountID) == homeAccountID &&
+		checkAlias(msalbase.GetStringFromPointer(credEnvironment), envAliases) &&
+		(clientID == "" || msalbase.GetStringFromPointer(credClientID) == clientID)
+}
+
+func (m *defaultStorageManager) DeleteCredentials(
+	homeAccountID string,
+	envAliases []string,
+	clientID string,
+	credentialTypes map[string]bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if credentialTypes[msalbase.CredentialTypeAccessToken] {
+		for key, at := range m.accessTokens {
+			if matchCredential(homeAccountID, envAliases, clientID, at.HomeAccountID, at.Environment, at.ClientID) {
+				delete(m.accessTokens, key)
+			}
+		}
+	}
+	if credentialTypes[msalbase.CredentialTypeRefreshToken] {
+		for key, rt := range m.refreshTokens {
+			if matchCredential(homeAccountID, envAliases, clientID, rt.HomeAccountID, rt.Environment, rt.ClientID) {
+				delete(m.refreshTokens, key)
+			}
+		}
+	}
+	if credentialTypes[msalbase.CredentialTypeIDToken] {
+		for key, idt := range m.idTokens {
+			if matchCredential(homeAccountID, envAliases, clientID, idt.HomeAccountID, idt.Environment, idt.ClientID) {
+				delete(m.idTokens, key)
+			}
+		}
 	}
-	lock.Unlock()
 	return nil
 }
 
 func (m *defaultStorageManager) ReadAppMetadata(envAliases []string, clientID string) *appMetadata {
-	lock.RLock()
-	defer lock.RUnlock()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	for _, app := range m.appMetadatas {
 		if checkAlias(msalbase.GetStringFromPointer(app.Environment), envAliases) &&
 			msalbase.GetStringFromPointer(app.ClientID) == clientID {
@@ -235,39 +357,68 @@ func (m *defaultStorageManager) ReadAppMetadata(envAliases []string, clientID st
 }
 
 func (m *defaultStorageManager) WriteAppMetadata(appMetadata *appMetadata) error {
-	lock.Lock()
+	m.lock.Lock()
 	key := appMetadata.CreateKey()
 	m.appMetadatas[key] = appMetadata
-	lock.Unlock()
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *defaultStorageManager) ReadAllAppMetadata() []*appMetadata {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	appMetadatas := []*appMetadata{}
+	for _, app := range m.appMetadatas {
+		appMetadatas = append(appMetadatas, app)
+	}
+	return appMetadatas
+}
+
+func (m *defaultStorageManager) DeleteAppMetadata(appMetadata *appMetadata) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.appMetadatas, appMetadata.CreateKey())
 	return nil
 }
 
-func (m *defaultStorageManager) Serialize() (string, error) {
-	lock.RLock()
+func (m *defaultStorageManager) Serialize() ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
 	m.cacheContract.AccessTokens = m.accessTokens
 	m.cacheContract.RefreshTokens = m.refreshTokens
 	m.cacheContract.IDTokens = m.idTokens
 	m.cacheContract.Accounts = m.accounts
 	m.cacheContract.AppMetadata = m.appMetadatas
-	lock.RUnlock()
-	serializedCache, err := m.cacheContract.MarshalJSON()
-	if err != nil {
-		return "", err
-	}
-	return string(serializedCache), nil
+	return m.cacheContract.MarshalJSON()
 }
 
 func (m *defaultStorageManager) Deserialize(cacheData []byte) error {
-	err := m.cacheContract.UnmarshalJSON(cacheData)
+	cacheContract := createCacheSerializationContract()
+	err := cacheContract.UnmarshalJSON(cacheData)
 	if err != nil {
 		return err
 	}
-	lock.Lock()
-	m.accessTokens = m.cacheContract.AccessTokens
-	m.refreshTokens = m.cacheContract.RefreshTokens
-	m.idTokens = m.cacheContract.IDTokens
-	m.accounts = m.cacheContract.Accounts
-	m.appMetadatas = m.cacheContract.AppMetadata
-	lock.Unlock()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// The deserialized entries are merged into the existing cache instead of replacing it
+	for k, v := range cacheContract.AccessTokens {
+		m.accessTokens[k] = v
+	}
+	for k, v := range cacheContract.RefreshTokens {
+		m.refreshTokens[k] = v
+	}
+	for k, v := range cacheContract.IDTokens {
+		m.idTokens[k] = v
+	}
+	for k, v := range cacheContract.Accounts {
+		m.accounts[k] = v
+	}
+	for k, v := range cacheContract.AppMetadata {
+		m.appMetadatas[k] = v
+	}
+	// Unknown top level entries are kept so they are written back out on the next serialization
+	for k, v := range cacheContract.snapshot {
+		m.cacheContract.snapshot[k] = v
+	}
 	return nil
 }
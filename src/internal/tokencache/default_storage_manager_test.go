@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -117,6 +119,7 @@ func TestReadAccessToken(t *testing.T) {
 		"realm",
 		"cid",
 		[]string{"user.read", "openid"},
+		"",
 	)
 	if !reflect.DeepEqual(testAccessToken, retAccessToken) {
 		t.Errorf("Returned access token %v is not the same as expected access token %v", retAccessToken, testAccessToken)
@@ -127,10 +130,22 @@ func TestReadAccessToken(t *testing.T) {
 		"realm",
 		"cid",
 		[]string{"user.read", "openid"},
+		"",
 	)
 	if readAccessToken != nil {
 		t.Errorf("Returned access token should be nil; instead it is %v", readAccessToken)
 	}
+	popAccessToken := storageManager.ReadAccessToken(
+		"hid",
+		[]string{"env"},
+		"realm",
+		"cid",
+		[]string{"user.read", "openid"},
+		"kid",
+	)
+	if popAccessToken != nil {
+		t.Errorf("Bearer token shouldn't be returned for a proof-of-possession key; instead it is %v", popAccessToken)
+	}
 }
 
 func TestWriteAccessToken(t *testing.T) {
@@ -186,6 +201,28 @@ func TestReadAccount(t *testing.T) {
 	}
 }
 
+func TestReadAccountWithoutRealm(t *testing.T) {
+	storageManager := CreateStorageManager()
+	testAcc := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	storageManager.WriteAccount(testAcc)
+	returnedAccount := storageManager.ReadAccount("hid", []string{"env"}, "")
+	if !reflect.DeepEqual(returnedAccount, testAcc) {
+		t.Errorf("The account of any realm should be returned when the realm is empty, instead the returned account is %v", returnedAccount)
+	}
+	// An account without a realm, e.g. of ADFS, is still preferred
+	adfsAcc := msalbase.CreateAccount("hid", "env", "", "lid", msalbase.ADFS, "username")
+	storageManager.WriteAccount(adfsAcc)
+	if returnedAccount := storageManager.ReadAccount("hid", []string{"env"}, ""); !reflect.DeepEqual(returnedAccount, adfsAcc) {
+		t.Errorf("The account without a realm should be returned, instead the returned account is %v", returnedAccount)
+	}
+	if returnedAccount := storageManager.ReadAccount("hid", []string{"env"}, "otherRealm"); returnedAccount != nil {
+		t.Errorf("The account of another realm shouldn't be returned when the realm is set, instead it is %v", returnedAccount)
+	}
+	if returnedAccount := storageManager.ReadAccount("otherHid", []string{"env"}, ""); returnedAccount != nil {
+		t.Errorf("The account of another home account ID shouldn't be returned, instead it is %v", returnedAccount)
+	}
+}
+
 func TestWriteAccount(t *testing.T) {
 	storageManager := &defaultStorageManager{
 		accessTokens:  make(map[string]*accessTokenCacheItem),
@@ -649,3 +686,126 @@ func TestStorageManagerDeserialize(t *testing.T) {
 		t.Errorf("Expected app metadata family ID is nil, instead it is %s", *manager.appMetadatas["appmetadata-login.windows.net-my_client_id"].FamilyID)
 	}
 }
+
+func TestDeleteCredentials(t *testing.T) {
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid", 1, 1, 1, "openid", "secret"))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "env", "cid", "secret", ""))
+	storageManager.WriteIDToken(createIDTokenCacheItem("hid", "env", "realm", "cid", "secret"))
+	storageManager.WriteIDToken(createIDTokenCacheItem("hid", "otherEnv", "realm", "cid", "secret"))
+	err := storageManager.DeleteCredentials("hid", []string{"env", "alias"}, "", map[string]bool{msalbase.CredentialTypeIDToken: true})
+	if err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if len(storageManager.idTokens) != 1 {
+		t.Errorf("Only the ID token in the other environment should remain, instead there are %d ID tokens", len(storageManager.idTokens))
+	}
+	if len(storageManager.accessTokens) != 1 || len(storageManager.refreshTokens) != 1 {
+		t.Errorf("Credential types not in the filter shouldn't be deleted")
+	}
+}
+
+func TestStorageManagerReadAfterWriteAliases(t *testing.T) {
+	storageManager := CreateStorageManager()
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("hid", "login.windows.net", "realm", "cid", 1, 1, 1, "openid", "at"))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "login.windows.net", "cid", "rt", ""))
+	storageManager.WriteIDToken(createIDTokenCacheItem("hid", "login.windows.net", "realm", "cid", "idt"))
+	storageManager.WriteAccount(msalbase.CreateAccount("hid", "login.windows.net", "realm", "lid", msalbase.MSSTS, "username"))
+	storageManager.WriteAppMetadata(createAppMetadata("", "cid", "login.windows.net"))
+	tests := []struct {
+		name    string
+		aliases []string
+		found   bool
+	}{
+		{"written alias", []string{"login.windows.net"}, true},
+		{"other aliases of the authority", []string{"login.microsoftonline.com", "login.windows.net", "sts.windows.net"}, true},
+		{"aliases of another authority", []string{"login.microsoftonline.us", "login.usgovcloudapi.net"}, false},
+		{"no aliases", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if at := storageManager.ReadAccessToken("hid", test.aliases, "realm", "cid", []string{"openid"}, ""); (at != nil) != test.found {
+				t.Errorf("Access token should be found: %v, instead it is %v", test.found, at)
+			}
+			if rt := storageManager.ReadRefreshToken("hid", test.aliases, "", "cid"); (rt != nil) != test.found {
+				t.Errorf("Refresh token should be found: %v, instead it is %v", test.found, rt)
+			}
+			if idt := storageManager.ReadIDToken("hid", test.aliases, "realm", "cid"); (idt != nil) != test.found {
+				t.Errorf("ID token should be found: %v, instead it is %v", test.found, idt)
+			}
+			if acc := storageManager.ReadAccount("hid", test.aliases, "realm"); (acc != nil) != test.found {
+				t.Errorf("Account should be found: %v, instead it is %v", test.found, acc)
+			}
+			if app := storageManager.ReadAppMetadata(test.aliases, "cid"); (app != nil) != test.found {
+				t.Errorf("App metadata should be found: %v, instead it is %v", test.found, app)
+			}
+		})
+	}
+}
+
+func TestDeleteCredentialsOfClient(t *testing.T) {
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	storageManager.WriteAccessToken(createAccessTokenCacheItem("hid", "env", "realm", "cid", 1, 1, 1, "openid", "secret"))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "alias", "cid", "secret", ""))
+	storageManager.WriteRefreshToken(createRefreshTokenCacheItem("hid", "env", "otherCid", "secret", ""))
+	err := storageManager.DeleteCredentials("hid", []string{"env", "alias"}, "cid", map[string]bool{msalbase.CredentialTypeRefreshToken: true})
+	if err != nil {
+		t.Errorf("Error should be nil; instead, it is %v", err)
+	}
+	if storageManager.ReadRefreshToken("hid", []string{"env", "alias"}, "", "cid") != nil {
+		t.Error("The refresh token of the client should be deleted in every alias")
+	}
+	if len(storageManager.refreshTokens) != 1 || len(storageManager.accessTokens) != 1 {
+		t.Errorf("Only the refresh token of the client should be deleted, instead %d refresh and %d access tokens are left",
+			len(storageManager.refreshTokens), len(storageManager.accessTokens))
+	}
+}
+
+func TestStorageManagerConcurrentAccess(t *testing.T) {
+	storageManager := CreateStorageManager()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			homeAccountID := strconv.Itoa(i)
+			storageManager.WriteAccount(msalbase.CreateAccount(homeAccountID, "env", "realm", "lid", msalbase.MSSTS, "username"))
+			storageManager.ReadAllAccounts()
+			if _, err := storageManager.Serialize(); err != nil {
+				t.Errorf("Error should be nil; instead, it is %v", err)
+			}
+			if err := storageManager.DeleteAccounts(homeAccountID, []string{"env"}); err != nil {
+				t.Errorf("Error should be nil; instead, it is %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if accounts := storageManager.ReadAllAccounts(); len(accounts) != 0 {
+		t.Errorf("Every account should be deleted, instead %d are left", len(accounts))
+	}
+}
+
+func TestReadAccessTokenScopeSubset(t *testing.T) {
+	storageManager := CreateStorageManager().(*defaultStorageManager)
+	mailToken := createAccessTokenCacheItem("hid", "env", "realm", "cid", 1, 100, 100, "openid user.read mail.send", "mailSecret")
+	calendarToken := createAccessTokenCacheItem("hid", "env", "realm", "cid", 1, 200, 200, "user.read calendars.read", "calendarSecret")
+	storageManager.accessTokens[mailToken.CreateKey()] = mailToken
+	storageManager.accessTokens[calendarToken.CreateKey()] = calendarToken
+	tests := []struct {
+		scopes   []string
+		expected *accessTokenCacheItem
+	}{
+		{[]string{"mail.send"}, mailToken},
+		{[]string{"openid", "mail.send"}, mailToken},
+		// Both tokens are granted user.read, the one expiring last is returned
+		{[]string{"user.read"}, calendarToken},
+		{[]string{"user.read", "files.read"}, nil},
+		{[]string{"mail.send", "calendars.read"}, nil},
+	}
+	for _, test := range tests {
+		actual := storageManager.ReadAccessToken("hid", []string{"env"}, "realm", "cid", test.scopes, "")
+		if actual != test.expected {
+			t.Errorf("Scopes %v should read access token %v, instead they read %v", test.scopes, test.expected, actual)
+		}
+	}
+}
@@ -17,8 +17,9 @@ func (mock *MockStorageManager) ReadAccessToken(
 	envAliases []string,
 	realm string,
 	clientID string,
-	scopes []string) *accessTokenCacheItem {
-	args := mock.Called(homeAccountID, envAliases, realm, clientID, scopes)
+	scopes []string,
+	keyID string) *accessTokenCacheItem {
+	args := mock.Called(homeAccountID, envAliases, realm, clientID, scopes, keyID)
 	return args.Get(0).(*accessTokenCacheItem)
 }
 
@@ -27,6 +28,16 @@ func (mock *MockStorageManager) WriteAccessToken(accessToken *accessTokenCacheIt
 	return args.Error(0)
 }
 
+func (mock *MockStorageManager) ReadAllAccessTokens() []*accessTokenCacheItem {
+	args := mock.Called()
+	return args.Get(0).([]*accessTokenCacheItem)
+}
+
+func (mock *MockStorageManager) DeleteAccessToken(accessToken *accessTokenCacheItem) error {
+	args := mock.Called(accessToken)
+	return args.Error(0)
+}
+
 func (mock *MockStorageManager) ReadRefreshToken(
 	homeAccountID string,
 	envAliases []string,
@@ -77,6 +88,11 @@ func (mock *MockStorageManager) DeleteAccounts(homeAccountID string, envAliases
 	return args.Error(0)
 }
 
+func (mock *MockStorageManager) DeleteCredentials(homeAccountID string, envAliases []string, clientID string, credentialTypes map[string]bool) error {
+	args := mock.Called(homeAccountID, envAliases, clientID, credentialTypes)
+	return args.Error(0)
+}
+
 func (mock *MockStorageManager) ReadAppMetadata(envAliases []string, clientID string) *appMetadata {
 	args := mock.Called(envAliases, clientID)
 	return args.Get(0).(*appMetadata)
@@ -87,12 +103,47 @@ func (mock *MockStorageManager) WriteAppMetadata(appMetadata *appMetadata) error
 	return args.Error(0)
 }
 
-func (mock *MockStorageManager) Serialize() (string, error) {
+func (mock *MockStorageManager) Serialize() ([]byte, error) {
 	args := mock.Called()
-	return args.String(0), args.Error(1)
+	return args.Get(0).([]byte), args.Error(1)
 }
 
 func (mock *MockStorageManager) Deserialize(cacheData []byte) error {
 	args := mock.Called(cacheData)
 	return args.Error(0)
 }
+
+func (mock *MockStorageManager) ReadAllRefreshTokens() []*refreshTokenCacheItem {
+	args := mock.Called()
+	return args.Get(0).([]*refreshTokenCacheItem)
+}
+
+func (mock *MockStorageManager) DeleteRefreshToken(refreshToken *refreshTokenCacheItem) error {
+	args := mock.Called(refreshToken)
+	return args.Error(0)
+}
+
+func (mock *MockStorageManager) ReadAllIDTokens() []*idTokenCacheItem {
+	args := mock.Called()
+	return args.Get(0).([]*idTokenCacheItem)
+}
+
+func (mock *MockStorageManager) DeleteIDToken(idToken *idTokenCacheItem) error {
+	args := mock.Called(idToken)
+	return args.Error(0)
+}
+
+func (mock *MockStorageManager) DeleteAccount(account *msalbase.Account) error {
+	args := mock.Called(account)
+	return args.Error(0)
+}
+
+func (mock *MockStorageManager) ReadAllAppMetadata() []*appMetadata {
+	args := mock.Called()
+	return args.Get(0).([]*appMetadata)
+}
+
+func (mock *MockStorageManager) DeleteAppMetadata(appMetadata *appMetadata) error {
+	args := mock.Called(appMetadata)
+	return args.Error(0)
+}
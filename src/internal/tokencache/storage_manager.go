@@ -14,10 +14,15 @@ type StorageManager interface {
 		envAliases []string,
 		realm string,
 		clientID string,
-		scopes []string) *accessTokenCacheItem
+		scopes []string,
+		keyID string) *accessTokenCacheItem
 
 	WriteAccessToken(accessToken *accessTokenCacheItem) error
 
+	ReadAllAccessTokens() []*accessTokenCacheItem
+
+	DeleteAccessToken(accessToken *accessTokenCacheItem) error
+
 	ReadRefreshToken(
 		homeAccountID string,
 		envAliases []string,
@@ -27,6 +32,10 @@ type StorageManager interface {
 
 	WriteRefreshToken(refreshToken *refreshTokenCacheItem) error
 
+	ReadAllRefreshTokens() []*refreshTokenCacheItem
+
+	DeleteRefreshToken(refreshToken *refreshTokenCacheItem) error
+
 	ReadIDToken(
 		homeAccountID string,
 		envAliases []string,
@@ -36,6 +45,10 @@ type StorageManager interface {
 
 	WriteIDToken(idToken *idTokenCacheItem) error
 
+	ReadAllIDTokens() []*idTokenCacheItem
+
+	DeleteIDToken(idToken *idTokenCacheItem) error
+
 	ReadAllAccounts() []*msalbase.Account
 
 	ReadAccount(homeAccountID string, envAliases []string, realm string) *msalbase.Account
@@ -44,11 +57,19 @@ type StorageManager interface {
 
 	DeleteAccounts(homeAccountID string, envAliases []string) error
 
+	DeleteAccount(account *msalbase.Account) error
+
+	DeleteCredentials(homeAccountID string, envAliases []string, clientID string, credentialTypes map[string]bool) error
+
 	ReadAppMetadata(envAliases []string, clientID string) *appMetadata
 
 	WriteAppMetadata(appMetadata *appMetadata) error
 
-	Serialize() (string, error)
+	ReadAllAppMetadata() []*appMetadata
+
+	DeleteAppMetadata(appMetadata *appMetadata) error
+
+	Serialize() ([]byte, error)
 
 	Deserialize(cacheData []byte) error
 }
@@ -7,10 +7,7 @@ import (
 	"encoding/xml"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
-	uuid "github.com/google/uuid"
 )
 
 type EndpointVersion int
@@ -120,13 +117,13 @@ func (wte *Endpoint) buildTokenRequestMessage(authType msalbase.AuthorizationTyp
 	expiresTime := createdTime.Add(10 * time.Minute)
 
 	if wte.EndpointVersion == Trust2005 {
-		log.Trace("Building WS-Trust token request for v2005")
+		msalbase.GetLogger().Trace("Building WS-Trust token request for v2005")
 		soapAction = trust2005Spec
 		trustNamespace = "http://schemas.xmlsoap.org/ws/2005/02/trust"
 		keyType = "http://schemas.xmlsoap.org/ws/2005/05/identity/NoProofKey"
 		requestType = "http://schemas.xmlsoap.org/ws/2005/02/trust/Issue"
 	} else {
-		log.Trace("Building WS-Trust token request for v1.3")
+		msalbase.GetLogger().Trace("Building WS-Trust token request for v1.3")
 		soapAction = trust13Spec
 		trustNamespace = "http://docs.oasis-open.org/ws-sx/ws-trust/200512"
 		keyType = "http://docs.oasis-open.org/ws-sx/ws-trust/200512/Bearer"
@@ -135,7 +132,10 @@ func (wte *Endpoint) buildTokenRequestMessage(authType msalbase.AuthorizationTyp
 
 	var envelope wsTrustTokenRequestEnvelope
 
-	messageUUID := uuid.New()
+	messageUUID, err := msalbase.NewUUID()
+	if err != nil {
+		return "", err
+	}
 
 	envelope.S = "http://www.w3.org/2003/05/soap-envelope"
 	envelope.Wsa = "http://www.w3.org/2005/08/addressing"
@@ -143,20 +143,23 @@ func (wte *Endpoint) buildTokenRequestMessage(authType msalbase.AuthorizationTyp
 
 	envelope.Header.Action.MustUnderstand = "1"
 	envelope.Header.Action.Text = soapAction
-	envelope.Header.MessageID.Text = "urn:uuid:" + messageUUID.String()
+	envelope.Header.MessageID.Text = "urn:uuid:" + messageUUID
 	envelope.Header.ReplyTo.Address.Text = "http://www.w3.org/2005/08/addressing/anonymous"
 	envelope.Header.To.MustUnderstand = "1"
 	envelope.Header.To.Text = wte.URL
 
 	if authType == msalbase.AuthorizationTypeUsernamePassword {
 
-		endpointUUID := uuid.New()
+		endpointUUID, err := msalbase.NewUUID()
+		if err != nil {
+			return "", err
+		}
 
 		var trustID string
 		if wte.EndpointVersion == Trust2005 {
-			trustID = "UnPwSecTok2005-" + endpointUUID.String()
+			trustID = "UnPwSecTok2005-" + endpointUUID
 		} else {
-			trustID = "UnPwSecTok13-" + endpointUUID.String()
+			trustID = "UnPwSecTok13-" + endpointUUID
 		}
 
 		envelope.Header.Security.MustUnderstand = "1"
@@ -8,7 +8,7 @@ import (
 	"errors"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 type wsEndpointType int
@@ -35,12 +35,12 @@ type MexDocument struct {
 
 func updateEndpoint(cached *Endpoint, found Endpoint) bool {
 	if cached == nil {
-		log.Trace("No endpoint cached, using found endpoint")
+		msalbase.GetLogger().Trace("No endpoint cached, using found endpoint")
 		*cached = found
 		return true
 	}
 	if (*cached).EndpointVersion == Trust2005 && found.EndpointVersion == Trust13 {
-		log.Trace("Cached endpoint is v2005, replacing with v1.3")
+		msalbase.GetLogger().Trace("Cached endpoint is v2005, replacing with v1.3")
 		*cached = found
 		return true
 	}
@@ -58,15 +58,15 @@ func CreateWsTrustMexDocument(responseData string) (*MexDocument, error) {
 
 	for _, policy := range definitions.Policy {
 		if policy.ExactlyOne.All.SignedEncryptedSupportingTokens.Policy.UsernameToken.Policy.WssUsernameToken10.XMLName.Local != "" {
-			log.Trace("Found Policy with UsernamePassword 1.3: " + policy.ID)
+			msalbase.GetLogger().Trace("Found Policy with UsernamePassword 1.3: " + policy.ID)
 			policies["#"+policy.ID] = wsEndpointTypeUsernamePassword
 		}
 		if policy.ExactlyOne.All.SignedSupportingTokens.Policy.UsernameToken.Policy.WssUsernameToken10.XMLName.Local != "" {
-			log.Trace("Found Policy with UsernamePassword 2005: " + policy.ID)
+			msalbase.GetLogger().Trace("Found Policy with UsernamePassword 2005: " + policy.ID)
 			policies["#"+policy.ID] = wsEndpointTypeUsernamePassword
 		}
 		if policy.ExactlyOne.All.NegotiateAuthentication.XMLName.Local != "" {
-			log.Trace("Found policy with WindowsTransport: " + policy.ID)
+			msalbase.GetLogger().Trace("Found policy with WindowsTransport: " + policy.ID)
 			policies["#"+policy.ID] = wsEndpointTypeWindowsTransport
 		}
 	}
@@ -81,7 +81,7 @@ func CreateWsTrustMexDocument(responseData string) (*MexDocument, error) {
 			if policy, ok := policies[policyName]; ok {
 				bindingName := binding.Name
 				specVersion := binding.Operation.Operation.SoapAction
-				log.Tracef("Found binding %v Spec %v", bindingName, specVersion)
+				msalbase.GetLogger().Tracef("Found binding %v Spec %v", bindingName, specVersion)
 
 				if specVersion == trust13Spec {
 					bindings[bindingName] = wsEndpointData{Trust13, policy}
@@ -99,7 +99,7 @@ func CreateWsTrustMexDocument(responseData string) (*MexDocument, error) {
 
 	for _, port := range definitions.Service.Port {
 		bindingName := port.Binding
-		log.Trace("Parsing port with binding name: " + bindingName)
+		msalbase.GetLogger().Trace("Parsing port with binding name: " + bindingName)
 
 		index := strings.Index(bindingName, ":")
 		if index != -1 {
@@ -112,16 +112,16 @@ func CreateWsTrustMexDocument(responseData string) (*MexDocument, error) {
 
 			endpoint := createWsTrustEndpoint(binding.Version, url)
 
-			log.Tracef("Associated port '%v' with binding, url '%v'", bindingName, url)
+			msalbase.GetLogger().Tracef("Associated port '%v' with binding, url '%v'", bindingName, url)
 			switch binding.EndpointType {
 			case wsEndpointTypeUsernamePassword:
 				if updateEndpoint(&usernamePasswordEndpoint, endpoint) {
-					log.Tracef("Updated cached username/password endpoint to binding '%v'", bindingName)
+					msalbase.GetLogger().Tracef("Updated cached username/password endpoint to binding '%v'", bindingName)
 				}
 				break
 			case wsEndpointTypeWindowsTransport:
 				if updateEndpoint(&windowsTransportEndpoint, endpoint) {
-					log.Tracef("Updated cached windows transport endpoint to binding '%v'", bindingName)
+					msalbase.GetLogger().Tracef("Updated cached windows transport endpoint to binding '%v'", bindingName)
 				}
 				break
 			default:
@@ -131,6 +131,6 @@ func CreateWsTrustMexDocument(responseData string) (*MexDocument, error) {
 	}
 
 	doc := &MexDocument{usernamePasswordEndpoint, windowsTransportEndpoint, policies, bindings}
-	log.Trace("Created WsTrustMexDocument!")
+	msalbase.GetLogger().Trace("Created WsTrustMexDocument!")
 	return doc, nil
 }
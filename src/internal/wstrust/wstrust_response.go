@@ -8,7 +8,7 @@ import (
 	"errors"
 	"fmt"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 type Response struct {
@@ -42,7 +42,7 @@ func (wsTrustResponse *Response) GetSAMLAssertion(endpoint *Endpoint) (*SamlToke
 		return nil, errors.New("WS Trust 2005 support is not implemented")
 	case Trust13:
 		{
-			log.Trace("Extracting assertion from WS-Trust 1.3 token:")
+			msalbase.GetLogger().Trace("Extracting assertion from WS-Trust 1.3 token:")
 
 			samldefinitions := &samldefinitions{}
 			var err = xml.Unmarshal([]byte(wsTrustResponse.responseData), samldefinitions)
@@ -53,16 +53,16 @@ func (wsTrustResponse *Response) GetSAMLAssertion(endpoint *Endpoint) (*SamlToke
 			for _, tokenResponse := range samldefinitions.Body.RequestSecurityTokenResponseCollection.RequestSecurityTokenResponse {
 				token := tokenResponse.RequestedSecurityToken
 				if token.Assertion.XMLName.Local != "" {
-					log.Trace("Found valid assertion")
+					msalbase.GetLogger().Trace("Found valid assertion")
 					assertion := token.AssertionRawXML
 
 					samlVersion := token.Assertion.Saml
 					if samlVersion == "urn:oasis:names:tc:SAML:1.0:assertion" {
-						log.Trace("Retrieved WS-Trust 1.3 / SAML V1 assertion")
+						msalbase.GetLogger().Trace("Retrieved WS-Trust 1.3 / SAML V1 assertion")
 						return createSamlTokenInfo(SamlV1, assertion), nil
 					}
 					if samlVersion == "urn:oasis:names:tc:SAML:2.0:assertion" {
-						log.Trace("Retrieved WS-Trust 1.3 / SAML V2 assertion")
+						msalbase.GetLogger().Trace("Retrieved WS-Trust 1.3 / SAML V2 assertion")
 						return createSamlTokenInfo(SamlV2, assertion), nil
 					}
 
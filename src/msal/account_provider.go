@@ -3,6 +3,8 @@
 
 package msalgo
 
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
 //AccountProvider is an interface representing an account that is returned to users.
 //This can help with accessing the cache for tokens.
 type AccountProvider interface {
@@ -10,3 +12,6 @@ type AccountProvider interface {
 	GetHomeAccountID() string
 	GetEnvironment() string
 }
+
+//Account is an account of the cache, it's returned by GetAccounts and with the AuthenticationResultProvider of a token acquisition
+type Account = msalbase.Account
@@ -31,7 +31,7 @@ func TestAugmentAuthenticationParametersForAuth(t *testing.T) {
 	testScopes := []string{"user.read"}
 	testRedirectURI := "http://localhost:3000/redirect"
 	testAuthParams := &msalbase.AuthParametersInternal{}
-	testTokenCommonParams := &acquireTokenCommonParameters{testScopes}
+	testTokenCommonParams := &acquireTokenCommonParameters{scopes: testScopes}
 	testAuthCodeParams := &AcquireTokenAuthCodeParameters{
 		commonParameters: testTokenCommonParams,
 		redirectURI:      testRedirectURI,
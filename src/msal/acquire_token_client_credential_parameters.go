@@ -19,7 +19,64 @@ func CreateAcquireTokenClientCredentialParameters(scopes []string) *AcquireToken
 	return params
 }
 
-func (p *AcquireTokenClientCredentialParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) {
-	p.commonParameters.augmentAuthenticationParameters(authParams)
+// SetClaims sets the claims challenge returned by the authority or a resource, e.g. by Continuous Access Evaluation.
+// The claims are sent with the token request, so the token satisfies the challenge.
+func (p *AcquireTokenClientCredentialParameters) SetClaims(claims string) {
+	p.commonParameters.claims = claims
+}
+
+// SetResource requests the token for a v1 resource, e.g. GraphResource or "https://graph.microsoft.com", instead of the scopes, for apps migrating from ADAL.
+// The scopes are replaced with the .default scope of the resource, which the token is cached under like any other scope.
+func (p *AcquireTokenClientCredentialParameters) SetResource(resource string) {
+	p.commonParameters.setResource(resource)
+}
+
+// SetProofOfPossession binds the access token to the key, instead of requesting a bearer token.
+// Requests to the resource need the Authorization header created with the key's CreateAuthorizationHeader.
+func (p *AcquireTokenClientCredentialParameters) SetProofOfPossession(key *PoPKey) {
+	p.commonParameters.popKey = key
+}
+
+// SetCachePartition reads and writes the tokens of the request in the cache partition of the key, e.g. the home account ID of the signed-in user.
+// Web apps serving many users can partition the cache per user, so tokens can't be returned for another user and lookups only search the user's tokens.
+// Partitions are serialized with the rest of the cache, so a cache accessor persists them too.
+func (p *AcquireTokenClientCredentialParameters) SetCachePartition(partitionKey string) {
+	p.commonParameters.partitionKey = partitionKey
+}
+
+// SetSkipExpirationBuffer returns a cached access token until it actually expires, instead of treating it as expired 5 minutes early.
+// Batch jobs that retry on a 401 response can use it to get the most out of every token; the cached token itself isn't changed.
+func (p *AcquireTokenClientCredentialParameters) SetSkipExpirationBuffer(skip bool) {
+	p.commonParameters.skipExpirationBuffer = skip
+}
+
+// SetExtraQueryParameters adds the parameters to the token requests, e.g. for features of the authority the parameters don't cover.
+// The parameters of the protocol, e.g. client_id, scope or grant_type, can't be replaced and are dropped from them.
+func (p *AcquireTokenClientCredentialParameters) SetExtraQueryParameters(parameters map[string]string) {
+	p.commonParameters.extraQueryParameters = parameters
+}
+
+// SetCorrelationID sends the GUID as the client-request-id of the requests, instead of a generated one.
+// Apps that already have an ID for the operation can use it to find the requests in the authority's logs.
+func (p *AcquireTokenClientCredentialParameters) SetCorrelationID(correlationID string) {
+	p.commonParameters.correlationID = correlationID
+}
+
+// SetAuthority sends the request to the authority instead of the client application's, e.g. "https://login.microsoftonline.com/<tenant>/" to get a token from another tenant.
+// The authority of the client application isn't changed. It has to be in the same cloud, unless SetAllowCrossCloudAuthority is called too.
+func (p *AcquireTokenClientCredentialParameters) SetAuthority(authorityURI string) {
+	p.commonParameters.authority = authorityURI
+}
+
+// SetAllowCrossCloudAuthority lets the authority set with SetAuthority be in another cloud than the client application's authority, e.g. a national cloud.
+func (p *AcquireTokenClientCredentialParameters) SetAllowCrossCloudAuthority(allow bool) {
+	p.commonParameters.allowCrossCloudAuthority = allow
+}
+
+func (p *AcquireTokenClientCredentialParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) error {
+	if err := p.commonParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return err
+	}
 	authParams.AuthorizationType = msalbase.AuthorizationTypeClientCredentials
+	return nil
 }
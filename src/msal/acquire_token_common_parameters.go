@@ -4,27 +4,80 @@
 package msalgo
 
 import (
-	"strings"
+	"fmt"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 type acquireTokenCommonParameters struct {
 	scopes []string
+	claims string
+	popKey *msalbase.PoPKey
+	//partitionKey selects the cache partition of the request
+	partitionKey string
+	//correlationID replaces the correlation ID generated for the request when it's set
+	correlationID string
+	//authority replaces the authority of the client application for the request when it's set
+	authority string
+	//allowCrossCloudAuthority lets the authority be in another cloud than the client application's
+	allowCrossCloudAuthority bool
+	//skipExpirationBuffer returns cached access tokens until they expire, without the early expiry of the cache
+	skipExpirationBuffer bool
+	//extraQueryParameters are added to the token requests
+	extraQueryParameters map[string]string
 }
 
 func createAcquireTokenCommonParameters(scopes []string) *acquireTokenCommonParameters {
-	loweredScopes := []string{}
-	for _, s := range scopes {
-		s = strings.ToLower(s)
-		loweredScopes = append(loweredScopes, s)
-	}
 	p := &acquireTokenCommonParameters{
-		scopes: loweredScopes,
+		scopes: msalbase.NormalizeScopes(scopes),
 	}
 	return p
 }
 
-func (p *acquireTokenCommonParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) {
+//setResource replaces the scopes with the .default scope of the v1 resource
+func (p *acquireTokenCommonParameters) setResource(resource string) {
+	p.scopes = msalbase.NormalizeScopes([]string{msalbase.ResourceToScope(resource)})
+}
+
+func (p *acquireTokenCommonParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) error {
 	authParams.Scopes = p.scopes
+	authParams.Claims = p.claims
+	authParams.PoPKey = p.popKey
+	authParams.PartitionKey = p.partitionKey
+	authParams.SkipExpirationBuffer = p.skipExpirationBuffer
+	authParams.ExtraQueryParameters = p.extraQueryParameters
+	if p.correlationID != "" {
+		authParams.CorrelationID = p.correlationID
+	}
+	return p.overrideAuthority(authParams)
+}
+
+//overrideAuthority replaces the authority of the request, the AuthorityInfo of the client application is shared by its requests so it's never changed
+func (p *acquireTokenCommonParameters) overrideAuthority(authParams *msalbase.AuthParametersInternal) error {
+	if p.authority == "" {
+		return nil
+	}
+	validateAuthority := true
+	instanceDiscoveryDisabled := false
+	var instanceDiscoveryHeaders map[string]string
+	var knownAuthorityHosts []string
+	if authParams.AuthorityInfo != nil {
+		validateAuthority = authParams.AuthorityInfo.ValidateAuthority
+		instanceDiscoveryDisabled = authParams.AuthorityInfo.InstanceDiscoveryDisabled
+		instanceDiscoveryHeaders = authParams.AuthorityInfo.InstanceDiscoveryHeaders
+		knownAuthorityHosts = authParams.AuthorityInfo.KnownAuthorityHosts
+	}
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI(p.authority, validateAuthority)
+	if err != nil {
+		return err
+	}
+	authorityInfo.InstanceDiscoveryDisabled = instanceDiscoveryDisabled
+	authorityInfo.InstanceDiscoveryHeaders = instanceDiscoveryHeaders
+	authorityInfo.KnownAuthorityHosts = knownAuthorityHosts
+	if authParams.AuthorityInfo != nil && !p.allowCrossCloudAuthority && !authParams.AuthorityInfo.IsSameCloud(authorityInfo) {
+		return fmt.Errorf("authority host '%s' isn't in the cloud of the client application's authority host '%s', cross-cloud authorities have to be allowed",
+			authorityInfo.Host, authParams.AuthorityInfo.Host)
+	}
+	authParams.AuthorityInfo = authorityInfo
+	return nil
 }
@@ -24,11 +24,66 @@ func TestCreateAcquireTokenCommonParameters(t *testing.T) {
 
 func TestAugmentAuthenticationParameters(t *testing.T) {
 	testScopes := []string{"user.read"}
-	testTokenParams := &acquireTokenCommonParameters{testScopes}
+	testTokenParams := &acquireTokenCommonParameters{scopes: testScopes, partitionKey: "hid"}
 	testAuthParams := &msalbase.AuthParametersInternal{}
 	testTokenParams.augmentAuthenticationParameters(testAuthParams)
 	authScopes := testAuthParams.Scopes
 	if !reflect.DeepEqual(testScopes, authScopes) {
 		t.Errorf("Actual scopes %v differ from expected scopes %v", authScopes, testScopes)
 	}
+	if testAuthParams.PartitionKey != "hid" {
+		t.Errorf("Actual partition key %v differs from expected partition key hid", testAuthParams.PartitionKey)
+	}
+}
+
+func TestAugmentAuthenticationParametersAuthorityOverride(t *testing.T) {
+	tests := []struct {
+		authority  string
+		allowCross bool
+		expectErr  bool
+	}{
+		{"https://login.microsoftonline.com/tenant/", false, false},
+		// login.windows.net is another host of the public cloud
+		{"https://login.windows.net/tenant/", false, false},
+		{"https://login.microsoftonline.us/tenant/", false, true},
+		{"https://login.microsoftonline.us/tenant/", true, false},
+		{"https://contoso.b2clogin.com/tfp/contoso.onmicrosoft.com/b2c_1_signin/", false, true},
+	}
+	for _, test := range tests {
+		testTokenParams := &acquireTokenCommonParameters{authority: test.authority, allowCrossCloudAuthority: test.allowCross}
+		testAuthParams := &msalbase.AuthParametersInternal{AuthorityInfo: testAuthorityInfo}
+		err := testTokenParams.augmentAuthenticationParameters(testAuthParams)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("Authority %s should be rejected as another cloud", test.authority)
+			}
+			if testAuthParams.AuthorityInfo != testAuthorityInfo {
+				t.Errorf("A rejected authority %s shouldn't replace the authority of the request", test.authority)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Authority %s should be used, instead the error is %v", test.authority, err)
+			continue
+		}
+		if testAuthParams.AuthorityInfo.CanonicalAuthorityURI != test.authority {
+			t.Errorf("Request should use authority %s, instead it uses %s", test.authority, testAuthParams.AuthorityInfo.CanonicalAuthorityURI)
+		}
+	}
+	if testAuthorityInfo.Tenant != "v2.0" {
+		t.Errorf("The authority of the client application shouldn't be changed, instead its tenant is %s", testAuthorityInfo.Tenant)
+	}
+}
+
+func TestAugmentAuthenticationParametersAuthorityOverrideWithoutInstanceDiscovery(t *testing.T) {
+	authorityInfo, _ := msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/tenant/", true)
+	authorityInfo.InstanceDiscoveryDisabled = true
+	testTokenParams := &acquireTokenCommonParameters{authority: "https://login.microsoftonline.com/othertenant/"}
+	testAuthParams := &msalbase.AuthParametersInternal{AuthorityInfo: authorityInfo}
+	if err := testTokenParams.augmentAuthenticationParameters(testAuthParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !testAuthParams.AuthorityInfo.InstanceDiscoveryDisabled {
+		t.Error("The authority of the request should keep instance discovery disabled like the client application's")
+	}
 }
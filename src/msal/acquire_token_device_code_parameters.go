@@ -3,17 +3,12 @@
 
 package msalgo
 
-import (
-	"context"
-
-	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
-)
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 
 // AcquireTokenDeviceCodeParameters contains the parameters required to acquire an access token using the device code flow.
 type AcquireTokenDeviceCodeParameters struct {
 	commonParameters   *acquireTokenCommonParameters
 	deviceCodeCallback func(DeviceCodeResultProvider)
-	cancelCtx          context.Context
 }
 
 // CreateAcquireTokenDeviceCodeParameters creates an AcquireTokenDeviceCodeParameters instance.
@@ -21,18 +16,68 @@ type AcquireTokenDeviceCodeParameters struct {
 // The authorization server issues a DeviceCode object with a verification code, an end-user code, and the end-user verification URI.
 // The DeviceCode object is provided through the DeviceCodeResultProvider callback, and the end-user should be instructed to use
 // another device to navigate to the verification URI to input credentials. Since the client cannot receive incoming requests,
-// MSAL polls the authorization server repeatedly until the end-user completes input of credentials. Use cancelCtx to cancel the polling.
-func CreateAcquireTokenDeviceCodeParameters(cancelCtx context.Context, scopes []string,
+// MSAL polls the authorization server repeatedly until the end-user completes input of credentials. Cancel the context passed to AcquireTokenByDeviceCode to stop the polling.
+func CreateAcquireTokenDeviceCodeParameters(scopes []string,
 	deviceCodeCallback func(DeviceCodeResultProvider)) *AcquireTokenDeviceCodeParameters {
 	p := &AcquireTokenDeviceCodeParameters{
 		commonParameters:   createAcquireTokenCommonParameters(scopes),
 		deviceCodeCallback: deviceCodeCallback,
-		cancelCtx:          cancelCtx,
 	}
 	return p
 }
 
-func (p *AcquireTokenDeviceCodeParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) {
-	p.commonParameters.augmentAuthenticationParameters(authParams)
+// SetClaims sets the claims challenge returned by the authority or a resource, e.g. by Continuous Access Evaluation.
+// The claims are sent with the token request, so the token satisfies the challenge.
+func (p *AcquireTokenDeviceCodeParameters) SetClaims(claims string) {
+	p.commonParameters.claims = claims
+}
+
+// SetResource requests the token for a v1 resource, e.g. GraphResource or "https://graph.microsoft.com", instead of the scopes, for apps migrating from ADAL.
+// The scopes are replaced with the .default scope of the resource, which the token is cached under like any other scope.
+func (p *AcquireTokenDeviceCodeParameters) SetResource(resource string) {
+	p.commonParameters.setResource(resource)
+}
+
+// SetProofOfPossession binds the access token to the key, instead of requesting a bearer token.
+// Requests to the resource need the Authorization header created with the key's CreateAuthorizationHeader.
+func (p *AcquireTokenDeviceCodeParameters) SetProofOfPossession(key *PoPKey) {
+	p.commonParameters.popKey = key
+}
+
+// SetCachePartition reads and writes the tokens of the request in the cache partition of the key, e.g. the home account ID of the signed-in user.
+// Web apps serving many users can partition the cache per user, so tokens can't be returned for another user and lookups only search the user's tokens.
+// Partitions are serialized with the rest of the cache, so a cache accessor persists them too.
+func (p *AcquireTokenDeviceCodeParameters) SetCachePartition(partitionKey string) {
+	p.commonParameters.partitionKey = partitionKey
+}
+
+// SetExtraQueryParameters adds the parameters to the token requests, e.g. for features of the authority the parameters don't cover.
+// The parameters of the protocol, e.g. client_id, scope or grant_type, can't be replaced and are dropped from them.
+func (p *AcquireTokenDeviceCodeParameters) SetExtraQueryParameters(parameters map[string]string) {
+	p.commonParameters.extraQueryParameters = parameters
+}
+
+// SetCorrelationID sends the GUID as the client-request-id of the requests, instead of a generated one.
+// Apps that already have an ID for the operation can use it to find the requests in the authority's logs.
+func (p *AcquireTokenDeviceCodeParameters) SetCorrelationID(correlationID string) {
+	p.commonParameters.correlationID = correlationID
+}
+
+// SetAuthority sends the request to the authority instead of the client application's, e.g. "https://login.microsoftonline.com/<tenant>/" to get a token from another tenant.
+// The authority of the client application isn't changed. It has to be in the same cloud, unless SetAllowCrossCloudAuthority is called too.
+func (p *AcquireTokenDeviceCodeParameters) SetAuthority(authorityURI string) {
+	p.commonParameters.authority = authorityURI
+}
+
+// SetAllowCrossCloudAuthority lets the authority set with SetAuthority be in another cloud than the client application's authority, e.g. a national cloud.
+func (p *AcquireTokenDeviceCodeParameters) SetAllowCrossCloudAuthority(allow bool) {
+	p.commonParameters.allowCrossCloudAuthority = allow
+}
+
+func (p *AcquireTokenDeviceCodeParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) error {
+	if err := p.commonParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return err
+	}
 	authParams.AuthorizationType = msalbase.AuthorizationTypeDeviceCode
+	return nil
 }
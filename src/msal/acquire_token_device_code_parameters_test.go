@@ -13,7 +13,7 @@ import (
 func TestAugmentAuthenticationParametersDeviceCode(t *testing.T) {
 	testScopes := []string{"user.read"}
 	testAuthParams := &msalbase.AuthParametersInternal{}
-	testTokenCommonParams := &acquireTokenCommonParameters{testScopes}
+	testTokenCommonParams := &acquireTokenCommonParameters{scopes: testScopes}
 	testDeviceCodeParams := &AcquireTokenDeviceCodeParameters{
 		commonParameters: testTokenCommonParams,
 	}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"strings"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// AcquireTokenManagedIdentityParameters contains the parameters required to acquire an access token for a managed identity.
+type AcquireTokenManagedIdentityParameters struct {
+	commonParameters *acquireTokenCommonParameters
+	resource         string
+}
+
+// CreateAcquireTokenManagedIdentityParameters creates an AcquireTokenManagedIdentityParameters instance.
+// Pass in the resource the token is for, e.g. "https://management.azure.com"; a "/.default" scope of the resource is accepted too.
+func CreateAcquireTokenManagedIdentityParameters(resource string) *AcquireTokenManagedIdentityParameters {
+	return &AcquireTokenManagedIdentityParameters{
+		commonParameters: createAcquireTokenCommonParameters(nil),
+		resource:         strings.TrimSuffix(resource, "/.default"),
+	}
+}
+
+// SetSkipExpirationBuffer returns a cached access token until it actually expires, instead of treating it as expired 5 minutes early.
+// Batch jobs that retry on a 401 response can use it to get the most out of every token; the cached token itself isn't changed.
+func (p *AcquireTokenManagedIdentityParameters) SetSkipExpirationBuffer(skip bool) {
+	p.commonParameters.skipExpirationBuffer = skip
+}
+
+// SetCorrelationID sets the GUID the request is logged with, instead of a generated one.
+func (p *AcquireTokenManagedIdentityParameters) SetCorrelationID(correlationID string) {
+	p.commonParameters.correlationID = correlationID
+}
+
+func (p *AcquireTokenManagedIdentityParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) {
+	// The authority of managed identity requests isn't overridden, so the common parameters can't fail
+	_ = p.commonParameters.augmentAuthenticationParameters(authParams)
+	// The resource is the only scope, so the token is cached for it
+	authParams.Scopes = []string{p.resource}
+	authParams.AuthorizationType = msalbase.AuthorizationTypeManagedIdentity
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
+// AcquireTokenOnBehalfOfParameters contains the parameters required to acquire an access token using the on-behalf-of flow.
+// Middle-tier APIs use this flow to call downstream APIs with the identity of the user who called them.
+type AcquireTokenOnBehalfOfParameters struct {
+	commonParameters *acquireTokenCommonParameters
+	userAssertion    string
+}
+
+// CreateAcquireTokenOnBehalfOfParameters creates an AcquireTokenOnBehalfOfParameters instance.
+// Pass in the scopes of the downstream API and the access token the middle-tier API was called with.
+func CreateAcquireTokenOnBehalfOfParameters(scopes []string, userAssertion string) *AcquireTokenOnBehalfOfParameters {
+	p := &AcquireTokenOnBehalfOfParameters{
+		commonParameters: createAcquireTokenCommonParameters(scopes),
+		userAssertion:    userAssertion,
+	}
+	return p
+}
+
+// SetClaims sets the claims challenge returned by the authority or a resource, e.g. by Continuous Access Evaluation.
+// The claims are sent with the token request, so the token satisfies the challenge.
+func (p *AcquireTokenOnBehalfOfParameters) SetClaims(claims string) {
+	p.commonParameters.claims = claims
+}
+
+// SetResource requests the token for a v1 resource, e.g. GraphResource or "https://graph.microsoft.com", instead of the scopes, for apps migrating from ADAL.
+// The scopes are replaced with the .default scope of the resource, which the token is cached under like any other scope.
+func (p *AcquireTokenOnBehalfOfParameters) SetResource(resource string) {
+	p.commonParameters.setResource(resource)
+}
+
+// SetProofOfPossession binds the access token to the key, instead of requesting a bearer token.
+// Requests to the resource need the Authorization header created with the key's CreateAuthorizationHeader.
+func (p *AcquireTokenOnBehalfOfParameters) SetProofOfPossession(key *PoPKey) {
+	p.commonParameters.popKey = key
+}
+
+// SetCachePartition reads and writes the tokens of the request in the cache partition of the key, e.g. the home account ID of the signed-in user.
+// Web apps serving many users can partition the cache per user, so tokens can't be returned for another user and lookups only search the user's tokens.
+// Partitions are serialized with the rest of the cache, so a cache accessor persists them too.
+func (p *AcquireTokenOnBehalfOfParameters) SetCachePartition(partitionKey string) {
+	p.commonParameters.partitionKey = partitionKey
+}
+
+// SetExtraQueryParameters adds the parameters to the token requests, e.g. for features of the authority the parameters don't cover.
+// The parameters of the protocol, e.g. client_id, scope or grant_type, can't be replaced and are dropped from them.
+func (p *AcquireTokenOnBehalfOfParameters) SetExtraQueryParameters(parameters map[string]string) {
+	p.commonParameters.extraQueryParameters = parameters
+}
+
+// SetCorrelationID sends the GUID as the client-request-id of the requests, instead of a generated one.
+// Apps that already have an ID for the operation can use it to find the requests in the authority's logs.
+func (p *AcquireTokenOnBehalfOfParameters) SetCorrelationID(correlationID string) {
+	p.commonParameters.correlationID = correlationID
+}
+
+// SetAuthority sends the request to the authority instead of the client application's, e.g. "https://login.microsoftonline.com/<tenant>/" to get a token from another tenant.
+// The authority of the client application isn't changed. It has to be in the same cloud, unless SetAllowCrossCloudAuthority is called too.
+func (p *AcquireTokenOnBehalfOfParameters) SetAuthority(authorityURI string) {
+	p.commonParameters.authority = authorityURI
+}
+
+// SetAllowCrossCloudAuthority lets the authority set with SetAuthority be in another cloud than the client application's authority, e.g. a national cloud.
+func (p *AcquireTokenOnBehalfOfParameters) SetAllowCrossCloudAuthority(allow bool) {
+	p.commonParameters.allowCrossCloudAuthority = allow
+}
+
+func (p *AcquireTokenOnBehalfOfParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) error {
+	if err := p.commonParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return err
+	}
+	// The tokens are cached for the user the assertion was issued to
+	claims, err := msalbase.CreateIDToken(p.userAssertion)
+	if err != nil {
+		return err
+	}
+	authParams.HomeaccountID = claims.Subject
+	authParams.UserAssertion = p.userAssertion
+	authParams.AuthorizationType = msalbase.AuthorizationTypeOnBehalfOf
+	return nil
+}
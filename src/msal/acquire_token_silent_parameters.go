@@ -12,6 +12,12 @@ import (
 type AcquireTokenSilentParameters struct {
 	commonParameters *acquireTokenCommonParameters
 	account          AccountProvider
+	//homeAccountID selects the cached refresh token of the request when it has no account, the account may not be cached
+	homeAccountID string
+	//loginHint selects the cached account of the request when it has no account
+	loginHint string
+	//accountFilter selects the cached account of the request when it has no account, together with the login hint
+	accountFilter    func(account AccountProvider) bool
 	requestType      requests.RefreshTokenReqType
 	clientCredential *msalbase.ClientCredential
 }
@@ -36,8 +42,103 @@ func CreateAcquireTokenSilentParametersWithAccount(scopes []string, account Acco
 	return p
 }
 
-func (p *AcquireTokenSilentParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) {
-	p.commonParameters.augmentAuthenticationParameters(authParams)
+// CreateAcquireTokenSilentParametersWithHomeAccountID creates an AcquireTokenSilentParameters instance for the refresh token of the home account ID,
+// for caches that have a refresh token without its account, e.g. imported or legacy caches. The home account IDs of the cached refresh tokens
+// are listed by GetAllRefreshTokens. When the refresh token is redeemed, the account is created from the ID token of the response,
+// so GetAccounts returns it afterwards.
+func CreateAcquireTokenSilentParametersWithHomeAccountID(scopes []string, homeAccountID string) *AcquireTokenSilentParameters {
+	p := &AcquireTokenSilentParameters{
+		commonParameters: createAcquireTokenCommonParameters(scopes),
+		account:          &msalbase.Account{},
+		homeAccountID:    homeAccountID,
+	}
+	return p
+}
+
+// SetLoginHint selects the cached account whose username is the login hint, e.g. the loginHint of the authorization code URL
+// the user signed in with, when the parameters were created without an account. The request fails with an InteractionRequiredError
+// when no cached account has the username, and with an AmbiguousAccountError when more than one has.
+func (p *AcquireTokenSilentParameters) SetLoginHint(loginHint string) {
+	p.loginHint = loginHint
+}
+
+// SetAccount sets the account of the request, e.g. one returned by GetAccounts; it takes precedence over the login hint and the account filter.
+func (p *AcquireTokenSilentParameters) SetAccount(account AccountProvider) {
+	p.account = account
+}
+
+// SetAccountFilter selects the cached account the filter returns true for, when the parameters were created without an account,
+// e.g. to pick the account of a tenant. The request fails with an InteractionRequiredError when no cached account matches,
+// and with an AmbiguousAccountError listing the candidates when more than one does. A login hint set too has to match as well.
+func (p *AcquireTokenSilentParameters) SetAccountFilter(filter func(account AccountProvider) bool) {
+	p.accountFilter = filter
+}
+
+// SetClaims sets the claims challenge returned by the authority or a resource, e.g. by Continuous Access Evaluation.
+// Cached access tokens don't satisfy a claims challenge, so a new token is requested with the refresh token.
+func (p *AcquireTokenSilentParameters) SetClaims(claims string) {
+	p.commonParameters.claims = claims
+}
+
+// SetResource requests the token for a v1 resource, e.g. GraphResource or "https://graph.microsoft.com", instead of the scopes, for apps migrating from ADAL.
+// The scopes are replaced with the .default scope of the resource, which the token is cached under like any other scope.
+func (p *AcquireTokenSilentParameters) SetResource(resource string) {
+	p.commonParameters.setResource(resource)
+}
+
+// SetProofOfPossession binds the access token to the key, instead of requesting a bearer token.
+// Requests to the resource need the Authorization header created with the key's CreateAuthorizationHeader.
+func (p *AcquireTokenSilentParameters) SetProofOfPossession(key *PoPKey) {
+	p.commonParameters.popKey = key
+}
+
+// SetCachePartition reads and writes the tokens of the request in the cache partition of the key, e.g. the home account ID of the signed-in user.
+// Web apps serving many users can partition the cache per user, so tokens can't be returned for another user and lookups only search the user's tokens.
+// Partitions are serialized with the rest of the cache, so a cache accessor persists them too.
+func (p *AcquireTokenSilentParameters) SetCachePartition(partitionKey string) {
+	p.commonParameters.partitionKey = partitionKey
+}
+
+// SetSkipExpirationBuffer returns a cached access token until it actually expires, instead of treating it as expired 5 minutes early.
+// Batch jobs that retry on a 401 response can use it to get the most out of every token; the cached token itself isn't changed.
+func (p *AcquireTokenSilentParameters) SetSkipExpirationBuffer(skip bool) {
+	p.commonParameters.skipExpirationBuffer = skip
+}
+
+// SetExtraQueryParameters adds the parameters to the token requests, e.g. for features of the authority the parameters don't cover.
+// The parameters of the protocol, e.g. client_id, scope or grant_type, can't be replaced and are dropped from them.
+func (p *AcquireTokenSilentParameters) SetExtraQueryParameters(parameters map[string]string) {
+	p.commonParameters.extraQueryParameters = parameters
+}
+
+// SetCorrelationID sends the GUID as the client-request-id of the requests, instead of a generated one.
+// Apps that already have an ID for the operation can use it to find the requests in the authority's logs.
+func (p *AcquireTokenSilentParameters) SetCorrelationID(correlationID string) {
+	p.commonParameters.correlationID = correlationID
+}
+
+// SetAuthority sends the request to the authority instead of the client application's, e.g. "https://login.microsoftonline.com/<tenant>/" to get a token from another tenant.
+// The authority of the client application isn't changed. It has to be in the same cloud, unless SetAllowCrossCloudAuthority is called too.
+func (p *AcquireTokenSilentParameters) SetAuthority(authorityURI string) {
+	p.commonParameters.authority = authorityURI
+}
+
+// SetAllowCrossCloudAuthority lets the authority set with SetAuthority be in another cloud than the client application's authority, e.g. a national cloud.
+func (p *AcquireTokenSilentParameters) SetAllowCrossCloudAuthority(allow bool) {
+	p.commonParameters.allowCrossCloudAuthority = allow
+}
+
+func (p *AcquireTokenSilentParameters) augmentAuthenticationParameters(authParams *msalbase.AuthParametersInternal) error {
+	if err := p.commonParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return err
+	}
 	authParams.AuthorizationType = msalbase.AuthorizationTypeRefreshTokenExchange
-	authParams.HomeaccountID = p.account.GetHomeAccountID()
+	//The account is nil when SetAccount(nil) is called, the home account ID or the account selection is used then
+	if p.account != nil {
+		authParams.HomeaccountID = p.account.GetHomeAccountID()
+	}
+	if authParams.HomeaccountID == "" {
+		authParams.HomeaccountID = p.homeAccountID
+	}
+	return nil
 }
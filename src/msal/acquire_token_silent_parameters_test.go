@@ -13,7 +13,7 @@ import (
 func TestAugmentAuthenticationParametersSilent(t *testing.T) {
 	testScopes := []string{"user.read"}
 	testAuthParams := &msalbase.AuthParametersInternal{}
-	testTokenCommonParams := &acquireTokenCommonParameters{testScopes}
+	testTokenCommonParams := &acquireTokenCommonParameters{scopes: testScopes}
 	homeAccountID := "hid"
 	testAccount := &msalbase.Account{
 		HomeAccountID: &homeAccountID,
@@ -32,3 +32,40 @@ func TestAugmentAuthenticationParametersSilent(t *testing.T) {
 		t.Errorf("Actual home account ID %s differs from expected home account ID %s", actualHomeAccountID, homeAccountID)
 	}
 }
+
+func TestAugmentAuthenticationParametersSilentClaims(t *testing.T) {
+	claims := `{"access_token":{"nbf":{"essential":true,"value":"1597963755"}}}`
+	testSilentParams := CreateAcquireTokenSilentParameters([]string{"user.read"})
+	testSilentParams.SetClaims(claims)
+	testAuthParams := &msalbase.AuthParametersInternal{}
+	testSilentParams.augmentAuthenticationParameters(testAuthParams)
+	if testAuthParams.Claims != claims {
+		t.Errorf("Actual claims %v differ from expected claims %v", testAuthParams.Claims, claims)
+	}
+}
+
+func TestAugmentAuthenticationParametersSilentSkipExpirationBuffer(t *testing.T) {
+	testSilentParams := CreateAcquireTokenSilentParameters([]string{"user.read"})
+	testAuthParams := &msalbase.AuthParametersInternal{}
+	testSilentParams.augmentAuthenticationParameters(testAuthParams)
+	if testAuthParams.SkipExpirationBuffer {
+		t.Error("The expiration buffer should be applied by default")
+	}
+	testSilentParams.SetSkipExpirationBuffer(true)
+	testSilentParams.augmentAuthenticationParameters(testAuthParams)
+	if !testAuthParams.SkipExpirationBuffer {
+		t.Error("The expiration buffer should be skipped for the request")
+	}
+}
+
+func TestAugmentAuthenticationParametersSilentNilAccount(t *testing.T) {
+	testSilentParams := CreateAcquireTokenSilentParametersWithHomeAccountID([]string{"user.read"}, "hid")
+	testSilentParams.SetAccount(nil)
+	testAuthParams := &msalbase.AuthParametersInternal{}
+	if err := testSilentParams.augmentAuthenticationParameters(testAuthParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if testAuthParams.HomeaccountID != "hid" {
+		t.Errorf("The home account ID of the parameters should be used without an account, instead it is %q", testAuthParams.HomeaccountID)
+	}
+}
@@ -15,7 +15,7 @@ func TestAugmentAuthenticationParametersForUsernamePass(t *testing.T) {
 	testUsername := "testUser"
 	testPassword := "testPass"
 	testAuthParams := &msalbase.AuthParametersInternal{}
-	testTokenCommonParams := &acquireTokenCommonParameters{testScopes}
+	testTokenCommonParams := &acquireTokenCommonParameters{scopes: testScopes}
 	tokenUserPassParams := &AcquireTokenUsernamePasswordParameters{
 		commonParameters: testTokenCommonParams,
 		username:         testUsername,
@@ -3,16 +3,26 @@
 
 package msalgo
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 type applicationCommonParameters struct {
-	clientID      string
-	authorityInfo *msalbase.AuthorityInfo
+	clientID                  string
+	authorityInfo             *msalbase.AuthorityInfo
+	skipIDTokenValidation     bool
+	azureRegion               string
+	clientCapabilities        []string
+	clockSkew                 time.Duration
+	normalizeCacheEnvironment bool
 }
 
 func createApplicationCommonParameters(clientID string) *applicationCommonParameters {
 	p := &applicationCommonParameters{
-		clientID: clientID,
+		clientID:  clientID,
+		clockSkew: msalbase.DefaultClockSkew,
 	}
 	return p
 }
@@ -26,11 +36,56 @@ func (p *applicationCommonParameters) setAadAuthority(authorityURI string) error
 	return nil
 }
 
+func (p *applicationCommonParameters) setCloudAuthority(cloud *msalbase.CloudInstance, tenant string) error {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromCloudInstance(cloud, tenant, true)
+	if err != nil {
+		return err
+	}
+	p.authorityInfo = authorityInfo
+	return nil
+}
+
+func (p *applicationCommonParameters) setValidateAuthority(validateAuthority bool) {
+	if p.authorityInfo != nil {
+		p.authorityInfo.ValidateAuthority = validateAuthority
+	}
+}
+
+func (p *applicationCommonParameters) setInstanceDiscovery(enabled bool) {
+	if p.authorityInfo != nil {
+		p.authorityInfo.InstanceDiscoveryDisabled = !enabled
+	}
+}
+
+func (p *applicationCommonParameters) setInstanceDiscoveryHeaders(headers map[string]string) {
+	if p.authorityInfo == nil {
+		return
+	}
+	// The headers are copied so the caller's map can't change them while requests are sent
+	p.authorityInfo.InstanceDiscoveryHeaders = make(map[string]string, len(headers))
+	for k, v := range headers {
+		p.authorityInfo.InstanceDiscoveryHeaders[k] = v
+	}
+}
+
+func (p *applicationCommonParameters) setKnownAuthorityHosts(hosts []string) {
+	if p.authorityInfo == nil {
+		return
+	}
+	p.authorityInfo.KnownAuthorityHosts = append([]string(nil), hosts...)
+}
+
 func (p *applicationCommonParameters) validate() error {
 	return nil
 }
 
-func (p *applicationCommonParameters) createAuthenticationParameters() *msalbase.AuthParametersInternal {
-	params := msalbase.CreateAuthParametersInternal(p.clientID, p.authorityInfo)
-	return params
+func (p *applicationCommonParameters) createAuthenticationParameters() (*msalbase.AuthParametersInternal, error) {
+	params, err := msalbase.CreateAuthParametersInternal(p.clientID, p.authorityInfo)
+	if err != nil {
+		return nil, err
+	}
+	params.ClientCapabilities = p.clientCapabilities
+	params.ClockSkew = p.clockSkew
+	params.NormalizeCacheEnvironment = p.normalizeCacheEnvironment
+	return params, nil
 }
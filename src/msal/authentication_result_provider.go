@@ -3,8 +3,32 @@
 
 package msalgo
 
+import "time"
+
 // AuthenticationResultProvider contains the results of one token acquisition operation in PublicClientApplication
 // or ConfidentialClientApplication.
+// The ID token claims are returned as zero values when there's no ID token, or the claim is absent.
+// GetCorrelationID returns the ID the requests were sent with, it can be logged to investigate the token acquisition with support.
+// GetGrantedScopes returns the scopes the token was granted, GetDeclinedScopes the requested scopes it wasn't granted,
+// e.g. because the user consented to only some of them. Apps can ask for the declined scopes again once they're needed.
+// GetAccount returns the account the tokens were cached for, the same account GetAccounts returns, together with the claims
+// of its ID token from GetIDTokenClaims so a session can be created from the result alone. It's nil for tokens acquired without a user.
 type AuthenticationResultProvider interface {
 	GetAccessToken() string
+	GetTokenType() string
+	GetGrantedScopes() []string
+	GetDeclinedScopes() []string
+	GetExpiresOn() time.Time
+	GetExtExpiresOn() time.Time
+	GetSpaCode() string
+	GetCorrelationID() string
+	GetAccount() *Account
+	GetRawIDToken() string
+	GetIDTokenClaims() (map[string]interface{}, error)
+	GetTenantID() string
+	GetObjectID() string
+	GetName() string
+	GetEmail() string
+	GetRoles() []string
+	GetGroups() []string
 }
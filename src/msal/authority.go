@@ -0,0 +1,24 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// WithTenant rewrites a /common or /organizations authority to the tenant, e.g. the tid claim of the ID token of the signed in user.
+// Passing the result to SetAuthority of the silent requests keeps them in the user's home tenant,
+// so the authority doesn't have to pick between the tenants the user is a guest of.
+// An authority that's already tenant-specific is only accepted for that same tenant.
+func WithTenant(authorityURI string, tenantID string) (string, error) {
+	authorityInfo, err := msalbase.CreateAuthorityInfoFromAuthorityURI(authorityURI, false)
+	if err != nil {
+		return "", err
+	}
+	tenantAuthority, err := authorityInfo.WithTenant(tenantID)
+	if err != nil {
+		return "", err
+	}
+	return tenantAuthority.CanonicalAuthorityURI, nil
+}
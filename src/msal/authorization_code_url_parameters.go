@@ -4,12 +4,30 @@
 package msalgo
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 )
 
+// These are the values of the Prompt of the authorization code URL, the user is only prompted when needed if it's empty.
+const (
+	// PromptLogin makes the user enter their credentials, even when they're signed in.
+	PromptLogin = "login"
+	// PromptConsent shows the consent dialog, even when the user already consented to the scopes.
+	PromptConsent = "consent"
+	// PromptSelectAccount lets the user choose which of their signed in accounts to use.
+	PromptSelectAccount = "select_account"
+	// PromptNone never shows a prompt, the authority redirects back with an error when the user would have to interact.
+	// It's used for silent sign-in in a hidden iframe.
+	PromptNone = "none"
+)
+
+// maxPendingNonces bounds the nonces a client application keeps for the sign-ins that haven't redeemed their code yet
+const maxPendingNonces = 1000
+
 // AuthorizationCodeURLParameters has the parameters to create the URL to generate an authorization code.
 type AuthorizationCodeURLParameters struct {
 	ClientID            string
@@ -22,7 +40,13 @@ type AuthorizationCodeURLParameters struct {
 	DomainHint          string
 	CodeChallenge       string
 	CodeChallengeMethod string
-	Scopes              []string
+	Claims              string
+	// Nonce is generated when the URL is created unless it's set, the ID token of the code has to contain it.
+	// A generated nonce is checked by the State the code is redeemed with, so the State is required unless the Nonce is set
+	Nonce  string
+	Scopes []string
+	// ExtraQueryParameters are added to the URL, the parameters of the fields above can't be replaced by them
+	ExtraQueryParameters map[string]string
 }
 
 // CreateAuthorizationCodeURLParameters creates an AuthorizationCodeURLParameters instance. These are the basic required parameters to create this URL.
@@ -37,10 +61,44 @@ func CreateAuthorizationCodeURLParameters(clientID string, redirectURI string, s
 	return p
 }
 
+// SetCodeVerifier turns on PKCE for the authorization code URL by adding the S256 code challenge for the code verifier.
+// The same code verifier needs to be passed in when acquiring a token with the authorization code.
+func (p *AuthorizationCodeURLParameters) SetCodeVerifier(codeVerifier string) {
+	p.CodeChallenge = CreateCodeChallenge(codeVerifier)
+	p.CodeChallengeMethod = msalbase.CodeChallengeMethodS256
+}
+
+// AuthCodeURLOption sets an optional parameter of the URL created by AuthCodeURL.
+type AuthCodeURLOption func(*AuthorizationCodeURLParameters)
+
+// WithCodeVerifier adds the PKCE code challenge for the code verifier, see SetCodeVerifier.
+func WithCodeVerifier(codeVerifier string) AuthCodeURLOption {
+	return func(p *AuthorizationCodeURLParameters) {
+		p.SetCodeVerifier(codeVerifier)
+	}
+}
+
+// WithLoginHint prefills the username on the sign-in page.
+func WithLoginHint(loginHint string) AuthCodeURLOption {
+	return func(p *AuthorizationCodeURLParameters) {
+		p.LoginHint = loginHint
+	}
+}
+
+// WithPrompt sets how the user is prompted, it's one of PromptLogin, PromptConsent, PromptSelectAccount and PromptNone.
+func WithPrompt(prompt string) AuthCodeURLOption {
+	return func(p *AuthorizationCodeURLParameters) {
+		p.Prompt = prompt
+	}
+}
+
 //createURL creates the URL required to generate an authorization code from the parameters
-func (p *AuthorizationCodeURLParameters) createURL(wrm requests.WebRequestManager, authParams *msalbase.AuthParametersInternal) (string, error) {
+func (p *AuthorizationCodeURLParameters) createURL(ctx context.Context, wrm requests.WebRequestManager, authParams *msalbase.AuthParametersInternal) (string, error) {
+	if err := validatePrompt(p.Prompt); err != nil {
+		return "", err
+	}
 	resolutionManager := requests.CreateAuthorityEndpointResolutionManager(wrm)
-	endpoints, err := resolutionManager.ResolveEndpoints(authParams.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, authParams.AuthorityInfo, "")
 	if err != nil {
 		return "", err
 	}
@@ -74,10 +132,37 @@ func (p *AuthorizationCodeURLParameters) createURL(wrm requests.WebRequestManage
 	if p.CodeChallengeMethod != "" {
 		urlParams.Add("code_challenge_method", p.CodeChallengeMethod)
 	}
+	claims, err := msalbase.MergeClientCapabilities(p.Claims, authParams.ClientCapabilities)
+	if err != nil {
+		return "", err
+	}
+	if claims != "" {
+		urlParams.Add("claims", claims)
+	}
+	if p.Nonce == "" {
+		if p.Nonce, err = msalbase.NewUUID(); err != nil {
+			return "", err
+		}
+	}
+	urlParams.Add("nonce", p.Nonce)
+	for name, value := range p.ExtraQueryParameters {
+		if _, ok := urlParams[name]; ok || isReservedQueryParameter(name) {
+			continue
+		}
+		urlParams.Add(name, value)
+	}
 	baseURL.RawQuery = urlParams.Encode()
 	return baseURL.String(), nil
 }
 
+func validatePrompt(prompt string) error {
+	switch prompt {
+	case "", PromptLogin, PromptConsent, PromptSelectAccount, PromptNone:
+		return nil
+	}
+	return fmt.Errorf("prompt '%s' isn't one of '%s', '%s', '%s' and '%s'", prompt, PromptLogin, PromptConsent, PromptSelectAccount, PromptNone)
+}
+
 func (p *AuthorizationCodeURLParameters) getSeparatedScopes() string {
 	return msalbase.ConcatenateScopes(p.Scopes)
 }
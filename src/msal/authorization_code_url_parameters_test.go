@@ -4,7 +4,11 @@
 package msalgo
 
 import (
+	"bytes"
+	"context"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
@@ -13,7 +17,7 @@ import (
 
 var (
 	testURLAuthorityInfo, _ = msalbase.CreateAuthorityInfoFromAuthorityURI("https://login.microsoftonline.com/v2.0/", true)
-	testURLAuthParams       = msalbase.CreateAuthParametersInternal("clientID", testURLAuthorityInfo)
+	testURLAuthParams, _    = msalbase.CreateAuthParametersInternal("clientID", testURLAuthorityInfo)
 	urlWRM                  = new(requests.MockWebRequestManager)
 	authCodeURLParams       = CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid", "user.read"})
 )
@@ -28,6 +32,7 @@ func TestGetSeparatedScopes(t *testing.T) {
 
 func TestCreateURL(t *testing.T) {
 	authCodeURLParams.CodeChallenge = "codeChallenge"
+	authCodeURLParams.Nonce = "nonce123"
 	tdr := &requests.TenantDiscoveryResponse{
 		AuthorizationEndpoint: "https://login.microsoftonline.com/v2.0/authorize",
 		TokenEndpoint:         "https://login.microsoftonline.com/v2.0/token",
@@ -35,13 +40,207 @@ func TestCreateURL(t *testing.T) {
 	}
 	urlWRM.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
-	url, err := authCodeURLParams.createURL(urlWRM, testURLAuthParams)
+	url, err := authCodeURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
 	if err != nil {
 		t.Errorf("Error is supposed to be nil, instead it is %v", err)
 	}
 	actualURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&code_challenge=codeChallenge" +
-		"&redirect_uri=redirect&response_type=code&scope=openid+user.read"
+		"&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid+user.read"
 	if !reflect.DeepEqual(url, actualURL) {
 		t.Errorf("Actual URL %v differs from expected URL %v", actualURL, url)
 	}
 }
+
+func TestCreateURLWithClaims(t *testing.T) {
+	claimsURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid", "user.read"})
+	claimsURLParams.Claims = `{"id_token":{"auth_time":{"essential":true}}}`
+	claimsURLParams.Nonce = "nonce123"
+	tdr := &requests.TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.microsoftonline.com/v2.0/authorize",
+		TokenEndpoint:         "https://login.microsoftonline.com/v2.0/token",
+		Issuer:                "https://login.microsoftonline.com/v2.0",
+	}
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	url, err := claimsURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?claims=%7B%22id_token%22%3A%7B%22auth_time%22%3A%7B%22essential%22%3Atrue%7D%7D%7D" +
+		"&client_id=clientID&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid+user.read"
+	if !reflect.DeepEqual(url, expectedURL) {
+		t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+	}
+}
+
+func TestCreateURLWithNonce(t *testing.T) {
+	nonceURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	nonceURLParams.Nonce = "nonce123"
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	url, err := nonceURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
+	if url != expectedURL {
+		t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+	}
+}
+
+func TestCreateURLNonceWithRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		SetRandomSource(bytes.NewReader(bytes.Repeat([]byte{0xff}, 16)))
+		nonceURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+		if _, err := nonceURLParams.createURL(context.Background(), urlWRM, testURLAuthParams); err != nil {
+			t.Fatalf("Error is supposed to be nil, instead it is %v", err)
+		}
+		nonces = append(nonces, nonceURLParams.Nonce)
+	}
+	if nonces[0] != "ffffffff-ffff-4fff-bfff-ffffffffffff" || nonces[0] != nonces[1] {
+		t.Errorf("The nonces should be read from the random source, instead they are %v", nonces)
+	}
+	nonceURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	if url, err := nonceURLParams.createURL(context.Background(), urlWRM, testURLAuthParams); err == nil {
+		t.Errorf("Error is supposed to be returned when the random source is exhausted, instead the URL is %v", url)
+	}
+}
+
+func TestCreateURLWithExtraQueryParameters(t *testing.T) {
+	extraURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	extraURLParams.ExtraQueryParameters = map[string]string{"dc": "ESTS-PUB-WUS2", "client_id": "otherClient", "Response_Type": "token", "nonce": "otherNonce"}
+	extraURLParams.Nonce = "nonce123"
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	url, err := extraURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&dc=ESTS-PUB-WUS2&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
+	if url != expectedURL {
+		t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+	}
+}
+
+func TestCreateURLWithHints(t *testing.T) {
+	hintURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	hintURLParams.LoginHint = "user+test@contoso.com"
+	hintURLParams.DomainHint = "contoso.com"
+	hintURLParams.Nonce = "nonce123"
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	url, err := hintURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&domain_hint=contoso.com" +
+		"&login_hint=user%2Btest%40contoso.com&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
+	if url != expectedURL {
+		t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+	}
+}
+
+func TestCreateURLWithClientCapabilities(t *testing.T) {
+	capabilityURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	capabilityURLParams.Claims = `{"id_token":{"auth_time":{"essential":true}}}`
+	capabilityURLParams.Nonce = "nonce123"
+	authParams := *testURLAuthParams
+	authParams.ClientCapabilities = []string{"cp1"}
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	url, err := capabilityURLParams.createURL(context.Background(), urlWRM, &authParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?claims=" +
+		"%7B%22access_token%22%3A%7B%22xms_cc%22%3A%7B%22values%22%3A%5B%22cp1%22%5D%7D%7D%2C%22id_token%22%3A%7B%22auth_time%22%3A%7B%22essential%22%3Atrue%7D%7D%7D" +
+		"&client_id=clientID&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
+	if url != expectedURL {
+		t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.authcodeurl.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetInstanceDiscovery(false)
+	wrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = wrm
+	wrm.On("GetTenantDiscoveryResponse", "https://login.authcodeurl.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(&requests.TenantDiscoveryResponse{
+		AuthorizationEndpoint: "https://login.authcodeurl.contoso.com/tenant/oauth2/v2.0/authorize",
+		TokenEndpoint:         "https://login.authcodeurl.contoso.com/tenant/oauth2/v2.0/token",
+		Issuer:                "https://login.authcodeurl.contoso.com/tenant/v2.0",
+	}, nil)
+	authCodeURL, err := pca.AuthCodeURL(context.Background(), []string{"openid", "user.read"}, "http://localhost:8080/redirect?app=1", "state&value",
+		WithCodeVerifier("codeVerifier"), WithLoginHint("user+test@contoso.com"), WithPrompt("select_account"))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !strings.HasPrefix(authCodeURL, "https://login.authcodeurl.contoso.com/tenant/oauth2/v2.0/authorize?") {
+		t.Errorf("The URL should be of the authorize endpoint of the authority, instead it is %s", authCodeURL)
+	}
+	for _, escaped := range []string{"state=state%26value", "redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Fredirect%3Fapp%3D1", "login_hint=user%2Btest%40contoso.com"} {
+		if !strings.Contains(authCodeURL, escaped) {
+			t.Errorf("The URL should contain the escaped %s, instead it is %s", escaped, authCodeURL)
+		}
+	}
+	parsedURL, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	nonce := parsedURL.Query().Get("nonce")
+	if nonce == "" {
+		t.Error("A nonce should be generated for the URL")
+	}
+	expectedQuery := url.Values{
+		"client_id":             {"clientID"},
+		"response_type":         {"code"},
+		"redirect_uri":          {"http://localhost:8080/redirect?app=1"},
+		"scope":                 {"openid user.read"},
+		"state":                 {"state&value"},
+		"code_challenge":        {CreateCodeChallenge("codeVerifier")},
+		"code_challenge_method": {"S256"},
+		"login_hint":            {"user+test@contoso.com"},
+		"prompt":                {"select_account"},
+		"nonce":                 {nonce},
+	}
+	if !reflect.DeepEqual(parsedURL.Query(), expectedQuery) {
+		t.Errorf("Actual query %v differs from expected query %v", parsedURL.Query(), expectedQuery)
+	}
+}
+
+func TestCreateURLWithPrompt(t *testing.T) {
+	urlWRM.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	for _, prompt := range []string{PromptLogin, PromptConsent, PromptSelectAccount, PromptNone} {
+		promptURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+		promptURLParams.Prompt = prompt
+		promptURLParams.Nonce = "nonce123"
+		url, err := promptURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+		if err != nil {
+			t.Errorf("Error is supposed to be nil for prompt %s, instead it is %v", prompt, err)
+		}
+		expectedURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&nonce=nonce123&prompt=" + prompt + "&redirect_uri=redirect&response_type=code&scope=openid"
+		if url != expectedURL {
+			t.Errorf("Actual URL %v differs from expected URL %v", url, expectedURL)
+		}
+	}
+	promptURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	url, err := promptURLParams.createURL(context.Background(), urlWRM, testURLAuthParams)
+	if err != nil {
+		t.Errorf("Error is supposed to be nil, instead it is %v", err)
+	}
+	if strings.Contains(url, "prompt=") {
+		t.Errorf("The prompt should be left out of the URL when it isn't set, instead the URL is %v", url)
+	}
+	promptURLParams.Prompt = "always"
+	if url, err := promptURLParams.createURL(context.Background(), urlWRM, testURLAuthParams); err == nil {
+		t.Errorf("Error is supposed to be returned for an invalid prompt, instead the URL is %v", url)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+)
+
+// AutoDetectRegion is passed to SetAzureRegion to use the region the app runs in.
+// The region is read from the AZURE_REGION environment variable, or else from the instance metadata service of the Azure VM.
+const AutoDetectRegion = "TryAutoDetect"
+
+const azureRegionEnvVar = "AZURE_REGION"
+
+// imdsTimeout bounds the instance metadata request, outside of Azure nothing answers it
+const imdsTimeout = 2 * time.Second
+
+// getRegion returns the region whose token endpoint is used, it's empty when the global endpoint is used.
+// A detected region is remembered, so the instance metadata service is only asked once.
+func (client *clientApplication) getRegion(ctx context.Context) string {
+	region := client.clientApplicationParameters.commonParameters.azureRegion
+	if region != AutoDetectRegion {
+		return region
+	}
+	client.regionLock.Lock()
+	defer client.regionLock.Unlock()
+	if client.detectedRegion == nil {
+		detected, err := detectRegion(ctx, client.webRequestManager)
+		if err != nil {
+			msalbase.GetLogger().Warnf("The Azure region couldn't be detected, the global token endpoint is used: %v", err)
+			// A canceled request doesn't mean the region can't be detected, so a later request tries again
+			if ctx.Err() != nil {
+				return ""
+			}
+		}
+		client.detectedRegion = &detected
+	}
+	return *client.detectedRegion
+}
+
+func detectRegion(ctx context.Context, wrm requests.WebRequestManager) (string, error) {
+	if region := os.Getenv(azureRegionEnvVar); region != "" {
+		return strings.ToLower(region), nil
+	}
+	imdsCtx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+	region, err := wrm.GetAzureRegion(imdsCtx)
+	if err != nil {
+		return "", err
+	}
+	if region == "" {
+		return "", errors.New("instance metadata service returned no region")
+	}
+	return strings.ToLower(region), nil
+}
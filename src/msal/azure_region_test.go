@@ -0,0 +1,100 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
+)
+
+func createRegionalTestCCA(region string, testWrm *requests.MockWebRequestManager, testCacheManager *requests.MockCacheManager) *ConfidentialClientApplication {
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	params := &clientApplicationParameters{
+		commonParameters: &applicationCommonParameters{
+			clientID:      "clientID",
+			authorityInfo: testAuthorityInfo,
+			azureRegion:   region,
+		},
+	}
+	return &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: params,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+}
+
+// acquireRegionalTestToken acquires a token with the client credentials grant and returns the token endpoint it was requested from
+func acquireRegionalTestToken(t *testing.T, cca *ConfidentialClientApplication,
+	testWrm *requests.MockWebRequestManager, testCacheManager *requests.MockCacheManager) string {
+	var emptyAccessToken *msalbase.MockAccessToken
+	emptyStorageToken := msalbase.CreateStorageTokenResponse(emptyAccessToken, nil, nil, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(emptyStorageToken, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenEndpoint := ""
+	tokenResp := &msalbase.TokenResponse{}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").
+		Run(func(args mock.Arguments) {
+			tokenEndpoint = args.Get(0).(*msalbase.AuthParametersInternal).Endpoints.TokenEndpoint
+		}).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	return tokenEndpoint
+}
+
+func TestAcquireTokenByClientCredentialWithRegion(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cca := createRegionalTestCCA("eastus", testWrm, testCacheManager)
+	tokenEndpoint := acquireRegionalTestToken(t, cca, testWrm, testCacheManager)
+	if expected := "https://eastus.login.microsoft.com/v2.0/token"; tokenEndpoint != expected {
+		t.Errorf("Token endpoint should be %s, instead it is %s", expected, tokenEndpoint)
+	}
+	testWrm.AssertNotCalled(t, "GetAzureRegion")
+}
+
+func TestAcquireTokenByClientCredentialRegionFallback(t *testing.T) {
+	os.Unsetenv(azureRegionEnvVar)
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cca := createRegionalTestCCA(AutoDetectRegion, testWrm, testCacheManager)
+	testWrm.On("GetAzureRegion").Return("", errors.New("dial tcp 169.254.169.254:80: i/o timeout")).Once()
+	tokenEndpoint := acquireRegionalTestToken(t, cca, testWrm, testCacheManager)
+	if expected := "https://login.microsoftonline.com/v2.0/token"; tokenEndpoint != expected {
+		t.Errorf("Token endpoint should fall back to %s, instead it is %s", expected, tokenEndpoint)
+	}
+	// The failed detection is remembered, so the instance metadata service isn't asked again
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAzureRegion", 1)
+}
+
+func TestDetectRegion(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testWrm.On("GetAzureRegion").Return("WestUS2", nil)
+	os.Setenv(azureRegionEnvVar, "NorthEurope")
+	defer os.Unsetenv(azureRegionEnvVar)
+	region, err := detectRegion(context.Background(), testWrm)
+	if err != nil || region != "northeurope" {
+		t.Errorf("Region should be read from %s, instead it is %s with error %v", azureRegionEnvVar, region, err)
+	}
+	testWrm.AssertNotCalled(t, "GetAzureRegion")
+	os.Unsetenv(azureRegionEnvVar)
+	region, err = detectRegion(context.Background(), testWrm)
+	if err != nil || region != "westus2" {
+		t.Errorf("Region should be read from the instance metadata service, instead it is %s with error %v", region, err)
+	}
+}
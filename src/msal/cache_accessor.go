@@ -5,7 +5,8 @@ package msalgo
 
 // CacheAccessor is an interface where the user can specify cache persistence properties.
 // BeforeCacheAccess is called everytime before the cache is accessed, and AfterCacheAccess
-// is called after it is accessed.
+// is called after it is accessed. The CacheContext passed to AfterCacheAccess reports whether the
+// access wrote to the cache.
 type CacheAccessor interface {
 	BeforeCacheAccess(context *CacheContext)
 	AfterCacheAccess(context *CacheContext)
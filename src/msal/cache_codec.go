@@ -0,0 +1,23 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+// CacheCodec protects the serialized cache at rest, e.g. by encrypting it with a key from the OS keyring or DPAPI.
+// Encrypt is applied to the bytes returned by CacheContext.SerializeCache before the CacheAccessor persists them,
+// and Decrypt to the bytes passed to CacheContext.DeserializeCache. By default the cache isn't encoded.
+type CacheCodec interface {
+	Encrypt(data []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// noopCacheCodec is the default codec, it leaves the serialized cache as it is
+type noopCacheCodec struct{}
+
+func (noopCacheCodec) Encrypt(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (noopCacheCodec) Decrypt(data []byte) ([]byte, error) {
+	return data, nil
+}
@@ -3,19 +3,66 @@
 
 package msalgo
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+import (
+	"context"
+	"fmt"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+)
 
 // CacheContext allows the user access to the cache to use in their CacheAccessor implementation.
 type CacheContext struct {
-	cache requests.CacheManager
+	cache           requests.CacheManager
+	hasStateChanged bool
+	codec           CacheCodec
+	ctx             context.Context
+}
+
+// Context returns the context of the call that accesses the cache, CacheAccessor implementations can stop waiting for a lock or for storage when it's done.
+// It's context.Background() for calls that aren't passed a context.
+func (cacheContext *CacheContext) Context() context.Context {
+	if cacheContext.ctx == nil {
+		return context.Background()
+	}
+	return cacheContext.ctx
+}
+
+// HasStateChanged reports whether the cache was written to during this access.
+// AfterCacheAccess implementations can use it to only persist the cache when it changed.
+func (context *CacheContext) HasStateChanged() bool {
+	return context.hasStateChanged
+}
+
+func (context *CacheContext) getCodec() CacheCodec {
+	if context.codec == nil {
+		return noopCacheCodec{}
+	}
+	return context.codec
+}
+
+// SerializeCache serializes the cache to the unified MSAL JSON cache format.
+// The JSON is encrypted with the app's CacheCodec, when one is set.
+func (context *CacheContext) SerializeCache() ([]byte, error) {
+	data, err := context.cache.SerializeCache()
+	if err != nil {
+		return nil, err
+	}
+	return context.getCodec().Encrypt(data)
 }
 
-// SerializeCache serializes the cache to a json string.
-func (context *CacheContext) SerializeCache() (string, error) {
-	return context.cache.Serialize()
+// RemoveExpiredAccessTokens deletes the access tokens that have expired from the cache and returns how many were removed.
+// Long-running applications can call it to keep a persisted cache from growing.
+func (context *CacheContext) RemoveExpiredAccessTokens() (int, error) {
+	return context.cache.RemoveExpiredAccessTokens()
 }
 
 // DeserializeCache converts a byte array representing the JSON cache to the internal cache representation.
+// The entries are merged into the existing cache, and unknown JSON fields are preserved.
+// The data is decrypted with the app's CacheCodec first, when it can't be decrypted ErrCacheDecryption is returned and the cache is left unchanged.
 func (context *CacheContext) DeserializeCache(data []byte) error {
-	return context.cache.Deserialize(data)
+	decrypted, err := context.getCodec().Decrypt(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCacheDecryption, err)
+	}
+	return context.cache.DeserializeCache(decrypted)
 }
@@ -4,10 +4,17 @@
 package msalgo
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
 	"reflect"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestContextSerialize(t *testing.T) {
@@ -15,8 +22,8 @@ func TestContextSerialize(t *testing.T) {
 	context := &CacheContext{
 		cache: mockCacheMgr,
 	}
-	exampleCache := "jsonCache"
-	mockCacheMgr.On("Serialize").Return(exampleCache, nil)
+	exampleCache := []byte("jsonCache")
+	mockCacheMgr.On("SerializeCache").Return(exampleCache, nil)
 	actualCache, err := context.SerializeCache()
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
@@ -32,9 +39,102 @@ func TestContextDeserialize(t *testing.T) {
 		cache: mockCacheMgr,
 	}
 	exampleCache := []byte("jsonCache")
-	mockCacheMgr.On("Deserialize", exampleCache).Return(nil)
+	mockCacheMgr.On("DeserializeCache", exampleCache).Return(nil)
 	err := context.DeserializeCache(exampleCache)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestContextRemoveExpiredAccessTokens(t *testing.T) {
+	mockCacheMgr := new(requests.MockCacheManager)
+	context := &CacheContext{
+		cache: mockCacheMgr,
+	}
+	mockCacheMgr.On("RemoveExpiredAccessTokens").Return(2, nil)
+	removed, err := context.RemoveExpiredAccessTokens()
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Removed count should be 2, but it is %d", removed)
+	}
+}
+
+// aesGCMCodec is the kind of codec an app would implement, the nonce is prepended to the sealed cache
+type aesGCMCodec struct {
+	aead cipher.AEAD
+}
+
+func createAESGCMCodec(t *testing.T) *aesGCMCodec {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	return &aesGCMCodec{aead: aead}
+}
+
+func (c *aesGCMCodec) Encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (c *aesGCMCodec) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func TestContextCodecRoundTrip(t *testing.T) {
+	codec := createAESGCMCodec(t)
+	mockCacheMgr := new(requests.MockCacheManager)
+	context := &CacheContext{
+		cache: mockCacheMgr,
+		codec: codec,
+	}
+	exampleCache := []byte(`{"RefreshToken":{"key":{"secret":"refreshToken"}}}`)
+	mockCacheMgr.On("SerializeCache").Return(exampleCache, nil)
+	mockCacheMgr.On("DeserializeCache", exampleCache).Return(nil)
+	encrypted, err := context.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if bytes.Contains(encrypted, []byte("refreshToken")) {
+		t.Errorf("Serialized cache should be encrypted, but it is %s", encrypted)
+	}
+	if err := context.DeserializeCache(encrypted); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	mockCacheMgr.AssertCalled(t, "DeserializeCache", exampleCache)
+}
+
+func TestContextDeserializeDecryptionFailure(t *testing.T) {
+	mockCacheMgr := new(requests.MockCacheManager)
+	encrypted, err := createAESGCMCodec(t).Encrypt([]byte("jsonCache"))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	// The cache was encrypted with another key
+	context := &CacheContext{
+		cache: mockCacheMgr,
+		codec: createAESGCMCodec(t),
+	}
+	err = context.DeserializeCache(encrypted)
+	if !errors.Is(err, ErrCacheDecryption) {
+		t.Errorf("Error should be ErrCacheDecryption, but it is %v", err)
+	}
+	mockCacheMgr.AssertNotCalled(t, "DeserializeCache", mock.Anything)
+}
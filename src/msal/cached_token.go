@@ -0,0 +1,16 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
+// CachedAccessToken describes an access token of the cache, it's returned by GetAllAccessTokens for diagnostics.
+// Valid tells whether silent requests still get the token; a token that expires within the buffer of the cache isn't returned to them.
+type CachedAccessToken = msalbase.CachedAccessToken
+
+// CachedRefreshToken describes a refresh token of the cache, it's returned by GetAllRefreshTokens for diagnostics.
+type CachedRefreshToken = msalbase.CachedRefreshToken
+
+// CacheStats counts the accounts and tokens of the cache, including its partitions, it's returned by Stats for health checks.
+type CacheStats = msalbase.CacheStats
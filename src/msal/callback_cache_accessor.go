@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// CallbackCacheAccessor is a CacheAccessor that loads and saves the serialized cache with two functions,
+// for apps that keep the cache somewhere simple, e.g. a file or a secret store, and don't need a CacheAccessor of their own.
+type CallbackCacheAccessor struct {
+	beforeAccess func() ([]byte, error)
+	afterAccess  func(data []byte, changed bool) error
+}
+
+// CreateCallbackCacheAccessor creates a CallbackCacheAccessor. beforeAccess returns the serialized cache that is loaded before every access,
+// no data is an empty cache. afterAccess is called after every access, with the serialized cache when the access changed it
+// and with nil data otherwise. Either function can be nil. Errors of the functions are logged, the access goes on without the persisted cache.
+func CreateCallbackCacheAccessor(beforeAccess func() ([]byte, error), afterAccess func(data []byte, changed bool) error) *CallbackCacheAccessor {
+	return &CallbackCacheAccessor{beforeAccess: beforeAccess, afterAccess: afterAccess}
+}
+
+// BeforeCacheAccess loads the data returned by beforeAccess into the cache.
+func (a *CallbackCacheAccessor) BeforeCacheAccess(context *CacheContext) {
+	if a.beforeAccess == nil {
+		return
+	}
+	data, err := a.beforeAccess()
+	if err != nil {
+		msalbase.GetLogger().Errorf("The cache couldn't be loaded: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	if err := context.DeserializeCache(data); err != nil {
+		msalbase.GetLogger().Errorf("The cache couldn't be loaded: %v", err)
+	}
+}
+
+// AfterCacheAccess passes the serialized cache to afterAccess when the access changed it.
+func (a *CallbackCacheAccessor) AfterCacheAccess(context *CacheContext) {
+	if a.afterAccess == nil {
+		return
+	}
+	var data []byte
+	changed := context.HasStateChanged()
+	if changed {
+		var err error
+		if data, err = context.SerializeCache(); err != nil {
+			msalbase.GetLogger().Errorf("The cache couldn't be serialized: %v", err)
+			return
+		}
+	}
+	if err := a.afterAccess(data, changed); err != nil {
+		msalbase.GetLogger().Errorf("The cache couldn't be saved: %v", err)
+	}
+}
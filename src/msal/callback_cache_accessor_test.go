@@ -0,0 +1,148 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCallbackCacheAccessorFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msalcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.json")
+	saves := 0
+	beforeAccess := func() ([]byte, error) {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return data, err
+	}
+	afterAccess := func(data []byte, changed bool) error {
+		if !changed {
+			if data != nil {
+				t.Error("The cache shouldn't be serialized when the access didn't change it")
+			}
+			return nil
+		}
+		saves++
+		return ioutil.WriteFile(file, data, 0600)
+	}
+	createApp := func() (*ConfidentialClientApplication, *requests.MockWebRequestManager) {
+		cred, err := CreateClientCredentialFromSecret("client_secret")
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		cca, err := CreateConfidentialClientApplication("clientID", "https://login.callbackcache.contoso.com/tenant/", cred)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		cca.SetInstanceDiscovery(false)
+		cca.SetCacheCallbacks(beforeAccess, afterAccess)
+		testWrm := new(requests.MockWebRequestManager)
+		cca.clientApplication.webRequestManager = testWrm
+		testWrm.On("GetTenantDiscoveryResponse",
+			"https://login.callbackcache.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+		return cca, testWrm
+	}
+	writer, writerWrm := createApp()
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	writerWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+	if _, err := writer.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if saves != 1 {
+		t.Fatalf("The cache should be saved once after the token was written, instead it was saved %d times", saves)
+	}
+
+	// Another app loads the token from the file, so it isn't requested again
+	reader, readerWrm := createApp()
+	result, err := reader.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"}))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "secret" {
+		t.Errorf("The access token should be read from the file, instead it is %s", result.GetAccessToken())
+	}
+	readerWrm.AssertNotCalled(t, "GetAccessTokenWithClientSecret", mock.Anything, mock.Anything)
+	if saves != 1 {
+		t.Errorf("Reading the cache shouldn't save it, instead it was saved %d times", saves)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.flush.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if err := cca.Flush(); err != nil {
+		t.Errorf("Flush without a cache accessor should do nothing, instead the error is %v", err)
+	}
+	cca.SetInstanceDiscovery(false)
+	var saved []byte
+	savedChanged := false
+	failSave := true
+	cca.SetCacheCallbacks(nil, func(data []byte, changed bool) error {
+		// The save of the token fails, so only Flush persists it
+		if failSave {
+			return errors.New("cache not saved")
+		}
+		saved, savedChanged = data, changed
+		return nil
+	})
+	testWrm := new(requests.MockWebRequestManager)
+	cca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.flush.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	failSave = false
+	if err := cca.Flush(); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !savedChanged {
+		t.Error("Flush should save the cache as changed")
+	}
+	expected, err := cca.clientApplication.cacheContext.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !bytes.Equal(saved, expected) {
+		t.Errorf("Flush should save the current cache %s, instead it saved %s", expected, saved)
+	}
+	if !bytes.Contains(saved, []byte("secret")) {
+		t.Error("The saved cache should hold the access token")
+	}
+}
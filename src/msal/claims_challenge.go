@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+)
+
+var claimsChallengeRegexp = regexp.MustCompile(`(?i)\bclaims="([^"]*)"`)
+
+// GetClaimsFromWWWAuthenticate returns the claims challenge in the WWW-Authenticate header of a resource's 401 response,
+// e.g. Bearer realm="", error="insufficient_claims", claims="eyJhY2Nlc3NfdG9rZW4iOnsi...".
+// The claims are base64 encoded in the header and are returned decoded, so they can be passed to SetClaims.
+func GetClaimsFromWWWAuthenticate(header string) (string, error) {
+	match := claimsChallengeRegexp.FindStringSubmatch(header)
+	if match == nil || match[1] == "" {
+		return "", errors.New("WWW-Authenticate header doesn't have a claims challenge")
+	}
+	claims, err := base64.StdEncoding.DecodeString(match[1])
+	if err != nil {
+		claims, err = base64.RawURLEncoding.DecodeString(match[1])
+		if err != nil {
+			return "", errors.New("claims challenge in the WWW-Authenticate header isn't base64 encoded")
+		}
+	}
+	return string(claims), nil
+}
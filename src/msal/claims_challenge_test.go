@@ -0,0 +1,25 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestGetClaimsFromWWWAuthenticate(t *testing.T) {
+	claims := `{"access_token":{"nbf":{"essential":true,"value":"1597963755"}}}`
+	header := `Bearer realm="", authorization_uri="https://login.microsoftonline.com/common/oauth2/authorize", error="insufficient_claims", claims="` +
+		base64.StdEncoding.EncodeToString([]byte(claims)) + `"`
+	actualClaims, err := GetClaimsFromWWWAuthenticate(header)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if actualClaims != claims {
+		t.Errorf("Actual claims %v differ from expected claims %v", actualClaims, claims)
+	}
+	if _, err := GetClaimsFromWWWAuthenticate(`Bearer realm="", error="invalid_token"`); err == nil {
+		t.Error("Error should be returned when the header has no claims challenge")
+	}
+}
@@ -4,13 +4,17 @@
 package msalgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/tokencache"
-	log "github.com/sirupsen/logrus"
 )
 
 type clientApplication struct {
@@ -18,113 +22,595 @@ type clientApplication struct {
 	clientApplicationParameters *clientApplicationParameters
 	cacheContext                *CacheContext
 	cacheAccessor               CacheAccessor
+	cacheCodec                  CacheCodec
+	retryPolicy                 RetryPolicy
+	httpTimeout                 time.Duration
+	maxResponseSize             int64
+	userAgent                   string
+	allowRefreshTokenMigration  bool
+	regionLock                  sync.Mutex
+	detectedRegion              *string
+	// pendingNonces has the nonces of the authorization code URLs by their state, a nonce is removed when its code is redeemed.
+	// pendingStates has the same states from the oldest URL to the newest, so the oldest sign-in is dropped first
+	nonceLock     sync.Mutex
+	pendingNonces map[string]string
+	pendingStates []string
+	// onRefreshTokenUpdated and onRefreshTokenInvalidated are called when an account's refresh token is rotated or revoked, they're nil when not set
+	onRefreshTokenUpdated     func(account AccountProvider)
+	onRefreshTokenInvalidated func(account AccountProvider)
 }
 
 func createClientApplication(clientID string, authority string) *clientApplication {
 	params := createClientApplicationParameters(clientID)
 	params.setAadAuthority(authority)
-	httpManager := createHTTPManager()
-	webRequestManager := createWebRequestManager(httpManager)
 	storageManager := tokencache.CreateStorageManager()
 	cacheManager := tokencache.CreateCacheManager(storageManager)
-	cacheContext := &CacheContext{cacheManager}
+	cacheContext := &CacheContext{cache: cacheManager}
 	client := &clientApplication{
-		webRequestManager:           webRequestManager,
 		clientApplicationParameters: params,
 		cacheContext:                cacheContext,
+		cacheCodec:                  noopCacheCodec{},
+		retryPolicy:                 defaultRetryPolicy,
+		httpTimeout:                 defaultHTTPTimeout,
+		maxResponseSize:             defaultMaxResponseSize,
 	}
+	client.setHTTPManager(createHTTPManager())
 	return client
 }
 
-func (client *clientApplication) createAuthCodeURL(authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
-	return authCodeURLParameters.createURL(client.webRequestManager, client.clientApplicationParameters.createAuthenticationParameters())
+// setHTTPManager sends the requests of the client with the HTTPManager, transient failures are retried according to the retry policy of the client
+func (client *clientApplication) setHTTPManager(httpManager HTTPManager) {
+	limitResponseSize(httpManager, &client.maxResponseSize)
+	retryManager := createRetryHTTPManager(createUserAgentHTTPManager(httpManager, &client.userAgent), &client.retryPolicy)
+	retryManager.timeout = &client.httpTimeout
+	client.webRequestManager = createWebRequestManager(retryManager)
 }
 
-func (client *clientApplication) acquireTokenSilent(
-	silentParameters *AcquireTokenSilentParameters) (AuthenticationResultProvider, error) {
-	authParams := client.clientApplicationParameters.createAuthenticationParameters()
-	silentParameters.augmentAuthenticationParameters(authParams)
+// beforeCacheAccess lets the cache accessor load the cache before it's used, ctx is the context of the call that accesses it
+// Every access gets its own CacheContext, so concurrent accesses don't share the has changed flag
+func (client *clientApplication) beforeCacheAccess(ctx context.Context) *CacheContext {
+	cacheContext := &CacheContext{cache: client.cacheContext.cache, codec: client.cacheCodec, ctx: ctx}
 	if client.cacheAccessor != nil {
-		client.cacheAccessor.BeforeCacheAccess(client.cacheContext)
+		client.cacheAccessor.BeforeCacheAccess(cacheContext)
 	}
-	storageTokenResponse, err := client.cacheContext.cache.TryReadCache(authParams, client.webRequestManager)
+	return cacheContext
+}
+
+// afterCacheAccess lets the cache accessor persist the cache, hasStateChanged is set when the access wrote to the cache
+func (client *clientApplication) afterCacheAccess(cacheContext *CacheContext, hasStateChanged bool) {
 	if client.cacheAccessor != nil {
-		client.cacheAccessor.AfterCacheAccess(client.cacheContext)
+		cacheContext.hasStateChanged = hasStateChanged
+		client.cacheAccessor.AfterCacheAccess(cacheContext)
 	}
+}
+
+func createClientApplicationForCloud(clientID string, cloud CloudInstance, tenant string) (*clientApplication, error) {
+	cloudInstance, err := cloud.getCloudInstance()
 	if err != nil {
 		return nil, err
 	}
+	client := createClientApplication(clientID, "")
+	if err := client.clientApplicationParameters.setCloudAuthority(cloudInstance, tenant); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (client *clientApplication) createAuthCodeURL(ctx context.Context, authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
+	authParams, err := client.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return "", err
+	}
+	// The generated nonce is only checked when the code is redeemed with the state of the URL, the caller never sees it otherwise
+	if authCodeURLParameters.State == "" && authCodeURLParameters.Nonce == "" {
+		return "", errors.New("the authorization code URL needs a state, or a nonce set by the caller, so the nonce of the ID token can be checked")
+	}
+	authCodeURL, err := authCodeURLParameters.createURL(ctx, client.webRequestManager, authParams)
+	if err != nil {
+		return "", err
+	}
+	if authCodeURLParameters.State != "" {
+		client.addPendingNonce(authCodeURLParameters.State, authCodeURLParameters.Nonce)
+	}
+	return authCodeURL, nil
+}
+
+// addPendingNonce keeps the nonce of the URL with the state until the code is redeemed,
+// once there are maxPendingNonces the nonce of the oldest sign-in that was never finished is dropped
+func (client *clientApplication) addPendingNonce(state string, nonce string) {
+	client.nonceLock.Lock()
+	defer client.nonceLock.Unlock()
+	if client.pendingNonces == nil {
+		client.pendingNonces = map[string]string{}
+	}
+	if _, ok := client.pendingNonces[state]; ok {
+		client.removePendingState(state)
+	} else if len(client.pendingStates) >= maxPendingNonces {
+		delete(client.pendingNonces, client.pendingStates[0])
+		client.pendingStates = client.pendingStates[1:]
+	}
+	client.pendingNonces[state] = nonce
+	client.pendingStates = append(client.pendingStates, state)
+}
+
+// takePendingNonce returns the nonce of the URL with the state and forgets it, so a code can't be redeemed twice with it
+func (client *clientApplication) takePendingNonce(state string) string {
+	client.nonceLock.Lock()
+	defer client.nonceLock.Unlock()
+	nonce, ok := client.pendingNonces[state]
+	if ok {
+		delete(client.pendingNonces, state)
+		client.removePendingState(state)
+	}
+	return nonce
+}
+
+// removePendingState removes the state from the order of the pending nonces, the caller holds nonceLock
+func (client *clientApplication) removePendingState(state string) {
+	for i, pendingState := range client.pendingStates {
+		if pendingState == state {
+			client.pendingStates = append(client.pendingStates[:i], client.pendingStates[i+1:]...)
+			return
+		}
+	}
+}
+
+// authCodeURL creates the authorization code URL for the client ID of the client, with the state the redirect is checked against
+func (client *clientApplication) authCodeURL(ctx context.Context, scopes []string, redirectURI string, state string, options []AuthCodeURLOption) (string, error) {
+	authCodeURLParameters := CreateAuthorizationCodeURLParameters(client.clientApplicationParameters.commonParameters.clientID, redirectURI, scopes)
+	authCodeURLParameters.State = state
+	for _, option := range options {
+		option(authCodeURLParameters)
+	}
+	return client.createAuthCodeURL(ctx, authCodeURLParameters)
+}
+
+// validateAuthority runs instance discovery and tenant discovery for the authority of the client without requesting a token,
+// the error lists every problem that was found
+func (client *clientApplication) validateAuthority(ctx context.Context) error {
+	authorityInfo := client.clientApplicationParameters.commonParameters.authorityInfo
+	if authorityInfo == nil {
+		return errors.New("the client application has no authority")
+	}
+	var problems []string
+	metadata, err := requests.CreateAadInstanceDiscovery(client.webRequestManager).GetMetadataEntry(ctx, authorityInfo)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("instance discovery failed: %v", err))
+	} else if authorityInfo.ValidateAuthority && !requests.IsInTrustedHostList(authorityInfo.Host) && !hasAlias(metadata, authorityInfo.Host) {
+		problems = append(problems, fmt.Sprintf("host '%s' isn't known to instance discovery", authorityInfo.Host))
+	}
+	if err := authorityInfo.ValidateKnownHost(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		problems = append(problems, "tenant discovery wasn't attempted")
+	} else if _, err := requests.CreateAuthorityEndpointResolutionManager(client.webRequestManager).ResolveEndpoints(ctx, authorityInfo, ""); err != nil {
+		problems = append(problems, fmt.Sprintf("tenant '%s' couldn't be resolved: %v", authorityInfo.Tenant, err))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("authority '%s' isn't valid: %s", authorityInfo.CanonicalAuthorityURI, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func hasAlias(metadata *requests.InstanceDiscoveryMetadata, host string) bool {
+	for _, alias := range metadata.Aliases {
+		if alias == host {
+			return true
+		}
+	}
+	return false
+}
+
+func (client *clientApplication) acquireTokenSilent(ctx context.Context,
+	silentParameters *AcquireTokenSilentParameters) (result AuthenticationResultProvider, err error) {
+	ctx, span := msalbase.StartSpan(ctx, msalbase.AcquireTokenSilentSpan)
+	defer func() { span.End(err) }()
+	if silentParameters, err = client.selectAccount(silentParameters); err != nil {
+		return nil, err
+	}
+	authParams, err := client.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := silentParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	if authParams.AuthorityInfo != nil {
+		span.SetAttribute(msalbase.AuthorityAttribute, authParams.AuthorityInfo.Host)
+	}
+	span.SetAttribute(msalbase.ClientIDAttribute, authParams.ClientID)
+	span.SetAttribute(msalbase.CacheHitAttribute, false)
+	result, err = client.acquireTokenSilentWithAuthParams(ctx, silentParameters, authParams)
+	if err != nil && isErrorClientMismatch(err) && !authParams.IgnoreFamilyRefreshToken {
+		// The app isn't in the family of the refresh token, so the app's own refresh token is used instead
+		msalbase.GetLogger().Warn("The family refresh token was rejected, retrying with the app's own refresh token")
+		authParams.IgnoreFamilyRefreshToken = true
+		return client.acquireTokenSilentWithAuthParams(ctx, silentParameters, authParams)
+	}
+	return result, err
+}
+
+// selectAccount returns a copy of the silent parameters for the cached account the login hint and the account filter select,
+// parameters that already have an account or a home account ID, or have neither a login hint nor an account filter, are returned as they are
+func (client *clientApplication) selectAccount(silentParameters *AcquireTokenSilentParameters) (*AcquireTokenSilentParameters, error) {
+	if (silentParameters.loginHint == "" && silentParameters.accountFilter == nil) || silentParameters.homeAccountID != "" ||
+		(silentParameters.account != nil && silentParameters.account.GetHomeAccountID() != "") {
+		return silentParameters, nil
+	}
+	accounts := client.getMatchingAccounts(func(acc *msalbase.Account) bool {
+		if silentParameters.loginHint != "" && !strings.EqualFold(msalbase.GetStringFromPointer(acc.PreferredUsername), silentParameters.loginHint) {
+			return false
+		}
+		return silentParameters.accountFilter == nil || silentParameters.accountFilter(acc)
+	})
+	if len(accounts) == 0 {
+		return nil, &InteractionRequiredError{reason: "no cached account matches the login hint or the account filter", err: ErrNoCachedToken}
+	}
+	if len(accounts) > 1 {
+		return nil, &AmbiguousAccountError{Candidates: accounts}
+	}
+	withAccount := *silentParameters
+	withAccount.account = accounts[0]
+	return &withAccount, nil
+}
+
+func (client *clientApplication) acquireTokenSilentWithAuthParams(ctx context.Context,
+	silentParameters *AcquireTokenSilentParameters, authParams *msalbase.AuthParametersInternal) (AuthenticationResultProvider, error) {
+	cacheContext := client.beforeCacheAccess(ctx)
+	storageTokenResponse, err := client.cacheContext.cache.TryReadCache(ctx, authParams, client.webRequestManager)
+	client.afterCacheAccess(cacheContext, false)
+	if err != nil {
+		if errors.Is(err, tokencache.ErrCacheKeyIncomplete) {
+			return nil, &InteractionRequiredError{reason: "no cache entry found", CorrelationID: authParams.CorrelationID, err: ErrNoCachedToken}
+		}
+		return nil, err
+	}
 	if storageTokenResponse != nil {
 		result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
-		if err != nil {
-			log.Error(err)
+		// A cached access token doesn't satisfy a claims challenge, so the refresh token is used instead
+		if err != nil || authParams.Claims != "" {
+			if err != nil {
+				msalbase.GetLogger().Error(err)
+			}
 			if reflect.ValueOf(storageTokenResponse.RefreshToken).IsNil() {
-				return nil, errors.New("no refresh token found")
+				return nil, &InteractionRequiredError{reason: "no refresh token found", CorrelationID: authParams.CorrelationID, err: ErrNoCachedToken}
 			}
 			req := requests.CreateRefreshTokenExchangeRequest(client.webRequestManager,
 				authParams, storageTokenResponse.RefreshToken, silentParameters.requestType)
 			if req.RequestType == requests.RefreshTokenConfidential {
 				req.ClientCredential = silentParameters.clientCredential
 			}
-			return client.executeTokenRequestWithCacheWrite(req, authParams)
+			result, err := client.executeTokenRequestWithCacheWrite(ctx, req, authParams)
+			// A user who has to do MFA gets a new token with the same refresh token after signing in interactively, so it's kept
+			if err != nil && isErrorInvalidGrant(err) && !isErrorMFARequired(err) {
+				// The refresh token was revoked or has expired, so it's removed from the cache
+				client.deleteCachedRefreshToken(authParams)
+				if client.onRefreshTokenInvalidated != nil {
+					client.onRefreshTokenInvalidated(silentParameters.account)
+				}
+				return nil, &InteractionRequiredError{reason: "refresh token is no longer valid", CorrelationID: authParams.CorrelationID, err: err}
+			}
+			if claimsErr := getInteractionRequiredError(err); claimsErr != nil {
+				return nil, claimsErr
+			}
+			if err != nil && isErrorAuthorityUnreachable(ctx, err) {
+				// The authority is down, so an access token that is still within its extended expiry is returned instead
+				msalbase.GetLogger().Warnf("The authority couldn't be reached, checking the cache for a token within its extended expiry: %v", err)
+				authParams.AllowExtendedExpiry = true
+				if result, cacheErr := client.acquireTokenFromCache(ctx, authParams); cacheErr == nil {
+					return result, nil
+				}
+			}
+			return result, err
+		}
+		msalbase.SpanFromContext(ctx).SetAttribute(msalbase.CacheHitAttribute, true)
+		return withCorrelationID(result, nil, authParams)
+	}
+	return nil, &InteractionRequiredError{reason: "no cache entry found", CorrelationID: authParams.CorrelationID, err: ErrNoCachedToken}
+}
+
+// mfaRequiredErrorCodes are the AADSTS codes of the errors for a conditional access policy that requires multi-factor authentication,
+// AADSTS50076 when the user has to do MFA and AADSTS50079 when the user has to register for it first
+var mfaRequiredErrorCodes = map[int]bool{50076: true, 50079: true}
+
+// mfaRequiredSubErrors are the suberrors of invalid_grant errors that the user resolves by signing in interactively
+var mfaRequiredSubErrors = map[string]bool{"basic_action": true, "additional_action": true}
+
+// isErrorMFARequired checks if the authority answered that the user has to do MFA, or another action of an interactive sign in
+func isErrorMFARequired(err error) bool {
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		return false
+	}
+	for _, code := range callErr.ErrorCodes {
+		if mfaRequiredErrorCodes[code] {
+			return true
 		}
-		return result, nil
 	}
-	return nil, errors.New("no cache entry found")
+	return mfaRequiredSubErrors[callErr.SubError]
+}
+
+// getInteractionRequiredError returns an InteractionRequiredError carrying the claims challenge and the suberror,
+// if the authority answered that user interaction is required, e.g. for MFA, or sent a claims challenge
+func getInteractionRequiredError(err error) *InteractionRequiredError {
+	var callErr *CallError
+	if !errors.As(err, &callErr) || (callErr.Code != "interaction_required" && callErr.Claims == "" && !isErrorMFARequired(err)) {
+		return nil
+	}
+	reason := callErr.Description
+	if reason == "" {
+		reason = callErr.Code
+	}
+	return &InteractionRequiredError{reason: reason, Claims: callErr.Claims, SubError: callErr.SubError, CorrelationID: callErr.CorrelationID, err: callErr}
+}
+
+func (client *clientApplication) deleteCachedRefreshToken(authParams *msalbase.AuthParametersInternal) {
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, true)
+	if err := client.cacheContext.cache.DeleteCachedRefreshToken(authParams); err != nil {
+		msalbase.GetLogger().Warnf("Failed to delete the invalid refresh token: %v", err)
+	}
 }
 
-func (client *clientApplication) acquireTokenByAuthCode(
+func (client *clientApplication) acquireTokenFromCache(ctx context.Context, authParams *msalbase.AuthParametersInternal) (AuthenticationResultProvider, error) {
+	cacheContext := client.beforeCacheAccess(ctx)
+	defer client.afterCacheAccess(cacheContext, false)
+	storageTokenResponse, err := client.cacheContext.cache.TryReadCache(ctx, authParams, client.webRequestManager)
+	if err != nil {
+		return nil, err
+	}
+	result, err := msalbase.CreateAuthenticationResultFromStorageTokenResponse(storageTokenResponse)
+	return withCorrelationID(result, err, authParams)
+}
+
+// withCorrelationID stamps the result with the correlation ID of the request, so apps can log it for support
+func withCorrelationID(result *msalbase.AuthenticationResult, err error, authParams *msalbase.AuthParametersInternal) (AuthenticationResultProvider, error) {
+	if err != nil {
+		return nil, err
+	}
+	result.CorrelationID = authParams.CorrelationID
+	return result, nil
+}
+
+func (client *clientApplication) acquireTokenByAuthCode(ctx context.Context,
 	authCodeParams *AcquireTokenAuthCodeParameters) (AuthenticationResultProvider, error) {
-	authParams := client.clientApplicationParameters.createAuthenticationParameters()
-	authCodeParams.augmentAuthenticationParameters(authParams)
+	authParams, err := client.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := authCodeParams.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	if authParams.Nonce == "" && authCodeParams.State != "" {
+		authParams.Nonce = client.takePendingNonce(authCodeParams.State)
+	}
 	req := requests.CreateAuthCodeRequest(client.webRequestManager, authParams, authCodeParams.requestType)
 	req.Code = authCodeParams.Code
 	req.CodeChallenge = authCodeParams.CodeChallenge
 	if req.RequestType == requests.AuthCodeConfidential {
 		req.ClientCredential = authCodeParams.clientCredential
 	}
-	return client.executeTokenRequestWithCacheWrite(req, authParams)
+	return client.executeTokenRequestWithCacheWrite(ctx, req, authParams)
 }
 
-func (client *clientApplication) executeTokenRequestWithoutCacheWrite(
+func (client *clientApplication) executeTokenRequestWithoutCacheWrite(ctx context.Context,
 	req requests.TokenRequester,
 	authParams *msalbase.AuthParametersInternal) (AuthenticationResultProvider, error) {
-	tokenResponse, err := req.Execute()
+	tokenResponse, err := req.Execute(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return msalbase.CreateAuthenticationResult(tokenResponse, nil)
+	result, err := msalbase.CreateAuthenticationResult(tokenResponse, nil)
+	return withCorrelationID(result, err, authParams)
 }
 
-func (client *clientApplication) executeTokenRequestWithCacheWrite(
+func (client *clientApplication) executeTokenRequestWithCacheWrite(ctx context.Context,
 	req requests.TokenRequester,
 	authParams *msalbase.AuthParametersInternal) (AuthenticationResultProvider, error) {
-	tokenResponse, err := req.Execute()
+	tokenResponse, err := req.Execute(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if client.cacheAccessor != nil {
-		client.cacheAccessor.BeforeCacheAccess(client.cacheContext)
-		defer client.cacheAccessor.AfterCacheAccess(client.cacheContext)
+	if err := client.validateIDToken(ctx, authParams, tokenResponse); err != nil {
+		return nil, err
 	}
+	cacheContext := client.beforeCacheAccess(ctx)
 	account, err := client.cacheContext.cache.CacheTokenResponse(authParams, tokenResponse)
+	// The cache access ends before the callback, so the callback can use the cache
+	client.afterCacheAccess(cacheContext, true)
 	if err != nil {
 		return nil, err
 	}
-	return msalbase.CreateAuthenticationResult(tokenResponse, account)
+	if authParams.AuthorizationType == msalbase.AuthorizationTypeRefreshTokenExchange && tokenResponse.HasRefreshToken() {
+		client.refreshTokenUpdated(account, authParams.HomeaccountID)
+	}
+	result, err := msalbase.CreateAuthenticationResult(tokenResponse, account)
+	return withCorrelationID(result, err, authParams)
+}
+
+// refreshTokenUpdated calls the onRefreshTokenUpdated callback, refresh responses without an ID token don't return the account
+// so the cached account of the home account ID is passed instead
+func (client *clientApplication) refreshTokenUpdated(account *msalbase.Account, homeAccountID string) {
+	if client.onRefreshTokenUpdated == nil {
+		return
+	}
+	if account != nil {
+		client.onRefreshTokenUpdated(account)
+		return
+	}
+	accounts := client.getMatchingAccounts(func(acc *msalbase.Account) bool {
+		return acc.GetHomeAccountID() == homeAccountID
+	})
+	if len(accounts) > 0 {
+		client.onRefreshTokenUpdated(accounts[0])
+		return
+	}
+	client.onRefreshTokenUpdated(&msalbase.Account{HomeAccountID: &homeAccountID})
+}
+
+// validateIDToken checks the ID token of the response before it's cached, unless validation was turned off.
+// The nonce of the authorization request is always checked, because only the client knows it.
+func (client *clientApplication) validateIDToken(ctx context.Context, authParams *msalbase.AuthParametersInternal, tokenResponse *msalbase.TokenResponse) error {
+	if authParams.Nonce != "" {
+		if tokenResponse.IDToken == nil {
+			return errors.New("id token with the nonce of the authorization request is missing")
+		}
+		if err := tokenResponse.IDToken.ValidateNonce(authParams.Nonce); err != nil {
+			return err
+		}
+	}
+	if client.clientApplicationParameters.commonParameters.skipIDTokenValidation ||
+		tokenResponse.IDToken == nil || tokenResponse.IDToken.RawToken == "" {
+		return nil
+	}
+	return requests.ValidateIDToken(ctx, client.webRequestManager, authParams, tokenResponse.IDToken)
 }
 
 func (client *clientApplication) getAccounts() []AccountProvider {
+	return client.getMatchingAccounts(func(*msalbase.Account) bool { return true })
+}
+
+// getAccountsByUsername returns the accounts whose username matches, ignoring case
+func (client *clientApplication) getAccountsByUsername(username string) []AccountProvider {
+	return client.getMatchingAccounts(func(acc *msalbase.Account) bool {
+		return strings.EqualFold(msalbase.GetStringFromPointer(acc.PreferredUsername), username)
+	})
+}
+
+// getAccountsByTenant returns the accounts whose realm matches, ignoring case
+func (client *clientApplication) getAccountsByTenant(realm string) []AccountProvider {
+	return client.getMatchingAccounts(func(acc *msalbase.Account) bool {
+		return strings.EqualFold(msalbase.GetStringFromPointer(acc.Realm), realm)
+	})
+}
+
+func (client *clientApplication) getAllAccessTokens(redactSecrets bool) []*CachedAccessToken {
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, false)
+	return client.cacheContext.cache.GetAllAccessTokens(redactSecrets)
+}
+
+func (client *clientApplication) getAllRefreshTokens(redactSecrets bool) []*CachedRefreshToken {
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, false)
+	return client.cacheContext.cache.GetAllRefreshTokens(redactSecrets)
+}
+
+func (client *clientApplication) getStats() (*CacheStats, error) {
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, false)
+	return client.cacheContext.cache.GetStats()
+}
+
+func (client *clientApplication) getMatchingAccounts(match func(*msalbase.Account) bool) []AccountProvider {
 	returnedAccounts := []AccountProvider{}
-	if client.cacheAccessor != nil {
-		client.cacheAccessor.BeforeCacheAccess(client.cacheContext)
-	}
+	cacheContext := client.beforeCacheAccess(context.Background())
 	accounts := client.cacheContext.cache.GetAllAccounts()
-	if client.cacheAccessor != nil {
-		client.cacheAccessor.AfterCacheAccess(client.cacheContext)
-	}
+	client.afterCacheAccess(cacheContext, false)
 	for _, acc := range accounts {
-		returnedAccounts = append(returnedAccounts, acc)
+		if match(acc) {
+			returnedAccounts = append(returnedAccounts, acc)
+		}
 	}
 	return returnedAccounts
 }
+
+func (client *clientApplication) getAccount(ctx context.Context, homeAccountID string) (AccountProvider, error) {
+	cacheContext := client.beforeCacheAccess(ctx)
+	defer client.afterCacheAccess(cacheContext, false)
+	account, err := client.cacheContext.cache.GetAccount(ctx, homeAccountID,
+		client.clientApplicationParameters.commonParameters.authorityInfo, client.webRequestManager)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// flush lets the cache accessor persist the whole cache as if the access had changed it, e.g. when an earlier save failed
+func (client *clientApplication) flush() error {
+	if client.cacheAccessor == nil {
+		return nil
+	}
+	cacheContext := client.beforeCacheAccess(context.Background())
+	// The accessor only logs its errors, so a cache that can't be serialized is reported here instead
+	if _, err := cacheContext.SerializeCache(); err != nil {
+		client.afterCacheAccess(cacheContext, false)
+		return err
+	}
+	client.afterCacheAccess(cacheContext, true)
+	return nil
+}
+
+func (client *clientApplication) removeAccount(ctx context.Context, account AccountProvider) error {
+	acc, ok := account.(*msalbase.Account)
+	if !ok {
+		return errors.New("account was not returned by GetAccounts")
+	}
+	cacheContext := client.beforeCacheAccess(ctx)
+	defer client.afterCacheAccess(cacheContext, true)
+	return client.cacheContext.cache.RemoveAccount(ctx, acc, client.webRequestManager)
+}
+
+func (client *clientApplication) deleteAccessTokens(ctx context.Context, account AccountProvider, scopes []string) error {
+	acc, ok := account.(*msalbase.Account)
+	if !ok {
+		return errors.New("account was not returned by GetAccounts")
+	}
+	cacheContext := client.beforeCacheAccess(ctx)
+	defer client.afterCacheAccess(cacheContext, true)
+	_, err := client.cacheContext.cache.DeleteAccessTokens(ctx, acc, scopes, client.webRequestManager)
+	return err
+}
+
+func (client *clientApplication) exportRefreshToken(ctx context.Context, account AccountProvider) (string, error) {
+	if !client.allowRefreshTokenMigration {
+		return "", ErrRefreshTokenMigrationDisabled
+	}
+	acc, ok := account.(*msalbase.Account)
+	if !ok {
+		return "", errors.New("account was not returned by GetAccounts")
+	}
+	cacheContext := client.beforeCacheAccess(ctx)
+	defer client.afterCacheAccess(cacheContext, false)
+	refreshToken, err := client.cacheContext.cache.ExportRefreshToken(ctx, acc,
+		client.clientApplicationParameters.commonParameters.clientID, client.webRequestManager)
+	if err != nil {
+		return "", err
+	}
+	if refreshToken == "" {
+		return "", ErrNoCachedToken
+	}
+	return refreshToken, nil
+}
+
+// importRefreshToken caches the refresh token for the authority of the client, the returned account has no username
+// until the refresh token is redeemed and the account is written from the ID token
+func (client *clientApplication) importRefreshToken(homeAccountID string, refreshToken string, familyID string) (AccountProvider, error) {
+	if !client.allowRefreshTokenMigration {
+		return nil, ErrRefreshTokenMigrationDisabled
+	}
+	if refreshToken == "" {
+		return nil, errors.New("the refresh token is empty")
+	}
+	authParams, err := client.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, true)
+	if err := client.cacheContext.cache.ImportRefreshToken(authParams, homeAccountID, refreshToken, familyID); err != nil {
+		return nil, err
+	}
+	authorityInfo := authParams.AuthorityInfo
+	return msalbase.CreateAccount(homeAccountID, authorityInfo.Host, authorityInfo.Tenant, "", authorityInfo.AuthorityType, ""), nil
+}
+
+func (client *clientApplication) clearCache() error {
+	cacheContext := client.beforeCacheAccess(context.Background())
+	defer client.afterCacheAccess(cacheContext, true)
+	return client.cacheContext.cache.Clear()
+}
@@ -3,7 +3,11 @@
 
 package msalgo
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 type clientApplicationParameters struct {
 	commonParameters *applicationCommonParameters
@@ -19,12 +23,54 @@ func (p *clientApplicationParameters) setAadAuthority(authorityURI string) {
 	p.commonParameters.setAadAuthority(authorityURI)
 }
 
+func (p *clientApplicationParameters) setCloudAuthority(cloud *msalbase.CloudInstance, tenant string) error {
+	return p.commonParameters.setCloudAuthority(cloud, tenant)
+}
+
+func (p *clientApplicationParameters) setValidateAuthority(validateAuthority bool) {
+	p.commonParameters.setValidateAuthority(validateAuthority)
+}
+
+func (p *clientApplicationParameters) setInstanceDiscovery(enabled bool) {
+	p.commonParameters.setInstanceDiscovery(enabled)
+}
+
+func (p *clientApplicationParameters) setInstanceDiscoveryHeaders(headers map[string]string) {
+	p.commonParameters.setInstanceDiscoveryHeaders(headers)
+}
+
+func (p *clientApplicationParameters) setKnownAuthorityHosts(hosts []string) {
+	p.commonParameters.setKnownAuthorityHosts(hosts)
+}
+
+func (p *clientApplicationParameters) setValidateIDToken(validateIDToken bool) {
+	p.commonParameters.skipIDTokenValidation = !validateIDToken
+}
+
+func (p *clientApplicationParameters) setClockSkew(clockSkew time.Duration) {
+	if clockSkew < 0 {
+		clockSkew = 0
+	}
+	p.commonParameters.clockSkew = clockSkew
+}
+
+func (p *clientApplicationParameters) setNormalizeCacheEnvironment(normalize bool) {
+	p.commonParameters.normalizeCacheEnvironment = normalize
+}
+
+func (p *clientApplicationParameters) setClientCapabilities(capabilities []string) {
+	p.commonParameters.clientCapabilities = capabilities
+}
+
+func (p *clientApplicationParameters) setAzureRegion(region string) {
+	p.commonParameters.azureRegion = region
+}
+
 func (p *clientApplicationParameters) validate() error {
 	err := p.commonParameters.validate()
 	return err
 }
 
-func (p *clientApplicationParameters) createAuthenticationParameters() *msalbase.AuthParametersInternal {
-	params := p.commonParameters.createAuthenticationParameters()
-	return params
+func (p *clientApplicationParameters) createAuthenticationParameters() (*msalbase.AuthParametersInternal, error) {
+	return p.commonParameters.createAuthenticationParameters()
 }
@@ -4,11 +4,20 @@
 package msalgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/tokencache"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -25,7 +34,7 @@ var (
 	testClientApplication = &clientApplication{
 		clientApplicationParameters: clientAppParams,
 		webRequestManager:           wrm,
-		cacheContext:                &CacheContext{cacheManager},
+		cacheContext:                &CacheContext{cache: cacheManager},
 	}
 )
 
@@ -50,37 +59,763 @@ func TestAcquireTokenSilent(t *testing.T) {
 	at.On("GetScopes").Return("openid")
 	rt.On("GetSecret").Return("secret")
 	id.On("GetSecret").Return("secret")
-	_, err := testClientApplication.acquireTokenSilent(silentParams)
+	_, err := testClientApplication.acquireTokenSilent(context.Background(), silentParams)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
 
+func createSilentTestClient() (*clientApplication, *requests.MockWebRequestManager, *requests.MockCacheManager) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	client := &clientApplication{
+		clientApplicationParameters: clientAppParams,
+		webRequestManager:           testWrm,
+		cacheContext:                &CacheContext{cache: testCacheManager},
+	}
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	return client, testWrm, testCacheManager
+}
+
+func TestAcquireTokenSilentRefreshesExpiredToken(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	// The cache manager drops access tokens that have expired
+	var expiredAT *msalbase.MockAccessToken
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	var id *msalbase.MockCredential
+	storageToken := msalbase.CreateStorageTokenResponse(expiredAT, rt, id, account)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(storageToken, nil)
+	tokenResp := &msalbase.TokenResponse{AccessToken: "newSecret"}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(account, nil)
+	result, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "newSecret" {
+		t.Errorf("Access token should be the refreshed one, instead it is %v", result.GetAccessToken())
+	}
+	testCacheManager.AssertCalled(t, "CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp)
+}
+
+func TestAcquireTokenSilentInteractionRequired(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var at *msalbase.MockAccessToken
+	var rt, id *msalbase.MockCredential
+	storageToken := msalbase.CreateStorageTokenResponse(at, rt, id, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(storageToken, nil)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if _, ok := err.(*InteractionRequiredError); !ok {
+		t.Errorf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+	if !errors.Is(err, ErrNoCachedToken) {
+		t.Errorf("Error should be ErrNoCachedToken, instead it is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentEmptyCache(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	silentParams := CreateAcquireTokenSilentParameters([]string{"openid"})
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).
+		Return((*msalbase.StorageTokenResponse)(nil), nil)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if !errors.Is(err, ErrNoCachedToken) {
+		t.Errorf("Error should be ErrNoCachedToken, instead it is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentNetworkFailure(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var expiredAT *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(expiredAT, rt, id, account), nil)
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), unreachable)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err == nil {
+		t.Fatal("Error should be returned when the authority can't be reached")
+	}
+	if errors.Is(err, ErrNoCachedToken) {
+		t.Errorf("A network failure shouldn't be ErrNoCachedToken, instead it is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentIncompleteCacheKey(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	silentParams := CreateAcquireTokenSilentParameters([]string{"openid"})
+	keyErr := &tokencache.CacheKeyError{Key: "environment"}
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).
+		Return((*msalbase.StorageTokenResponse)(nil), keyErr)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if _, ok := err.(*InteractionRequiredError); !ok {
+		t.Errorf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentInvalidGrant(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var at *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("revokedSecret")
+	storageToken := msalbase.CreateStorageTokenResponse(at, rt, id, account)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(storageToken, nil)
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "revokedSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), errors.New("invalid_grant"))
+	testCacheManager.On("DeleteCachedRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(nil)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if _, ok := err.(*InteractionRequiredError); !ok {
+		t.Errorf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+	testCacheManager.AssertCalled(t, "DeleteCachedRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"))
+}
+
+func TestAcquireTokenSilentAuthorityUnreachable(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var expiredAT *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	normalParams := mock.MatchedBy(func(p *msalbase.AuthParametersInternal) bool { return !p.AllowExtendedExpiry })
+	extendedParams := mock.MatchedBy(func(p *msalbase.AuthParametersInternal) bool { return p.AllowExtendedExpiry })
+	testCacheManager.On("TryReadCache", normalParams, testWrm).Return(msalbase.CreateStorageTokenResponse(expiredAT, rt, id, account), nil)
+	extendedAT := new(msalbase.MockAccessToken)
+	extendedAT.On("GetSecret").Return("extendedSecret")
+	extendedAT.On("GetExpiresOn").Return("0")
+	extendedAT.On("GetScopes").Return("openid")
+	testCacheManager.On("TryReadCache", extendedParams, testWrm).Return(msalbase.CreateStorageTokenResponse(extendedAT, rt, id, account), nil)
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), unreachable)
+	result, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "extendedSecret" {
+		t.Errorf("Access token should be the one within its extended expiry, instead it is %v", result.GetAccessToken())
+	}
+}
+
+func TestAcquireTokenSilentCanceledIgnoresExtendedExpiry(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var expiredAT *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(expiredAT, rt, id, account), nil)
+	// The HTTP client reports a canceled request as a *url.Error, which is a net.Error
+	canceled := &url.Error{Op: "Post", URL: "https://login.microsoftonline.com/v2.0/token", Err: context.Canceled}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), canceled)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.acquireTokenSilent(ctx, silentParams); !errors.Is(err, context.Canceled) {
+		t.Errorf("Error should be the cancellation, instead it is %v", err)
+	}
+	// Only the first read of the cache is done, a token within its extended expiry isn't looked up
+	testCacheManager.AssertNumberOfCalls(t, "TryReadCache", 1)
+}
+
+func TestIsErrorAuthorityUnreachable(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		desc        string
+		ctx         context.Context
+		err         error
+		unreachable bool
+	}{
+		{"dial error", context.Background(), &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, true},
+		{"timeout", context.Background(), &url.Error{Op: "Post", Err: context.DeadlineExceeded}, true},
+		{"read error", context.Background(), &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}}, false},
+		{"canceled", context.Background(), &url.Error{Op: "Post", Err: context.Canceled}, false},
+		{"done context", canceledCtx, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, false},
+		{"other error", context.Background(), errors.New("server_error"), false},
+	}
+	for _, test := range tests {
+		if unreachable := isErrorAuthorityUnreachable(test.ctx, test.err); unreachable != test.unreachable {
+			t.Errorf("Unreachable should be %v for the %s, instead it is %v", test.unreachable, test.desc, unreachable)
+		}
+	}
+}
+
+func TestAcquireTokenSilentRefreshErrorIgnoresExtendedExpiry(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var expiredAT *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(expiredAT, rt, id, account), nil)
+	mockError := errors.New("server_error")
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), mockError)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != mockError {
+		t.Errorf("Actual error is %v, expected error is %v", err, mockError)
+	}
+	testCacheManager.AssertNumberOfCalls(t, "TryReadCache", 1)
+}
+
+func TestAcquireTokenSilentClaimsChallenge(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	claims := `{"access_token":{"nbf":{"essential":true,"value":"1597963755"}}}`
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	silentParams.SetClaims(claims)
+	// The cached access token is still valid, but it doesn't satisfy the claims challenge
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return("0")
+	at.On("GetScopes").Return("openid")
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(at, rt, id, account), nil)
+	claimsParams := mock.MatchedBy(func(p *msalbase.AuthParametersInternal) bool { return p.Claims == claims })
+	challenge := &CallError{Code: "interaction_required", Description: "AADSTS50079: MFA is required", Claims: claims, StatusCode: 400}
+	testWrm.On("GetAccessTokenFromRefreshToken", claimsParams, "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), challenge)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	interactionErr, ok := err.(*InteractionRequiredError)
+	if !ok {
+		t.Fatalf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+	if interactionErr.Claims != claims {
+		t.Errorf("Actual claims %v differ from expected claims %v", interactionErr.Claims, claims)
+	}
+	testWrm.AssertCalled(t, "GetAccessTokenFromRefreshToken", claimsParams, "refreshSecret", make(map[string]string))
+}
+
+func TestAcquireTokenSilentMFARequired(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var at *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	rt := new(msalbase.MockCredential)
+	rt.On("GetSecret").Return("refreshSecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(at, rt, id, account), nil)
+	claims := `{"access_token":{"capolids":{"essential":true,"values":["policy"]}}}`
+	body := `{"error":"invalid_grant","error_description":"AADSTS50076: Due to a configuration change made by your administrator, ` +
+		`or because you moved to a new location, you must use multi-factor authentication to access the resource.",` +
+		`"error_codes":[50076],"suberror":"basic_action","correlation_id":"corrID","claims":` + strconv.Quote(claims) + `}`
+	_, mfaErr := msalbase.CreateOAuthResponseBase(http.StatusBadRequest, body)
+	if mfaErr == nil {
+		t.Fatal("The error body should be decoded to an error")
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "refreshSecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), mfaErr)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	var interactionErr *InteractionRequiredError
+	if !errors.As(err, &interactionErr) {
+		t.Fatalf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+	if interactionErr.Claims != claims {
+		t.Errorf("Actual claims %v differ from expected claims %v", interactionErr.Claims, claims)
+	}
+	if interactionErr.SubError != "basic_action" {
+		t.Errorf("SubError should be basic_action, instead it is %s", interactionErr.SubError)
+	}
+	var callErr *CallError
+	if !errors.As(err, &callErr) || len(callErr.ErrorCodes) != 1 || callErr.ErrorCodes[0] != 50076 {
+		t.Errorf("The CallError of the authority should be wrapped, instead the error is %v", err)
+	}
+	// The refresh token still works once the user did MFA, so it isn't deleted
+	testCacheManager.AssertNotCalled(t, "DeleteCachedRefreshToken", mock.Anything)
+}
+
+func TestAcquireTokenSilentFamilyRefreshToken(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var at *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	familyRT := new(msalbase.MockCredential)
+	familyRT.On("GetSecret").Return("familySecret")
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(msalbase.CreateStorageTokenResponse(at, familyRT, id, account), nil)
+	tokenResp := &msalbase.TokenResponse{AccessToken: "newSecret"}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "familySecret", make(map[string]string)).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(account, nil)
+	result, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "newSecret" {
+		t.Errorf("Access token should be redeemed with the family refresh token, instead it is %v", result.GetAccessToken())
+	}
+}
+
+func TestAcquireTokenSilentClientMismatch(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	var at *msalbase.MockAccessToken
+	var id *msalbase.MockCredential
+	familyRT := new(msalbase.MockCredential)
+	familyRT.On("GetSecret").Return("familySecret")
+	appRT := new(msalbase.MockCredential)
+	appRT.On("GetSecret").Return("appSecret")
+	familyParams := mock.MatchedBy(func(p *msalbase.AuthParametersInternal) bool { return !p.IgnoreFamilyRefreshToken })
+	appParams := mock.MatchedBy(func(p *msalbase.AuthParametersInternal) bool { return p.IgnoreFamilyRefreshToken })
+	testCacheManager.On("TryReadCache", familyParams, testWrm).Return(msalbase.CreateStorageTokenResponse(at, familyRT, id, account), nil)
+	testCacheManager.On("TryReadCache", appParams, testWrm).Return(msalbase.CreateStorageTokenResponse(at, appRT, id, account), nil)
+	mismatch := &CallError{Code: "client_mismatch", StatusCode: 400}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "familySecret", make(map[string]string)).
+		Return((*msalbase.TokenResponse)(nil), mismatch)
+	tokenResp := &msalbase.TokenResponse{AccessToken: "appToken"}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "appSecret", make(map[string]string)).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(account, nil)
+	result, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "appToken" {
+		t.Errorf("Access token should be redeemed with the app's refresh token, instead it is %v", result.GetAccessToken())
+	}
+}
+
 func TestExecuteTokenRequestWithoutCacheWrite(t *testing.T) {
-	testAuthParams := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
 	req := new(requests.MockTokenRequest)
 	actualTokenResp := &msalbase.TokenResponse{}
 	req.On("Execute").Return(actualTokenResp, nil)
-	_, err := testClientApplication.executeTokenRequestWithoutCacheWrite(req, testAuthParams)
+	_, err := testClientApplication.executeTokenRequestWithoutCacheWrite(context.Background(), req, testAuthParams)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
 	mockError := errors.New("This is a mock error")
 	errorReq := new(requests.MockTokenRequest)
 	errorReq.On("Execute").Return(nil, mockError)
-	_, err = testClientApplication.executeTokenRequestWithoutCacheWrite(errorReq, testAuthParams)
+	_, err = testClientApplication.executeTokenRequestWithoutCacheWrite(context.Background(), errorReq, testAuthParams)
 	if err != mockError {
 		t.Errorf("Actual error is %v, expected error is %v", err, mockError)
 	}
 }
 
 func TestExecuteTokenRequestWithCacheWrite(t *testing.T) {
-	testAuthParams := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
 	mockError := errors.New("This is a mock error")
 	errorReq := new(requests.MockTokenRequest)
 	errorReq.On("Execute").Return(nil, mockError)
-	_, err := testClientApplication.executeTokenRequestWithCacheWrite(errorReq, testAuthParams)
+	_, err := testClientApplication.executeTokenRequestWithCacheWrite(context.Background(), errorReq, testAuthParams)
 	if err != mockError {
 		t.Errorf("Actual error is %v, expected error is %v", err, mockError)
 	}
 }
+
+// recordingCacheAccessor records the order of the cache accessor callbacks and the cache calls between them
+type recordingCacheAccessor struct {
+	calls []string
+}
+
+func (a *recordingCacheAccessor) BeforeCacheAccess(context *CacheContext) {
+	a.calls = append(a.calls, "before")
+}
+
+func (a *recordingCacheAccessor) AfterCacheAccess(context *CacheContext) {
+	a.calls = append(a.calls, fmt.Sprintf("after changed:%v", context.HasStateChanged()))
+}
+
+func TestExecuteTokenRequestRejectsInvalidIDToken(t *testing.T) {
+	testCacheManager := new(requests.MockCacheManager)
+	client := &clientApplication{
+		clientApplicationParameters: clientAppParams,
+		webRequestManager:           new(requests.MockWebRequestManager),
+		cacheContext:                &CacheContext{cache: testCacheManager},
+	}
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	testAuthParams.Endpoints = testAuthorityEndpoints
+	req := new(requests.MockTokenRequest)
+	req.On("Execute").Return(&msalbase.TokenResponse{IDToken: &msalbase.IDToken{RawToken: "unsigned.idToken"}}, nil)
+	if _, err := client.executeTokenRequestWithCacheWrite(context.Background(), req, testAuthParams); err == nil {
+		t.Error("Error should be returned for an ID token that isn't signed")
+	}
+	testCacheManager.AssertNotCalled(t, "CacheTokenResponse", mock.Anything, mock.Anything)
+}
+
+func TestAcquireTokenByAuthCodeNonce(t *testing.T) {
+	tests := []struct {
+		tokenNonce string
+		valid      bool
+	}{
+		{"nonce123", true},
+		{"otherNonce", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		testWrm := new(requests.MockWebRequestManager)
+		testCacheManager := new(requests.MockCacheManager)
+		// The nonce is checked even when the ID token signature isn't
+		params := &clientApplicationParameters{
+			commonParameters: &applicationCommonParameters{
+				clientID:              "clientID",
+				authorityInfo:         testAuthorityInfo,
+				skipIDTokenValidation: true,
+			},
+		}
+		client := &clientApplication{
+			clientApplicationParameters: params,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		}
+		testWrm.On("GetTenantDiscoveryResponse",
+			"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+		authCodeParams := CreateAcquireTokenAuthCodeParameters([]string{"openid"}, "redirect")
+		authCodeParams.Code = "code"
+		authCodeParams.Nonce = "nonce123"
+		tokenResp := &msalbase.TokenResponse{IDToken: &msalbase.IDToken{Nonce: test.tokenNonce, RawToken: "idToken"}}
+		testWrm.On("GetAccessTokenFromAuthCode", mock.AnythingOfType("*msalbase.AuthParametersInternal"),
+			"code", "", map[string]string{}).Return(tokenResp, nil)
+		testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+		_, err := client.acquireTokenByAuthCode(context.Background(), authCodeParams)
+		if test.valid && err != nil {
+			t.Errorf("Error should be nil for nonce '%s', but it is %v", test.tokenNonce, err)
+		}
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Error should be returned for nonce '%s'", test.tokenNonce)
+			}
+			testCacheManager.AssertNotCalled(t, "CacheTokenResponse", mock.Anything, mock.Anything)
+		}
+	}
+}
+
+func TestAcquireTokenByAuthCodeGeneratedNonce(t *testing.T) {
+	tests := []struct {
+		desc    string
+		idToken func(nonce string) *msalbase.IDToken
+		valid   bool
+	}{
+		{"matching", func(nonce string) *msalbase.IDToken { return &msalbase.IDToken{Nonce: nonce, RawToken: "idToken"} }, true},
+		{"mismatched", func(nonce string) *msalbase.IDToken {
+			return &msalbase.IDToken{Nonce: "otherNonce", RawToken: "idToken"}
+		}, false},
+		{"missing claim", func(nonce string) *msalbase.IDToken { return &msalbase.IDToken{RawToken: "idToken"} }, false},
+		{"missing ID token", func(nonce string) *msalbase.IDToken { return nil }, false},
+	}
+	for _, test := range tests {
+		testWrm := new(requests.MockWebRequestManager)
+		testCacheManager := new(requests.MockCacheManager)
+		params := &clientApplicationParameters{
+			commonParameters: &applicationCommonParameters{
+				clientID:              "clientID",
+				authorityInfo:         testAuthorityInfo,
+				skipIDTokenValidation: true,
+			},
+		}
+		client := &clientApplication{
+			clientApplicationParameters: params,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		}
+		testWrm.On("GetTenantDiscoveryResponse",
+			"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+		authCodeURL, err := client.authCodeURL(context.Background(), []string{"openid"}, "redirect", "state123", nil)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		parsedURL, err := url.Parse(authCodeURL)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		nonce := parsedURL.Query().Get("nonce")
+		if nonce == "" {
+			t.Fatalf("A nonce should be generated for the URL, instead it is %s", authCodeURL)
+		}
+		// The nonce is found by the state of the redirect, the app doesn't pass it in
+		authCodeParams := CreateAcquireTokenAuthCodeParameters([]string{"openid"}, "redirect")
+		authCodeParams.Code = "code"
+		authCodeParams.State = "state123"
+		tokenResp := &msalbase.TokenResponse{IDToken: test.idToken(nonce)}
+		testWrm.On("GetAccessTokenFromAuthCode", mock.AnythingOfType("*msalbase.AuthParametersInternal"),
+			"code", "", map[string]string{}).Return(tokenResp, nil)
+		testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+		_, err = client.acquireTokenByAuthCode(context.Background(), authCodeParams)
+		if test.valid && err != nil {
+			t.Errorf("Error should be nil for the %s nonce, but it is %v", test.desc, err)
+		}
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Error should be returned for the %s nonce", test.desc)
+			}
+			testCacheManager.AssertNotCalled(t, "CacheTokenResponse", mock.Anything, mock.Anything)
+		}
+		if len(client.pendingNonces) != 0 {
+			t.Errorf("The nonce should be forgotten once the code is redeemed, instead the pending nonces are %v", client.pendingNonces)
+		}
+	}
+}
+
+func TestAuthCodeURLWithoutState(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	client := &clientApplication{
+		clientApplicationParameters: &clientApplicationParameters{
+			commonParameters: &applicationCommonParameters{clientID: "clientID", authorityInfo: testAuthorityInfo},
+		},
+		webRequestManager: testWrm,
+	}
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	// Without the state the generated nonce could never be checked
+	if _, err := client.authCodeURL(context.Background(), []string{"openid"}, "redirect", "", nil); err == nil {
+		t.Error("Error should be returned when neither the state nor the nonce are set")
+	}
+	authCodeURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	authCodeURLParams.Nonce = "nonce123"
+	if _, err := client.createAuthCodeURL(context.Background(), authCodeURLParams); err != nil {
+		t.Errorf("Error should be nil when the caller sets the nonce, but it is %v", err)
+	}
+}
+
+func TestPendingNoncesEvictOldest(t *testing.T) {
+	client := &clientApplication{}
+	for i := 0; i < maxPendingNonces; i++ {
+		client.addPendingNonce(fmt.Sprintf("state%d", i), fmt.Sprintf("nonce%d", i))
+	}
+	if nonce := client.takePendingNonce("state1"); nonce != "nonce1" {
+		t.Errorf("The nonce of state1 should be nonce1, instead it is %q", nonce)
+	}
+	client.addPendingNonce("newState1", "newNonce1")
+	client.addPendingNonce("newState2", "newNonce2")
+	if len(client.pendingNonces) != maxPendingNonces || len(client.pendingStates) != maxPendingNonces {
+		t.Fatalf("There should be %d pending nonces, instead there are %d for %d states", maxPendingNonces, len(client.pendingNonces), len(client.pendingStates))
+	}
+	// The redeemed state1 freed a slot, so only the oldest sign-in was dropped
+	if nonce := client.takePendingNonce("state0"); nonce != "" {
+		t.Errorf("The nonce of the oldest state should be dropped, instead it is %q", nonce)
+	}
+	for _, state := range []string{"state2", "newState1", "newState2"} {
+		if nonce := client.takePendingNonce(state); nonce == "" {
+			t.Errorf("The nonce of %s should be kept", state)
+		}
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	testCacheManager.On("GetAccount", "hid", testAuthorityInfo, testWrm).Return(account, nil)
+	testCacheManager.On("GetAccount", "missingHid", testAuthorityInfo, testWrm).Return((*msalbase.Account)(nil), nil)
+	actualAccount, err := client.getAccount(context.Background(), "hid")
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if actualAccount != account {
+		t.Errorf("Actual account %v differs from expected account %v", actualAccount, account)
+	}
+	if _, err := client.getAccount(context.Background(), "missingHid"); err != ErrAccountNotFound {
+		t.Errorf("Error should be ErrAccountNotFound, instead it is %v", err)
+	}
+}
+
+func TestGetAccountsByUsernameAndTenant(t *testing.T) {
+	client, _, testCacheManager := createSilentTestClient()
+	tenantOne := msalbase.CreateAccount("hid.tenant1", "env", "tenant1", "lid", msalbase.MSSTS, "User@Contoso.com")
+	tenantTwo := msalbase.CreateAccount("hid.tenant2", "env", "Tenant2", "lid", msalbase.MSSTS, "user@contoso.com")
+	otherUser := msalbase.CreateAccount("other.tenant1", "env", "tenant1", "lid", msalbase.MSSTS, "other@contoso.com")
+	testCacheManager.On("GetAllAccounts").Return([]*msalbase.Account{tenantOne, tenantTwo, otherUser})
+	accounts := client.getAccountsByUsername("USER@contoso.com")
+	if !reflect.DeepEqual(accounts, []AccountProvider{tenantOne, tenantTwo}) {
+		t.Errorf("Actual accounts %v differ from the accounts of the username in both tenants", accounts)
+	}
+	accounts = client.getAccountsByTenant("tenant2")
+	if !reflect.DeepEqual(accounts, []AccountProvider{tenantTwo}) {
+		t.Errorf("Actual accounts %v differ from the accounts of the tenant", accounts)
+	}
+	if accounts := client.getAccountsByUsername("missing@contoso.com"); accounts == nil || len(accounts) != 0 {
+		t.Errorf("Accounts should be an empty slice when no account matches, instead they are %v", accounts)
+	}
+	if accounts := client.getAccountsByTenant("missingTenant"); accounts == nil || len(accounts) != 0 {
+		t.Errorf("Accounts should be an empty slice when no account matches, instead they are %v", accounts)
+	}
+}
+
+func TestCacheAccessorCallOrder(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	accessor := &recordingCacheAccessor{}
+	client.cacheAccessor = accessor
+	testAuthParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	tokenResp := &msalbase.TokenResponse{AccessToken: "secret"}
+	req := new(requests.MockTokenRequest)
+	req.On("Execute").Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", testAuthParams, tokenResp).Return(testAcc, nil).
+		Run(func(args mock.Arguments) { accessor.calls = append(accessor.calls, "write") })
+	if _, err := client.executeTokenRequestWithCacheWrite(context.Background(), req, testAuthParams); err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	var at *msalbase.MockAccessToken
+	var rt, id *msalbase.MockCredential
+	testCacheManager.On("TryReadCache", testAuthParams, testWrm).Return(msalbase.CreateStorageTokenResponse(at, rt, id, nil), nil).
+		Run(func(args mock.Arguments) { accessor.calls = append(accessor.calls, "read") })
+	client.acquireTokenFromCache(context.Background(), testAuthParams)
+	expectedCalls := []string{"before", "write", "after changed:true", "before", "read", "after changed:false"}
+	if !reflect.DeepEqual(accessor.calls, expectedCalls) {
+		t.Errorf("Actual calls %v differ from expected calls %v", accessor.calls, expectedCalls)
+	}
+}
+
+func createValidateAuthorityTestClient(authority string) (*clientApplication, *requests.MockWebRequestManager) {
+	testWrm := new(requests.MockWebRequestManager)
+	params := createClientApplicationParameters("clientID")
+	params.setAadAuthority(authority)
+	client := &clientApplication{
+		clientApplicationParameters: params,
+		webRequestManager:           testWrm,
+		cacheContext:                &CacheContext{cache: new(requests.MockCacheManager)},
+	}
+	return client, testWrm
+}
+
+func TestValidateAuthority(t *testing.T) {
+	client, testWrm := createValidateAuthorityTestClient("https://valid.contoso.com/tenant/")
+	authorityInfo := client.clientApplicationParameters.commonParameters.authorityInfo
+	endpoint := "https://valid.contoso.com/tenant/v2.0/.well-known/openid-configuration"
+	testWrm.On("GetAadinstanceDiscoveryResponse", authorityInfo).Return(&requests.InstanceDiscoveryResponse{
+		TenantDiscoveryEndpoint: endpoint,
+		Metadata:                []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"valid.contoso.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse", endpoint).Return(tdr, nil)
+	if err := client.validateAuthority(context.Background()); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertCalled(t, "GetTenantDiscoveryResponse", endpoint)
+	testWrm.AssertNotCalled(t, "GetAccessTokenFromRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestValidateAuthorityUnknownHost(t *testing.T) {
+	client, testWrm := createValidateAuthorityTestClient("https://unknown.contoso.com/tenant/")
+	authorityInfo := client.clientApplicationParameters.commonParameters.authorityInfo
+	testWrm.On("GetAadinstanceDiscoveryResponse", authorityInfo).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"login.microsoftonline.com"}}},
+	}, nil)
+	err := client.validateAuthority(context.Background())
+	if err == nil {
+		t.Fatal("Error should not be nil for a host that isn't known to instance discovery")
+	}
+	if !strings.Contains(err.Error(), "host 'unknown.contoso.com' isn't known to instance discovery") {
+		t.Errorf("Error should name the unknown host, but it is %v", err)
+	}
+	testWrm.AssertNotCalled(t, "GetTenantDiscoveryResponse", mock.Anything)
+}
+
+func TestValidateAuthorityUnreachableDiscovery(t *testing.T) {
+	client, testWrm := createValidateAuthorityTestClient("https://unreachable.contoso.com/tenant/")
+	authorityInfo := client.clientApplicationParameters.commonParameters.authorityInfo
+	var noResponse *requests.InstanceDiscoveryResponse
+	testWrm.On("GetAadinstanceDiscoveryResponse", authorityInfo).Return(noResponse, errors.New("connection refused"))
+	err := client.validateAuthority(context.Background())
+	if err == nil {
+		t.Fatal("Error should not be nil when instance discovery can't be reached")
+	}
+	if !strings.Contains(err.Error(), "instance discovery failed: connection refused") {
+		t.Errorf("Error should describe the discovery failure, but it is %v", err)
+	}
+	testWrm.AssertNotCalled(t, "GetTenantDiscoveryResponse", mock.Anything)
+}
+
+func TestAcquireTokenSilentWithLoginHint(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	hinted := msalbase.CreateAccount("hintedHid", "env", "realm", "lid", msalbase.MSSTS, "User@Contoso.com")
+	other := msalbase.CreateAccount("otherHid", "env", "realm", "lid", msalbase.MSSTS, "other@contoso.com")
+	testCacheManager.On("GetAllAccounts").Return([]*msalbase.Account{other, hinted})
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return("0")
+	at.On("GetScopes").Return("openid")
+	var rt, id *msalbase.MockCredential
+	storageToken := msalbase.CreateStorageTokenResponse(at, rt, id, hinted)
+	testCacheManager.On("TryReadCache", mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+		return authParams.HomeaccountID == "hintedHid"
+	}), testWrm).Return(storageToken, nil)
+	silentParams := CreateAcquireTokenSilentParameters([]string{"openid"})
+	silentParams.SetLoginHint("user@contoso.com")
+	if _, err := client.acquireTokenSilent(context.Background(), silentParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if silentParams.account.GetHomeAccountID() != "" {
+		t.Error("The account of the login hint shouldn't be set on the caller's parameters")
+	}
+	silentParams.SetLoginHint("missing@contoso.com")
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if !errors.Is(err, ErrNoCachedToken) {
+		t.Errorf("A login hint without a cached account should require interaction, instead the error is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentWithAccountFilter(t *testing.T) {
+	client, testWrm, testCacheManager := createSilentTestClient()
+	contoso := msalbase.CreateAccount("contosoHid", "env", "contoso", "lid", msalbase.MSSTS, "user@contoso.com")
+	fabrikam := msalbase.CreateAccount("fabrikamHid", "env", "fabrikam", "lid", msalbase.MSSTS, "user@fabrikam.com")
+	testCacheManager.On("GetAllAccounts").Return([]*msalbase.Account{contoso, fabrikam})
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return("0")
+	at.On("GetScopes").Return("openid")
+	var rt, id *msalbase.MockCredential
+	testCacheManager.On("TryReadCache", mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+		return authParams.HomeaccountID == "contosoHid"
+	}), testWrm).Return(msalbase.CreateStorageTokenResponse(at, rt, id, contoso), nil)
+	testCacheManager.On("TryReadCache", mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+		return authParams.HomeaccountID == "fabrikamHid"
+	}), testWrm).Return(msalbase.CreateStorageTokenResponse(at, rt, id, fabrikam), nil)
+
+	// A filter matching a single account selects it
+	silentParams := CreateAcquireTokenSilentParameters([]string{"openid"})
+	silentParams.SetAccountFilter(func(account AccountProvider) bool {
+		return strings.HasSuffix(account.GetUsername(), "@contoso.com")
+	})
+	result, err := client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccount().GetHomeAccountID() != "contosoHid" {
+		t.Errorf("The token should be for the account of the filter, but it is for %s", result.GetAccount().GetHomeAccountID())
+	}
+
+	// A filter matching several accounts is ambiguous, the error lists the candidates
+	silentParams.SetAccountFilter(func(account AccountProvider) bool { return true })
+	_, err = client.acquireTokenSilent(context.Background(), silentParams)
+	var ambiguous *AmbiguousAccountError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("The error should be an AmbiguousAccountError, instead it is %v", err)
+	}
+	if len(ambiguous.Candidates) != 2 || ambiguous.Candidates[0].GetHomeAccountID() != "contosoHid" || ambiguous.Candidates[1].GetHomeAccountID() != "fabrikamHid" {
+		t.Errorf("The candidates should be both cached accounts, but they are %v", ambiguous.Candidates)
+	}
+
+	// An explicit account is used without consulting the filter
+	silentParams.SetAccount(fabrikam)
+	result, err = client.acquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccount().GetHomeAccountID() != "fabrikamHid" {
+		t.Errorf("The token should be for the explicit account, but it is for %s", result.GetAccount().GetHomeAccountID())
+	}
+
+	// A filter nothing matches requires interaction
+	silentParams = CreateAcquireTokenSilentParameters([]string{"openid"})
+	silentParams.SetAccountFilter(func(account AccountProvider) bool { return false })
+	if _, err := client.acquireTokenSilent(context.Background(), silentParams); !errors.Is(err, ErrNoCachedToken) {
+		t.Errorf("A filter without a cached account should require interaction, instead the error is %v", err)
+	}
+}
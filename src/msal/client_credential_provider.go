@@ -3,7 +3,13 @@
 
 package msalgo
 
-import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
 
 type credentialType int
 
@@ -32,8 +38,25 @@ func CreateClientCredentialFromCertificate(thumbprint string, key []byte) (Clien
 	return msalbase.CreateClientCredentialFromCertificate(thumbprint, key)
 }
 
+// CreateClientCredentialFromX509Certificate returns a ClientCredentialProvider when given an X.509 certificate and its private key.
+// The private key must be an RSA key; it's used to sign the client assertion sent to the authority.
+func CreateClientCredentialFromX509Certificate(certificate *x509.Certificate, privateKey crypto.PrivateKey) (ClientCredentialProvider, error) {
+	cert, err := msalbase.CreateClientCertificateFromX509(certificate, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return msalbase.CreateClientCredentialFromCertificateObject(cert), nil
+}
+
 // CreateClientCredentialFromAssertion returns a ClientCredentialProvider when given an assertion JWT.
 // Assertion should be of type urn:ietf:params:oauth:client-assertion-type:jwt-bearer.
 func CreateClientCredentialFromAssertion(assertion string) (ClientCredentialProvider, error) {
 	return msalbase.CreateClientCredentialFromAssertion(assertion)
 }
+
+// CreateClientCredentialFromAssertionCallback returns a ClientCredentialProvider when given a callback returning an assertion JWT.
+// The callback is invoked for every token request sent by a confidential client, so it can return a fresh assertion,
+// e.g. one signed by a key vault or read from a federated identity token file. Its errors fail the token request.
+func CreateClientCredentialFromAssertionCallback(callback func(ctx context.Context) (string, error)) (ClientCredentialProvider, error) {
+	return msalbase.CreateClientCredentialFromAssertionCallback(callback)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"errors"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// CloudInstance is the Azure AD cloud a client application signs users in to.
+// It selects the login host of the authority and the host used for instance discovery.
+type CloudInstance int
+
+// The public and national Azure AD clouds.
+const (
+	AzurePublic CloudInstance = iota
+	AzureUSGovernment
+	AzureChina
+	AzureGermany
+)
+
+func (cloud CloudInstance) getCloudInstance() (*msalbase.CloudInstance, error) {
+	switch cloud {
+	case AzurePublic:
+		return msalbase.CloudInstancePublic, nil
+	case AzureUSGovernment:
+		return msalbase.CloudInstanceUSGovernment, nil
+	case AzureChina:
+		return msalbase.CloudInstanceChina, nil
+	case AzureGermany:
+		return msalbase.CloudInstanceGermany, nil
+	}
+	return nil, errors.New("unknown cloud instance")
+}
+
+// GetLoginHost returns the default authority host of the cloud.
+func (cloud CloudInstance) GetLoginHost() string {
+	instance, err := cloud.getCloudInstance()
+	if err != nil {
+		return ""
+	}
+	return instance.LoginHost
+}
+
+// GetGraphHost returns the host of Microsoft Graph in the cloud.
+func (cloud CloudInstance) GetGraphHost() string {
+	instance, err := cloud.getCloudInstance()
+	if err != nil {
+		return ""
+	}
+	return instance.GraphHost
+}
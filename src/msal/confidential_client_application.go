@@ -4,6 +4,10 @@
 package msalgo
 
 import (
+	"context"
+	"net/http"
+	"time"
+
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 )
@@ -12,6 +16,7 @@ import (
 // These are apps that run on servers (web apps, web API apps, or even service/daemon apps),
 // and are capable of safely storing an application secret.
 // For more information, visit https://docs.microsoft.com/azure/active-directory/develop/msal-client-applications
+// Methods that call the authority take a context, canceling it or passing its deadline aborts the requests they send.
 type ConfidentialClientApplication struct {
 	clientApplication *clientApplication
 	clientCredential  *msalbase.ClientCredential
@@ -32,12 +37,45 @@ func CreateConfidentialClientApplication(
 	}, nil
 }
 
+// CreateConfidentialClientApplicationForCloud creates a ConfidentialClientApplication instance for the tenant in an Azure AD national cloud.
+func CreateConfidentialClientApplicationForCloud(
+	clientID string, cloud CloudInstance, tenant string, clientCredential ClientCredentialProvider,
+) (*ConfidentialClientApplication, error) {
+	cred, err := createInternalClientCredential(clientCredential)
+	if err != nil {
+		return nil, err
+	}
+	clientApp, err := createClientApplicationForCloud(clientID, cloud, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfidentialClientApplication{
+		clientApplication: clientApp,
+		clientCredential:  cred,
+	}, nil
+}
+
+// CreateConfidentialClientApplicationWithCacheAccessor creates a ConfidentialClientApplication instance whose cache is loaded and persisted by the CacheAccessor.
+func CreateConfidentialClientApplicationWithCacheAccessor(
+	clientID string, authority string, clientCredential ClientCredentialProvider, accessor CacheAccessor,
+) (*ConfidentialClientApplication, error) {
+	cca, err := CreateConfidentialClientApplication(clientID, authority, clientCredential)
+	if err != nil {
+		return nil, err
+	}
+	cca.SetCacheAccessor(accessor)
+	return cca, nil
+}
+
 // This is used to convert the user-facing client credential interface to the internal representation of a client credential
 func createInternalClientCredential(interfaceCred ClientCredentialProvider) (*msalbase.ClientCredential, error) {
 	if interfaceCred.GetCredentialType() == msalbase.ClientCredentialSecret {
 		return msalbase.CreateClientCredentialFromSecret(interfaceCred.GetSecret())
 
 	}
+	if interfaceCred.GetAssertion().AssertionCallback != nil {
+		return msalbase.CreateClientCredentialFromAssertionCallback(interfaceCred.GetAssertion().AssertionCallback)
+	}
 	if interfaceCred.GetAssertion().ClientCertificate != nil {
 		return msalbase.CreateClientCredentialFromCertificateObject(
 			interfaceCred.GetAssertion().ClientCertificate), nil
@@ -47,8 +85,106 @@ func createInternalClientCredential(interfaceCred ClientCredentialProvider) (*ms
 
 // SetHTTPManager allows users to use their own implementation of HTTPManager.
 func (cca *ConfidentialClientApplication) SetHTTPManager(httpManager HTTPManager) {
-	webRequestManager := createWebRequestManager(httpManager)
-	cca.clientApplication.webRequestManager = webRequestManager
+	cca.clientApplication.setHTTPManager(httpManager)
+}
+
+// SetRetryPolicy sets how requests that fail with a transient error are retried; by default they are retried twice.
+func (cca *ConfidentialClientApplication) SetRetryPolicy(policy RetryPolicy) {
+	cca.clientApplication.retryPolicy = policy
+}
+
+// SetUserAgent appends the product token of the app, e.g. "contoso-service/1.2.0", to the User-Agent header of all of MSAL's requests.
+// The header is "MSAL.Go/<version>" by default, so the authority's logs tell the requests of the app apart from other MSAL Go apps.
+func (cca *ConfidentialClientApplication) SetUserAgent(userAgent string) {
+	cca.clientApplication.userAgent = userAgent
+}
+
+// SetHTTPTimeout bounds every request sent to the authority, including reading the response, by default to 30 seconds; zero turns the bound off.
+// A deadline of the context passed to a method still applies when it comes sooner. Retries of a failed request get a new timeout.
+func (cca *ConfidentialClientApplication) SetHTTPTimeout(timeout time.Duration) {
+	cca.clientApplication.httpTimeout = timeout
+}
+
+// SetMaxResponseSize bounds the body of every response read from the authority, by default to 4 MB; zero turns the bound off.
+// A larger body fails the request. The bound doesn't apply to the responses of an HTTPManager set with SetHTTPManager.
+func (cca *ConfidentialClientApplication) SetMaxResponseSize(size int64) {
+	cca.clientApplication.maxResponseSize = size
+}
+
+// SetHTTPClient sends all of MSAL's requests with the client, for example to use a proxy, custom TLS settings or timeouts.
+// Passing nil restores the default client.
+func (cca *ConfidentialClientApplication) SetHTTPClient(client *http.Client) {
+	cca.SetHTTPManager(createHTTPManagerWithClient(client))
+}
+
+// SetValidateAuthority sets whether the authority host is checked against instance discovery before tokens are requested.
+// It is on by default; turn it off only for private clouds whose hosts are explicitly trusted.
+func (cca *ConfidentialClientApplication) SetValidateAuthority(validateAuthority bool) {
+	cca.clientApplication.clientApplicationParameters.setValidateAuthority(validateAuthority)
+}
+
+// SetInstanceDiscovery sets whether the aliases of the authority host are looked up with instance discovery, it is on by default.
+// With it off no instance discovery request is sent, the host is its only alias, so tokens cached under another alias aren't found,
+// and the authority isn't validated; use it for private clouds and environments without access to the discovery endpoint.
+func (cca *ConfidentialClientApplication) SetInstanceDiscovery(enabled bool) {
+	cca.clientApplication.clientApplicationParameters.setInstanceDiscovery(enabled)
+}
+
+// SetInstanceDiscoveryHeaders sets headers that are sent with the instance discovery request, e.g. an Authorization header
+// for a gateway in front of the discovery endpoint of a private cloud. They aren't sent with any other request.
+func (cca *ConfidentialClientApplication) SetInstanceDiscoveryHeaders(headers map[string]string) {
+	cca.clientApplication.clientApplicationParameters.setInstanceDiscoveryHeaders(headers)
+}
+
+// SetKnownAuthorityHosts sets the authority hosts that are trusted when instance discovery is turned off with SetInstanceDiscovery,
+// so the authority is still validated in private clouds without discovery: an authority on any other host is rejected before tokens are requested.
+func (cca *ConfidentialClientApplication) SetKnownAuthorityHosts(hosts []string) {
+	cca.clientApplication.clientApplicationParameters.setKnownAuthorityHosts(hosts)
+}
+
+// SetClientCapabilities declares the capabilities of the app to the authority, e.g. "cp1" for apps that handle claims challenges,
+// so that the authority issues tokens for Continuous Access Evaluation. The capabilities are merged into the claims of every request,
+// together with a claims challenge passed to SetClaims.
+func (cca *ConfidentialClientApplication) SetClientCapabilities(capabilities []string) {
+	cca.clientApplication.clientApplicationParameters.setClientCapabilities(capabilities)
+}
+
+// ValidateAuthority checks the authority of the application with instance discovery and tenant discovery without acquiring a token.
+// It lets configuration problems be found at startup, the error describes everything that's wrong with the authority.
+func (cca *ConfidentialClientApplication) ValidateAuthority(ctx context.Context) error {
+	return cca.clientApplication.validateAuthority(ctx)
+}
+
+// SetAllowRefreshTokenMigration turns on ExportRefreshToken and ImportRefreshToken, which are off by default.
+// They exist only to move users between MSAL and another authentication library; a refresh token is a long-lived credential
+// that lets whoever holds it acquire tokens for the user, so an exported token must be protected like a password and never logged.
+func (cca *ConfidentialClientApplication) SetAllowRefreshTokenMigration(allow bool) {
+	cca.clientApplication.allowRefreshTokenMigration = allow
+}
+
+// SetValidateIDToken sets whether the signature, issuer, audience and lifetime of ID tokens are checked before they're cached.
+// It is on by default; turn it off only when the ID tokens are validated elsewhere, e.g. by the web app that received them.
+func (cca *ConfidentialClientApplication) SetValidateIDToken(validateIDToken bool) {
+	cca.clientApplication.clientApplicationParameters.setValidateIDToken(validateIDToken)
+}
+
+// SetClockSkew sets the difference allowed between the clocks of the authority and the server when the lifetime of ID tokens is checked,
+// so ID tokens that just expired or aren't valid yet within it are still accepted. It's 5 minutes by default.
+func (cca *ConfidentialClientApplication) SetClockSkew(clockSkew time.Duration) {
+	cca.clientApplication.clientApplicationParameters.setClockSkew(clockSkew)
+}
+
+// SetNormalizeCacheEnvironment sets whether tokens are cached under the preferred_cache alias that instance discovery returns for the authority host,
+// instead of the host the request used, so the cache holds the same environment whichever alias of the authority the app is configured with.
+func (cca *ConfidentialClientApplication) SetNormalizeCacheEnvironment(normalize bool) {
+	cca.clientApplication.clientApplicationParameters.setNormalizeCacheEnvironment(normalize)
+}
+
+// SetAzureRegion makes the client credentials grant use the token endpoint of the Azure region, e.g. "eastus", which lowers the latency of services in that region.
+// Pass AutoDetectRegion to use the region the app runs in; the global endpoint is used if it can't be detected, or if the region is empty.
+// Tokens from a regional endpoint are cached separately from the global ones.
+func (cca *ConfidentialClientApplication) SetAzureRegion(region string) {
+	cca.clientApplication.clientApplicationParameters.setAzureRegion(region)
 }
 
 // SetCacheAccessor allows users to use an implementation of CacheAccessor to handle cache persistence.
@@ -56,41 +192,193 @@ func (cca *ConfidentialClientApplication) SetCacheAccessor(accessor CacheAccesso
 	cca.clientApplication.cacheAccessor = accessor
 }
 
+// SetOnRefreshTokenUpdated sets a callback that is called with the account when its refresh token is replaced by a new one during a silent request,
+// e.g. to update a copy of the refresh token kept outside of the cache right away.
+func (cca *ConfidentialClientApplication) SetOnRefreshTokenUpdated(callback func(account AccountProvider)) {
+	cca.clientApplication.onRefreshTokenUpdated = callback
+}
+
+// SetOnRefreshTokenInvalidated sets a callback that is called with the account when the authority rejects its refresh token as revoked or expired,
+// the refresh token has been removed from the cache when it's called.
+func (cca *ConfidentialClientApplication) SetOnRefreshTokenInvalidated(callback func(account AccountProvider)) {
+	cca.clientApplication.onRefreshTokenInvalidated = callback
+}
+
+// SetCacheCallbacks persists the cache with two functions instead of a CacheAccessor, see CreateCallbackCacheAccessor.
+func (cca *ConfidentialClientApplication) SetCacheCallbacks(beforeAccess func() ([]byte, error), afterAccess func(data []byte, changed bool) error) {
+	cca.SetCacheAccessor(CreateCallbackCacheAccessor(beforeAccess, afterAccess))
+}
+
+// SetCacheCodec sets the codec that encrypts the serialized cache before the CacheAccessor persists it, and decrypts it when it's loaded.
+func (cca *ConfidentialClientApplication) SetCacheCodec(codec CacheCodec) {
+	cca.clientApplication.cacheCodec = codec
+}
+
+// Flush makes the CacheAccessor persist the current cache, with the changed flag set, so no tokens are lost when the app exits.
+// It's meant for a graceful shutdown; it does nothing when there's no CacheAccessor.
+func (cca *ConfidentialClientApplication) Flush() error {
+	return cca.clientApplication.flush()
+}
+
+// SetCacheMaxAccounts limits how many accounts each partition of the cache holds, when a token is written for one more account
+// the tokens of the least recently used account are evicted. Accounts are used when their tokens are read or written, 0 doesn't limit them.
+// App tokens, e.g. of the client credentials grant, don't belong to an account so they are never evicted.
+func (cca *ConfidentialClientApplication) SetCacheMaxAccounts(maxAccounts int) {
+	cca.clientApplication.cacheContext.cache.SetMaxAccounts(maxAccounts)
+}
+
+// SetTokenExpirationOffset makes the cache treat access tokens as if the offset had already passed, e.g. an hour makes a token that expires
+// within the hour expired, so tests of an app can exercise its refresh paths without waiting. It's meant for tests, by default there's no offset.
+// Unlike SetSkipExpirationBuffer of the parameters it shifts the clock of the cache reads, tokens acquired while it's set are still cached.
+func (cca *ConfidentialClientApplication) SetTokenExpirationOffset(offset time.Duration) {
+	cca.clientApplication.cacheContext.cache.SetTokenExpirationOffset(offset)
+}
+
 // CreateAuthCodeURL creates a URL used to acquire an authorization code. Users need to call CreateAuthorizationCodeURLParameters and pass it in.
-func (cca *ConfidentialClientApplication) CreateAuthCodeURL(authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
-	return cca.clientApplication.createAuthCodeURL(authCodeURLParameters)
+func (cca *ConfidentialClientApplication) CreateAuthCodeURL(ctx context.Context, authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
+	return cca.clientApplication.createAuthCodeURL(ctx, authCodeURLParameters)
+}
+
+// AuthCodeURL creates the URL of the authorize endpoint of the authority that the web app redirects the user to, for frameworks that do the redirect themselves.
+// The URL has the client ID of the app and the state, which the redirect back to the app has to match; options set the optional parameters.
+// The code of the redirect is redeemed with AcquireTokenByAuthCode.
+func (cca *ConfidentialClientApplication) AuthCodeURL(ctx context.Context, scopes []string, redirectURI string, state string, options ...AuthCodeURLOption) (string, error) {
+	return cca.clientApplication.authCodeURL(ctx, scopes, redirectURI, state, options)
 }
 
 // AcquireTokenSilent acquires a token from either the cache or using a refresh token
 // Users need to create an AcquireTokenSilentParameters instance and pass it in.
-func (cca *ConfidentialClientApplication) AcquireTokenSilent(
+func (cca *ConfidentialClientApplication) AcquireTokenSilent(ctx context.Context,
 	silentParameters *AcquireTokenSilentParameters) (AuthenticationResultProvider, error) {
 	silentParameters.requestType = requests.RefreshTokenConfidential
 	silentParameters.clientCredential = cca.clientCredential
-	return cca.clientApplication.acquireTokenSilent(silentParameters)
+	return cca.clientApplication.acquireTokenSilent(ctx, silentParameters)
 }
 
 // AcquireTokenByAuthCode is a request to acquire a security token from the authority, using an authorization code.
 // Users need to create an AcquireTokenAuthCodeParameters instance and pass it in.
-func (cca *ConfidentialClientApplication) AcquireTokenByAuthCode(
+func (cca *ConfidentialClientApplication) AcquireTokenByAuthCode(ctx context.Context,
 	authCodeParams *AcquireTokenAuthCodeParameters) (AuthenticationResultProvider, error) {
 	authCodeParams.requestType = requests.AuthCodeConfidential
 	authCodeParams.clientCredential = cca.clientCredential
-	return cca.clientApplication.acquireTokenByAuthCode(authCodeParams)
+	return cca.clientApplication.acquireTokenByAuthCode(ctx, authCodeParams)
 
 }
 
 // AcquireTokenByClientCredential acquires a security token from the authority, using the client credentials grant.
 // Users need to create an AcquireTokenClientCredentialParameters instance and pass it in.
-func (cca *ConfidentialClientApplication) AcquireTokenByClientCredential(
+func (cca *ConfidentialClientApplication) AcquireTokenByClientCredential(ctx context.Context,
 	clientCredParams *AcquireTokenClientCredentialParameters) (AuthenticationResultProvider, error) {
-	authParams := cca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
-	clientCredParams.augmentAuthenticationParameters(authParams)
+	authParams, err := cca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := clientCredParams.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	authParams.Region = cca.clientApplication.getRegion(ctx)
+	// App tokens can't be refreshed, so a new one is only requested when the cached one is missing or expired,
+	// or when there's a claims challenge, which a cached token doesn't satisfy
+	if authParams.Claims == "" {
+		if result, err := cca.clientApplication.acquireTokenFromCache(ctx, authParams); err == nil {
+			return result, nil
+		}
+	}
 	req := requests.CreateClientCredentialRequest(cca.clientApplication.webRequestManager, authParams, cca.clientCredential)
-	return cca.clientApplication.executeTokenRequestWithCacheWrite(req, authParams)
+	return cca.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
 }
 
-// GetAccounts gets all the accounts in the token cache.
+// AcquireTokenOnBehalfOf acquires a security token for a downstream API, using the access token the middle-tier API was called with.
+// Users need to create an AcquireTokenOnBehalfOfParameters instance and pass it in.
+// Tokens are cached for the subject of the user assertion, so they're reused while that user calls the API.
+func (cca *ConfidentialClientApplication) AcquireTokenOnBehalfOf(ctx context.Context,
+	oboParams *AcquireTokenOnBehalfOfParameters) (AuthenticationResultProvider, error) {
+	authParams, err := cca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	err = oboParams.augmentAuthenticationParameters(authParams)
+	if err != nil {
+		return nil, err
+	}
+	// A cached token doesn't satisfy a claims challenge
+	if authParams.Claims == "" {
+		if result, err := cca.clientApplication.acquireTokenFromCache(ctx, authParams); err == nil {
+			return result, nil
+		}
+	}
+	req := requests.CreateOnBehalfOfRequest(cca.clientApplication.webRequestManager, authParams, cca.clientCredential)
+	return cca.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
+}
+
+// GetAllAccessTokens lists the access tokens of the cache, including its partitions, to debug cache misses such as tokens cached under another authority host.
+// The secrets of the tokens are left out when redactSecrets is set, which is what support tooling and logs should use.
+func (cca *ConfidentialClientApplication) GetAllAccessTokens(redactSecrets bool) []*CachedAccessToken {
+	return cca.clientApplication.getAllAccessTokens(redactSecrets)
+}
+
+// GetAllRefreshTokens lists the refresh tokens of the cache, including its partitions.
+// The secrets of the tokens are left out when redactSecrets is set, which is what support tooling and logs should use.
+func (cca *ConfidentialClientApplication) GetAllRefreshTokens(redactSecrets bool) []*CachedRefreshToken {
+	return cca.clientApplication.getAllRefreshTokens(redactSecrets)
+}
+
+// Stats counts the accounts, access tokens and refresh tokens of the cache, including its partitions, and estimates their size.
+// It's cheap enough for a health check endpoint; the cache isn't serialized and only the lengths of the secrets are read.
+func (cca *ConfidentialClientApplication) Stats() (*CacheStats, error) {
+	return cca.clientApplication.getStats()
+}
+
+// GetAccounts gets all the accounts in the token cache, including the accounts of its partitions.
 func (cca *ConfidentialClientApplication) GetAccounts() []AccountProvider {
 	return cca.clientApplication.getAccounts()
 }
+
+// GetAccountsByUsername gets the accounts in the token cache with the username, ignoring case.
+// An account signed in to several tenants is returned once per tenant.
+func (cca *ConfidentialClientApplication) GetAccountsByUsername(username string) []AccountProvider {
+	return cca.clientApplication.getAccountsByUsername(username)
+}
+
+// GetAccountsByTenant gets the accounts in the token cache signed in to the tenant.
+func (cca *ConfidentialClientApplication) GetAccountsByTenant(realm string) []AccountProvider {
+	return cca.clientApplication.getAccountsByTenant(realm)
+}
+
+// GetAccount gets the account with the home account ID from the token cache, e.g. the ID stored in the user's session.
+// ErrAccountNotFound is returned if the account isn't in the cache.
+func (cca *ConfidentialClientApplication) GetAccount(ctx context.Context, homeAccountID string) (AccountProvider, error) {
+	return cca.clientApplication.getAccount(ctx, homeAccountID)
+}
+
+// RemoveAccount signs the account out by removing it and all of its tokens from the token cache.
+// Removing an account that isn't in the cache is not an error.
+func (cca *ConfidentialClientApplication) RemoveAccount(ctx context.Context, account AccountProvider) error {
+	return cca.clientApplication.removeAccount(ctx, account)
+}
+
+// DeleteAccessTokens removes the account's cached access tokens that were granted for all of the scopes, e.g. after the consent to an API was revoked.
+// The refresh and ID tokens are kept, so AcquireTokenSilent acquires new access tokens for the scopes. Deleting tokens that aren't in the cache is not an error.
+func (cca *ConfidentialClientApplication) DeleteAccessTokens(ctx context.Context, account AccountProvider, scopes []string) error {
+	return cca.clientApplication.deleteAccessTokens(ctx, account, scopes)
+}
+
+// ExportRefreshToken returns the cached refresh token that AcquireTokenSilent would redeem for the account, to hand it to another library.
+// It returns ErrRefreshTokenMigrationDisabled unless SetAllowRefreshTokenMigration allowed it, and ErrNoCachedToken if the account has none.
+func (cca *ConfidentialClientApplication) ExportRefreshToken(ctx context.Context, account AccountProvider) (string, error) {
+	return cca.clientApplication.exportRefreshToken(ctx, account)
+}
+
+// ImportRefreshToken caches a refresh token that another library acquired for the client ID and the authority of the application,
+// familyID is set when the token was issued to a family of client IDs. The home account ID is the user's object ID and tenant ID joined by a dot.
+// The returned account can be passed to AcquireTokenSilent, which redeems the refresh token.
+// It returns ErrRefreshTokenMigrationDisabled unless SetAllowRefreshTokenMigration allowed it.
+func (cca *ConfidentialClientApplication) ImportRefreshToken(homeAccountID string, refreshToken string, familyID string) (AccountProvider, error) {
+	return cca.clientApplication.importRefreshToken(homeAccountID, refreshToken, familyID)
+}
+
+// ClearCache removes all the accounts and tokens from the token cache, e.g. to sign every user out.
+// Clearing an empty cache is not an error.
+func (cca *ConfidentialClientApplication) ClearCache() error {
+	return cca.clientApplication.clearCache()
+}
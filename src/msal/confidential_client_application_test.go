@@ -4,10 +4,17 @@
 package msalgo
 
 import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -19,18 +26,476 @@ func TestAcquireTokenByClientCredential(t *testing.T) {
 		clientApplication: &clientApplication{
 			clientApplicationParameters: clientAppParams,
 			webRequestManager:           testWrm,
-			cacheContext:                &CacheContext{testCacheManager},
+			cacheContext:                &CacheContext{cache: testCacheManager},
 		},
 		clientCredential: cred,
 	}
+	var emptyAccessToken *msalbase.MockAccessToken
+	emptyStorageToken := msalbase.CreateStorageTokenResponse(emptyAccessToken, nil, nil, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(emptyStorageToken, nil)
 	testWrm.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
 	actualTokenResp := &msalbase.TokenResponse{}
 	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(actualTokenResp, nil)
 	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), actualTokenResp).Return(testAcc, nil)
 	clientCredParams := &AcquireTokenClientCredentialParameters{tokenCommonParams}
-	_, err := cca.AcquireTokenByClientCredential(clientCredParams)
+	_, err := cca.AcquireTokenByClientCredential(context.Background(), clientCredParams)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestAcquireTokenByClientCredentialFromCache(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	cca := &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return("1000")
+	at.On("GetScopes").Return("openid")
+	var emptyIDToken *msalbase.MockCredential
+	storageToken := msalbase.CreateStorageTokenResponse(at, nil, emptyIDToken, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(storageToken, nil)
+	clientCredParams := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+	result, err := cca.AcquireTokenByClientCredential(context.Background(), clientCredParams)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "secret" {
+		t.Errorf("Access token should be the cached one, instead it is %v", result.GetAccessToken())
+	}
+	testWrm.AssertNotCalled(t, "GetAccessTokenWithClientSecret", mock.Anything, mock.Anything)
+	testCacheManager.AssertNotCalled(t, "CacheTokenResponse", mock.Anything, mock.Anything)
+}
+
+func TestAcquireTokenWithClaimsSkipsCache(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	cca := &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return(strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	at.On("GetScopes").Return("openid")
+	var emptyIDToken *msalbase.MockCredential
+	storageToken := msalbase.CreateStorageTokenResponse(at, nil, emptyIDToken, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(storageToken, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	hasClaims := mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+		return authParams.Claims == `{"access_token":{"nbf":{"essential":true}}}`
+	})
+	tokenResp := &msalbase.TokenResponse{}
+	testWrm.On("GetAccessTokenWithClientSecret", hasClaims, "client_secret").Return(tokenResp, nil)
+	testWrm.On("GetAccessTokenOnBehalfOf", hasClaims, mock.Anything, mock.Anything).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", hasClaims, tokenResp).Return(testAcc, nil)
+
+	clientCredParams := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+	clientCredParams.SetClaims(`{"access_token":{"nbf":{"essential":true}}}`)
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), clientCredParams); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertCalled(t, "GetAccessTokenWithClientSecret", hasClaims, "client_secret")
+
+	userAssertion := "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"userSubject"}`)) + ".signature"
+	oboParams := CreateAcquireTokenOnBehalfOfParameters([]string{"openid"}, userAssertion)
+	oboParams.SetClaims(`{"access_token":{"nbf":{"essential":true}}}`)
+	if _, err := cca.AcquireTokenOnBehalfOf(context.Background(), oboParams); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertCalled(t, "GetAccessTokenOnBehalfOf", hasClaims, userAssertion, mock.Anything)
+	testCacheManager.AssertNotCalled(t, "TryReadCache", mock.Anything, mock.Anything)
+}
+
+func TestAcquireTokenByClientCredentialCorrelationID(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	cca := &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+	var emptyAccessToken *msalbase.MockAccessToken
+	emptyStorageToken := msalbase.CreateStorageTokenResponse(emptyAccessToken, nil, nil, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(emptyStorageToken, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	sentCorrelationID := ""
+	tokenResp := &msalbase.TokenResponse{}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").
+		Run(func(args mock.Arguments) {
+			sentCorrelationID = args.Get(0).(*msalbase.AuthParametersInternal).CorrelationID
+		}).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	result, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"}))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if _, err := uuid.Parse(sentCorrelationID); err != nil {
+		t.Errorf("Generated correlation ID should be a UUID, instead it is %s", sentCorrelationID)
+	}
+	if result.GetCorrelationID() != sentCorrelationID {
+		t.Errorf("Result should have the correlation ID %s, instead it has %s", sentCorrelationID, result.GetCorrelationID())
+	}
+	params := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+	params.SetCorrelationID("6a9d2b0e-4f4e-4c5a-9a55-3f4a0c2b1d7e")
+	result, err = cca.AcquireTokenByClientCredential(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if sentCorrelationID != "6a9d2b0e-4f4e-4c5a-9a55-3f4a0c2b1d7e" || result.GetCorrelationID() != sentCorrelationID {
+		t.Errorf("The caller's correlation ID should be sent and returned, instead %s was sent and %s returned", sentCorrelationID, result.GetCorrelationID())
+	}
+}
+
+func TestAcquireTokenByClientCredentialAuthorityOverride(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	cca := &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+	sentTenants := []string{}
+	recordTenant := func(args mock.Arguments) {
+		sentTenants = append(sentTenants, args.Get(0).(*msalbase.AuthParametersInternal).AuthorityInfo.Tenant)
+	}
+	var emptyAccessToken *msalbase.MockAccessToken
+	emptyStorageToken := msalbase.CreateStorageTokenResponse(emptyAccessToken, nil, nil, nil)
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).Return(emptyStorageToken, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/othertenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Run(recordTenant).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	params := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+	params.SetAuthority("https://login.microsoftonline.com/othertenant/")
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), params); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if len(sentTenants) != 2 || sentTenants[0] != "othertenant" || sentTenants[1] != "v2.0" {
+		t.Errorf("The first request should go to othertenant and the next one to the default tenant, instead they went to %v", sentTenants)
+	}
+	crossCloud := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+	crossCloud.SetAuthority("https://login.chinacloudapi.cn/othertenant/")
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), crossCloud); err == nil {
+		t.Error("An authority in another cloud should be rejected unless it's allowed")
+	}
+	if len(sentTenants) != 2 {
+		t.Errorf("No token should be requested from another cloud, instead tokens were requested from %v", sentTenants)
+	}
+}
+
+func TestAcquireTokenByClientCredentialMultipleAuthorities(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.multi.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	testWrm := new(requests.MockWebRequestManager)
+	cca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetAadinstanceDiscoveryResponse", mock.MatchedBy(func(authorityInfo *msalbase.AuthorityInfo) bool {
+		return authorityInfo.Host == "login.multi.contoso.com"
+	})).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{PreferredCache: "login.multi.contoso.com", Aliases: []string{"login.multi.contoso.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.multi.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	for _, host := range []string{"login.multi.contoso.com", "contoso.b2clogin.com"} {
+		host := host
+		testWrm.On("GetAccessTokenWithClientSecret", mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+			return authParams.AuthorityInfo.Host == host
+		}), "client_secret").Return(&msalbase.TokenResponse{
+			AccessToken:   host,
+			GrantedScopes: []string{"openid"},
+			ExpiresOn:     time.Now().Add(time.Hour),
+			ExtExpiresOn:  time.Now().Add(time.Hour),
+		}, nil)
+	}
+	acquire := func(b2c bool) string {
+		params := CreateAcquireTokenClientCredentialParameters([]string{"openid"})
+		if b2c {
+			params.SetAuthority("https://contoso.b2clogin.com/contoso.onmicrosoft.com/b2c_1_signin/")
+			params.SetAllowCrossCloudAuthority(true)
+		}
+		result, err := cca.AcquireTokenByClientCredential(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		return result.GetAccessToken()
+	}
+	// Each authority's token is cached under its own environment, so the second round is served from the cache
+	for i := 0; i < 2; i++ {
+		if token := acquire(false); token != "login.multi.contoso.com" {
+			t.Errorf("The token should be the one of the client application's authority, instead it is %s", token)
+		}
+		if token := acquire(true); token != "contoso.b2clogin.com" {
+			t.Errorf("The token should be the one of the B2C authority, instead it is %s", token)
+		}
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenWithClientSecret", 2)
+	testWrm.AssertNumberOfCalls(t, "GetAadinstanceDiscoveryResponse", 1)
+}
+
+func TestAcquireTokenOnBehalfOf(t *testing.T) {
+	testWrm := new(requests.MockWebRequestManager)
+	testCacheManager := new(requests.MockCacheManager)
+	cred, _ := msalbase.CreateClientCredentialFromSecret("client_secret")
+	cca := &ConfidentialClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           testWrm,
+			cacheContext:                &CacheContext{cache: testCacheManager},
+		},
+		clientCredential: cred,
+	}
+	userAssertion := "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"userSubject","oid":"userOid"}`)) + ".signature"
+	isOBOParams := mock.MatchedBy(func(authParams *msalbase.AuthParametersInternal) bool {
+		return authParams.HomeaccountID == "userSubject" && authParams.UserAssertion == userAssertion &&
+			authParams.AuthorizationType == msalbase.AuthorizationTypeOnBehalfOf
+	})
+	var emptyAccessToken *msalbase.MockAccessToken
+	emptyStorageToken := msalbase.CreateStorageTokenResponse(emptyAccessToken, nil, nil, nil)
+	testCacheManager.On("TryReadCache", isOBOParams, testWrm).Return(emptyStorageToken, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{}
+	testWrm.On("GetAccessTokenOnBehalfOf", isOBOParams, userAssertion, map[string]string{"client_secret": "client_secret"}).Return(tokenResp, nil)
+	testCacheManager.On("CacheTokenResponse", isOBOParams, tokenResp).Return(testAcc, nil)
+	oboParams := CreateAcquireTokenOnBehalfOfParameters([]string{"api://downstream/.default"}, userAssertion)
+	_, err := cca.AcquireTokenOnBehalfOf(context.Background(), oboParams)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testCacheManager.AssertCalled(t, "CacheTokenResponse", isOBOParams, tokenResp)
+	_, err = cca.AcquireTokenOnBehalfOf(context.Background(), CreateAcquireTokenOnBehalfOfParameters([]string{"api://downstream/.default"}, "notAJWT"))
+	if err == nil {
+		t.Error("Error should not be nil when the user assertion isn't a JWT")
+	}
+}
+
+func TestAcquireTokenByClientCredentialWithoutInstanceDiscovery(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.nodiscovery.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca.SetInstanceDiscovery(false)
+	testWrm := new(requests.MockWebRequestManager)
+	cca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.nodiscovery.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"openid"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+	for i := 0; i < 2; i++ {
+		result, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"}))
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		if result.GetAccessToken() != "secret" {
+			t.Errorf("Access token should be secret, instead it is %s", result.GetAccessToken())
+		}
+	}
+	testWrm.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", mock.Anything)
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenWithClientSecret", 1)
+}
+
+func TestSetTokenExpirationOffset(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.offset.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca.SetInstanceDiscovery(false)
+	testWrm := new(requests.MockWebRequestManager)
+	cca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.offset.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"scope"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"scope"})); err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenWithClientSecret", 1)
+	// The cached token expires within the offset, so it's requested again
+	cca.SetTokenExpirationOffset(time.Hour)
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"scope"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenWithClientSecret", 2)
+}
+
+func TestAcquireTokenByClientCredentialWithResource(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.resource.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca.SetInstanceDiscovery(false)
+	testWrm := new(requests.MockWebRequestManager)
+	cca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.resource.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		GrantedScopes: []string{"https://graph.microsoft.com/.default"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+	}
+	testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+	for i := 0; i < 2; i++ {
+		clientCredParams := CreateAcquireTokenClientCredentialParameters(nil)
+		clientCredParams.SetResource("https://graph.microsoft.com")
+		result, err := cca.AcquireTokenByClientCredential(context.Background(), clientCredParams)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		if result.GetAccessToken() != "secret" {
+			t.Errorf("Access token should be secret, instead it is %s", result.GetAccessToken())
+		}
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenWithClientSecret", 1)
+	for _, call := range testWrm.Calls {
+		if call.Method != "GetAccessTokenWithClientSecret" {
+			continue
+		}
+		authParams := call.Arguments.Get(0).(*msalbase.AuthParametersInternal)
+		if !reflect.DeepEqual(authParams.Scopes, []string{"https://graph.microsoft.com/.default"}) {
+			t.Errorf("Scopes should be the .default scope of the resource, instead they are %v", authParams.Scopes)
+		}
+	}
+}
+
+func TestSetKnownAuthorityHosts(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	for _, authority := range []string{"https://login.known.contoso.com/tenant/", "https://login.unknown.contoso.com/tenant/"} {
+		cca, err := CreateConfidentialClientApplication("clientID", authority, cred)
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		cca.SetInstanceDiscovery(false)
+		cca.SetKnownAuthorityHosts([]string{"login.known.contoso.com"})
+		testWrm := new(requests.MockWebRequestManager)
+		cca.clientApplication.webRequestManager = testWrm
+		testWrm.On("GetTenantDiscoveryResponse", authority+"v2.0/.well-known/openid-configuration").Return(tdr, nil)
+		tokenResp := &msalbase.TokenResponse{
+			AccessToken:   "secret",
+			GrantedScopes: []string{"openid"},
+			ExpiresOn:     time.Now().Add(time.Hour),
+			ExtExpiresOn:  time.Now().Add(time.Hour),
+		}
+		testWrm.On("GetAccessTokenWithClientSecret", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "client_secret").Return(tokenResp, nil)
+		_, err = cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"}))
+		if strings.Contains(authority, "unknown") {
+			if err == nil || !strings.Contains(err.Error(), "isn't one of the known authority hosts") {
+				t.Errorf("The unknown host should be rejected, instead the error is %v", err)
+			}
+			testWrm.AssertNotCalled(t, "GetTenantDiscoveryResponse", mock.Anything)
+			testWrm.AssertNotCalled(t, "GetAccessTokenWithClientSecret", mock.Anything, mock.Anything)
+			if err := cca.ValidateAuthority(context.Background()); err == nil {
+				t.Error("ValidateAuthority should report the unknown host")
+			}
+		} else if err != nil {
+			t.Errorf("The known host should pass, instead the error is %v", err)
+		}
+		testWrm.AssertNotCalled(t, "GetAadinstanceDiscoveryResponse", mock.Anything)
+	}
+}
+
+func TestSetInstanceDiscoveryHeaders(t *testing.T) {
+	cred, err := CreateClientCredentialFromSecret("client_secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.discoveryheaders.contoso.com/tenant/", cred)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca.SetInstanceDiscoveryHeaders(map[string]string{"Authorization": "Bearer gatewayToken"})
+	mockHTTPManager := new(mockHTTPManager)
+	cca.SetHTTPManager(mockHTTPManager)
+	cca.SetRetryPolicy(RetryPolicy{})
+	headersByURL := map[string]map[string]string{}
+	recordHeaders := func(args mock.Arguments) {
+		headersByURL[args.String(0)] = args.Get(len(args) - 1).(map[string]string)
+	}
+	mockHTTPManager.On("Get", mock.MatchedBy(func(url string) bool { return strings.Contains(url, "/discovery/instance") }), mock.Anything).Run(recordHeaders).
+		Return(&msalHTTPManagerResponse{responseCode: 200, responseData: `{"tenant_discovery_endpoint": "https://login.discoveryheaders.contoso.com/tenant/v2.0/.well-known/openid-configuration",
+			"metadata": [{"preferred_network": "login.discoveryheaders.contoso.com", "aliases": ["login.discoveryheaders.contoso.com"]}]}`}, nil)
+	mockHTTPManager.On("Get", "https://login.discoveryheaders.contoso.com/tenant/v2.0/.well-known/openid-configuration", mock.Anything).Run(recordHeaders).
+		Return(&msalHTTPManagerResponse{responseCode: 200, responseData: `{"authorization_endpoint": "https://login.discoveryheaders.contoso.com/tenant/oauth2/v2.0/authorize",
+			"token_endpoint": "https://login.discoveryheaders.contoso.com/tenant/oauth2/v2.0/token", "issuer": "https://login.discoveryheaders.contoso.com/tenant/v2.0"}`}, nil)
+	mockHTTPManager.On("Post", "https://login.discoveryheaders.contoso.com/tenant/oauth2/v2.0/token", mock.Anything, mock.Anything).Run(recordHeaders).
+		Return(&msalHTTPManagerResponse{responseCode: 200, responseData: `{"access_token": "secret", "expires_in": 3600, "scope": "openid"}`}, nil)
+	if _, err := cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"openid"})); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if len(headersByURL) != 3 {
+		t.Fatalf("Instance discovery, tenant discovery and the token request should have been sent, instead the requests were %v", headersByURL)
+	}
+	for url, headers := range headersByURL {
+		isDiscovery := strings.Contains(url, "/discovery/instance")
+		if authorization := headers["Authorization"]; isDiscovery && authorization != "Bearer gatewayToken" {
+			t.Errorf("The instance discovery request should have the configured Authorization header, instead it has %q", authorization)
+		} else if !isDiscovery && authorization != "" {
+			t.Errorf("The request to %s shouldn't have the instance discovery headers, instead its Authorization header is %q", url, authorization)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
+// The v1 resource IDs of common Microsoft resources, they can be passed to SetResource of the parameter types.
+const (
+	// GraphResource is the resource ID of Microsoft Graph.
+	GraphResource = msalbase.GraphResource
+	// ARMResource is the resource ID of Azure Resource Manager.
+	ARMResource = msalbase.ARMResource
+)
+
+// The .default scopes of common Microsoft resources, they can be passed as the scopes of the parameter types,
+// e.g. CreateAcquireTokenClientCredentialParameters([]string{GraphDefaultScope}). A token for one of them is cached
+// under the same scope as a token requested with SetResource for the resource.
+const (
+	// GraphDefaultScope requests all the permissions of Microsoft Graph the app was granted.
+	GraphDefaultScope = msalbase.GraphDefaultScope
+	// ARMDefaultScope requests all the permissions of Azure Resource Manager the app was granted.
+	ARMDefaultScope = msalbase.ARMDefaultScope
+)
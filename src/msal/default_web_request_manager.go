@@ -5,31 +5,78 @@ package msalgo
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/wstrust"
-	log "github.com/sirupsen/logrus"
 )
 
 // defaultWebRequestManager handles the HTTP calls and request building in MSAL
 type defaultWebRequestManager struct {
-	httpManager HTTPManager
+	httpManager     HTTPManager
+	throttlingCache *throttlingCache
+	//telemetryHeaders returns the headers that identify the library and platform, tests replace it to pin the values
+	telemetryHeaders func() map[string]string
+	telemetry        serverTelemetry
+}
+
+// getErrorCode returns the OAuth error code of a CallError, other errors are matched on their message
+func getErrorCode(err error) string {
+	var callErr *CallError
+	if errors.As(err, &callErr) {
+		return callErr.Code
+	}
+	return err.Error()
 }
 
 func isErrorAuthorizationPending(err error) bool {
-	return err.Error() == "authorization_pending"
+	return getErrorCode(err) == "authorization_pending"
 }
 
 func isErrorSlowDown(err error) bool {
-	return err.Error() == "slow_down"
+	return getErrorCode(err) == "slow_down"
+}
+
+func isErrorInvalidGrant(err error) bool {
+	return getErrorCode(err) == "invalid_grant"
+}
+
+// isErrorClientMismatch checks if the authority rejected a family refresh token because the app isn't in the family
+func isErrorClientMismatch(err error) bool {
+	return getErrorCode(err) == "client_mismatch"
+}
+
+func isErrorExpiredToken(err error) bool {
+	return getErrorCode(err) == "expired_token"
+}
+
+// isErrorAuthorityUnreachable checks if the request failed before a response was received from the authority, because it couldn't be connected to
+// or it timed out. A request that was canceled, or whose context is done, didn't find the authority unreachable
+func isErrorAuthorityUnreachable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return isConnectionFailure(err)
+}
+
+// isConnectionFailure checks if the error is a failure to dial the host, including a failed DNS lookup, or a timeout
+func isConnectionFailure(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 type contentType int
@@ -40,13 +87,23 @@ const (
 )
 
 func createWebRequestManager(httpManager HTTPManager) requests.WebRequestManager {
-	m := &defaultWebRequestManager{httpManager}
+	m := &defaultWebRequestManager{httpManager: httpManager, throttlingCache: createThrottlingCache(), telemetryHeaders: getTelemetryHeaders}
 	return m
 }
 
-func (wrm *defaultWebRequestManager) GetUserRealm(authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error) {
+// getTelemetryHeaders returns the standard MSAL headers AAD telemetry uses to tell the SKU, version and platform apart
+func getTelemetryHeaders() map[string]string {
+	return map[string]string{
+		msalbase.ProductHeaderName: msalbase.ProductHeaderValue,
+		msalbase.VersionHeaderName: msalbase.LibraryVersion,
+		msalbase.CPUHeaderName:     runtime.GOARCH,
+		msalbase.OSHeaderName:      runtime.GOOS,
+	}
+}
+
+func (wrm *defaultWebRequestManager) GetUserRealm(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.UserRealm, error) {
 	url := authParameters.Endpoints.GetUserRealmEndpoint(authParameters.Username)
-	httpManagerResponse, err := wrm.httpManager.Get(url, getAadHeaders(authParameters))
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, url, wrm.getAadHeaders(authParameters))
 	if err != nil {
 		return nil, err
 	}
@@ -58,8 +115,8 @@ func (wrm *defaultWebRequestManager) GetUserRealm(authParameters *msalbase.AuthP
 	return msalbase.CreateUserRealm(httpManagerResponse.GetResponseData())
 }
 
-func (wrm *defaultWebRequestManager) GetMex(federationMetadataURL string) (*wstrust.MexDocument, error) {
-	httpManagerResponse, err := wrm.httpManager.Get(federationMetadataURL, nil)
+func (wrm *defaultWebRequestManager) GetMex(ctx context.Context, federationMetadataURL string) (*wstrust.MexDocument, error) {
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, federationMetadataURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +129,7 @@ func (wrm *defaultWebRequestManager) GetMex(federationMetadataURL string) (*wstr
 }
 
 func (wrm *defaultWebRequestManager) GetWsTrustResponse(
+	ctx context.Context,
 	authParameters *msalbase.AuthParametersInternal,
 	cloudAudienceURN string,
 	endpoint *wstrust.Endpoint) (*wstrust.Response, error) {
@@ -106,7 +164,7 @@ func (wrm *defaultWebRequestManager) GetWsTrustResponse(
 
 	addContentTypeHeader(headers, soapXMLUtf8)
 
-	response, err := wrm.httpManager.Post(endpoint.URL, wsTrustRequestMessage, headers)
+	response, err := wrm.httpManager.Post(ctx, endpoint.URL, wsTrustRequestMessage, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +172,7 @@ func (wrm *defaultWebRequestManager) GetWsTrustResponse(
 	return wstrust.CreateWsTrustResponse(response.GetResponseData()), nil
 }
 
-func (wrm *defaultWebRequestManager) GetAccessTokenFromSamlGrant(authParameters *msalbase.AuthParametersInternal, samlGrant *wstrust.SamlTokenInfo) (*msalbase.TokenResponse, error) {
+func (wrm *defaultWebRequestManager) GetAccessTokenFromSamlGrant(ctx context.Context, authParameters *msalbase.AuthParametersInternal, samlGrant *wstrust.SamlTokenInfo) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type": msalbase.PasswordGrant,
 		"username":   authParameters.Username,
@@ -138,10 +196,11 @@ func (wrm *defaultWebRequestManager) GetAccessTokenFromSamlGrant(authParameters
 	addScopeQueryParam(decodedQueryParams, authParameters)
 	addClientInfoQueryParam(decodedQueryParams)
 
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
 func (wrm *defaultWebRequestManager) GetAccessTokenFromUsernamePassword(
+	ctx context.Context,
 	authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type": msalbase.PasswordGrant,
@@ -152,21 +211,25 @@ func (wrm *defaultWebRequestManager) GetAccessTokenFromUsernamePassword(
 	addClientIDQueryParam(decodedQueryParams, authParameters)
 	addScopeQueryParam(decodedQueryParams, authParameters)
 	addClientInfoQueryParam(decodedQueryParams)
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
-func (wrm *defaultWebRequestManager) GetDeviceCodeResult(authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error) {
+func (wrm *defaultWebRequestManager) GetDeviceCodeResult(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.DeviceCodeResult, error) {
 	decodedQueryParams := map[string]string{}
 
 	addClientIDQueryParam(decodedQueryParams, authParameters)
 	addScopeQueryParam(decodedQueryParams, authParameters)
+	if err := addClaimsQueryParam(decodedQueryParams, authParameters); err != nil {
+		return nil, err
+	}
+	addExtraQueryParams(decodedQueryParams, authParameters)
 
 	deviceCodeEndpoint := strings.Replace(authParameters.Endpoints.TokenEndpoint, "token", "devicecode", -1)
 
-	headers := getAadHeaders(authParameters)
+	headers := wrm.getAadHeaders(authParameters)
 	addContentTypeHeader(headers, urlEncodedUtf8)
 
-	response, err := wrm.httpManager.Post(
+	response, err := wrm.httpManager.Post(ctx,
 		deviceCodeEndpoint, encodeQueryParameters(decodedQueryParams), headers)
 	if err != nil {
 		return nil, err
@@ -180,6 +243,7 @@ func (wrm *defaultWebRequestManager) GetDeviceCodeResult(authParameters *msalbas
 }
 
 func (wrm *defaultWebRequestManager) GetAccessTokenFromDeviceCodeResult(
+	ctx context.Context,
 	authParameters *msalbase.AuthParametersInternal, deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type":  msalbase.DeviceCodeGrant,
@@ -190,7 +254,7 @@ func (wrm *defaultWebRequestManager) GetAccessTokenFromDeviceCodeResult(
 	addClientInfoQueryParam(decodedQueryParams)
 	addScopeQueryParam(decodedQueryParams, authParameters)
 
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
 func addClientIDQueryParam(queryParams map[string]string, authParameters *msalbase.AuthParametersInternal) {
@@ -198,13 +262,87 @@ func addClientIDQueryParam(queryParams map[string]string, authParameters *msalba
 }
 
 func addScopeQueryParam(queryParams map[string]string, authParameters *msalbase.AuthParametersInternal) {
-	log.Info("Adding scopes 'openid', 'offline_access', 'profile'")
+	msalbase.GetLogger().Info("Adding scopes 'openid', 'offline_access', 'profile'")
 	requestedScopes := authParameters.Scopes
 	// openid required to get an id token
 	// offline_access required to get a refresh token
 	// profile required to get the client_info field back
-	requestedScopes = append(requestedScopes, "openid", "offline_access", "profile")
-	queryParams["scope"] = msalbase.ConcatenateScopes(requestedScopes)
+	// the reserved scopes aren't sent twice when the app asked for them too
+	requestedScopes = append(requestedScopes[:len(requestedScopes):len(requestedScopes)], "openid", "offline_access", "profile")
+	queryParams["scope"] = msalbase.ConcatenateScopes(msalbase.NormalizeScopes(requestedScopes))
+}
+
+//reservedQueryParameters are the parameters of the protocol, extra query parameters can't replace them even when MSAL doesn't send them
+//with a request, e.g. claims and nonce are only sent when they're set
+var reservedQueryParameters = map[string]bool{
+	"assertion":             true,
+	"claims":                true,
+	"client_assertion":      true,
+	"client_assertion_type": true,
+	"client_id":             true,
+	"client_info":           true,
+	"client_secret":         true,
+	"code":                  true,
+	"code_challenge":        true,
+	"code_challenge_method": true,
+	"code_verifier":         true,
+	"device_code":           true,
+	"grant_type":            true,
+	"nonce":                 true,
+	"password":              true,
+	"redirect_uri":          true,
+	"refresh_token":         true,
+	"req_cnf":               true,
+	"requested_token_use":   true,
+	"response_type":         true,
+	"scope":                 true,
+	"state":                 true,
+	"token_type":            true,
+	"username":              true,
+}
+
+//isReservedQueryParameter checks whether an extra query parameter would replace a parameter of the protocol, names are compared without case
+func isReservedQueryParameter(name string) bool {
+	if reservedQueryParameters[strings.ToLower(name)] {
+		msalbase.GetLogger().Warnf("The extra query parameter %s is dropped, it's set by MSAL", name)
+		return true
+	}
+	return false
+}
+
+//addExtraQueryParams adds the extra query parameters of the request that the request doesn't already have
+func addExtraQueryParams(queryParams map[string]string, authParameters *msalbase.AuthParametersInternal) {
+	for name, value := range authParameters.ExtraQueryParameters {
+		if _, ok := queryParams[name]; ok || isReservedQueryParameter(name) {
+			continue
+		}
+		queryParams[name] = value
+	}
+}
+
+//addClaimsQueryParam sends the claims challenge of the request, with the client capabilities of the app merged into it
+func addClaimsQueryParam(queryParams map[string]string, authParameters *msalbase.AuthParametersInternal) error {
+	claims, err := msalbase.MergeClientCapabilities(authParameters.Claims, authParameters.ClientCapabilities)
+	if err != nil {
+		return err
+	}
+	if claims != "" {
+		queryParams["claims"] = claims
+	}
+	return nil
+}
+
+func addPoPQueryParams(queryParams map[string]string, authParameters *msalbase.AuthParametersInternal) error {
+	if authParameters.PoPKey == nil {
+		return nil
+	}
+	reqCnf, err := authParameters.PoPKey.GetReqCnf()
+	if err != nil {
+		return err
+	}
+	queryParams["token_type"] = msalbase.PoPTokenType
+	queryParams["req_cnf"] = reqCnf
+	return nil
 }
 
 func addClientInfoQueryParam(queryParams map[string]string) {
@@ -228,12 +366,16 @@ func addContentTypeHeader(headers map[string]string, contentType contentType) {
 	}
 }
 
-func getAadHeaders(authParameters *msalbase.AuthParametersInternal) map[string]string {
+func (wrm *defaultWebRequestManager) getAadHeaders(authParameters *msalbase.AuthParametersInternal) map[string]string {
+	telemetryHeaders := wrm.telemetryHeaders
+	if telemetryHeaders == nil {
+		telemetryHeaders = getTelemetryHeaders
+	}
 	headers := make(map[string]string)
-
-	headers[msalbase.ProductHeaderName] = msalbase.ProductHeaderValue
-	headers[msalbase.OSHeaderName] = runtime.GOOS
-	// headers["x-client-Ver"] = todo: client version here;
+	for k, v := range telemetryHeaders() {
+		headers[k] = v
+	}
+	wrm.telemetry.addHeaders(headers)
 	headers[msalbase.CorrelationIDHeaderName] = authParameters.CorrelationID
 	headers[msalbase.ReqCorrelationIDInResponseHeaderName] = "false"
 	return headers
@@ -260,18 +402,70 @@ func encodeQueryParameters(queryParameters map[string]string) string {
 	return result
 }
 
-func (wrm *defaultWebRequestManager) exchangeGrantForToken(authParameters *msalbase.AuthParametersInternal, queryParams map[string]string) (*msalbase.TokenResponse, error) {
-	headers := getAadHeaders(authParameters)
+func (wrm *defaultWebRequestManager) exchangeGrantForToken(ctx context.Context, authParameters *msalbase.AuthParametersInternal, queryParams map[string]string) (_ *msalbase.TokenResponse, err error) {
+	ctx, span := msalbase.StartSpan(ctx, msalbase.TokenRequestSpan)
+	defer func() { span.End(err) }()
+	if authParameters.AuthorityInfo != nil {
+		span.SetAttribute(msalbase.AuthorityAttribute, authParameters.AuthorityInfo.Host)
+	}
+	span.SetAttribute(msalbase.ClientIDAttribute, authParameters.ClientID)
+	if err := wrm.throttlingCache.checkThrottled(authParameters); err != nil {
+		return nil, err
+	}
+	if err := addClaimsQueryParam(queryParams, authParameters); err != nil {
+		return nil, err
+	}
+	if err := addPoPQueryParams(queryParams, authParameters); err != nil {
+		return nil, err
+	}
+	addExtraQueryParams(queryParams, authParameters)
+	headers := wrm.getAadHeaders(authParameters)
 	addContentTypeHeader(headers, urlEncodedUtf8)
 
-	response, err := wrm.httpManager.Post(authParameters.Endpoints.TokenEndpoint, encodeQueryParameters(queryParams), headers)
+	start := time.Now()
+	response, err := wrm.httpManager.Post(ctx, authParameters.Endpoints.TokenEndpoint, encodeQueryParameters(queryParams), headers)
 	if err != nil {
+		msalbase.GetMetrics().OnTokenRequest(time.Since(start), false)
+		wrm.telemetry.recordFailure(authParameters, err)
 		return nil, err
 	}
-	return msalbase.CreateTokenResponse(authParameters, response.GetResponseCode(), response.GetResponseData())
+	wrm.throttlingCache.recordResponse(authParameters, response)
+	tokenResponse, err := msalbase.CreateTokenResponse(authParameters, response.GetResponseCode(), response.GetResponseData())
+	msalbase.GetMetrics().OnTokenRequest(time.Since(start), err == nil)
+	if err != nil {
+		wrm.telemetry.recordFailure(authParameters, err)
+		return nil, getAdminConsentRequiredError(err)
+	}
+	wrm.telemetry.recordSuccess(authParameters)
+	return tokenResponse, nil
 }
 
-func (wrm *defaultWebRequestManager) GetAccessTokenFromAuthCode(authParameters *msalbase.AuthParametersInternal,
+//consentRequiredErrorCode is the AADSTS code of the error for an app no one has consented to yet
+const consentRequiredErrorCode = 65001
+
+//getAdminConsentRequiredError returns an AdminConsentRequiredError when the token endpoint answered that consent is required, err otherwise
+func getAdminConsentRequiredError(err error) error {
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		return err
+	}
+	consentRequired := callErr.Code == "consent_required"
+	for _, code := range callErr.ErrorCodes {
+		if code == consentRequiredErrorCode {
+			consentRequired = true
+		}
+	}
+	if !consentRequired {
+		return err
+	}
+	description := callErr.Description
+	if description == "" {
+		description = callErr.Code
+	}
+	return &AdminConsentRequiredError{Description: description, CorrelationID: callErr.CorrelationID, err: callErr}
+}
+
+func (wrm *defaultWebRequestManager) GetAccessTokenFromAuthCode(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	authCode string,
 	codeVerifier string,
 	params map[string]string) (*msalbase.TokenResponse, error) {
@@ -288,10 +482,10 @@ func (wrm *defaultWebRequestManager) GetAccessTokenFromAuthCode(authParameters *
 	addScopeQueryParam(decodedQueryParams, authParameters)
 	addClientInfoQueryParam(decodedQueryParams)
 
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
-func (wrm *defaultWebRequestManager) GetAccessTokenFromRefreshToken(authParameters *msalbase.AuthParametersInternal,
+func (wrm *defaultWebRequestManager) GetAccessTokenFromRefreshToken(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
 	refreshToken string, params map[string]string) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type":    msalbase.RefreshTokenGrant,
@@ -304,20 +498,20 @@ func (wrm *defaultWebRequestManager) GetAccessTokenFromRefreshToken(authParamete
 	addScopeQueryParam(decodedQueryParams, authParameters)
 	addClientInfoQueryParam(decodedQueryParams)
 
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
-func (wrm *defaultWebRequestManager) GetAccessTokenWithClientSecret(authParameters *msalbase.AuthParametersInternal, clientSecret string) (*msalbase.TokenResponse, error) {
+func (wrm *defaultWebRequestManager) GetAccessTokenWithClientSecret(ctx context.Context, authParameters *msalbase.AuthParametersInternal, clientSecret string) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type":    msalbase.ClientCredentialGrant,
 		"client_secret": clientSecret,
 	}
 	addClientIDQueryParam(decodedQueryParams, authParameters)
 	addScopeQueryParam(decodedQueryParams, authParameters)
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
-func (wrm *defaultWebRequestManager) GetAccessTokenWithAssertion(authParameters *msalbase.AuthParametersInternal, assertion string) (*msalbase.TokenResponse, error) {
+func (wrm *defaultWebRequestManager) GetAccessTokenWithAssertion(ctx context.Context, authParameters *msalbase.AuthParametersInternal, assertion string) (*msalbase.TokenResponse, error) {
 	decodedQueryParams := map[string]string{
 		"grant_type":            msalbase.ClientCredentialGrant,
 		"client_assertion_type": msalbase.ClientAssertionGrant,
@@ -326,26 +520,44 @@ func (wrm *defaultWebRequestManager) GetAccessTokenWithAssertion(authParameters
 
 	addScopeQueryParam(decodedQueryParams, authParameters)
 	addClientInfoQueryParam(decodedQueryParams)
-	return wrm.exchangeGrantForToken(authParameters, decodedQueryParams)
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
+}
+
+func (wrm *defaultWebRequestManager) GetAccessTokenOnBehalfOf(ctx context.Context, authParameters *msalbase.AuthParametersInternal,
+	userAssertion string,
+	params map[string]string) (*msalbase.TokenResponse, error) {
+	decodedQueryParams := map[string]string{
+		"grant_type":          msalbase.JWTBearerGrant,
+		"assertion":           userAssertion,
+		"requested_token_use": "on_behalf_of",
+	}
+	for k, v := range params {
+		decodedQueryParams[k] = v
+	}
+	addClientIDQueryParam(decodedQueryParams, authParameters)
+	addScopeQueryParam(decodedQueryParams, authParameters)
+	addClientInfoQueryParam(decodedQueryParams)
+
+	return wrm.exchangeGrantForToken(ctx, authParameters, decodedQueryParams)
 }
 
 func (wrm *defaultWebRequestManager) GetAadinstanceDiscoveryResponse(
-	authorityInfo *msalbase.AuthorityInfo) (*requests.InstanceDiscoveryResponse, error) {
+	ctx context.Context,
+	authorityInfo *msalbase.AuthorityInfo) (response *requests.InstanceDiscoveryResponse, err error) {
+	ctx, span := msalbase.StartSpan(ctx, msalbase.InstanceDiscoverySpan)
+	defer func() { span.End(err) }()
+	span.SetAttribute(msalbase.AuthorityAttribute, authorityInfo.Host)
 
 	queryParams := map[string]string{
 		"api-version":            "1.1",
 		"authorization_endpoint": fmt.Sprintf(msalbase.AuthorizationEndpoint, authorityInfo.Host, authorityInfo.Tenant),
 	}
 
-	var discoveryHost string
-	if requests.IsInTrustedHostList(authorityInfo.Host) {
-		discoveryHost = authorityInfo.Host
-	} else {
-		discoveryHost = msalbase.DefaultHost
-	}
+	discoveryHost := authorityInfo.GetInstanceDiscoveryHost(requests.IsInTrustedHostList(authorityInfo.Host))
 
 	instanceDiscoveryEndpoint := fmt.Sprintf(msalbase.InstanceDiscoveryEndpoint, discoveryHost, encodeQueryParameters(queryParams))
-	httpManagerResponse, err := wrm.httpManager.Get(instanceDiscoveryEndpoint, nil)
+	// Only the discovery request gets the configured headers, they aren't sent to the token endpoint
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, instanceDiscoveryEndpoint, authorityInfo.InstanceDiscoveryHeaders)
 	if err != nil {
 		return nil, err
 	}
@@ -358,12 +570,58 @@ func (wrm *defaultWebRequestManager) GetAadinstanceDiscoveryResponse(
 }
 
 func (wrm *defaultWebRequestManager) GetTenantDiscoveryResponse(
+	ctx context.Context,
 	openIDConfigurationEndpoint string) (*requests.TenantDiscoveryResponse, error) {
 
-	httpManagerResponse, err := wrm.httpManager.Get(openIDConfigurationEndpoint, nil)
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, openIDConfigurationEndpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	return requests.CreateTenantDiscoveryResponse(httpManagerResponse.GetResponseCode(), httpManagerResponse.GetResponseData())
 }
+
+func (wrm *defaultWebRequestManager) GetJSONWebKeySet(ctx context.Context, jwksURI string) (*requests.JSONWebKeySet, error) {
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return requests.CreateJSONWebKeySet(httpManagerResponse.GetResponseCode(), httpManagerResponse.GetResponseData())
+}
+
+// GetAzureRegion asks the instance metadata service of the Azure VM which region the VM is in
+func (wrm *defaultWebRequestManager) GetAzureRegion(ctx context.Context) (string, error) {
+	httpManagerResponse, err := wrm.httpManager.Get(ctx, msalbase.IMDSRegionEndpoint, map[string]string{"Metadata": "true"})
+	if err != nil {
+		return "", err
+	}
+	if httpManagerResponse.GetResponseCode() != 200 {
+		return "", fmt.Errorf("instance metadata service answered with HTTP %d", httpManagerResponse.GetResponseCode())
+	}
+	return strings.TrimSpace(httpManagerResponse.GetResponseData()), nil
+}
+
+// GetAccessTokenFromManagedIdentity requests a token for the resource from the managed identity endpoint of the Azure resource,
+// the App Service endpoint is used when the app runs on App Service or Functions, otherwise the instance metadata service of the Azure VM
+func (wrm *defaultWebRequestManager) GetAccessTokenFromManagedIdentity(ctx context.Context, authParameters *msalbase.AuthParametersInternal) (*msalbase.TokenResponse, error) {
+	if len(authParameters.Scopes) != 1 || authParameters.Scopes[0] == "" {
+		return nil, errors.New("a managed identity token is requested for exactly one resource")
+	}
+	source := getManagedIdentitySource()
+	query := url.Values{}
+	query.Set("api-version", source.apiVersion)
+	query.Set("resource", authParameters.Scopes[0])
+	if param, ok := source.idQueryParams[authParameters.ManagedIdentity.IDType]; ok {
+		query.Set(param, authParameters.ManagedIdentity.ID)
+	}
+	start := time.Now()
+	response, err := wrm.httpManager.Get(ctx, source.endpoint+"?"+query.Encode(), source.headers)
+	if err != nil {
+		msalbase.GetMetrics().OnTokenRequest(time.Since(start), false)
+		return nil, err
+	}
+	tokenResponse, err := msalbase.CreateManagedIdentityTokenResponse(authParameters, response.GetResponseCode(), response.GetResponseData())
+	msalbase.GetMetrics().OnTokenRequest(time.Since(start), err == nil)
+	return tokenResponse, err
+}
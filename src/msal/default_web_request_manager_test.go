@@ -4,9 +4,14 @@
 package msalgo
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -14,16 +19,22 @@ import (
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/wstrust"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
 )
 
 var testHeaders = map[string]string{
 	"x-client-SKU":             "MSAL.Go",
+	"x-client-Ver":             msalbase.LibraryVersion,
+	"x-client-CPU":             runtime.GOARCH,
 	"x-client-OS":              runtime.GOOS,
 	"client-request-id":        "",
 	"return-client-request-id": "false",
 }
 var testHeadersWURLUTF8 = map[string]string{
 	"x-client-SKU":             "MSAL.Go",
+	"x-client-Ver":             msalbase.LibraryVersion,
+	"x-client-CPU":             runtime.GOARCH,
 	"x-client-OS":              runtime.GOOS,
 	"client-request-id":        "",
 	"return-client-request-id": "false",
@@ -78,7 +89,7 @@ func TestGetUserRealm(t *testing.T) {
 		CloudAudienceURN:  "URN",
 		CloudInstanceName: "cloudInst",
 	}
-	actualRealm, err := wrm.GetUserRealm(authParams)
+	actualRealm, err := wrm.GetUserRealm(context.Background(), authParams)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
@@ -115,7 +126,7 @@ func TestGetAccessTokenFromUsernamePassword(t *testing.T) {
 	}
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", encodeQueryParameters(paramMap), testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenFromUsernamePassword(authParams)
+	actualToken, err := wrm.GetAccessTokenFromUsernamePassword(context.Background(), authParams)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -152,7 +163,7 @@ func TestGetAccessTokenFromSAMLGrant(t *testing.T) {
 		"scope=openid+offline_access+profile&username=username"
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", encodedParams, testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenFromSamlGrant(authParams, samlGrant)
+	actualToken, err := wrm.GetAccessTokenFromSamlGrant(context.Background(), authParams, samlGrant)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -177,7 +188,7 @@ func TestGetDeviceCodeResult(t *testing.T) {
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/devicecode",
 		"client_id=&scope=openid+offline_access+profile", testHeadersWURLUTF8).Return(response, nil)
-	_, err := wrm.GetDeviceCodeResult(authParams)
+	_, err := wrm.GetDeviceCodeResult(context.Background(), authParams)
 	if err != nil {
 		t.Errorf("Error should be nil, but is %v", err)
 	}
@@ -203,7 +214,7 @@ func TestGetAccessTokenFromAuthCode(t *testing.T) {
 		"grant_type=authorization_code&redirect_uri=&scope=openid+offline_access+profile"
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenFromAuthCode(authParams, "code", "ver", map[string]string{})
+	actualToken, err := wrm.GetAccessTokenFromAuthCode(context.Background(), authParams, "code", "ver", map[string]string{})
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -233,7 +244,7 @@ func TestGetAccessTokenFromRefreshToken(t *testing.T) {
 	params := "client_id=&client_info=1&grant_type=refresh_token&refresh_token=secret&scope=openid+offline_access+profile"
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenFromRefreshToken(authParams, "secret", map[string]string{})
+	actualToken, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "secret", map[string]string{})
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -263,7 +274,7 @@ func TestGetAccessTokenWithClientSecret(t *testing.T) {
 	params := "client_id=&client_secret=csecret&grant_type=client_credentials&scope=openid+offline_access+profile"
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenWithClientSecret(authParams, "csecret")
+	actualToken, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret")
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -274,6 +285,119 @@ func TestGetAccessTokenWithClientSecret(t *testing.T) {
 	}
 }
 
+func TestGetAccessTokenWithDefaultScopes(t *testing.T) {
+	tests := map[string]string{
+		GraphDefaultScope: "scope=https%3A%2F%2Fgraph.microsoft.com%2F.default+openid+offline_access+profile",
+		ARMDefaultScope:   "scope=https%3A%2F%2Fmanagement.azure.com%2F.default+openid+offline_access+profile",
+	}
+	for defaultScope, scopeParam := range tests {
+		mockHTTPManager := new(mockHTTPManager)
+		wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+		// The constant and SetResource for the resource send the same body
+		withScope := CreateAcquireTokenClientCredentialParameters([]string{defaultScope})
+		withResource := CreateAcquireTokenClientCredentialParameters(nil)
+		withResource.SetResource(strings.TrimSuffix(defaultScope, "/.default"))
+		for _, clientCredParams := range []*AcquireTokenClientCredentialParameters{withScope, withResource} {
+			authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints}
+			if err := clientCredParams.augmentAuthenticationParameters(authParams); err != nil {
+				t.Fatalf("Error should be nil, but it is %v", err)
+			}
+			response := &msalHTTPManagerResponse{responseCode: 200, responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`}
+			params := "client_id=&client_secret=csecret&grant_type=client_credentials&" + scopeParam
+			// The headers have the telemetry of the parameters, only the body is checked
+			mockHTTPManager.On("Post", "https://login.microsoftonline.com/v2.0/token", params, mock.Anything).Return(response, nil)
+			if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret"); err != nil {
+				t.Errorf("The body should have the scope %s, but the error is %v", defaultScope, err)
+			}
+		}
+	}
+}
+
+type recordingMetrics struct {
+	requests  int
+	successes int
+}
+
+func (m *recordingMetrics) OnCacheHit()  {}
+func (m *recordingMetrics) OnCacheMiss() {}
+func (m *recordingMetrics) OnTokenRequest(duration time.Duration, success bool) {
+	m.requests++
+	if success {
+		m.successes++
+	}
+}
+
+func TestTokenRequestMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	SetMetrics(metrics)
+	defer SetMetrics(nil)
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints}
+	success := &msalHTTPManagerResponse{responseCode: 200, responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`}
+	failure := &msalHTTPManagerResponse{responseCode: 400, responseData: `{"error":"invalid_client"}`}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(success, nil).Once()
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(failure, nil).Once()
+	if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret"); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret"); err == nil {
+		t.Fatal("Error should be returned for the invalid_client response")
+	}
+	if metrics.requests != 2 || metrics.successes != 1 {
+		t.Errorf("2 token requests and 1 success should be reported, instead %d requests and %d successes are", metrics.requests, metrics.successes)
+	}
+}
+
+func TestExtraQueryParameters(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{
+		Endpoints: testAuthorityEndpoints,
+		ExtraQueryParameters: map[string]string{
+			"slice":         "testslice",
+			"grant_type":    "password",
+			"Client_Secret": "injected",
+			"claims":        "{}",
+		},
+	}
+	response := &msalHTTPManagerResponse{responseCode: 200, responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`}
+	params := "client_id=&client_secret=csecret&grant_type=client_credentials&scope=openid+offline_access+profile&slice=testslice"
+	mockHTTPManager.On("Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
+	if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret"); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	mockHTTPManager.AssertExpectations(t)
+}
+
+func TestAdminConsentRequiredError(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints}
+	consentBody := `{"error":"invalid_grant","error_description":"AADSTS65001: The user or administrator has not consented to use the application.",` +
+		`"error_codes":[65001],"correlation_id":"cid"}`
+	consent := &msalHTTPManagerResponse{responseCode: 400, responseData: consentBody}
+	other := &msalHTTPManagerResponse{responseCode: 400, responseData: `{"error":"invalid_grant","error_codes":[70000]}`}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(consent, nil).Once()
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(other, nil).Once()
+	_, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret")
+	var consentErr *AdminConsentRequiredError
+	if !errors.As(err, &consentErr) {
+		t.Fatalf("Error should be an AdminConsentRequiredError, instead it is %v", err)
+	}
+	if consentErr.CorrelationID != "cid" || !strings.HasPrefix(consentErr.Description, "AADSTS65001") {
+		t.Errorf("Error should carry the description and correlation ID of the response, instead it is %+v", consentErr)
+	}
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.StatusCode != 400 {
+		t.Errorf("Error should wrap the CallError of the response, instead it is %v", err)
+	}
+	_, err = wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "csecret")
+	if errors.As(err, &consentErr) {
+		t.Errorf("Other errors shouldn't be AdminConsentRequiredErrors, instead the error is %v", err)
+	}
+}
+
 func TestGetAccessTokenWithAssertion(t *testing.T) {
 	mockHTTPManager := new(mockHTTPManager)
 	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
@@ -294,7 +418,7 @@ func TestGetAccessTokenWithAssertion(t *testing.T) {
 		"&client_info=1&grant_type=client_credentials&scope=openid+offline_access+profile"
 	mockHTTPManager.On(
 		"Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
-	actualToken, err := wrm.GetAccessTokenWithAssertion(authParams, "assertion")
+	actualToken, err := wrm.GetAccessTokenWithAssertion(context.Background(), authParams, "assertion")
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
@@ -305,6 +429,32 @@ func TestGetAccessTokenWithAssertion(t *testing.T) {
 	}
 }
 
+func TestGetAccessTokenOnBehalfOf(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{
+		Endpoints: testAuthorityEndpoints,
+		ClientID:  "clientID",
+	}
+	respData := `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: respData,
+	}
+	params := "assertion=userAssertion&client_id=clientID&client_info=1&client_secret=clientSecret" +
+		"&grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Ajwt-bearer&requested_token_use=on_behalf_of" +
+		"&scope=openid+offline_access+profile"
+	mockHTTPManager.On(
+		"Post", "https://login.microsoftonline.com/v2.0/token", params, testHeadersWURLUTF8).Return(response, nil)
+	actualToken, err := wrm.GetAccessTokenOnBehalfOf(context.Background(), authParams, "userAssertion", map[string]string{"client_secret": "clientSecret"})
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if actualToken.AccessToken != "secret" {
+		t.Errorf("Access token should be secret, instead it is %v", actualToken.AccessToken)
+	}
+}
+
 func TestGetAadInstanceDiscoveryResponse(t *testing.T) {
 	mockHTTPManager := new(mockHTTPManager)
 	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
@@ -322,7 +472,7 @@ func TestGetAadInstanceDiscoveryResponse(t *testing.T) {
 	var params map[string]string = nil
 	mockHTTPManager.On("Get", instanceDiscEndpoint, params).Return(response, nil)
 	expIDR := &requests.InstanceDiscoveryResponse{}
-	actIDR, err := wrm.GetAadinstanceDiscoveryResponse(authInfo)
+	actIDR, err := wrm.GetAadinstanceDiscoveryResponse(context.Background(), authInfo)
 
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
@@ -332,6 +482,28 @@ func TestGetAadInstanceDiscoveryResponse(t *testing.T) {
 	}
 }
 
+func TestGetAadInstanceDiscoveryResponseNationalCloud(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authInfo := &msalbase.AuthorityInfo{
+		Host:   "login.private.contoso.us",
+		Tenant: "tenant",
+		Cloud:  msalbase.CloudInstanceUSGovernment,
+	}
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{}`,
+	}
+	instanceDiscEndpoint := "https://login.microsoftonline.us/common/discovery/instance?api-version=1.1&" +
+		"authorization_endpoint=https%3A%2F%2Flogin.private.contoso.us%2Ftenant%2Foauth2%2Fv2.0%2Fauthorize"
+	var params map[string]string = nil
+	mockHTTPManager.On("Get", instanceDiscEndpoint, params).Return(response, nil)
+	if _, err := wrm.GetAadinstanceDiscoveryResponse(context.Background(), authInfo); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	mockHTTPManager.AssertCalled(t, "Get", instanceDiscEndpoint, params)
+}
+
 func TestGetTenantDiscoveryResponse(t *testing.T) {
 	mockHTTPManager := new(mockHTTPManager)
 	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
@@ -343,8 +515,157 @@ func TestGetTenantDiscoveryResponse(t *testing.T) {
 	openIDEndpoint := "endpoint"
 	var params map[string]string = nil
 	mockHTTPManager.On("Get", openIDEndpoint, params).Return(response, nil)
-	_, err := wrm.GetTenantDiscoveryResponse(openIDEndpoint)
+	_, err := wrm.GetTenantDiscoveryResponse(context.Background(), openIDEndpoint)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestExchangeGrantForTokenCallError(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints}
+	respData := `{"error":"interaction_required","error_description":"AADSTS50076: MFA required","error_codes":[50076],"suberror":"basic_action"}`
+	response := &msalHTTPManagerResponse{responseCode: 400, responseData: respData}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(response, nil)
+	_, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil)
+	var callErr *CallError
+	if !errors.As(err, &callErr) {
+		t.Fatalf("Expected a CallError, but the error is %v", err)
+	}
+	if callErr.Code != "interaction_required" || callErr.SubError != "basic_action" || callErr.StatusCode != 400 || callErr.Body != respData {
+		t.Errorf("Actual error %+v doesn't match the error response", callErr)
+	}
+	if isErrorInvalidGrant(err) {
+		t.Error("interaction_required shouldn't be treated as invalid_grant")
+	}
+}
+
+func TestExchangeGrantForTokenSendsClaims(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	claims := `{"access_token":{"xms_cc":{"values":["cp1"]}}}`
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints, Claims: claims}
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`,
+	}
+	withClaims := mock.MatchedBy(func(body string) bool { return strings.Contains(body, "claims="+url.QueryEscape(claims)) })
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, withClaims, mock.Anything).Return(response, nil)
+	if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+}
+
+func TestExchangeGrantForTokenMergesClientCapabilities(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	challenge := `{"access_token":{"nbf":{"essential":true,"value":"1604106651"}}}`
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints, Claims: challenge, ClientCapabilities: []string{"cp1"}}
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`,
+	}
+	var sentClaims string
+	recordClaims := func(args mock.Arguments) {
+		values, _ := url.ParseQuery(args.String(1))
+		sentClaims = values.Get("claims")
+	}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Run(recordClaims).Return(response, nil)
+	if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	claims := map[string]map[string]interface{}{}
+	if err := json.Unmarshal([]byte(sentClaims), &claims); err != nil {
+		t.Fatalf("The claims %s should be JSON, but the error is %v", sentClaims, err)
+	}
+	if _, ok := claims["access_token"]["nbf"]; !ok {
+		t.Errorf("The claims challenge should be kept, instead the claims are %s", sentClaims)
+	}
+	if !reflect.DeepEqual(claims["access_token"]["xms_cc"], map[string]interface{}{"values": []interface{}{"cp1"}}) {
+		t.Errorf("The client capabilities should be merged into the claims, instead the claims are %s", sentClaims)
+	}
+	if authParams.Claims != challenge {
+		t.Errorf("The claims challenge of the request shouldn't be changed, instead it is %s", authParams.Claims)
+	}
+}
+
+func TestExchangeGrantForTokenSendsTelemetryHeaders(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	if headers := wrm.getAadHeaders(&msalbase.AuthParametersInternal{}); headers["x-client-SKU"] != "MSAL.Go" || headers["x-client-CPU"] != runtime.GOARCH {
+		t.Errorf("Default telemetry headers should identify MSAL Go and the platform, instead they are %v", headers)
+	}
+	wrm.telemetryHeaders = func() map[string]string {
+		return map[string]string{"x-client-SKU": "MSAL.Go", "x-client-Ver": "1.2.3", "x-client-CPU": "testarch", "x-client-OS": "testos"}
+	}
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints, AuthorizationType: msalbase.AuthorizationTypeRefreshTokenExchange}
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`,
+	}
+	var sent []map[string]string
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { sent = append(sent, args.Get(2).(map[string]string)) }).Return(response, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+	}
+	for name, expected := range map[string]string{"x-client-SKU": "MSAL.Go", "x-client-Ver": "1.2.3", "x-client-CPU": "testarch", "x-client-OS": "testos"} {
+		if sent[0][name] != expected {
+			t.Errorf("Header %s should be %s, instead it is %s", name, expected, sent[0][name])
+		}
+	}
+	if telemetry, ok := sent[0]["x-client-current-telemetry"]; ok {
+		t.Errorf("The first request shouldn't describe a previous call, instead it sent %s", telemetry)
+	}
+	expectedTelemetry := "2|" + strconv.Itoa(int(msalbase.AuthorizationTypeRefreshTokenExchange)) + ",0|"
+	if sent[1]["x-client-current-telemetry"] != expectedTelemetry {
+		t.Errorf("Current telemetry should be %s, instead it is %s", expectedTelemetry, sent[1]["x-client-current-telemetry"])
+	}
+}
+
+func TestExchangeGrantForTokenSendsCorrelationID(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams, _ := msalbase.CreateAuthParametersInternal("clientID", testAuthorityInfo)
+	authParams.Endpoints = testAuthorityEndpoints
+	response := &msalHTTPManagerResponse{
+		responseCode: 400,
+		responseData: `{"error":"invalid_grant"}`,
+	}
+	sentCorrelationID := ""
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { sentCorrelationID = args.Get(2).(map[string]string)["client-request-id"] }).Return(response, nil)
+	_, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil)
+	if _, parseErr := uuid.Parse(sentCorrelationID); parseErr != nil {
+		t.Errorf("client-request-id should be a UUID, instead it is %s", sentCorrelationID)
+	}
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.CorrelationID != sentCorrelationID {
+		t.Errorf("Error should have the correlation ID %s, instead it is %v", sentCorrelationID, err)
+	}
+}
+
+func TestExchangeGrantForTokenSendsReqCnf(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	key, err := msalbase.CreatePoPKey()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	reqCnf, _ := key.GetReqCnf()
+	authParams := &msalbase.AuthParametersInternal{Endpoints: testAuthorityEndpoints, PoPKey: key}
+	response := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "token_type":"pop", "expires_in":10, "ext_expires_in":10}`,
+	}
+	withReqCnf := mock.MatchedBy(func(body string) bool {
+		return strings.Contains(body, "token_type=pop") && strings.Contains(body, "req_cnf="+url.QueryEscape(reqCnf))
+	})
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, withReqCnf, mock.Anything).Return(response, nil)
+	if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+}
@@ -17,64 +17,112 @@ type deviceCodeRequest struct {
 	webRequestManager  requests.WebRequestManager
 	authParameters     *msalbase.AuthParametersInternal
 	deviceCodeCallback func(DeviceCodeResultProvider)
-	cancelCtx          context.Context
+	// deviceCodeResult is a device code that was already issued, the request polls for it instead of requesting a new one
+	deviceCodeResult *msalbase.DeviceCodeResult
+	// after waits between polls, tests replace it so the intervals can be checked without waiting
+	after func(time.Duration) <-chan time.Time
 }
 
-func createDeviceCodeRequest(cancelCtx context.Context,
-	webRequestManager requests.WebRequestManager,
+func createDeviceCodeRequest(webRequestManager requests.WebRequestManager,
 	authParameters *msalbase.AuthParametersInternal,
 	deviceCodeCallback func(DeviceCodeResultProvider)) *deviceCodeRequest {
-	req := &deviceCodeRequest{webRequestManager, authParameters, deviceCodeCallback, cancelCtx}
+	req := &deviceCodeRequest{
+		webRequestManager:  webRequestManager,
+		authParameters:     authParameters,
+		deviceCodeCallback: deviceCodeCallback,
+		after:              time.After,
+	}
+	return req
+}
+
+// createDeviceCodeWaitRequest creates a request that polls for the device code issued by requestDeviceCode
+func createDeviceCodeWaitRequest(webRequestManager requests.WebRequestManager,
+	authParameters *msalbase.AuthParametersInternal,
+	deviceCodeResult *msalbase.DeviceCodeResult) *deviceCodeRequest {
+	req := createDeviceCodeRequest(webRequestManager, authParameters, nil)
+	req.deviceCodeResult = deviceCodeResult
 	return req
 }
 
 // Execute performs the token acquisition request and returns a token response or an error
-func (req *deviceCodeRequest) Execute() (*msalbase.TokenResponse, error) {
-	// Resolve authority endpoints
+// The polling stops with the error of the context when the context is canceled or its deadline passes
+func (req *deviceCodeRequest) Execute(ctx context.Context) (*msalbase.TokenResponse, error) {
+	deviceCodeResult := req.deviceCodeResult
+	if deviceCodeResult == nil {
+		var err error
+		if deviceCodeResult, err = req.requestDeviceCode(ctx); err != nil {
+			return nil, err
+		}
+		// Let the user do what they want with the device code result
+		req.deviceCodeCallback(deviceCodeResult)
+	} else if err := req.resolveEndpoints(ctx); err != nil {
+		return nil, err
+	}
+	// Using the device code to get the token response
+	return req.waitForTokenResponse(ctx, deviceCodeResult)
+}
+
+func (req *deviceCodeRequest) resolveEndpoints(ctx context.Context) error {
 	resolutionManager := requests.CreateAuthorityEndpointResolutionManager(req.webRequestManager)
-	endpoints, err := resolutionManager.ResolveEndpoints(req.authParameters.AuthorityInfo, "")
+	endpoints, err := resolutionManager.ResolveEndpoints(ctx, req.authParameters.AuthorityInfo, "")
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req.authParameters.Endpoints = endpoints
-	deviceCodeResult, err := req.webRequestManager.GetDeviceCodeResult(req.authParameters)
-	if err != nil {
+	return nil
+}
+
+// requestDeviceCode gets a device code from the authority without polling for the token
+func (req *deviceCodeRequest) requestDeviceCode(ctx context.Context) (*msalbase.DeviceCodeResult, error) {
+	if err := req.resolveEndpoints(ctx); err != nil {
 		return nil, err
 	}
-	// Let the user do what they want with the device code result
-	req.deviceCodeCallback(deviceCodeResult)
-	// Using the device code to get the token response
-	return req.waitForTokenResponse(deviceCodeResult)
+	return req.webRequestManager.GetDeviceCodeResult(ctx, req.authParameters)
 }
 
-func (req *deviceCodeRequest) waitForTokenResponse(deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error) {
-
+// waitForTokenResponse polls at the interval of the device code until the user authenticates,
+// the device code expires or the context is done
+func (req *deviceCodeRequest) waitForTokenResponse(ctx context.Context, deviceCodeResult *msalbase.DeviceCodeResult) (*msalbase.TokenResponse, error) {
 	interval := deviceCodeResult.GetInterval()
-	timeRemaining := deviceCodeResult.GetExpiresOn().Sub(time.Now().UTC())
-
-	for timeRemaining.Seconds() > 0.0 {
-		select {
+	if deviceCodeResult.GetExpiresOn().Sub(time.Now().UTC()) <= 0 {
+		return nil, errors.New("verification code expired before contacting the server")
+	}
+	for {
 		// If this request needs to be canceled, this context is used
-		case <-req.cancelCtx.Done():
-			return nil, errors.New("token request canceled")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		default:
-			tokenResponse, err := req.webRequestManager.GetAccessTokenFromDeviceCodeResult(req.authParameters, deviceCodeResult)
-			if err != nil {
-				// If authorization is pending, update the time remaining
-				if isErrorAuthorizationPending(err) {
-					timeRemaining = deviceCodeResult.GetExpiresOn().Sub(time.Now().UTC())
-					// If the device is polling too frequently, need to increase the polling interval
-				} else if isErrorSlowDown(err) {
-					interval += msalbase.IntervalAddition
-				} else {
-					return nil, err
-				}
-			} else {
-				return tokenResponse, nil
-			}
-			// Making sure the polling happens at the correct interval
-			time.Sleep(time.Duration(interval) * time.Second)
+		}
+		tokenResponse, err := req.webRequestManager.GetAccessTokenFromDeviceCodeResult(ctx, req.authParameters, deviceCodeResult)
+		if err == nil {
+			return tokenResponse, nil
+		}
+		switch {
+		case isErrorAuthorizationPending(err):
+			// The user hasn't authenticated yet, polling goes on at the same interval
+		case isErrorSlowDown(err):
+			// The device is polling too frequently, so the polling interval is increased
+			interval += msalbase.IntervalAddition
+		case isErrorExpiredToken(err):
+			// The device code expired before the user authenticated, polling again won't succeed
+			return nil, errors.New("device code expired before the user authenticated")
+		default:
+			return nil, err
+		}
+		timeRemaining := deviceCodeResult.GetExpiresOn().Sub(time.Now().UTC())
+		if timeRemaining <= 0 {
+			return nil, errors.New("device code expired before the user authenticated")
+		}
+		// Making sure the polling happens at the correct interval, without delaying a cancellation
+		wait := time.Duration(interval) * time.Second
+		if wait > timeRemaining {
+			wait = timeRemaining
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-req.after(wait):
 		}
 	}
-	return nil, errors.New("verification code expired before contacting the server")
 }
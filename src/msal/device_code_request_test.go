@@ -0,0 +1,184 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeviceCodePollsUntilAuthorized(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	mockCacheManager := new(requests.MockCacheManager)
+	pca := &PublicClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           mockWebRequestManager,
+			cacheContext:                &CacheContext{cache: mockCacheManager},
+		},
+	}
+	var callbackResult DeviceCodeResultProvider
+	callback := func(dcr DeviceCodeResultProvider) { callbackResult = dcr }
+	cancelCtx, cancelFunc := context.WithTimeout(context.Background(), time.Duration(100)*time.Second)
+	defer cancelFunc()
+	devCodeParams := CreateAcquireTokenDeviceCodeParameters([]string{"openid"}, callback)
+	mockWebRequestManager.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	devCodeResp := &requests.DeviceCodeResponse{UserCode: "userCode", DeviceCode: "deviceCode", ExpiresIn: 10}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	mockWebRequestManager.On("GetDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(devCodeResult, nil)
+	tokenResp := &msalbase.TokenResponse{}
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal"), devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("authorization_pending")).Twice()
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal"), devCodeResult).
+		Return(tokenResp, nil).Once()
+	mockCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	_, err := pca.AcquireTokenByDeviceCode(cancelCtx, devCodeParams)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if callbackResult == nil || callbackResult.GetUserCode() != "userCode" {
+		t.Errorf("Device code callback should receive the device code result, instead it received %v", callbackResult)
+	}
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetAccessTokenFromDeviceCodeResult", 3)
+	mockCacheManager.AssertCalled(t, "CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp)
+}
+
+func TestDeviceCodeExpiredToken(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	authParams := &msalbase.AuthParametersInternal{}
+	devCodeResp := &requests.DeviceCodeResponse{ExpiresIn: 10}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("expired_token"))
+	req := createDeviceCodeRequest(mockWebRequestManager, authParams, func(DeviceCodeResultProvider) {})
+	_, err := req.waitForTokenResponse(context.Background(), devCodeResult)
+	if err == nil {
+		t.Error("Error should not be nil when the device code has expired")
+	}
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetAccessTokenFromDeviceCodeResult", 1)
+}
+
+func TestDeviceCodeCanceledWhilePolling(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	authParams := &msalbase.AuthParametersInternal{}
+	devCodeResp := &requests.DeviceCodeResponse{ExpiresIn: 600, Interval: 60}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("authorization_pending"))
+	req := createDeviceCodeRequest(mockWebRequestManager, authParams, func(DeviceCodeResultProvider) {})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := req.waitForTokenResponse(ctx, devCodeResult)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Error should be context.Canceled, instead it is %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Polling should stop as soon as the context is canceled, instead it took %v", elapsed)
+	}
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetAccessTokenFromDeviceCodeResult", 1)
+}
+
+func TestDeviceCodePollingInterval(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	authParams := &msalbase.AuthParametersInternal{}
+	devCodeResp := &requests.DeviceCodeResponse{ExpiresIn: 600, Interval: 2}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	tokenResp := &msalbase.TokenResponse{AccessToken: "secret"}
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("authorization_pending")).Once()
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("slow_down")).Once()
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return(tokenResp, nil).Once()
+	req := createDeviceCodeRequest(mockWebRequestManager, authParams, func(DeviceCodeResultProvider) {})
+	waits := []time.Duration{}
+	req.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		return time.After(0)
+	}
+	actualResp, err := req.waitForTokenResponse(context.Background(), devCodeResult)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if actualResp != tokenResp {
+		t.Errorf("The token response of the authorized poll should be returned, instead it is %+v", actualResp)
+	}
+	// The interval of the device code is kept while authorization is pending, slow_down adds to it
+	expectedWaits := []time.Duration{2 * time.Second, (2 + msalbase.IntervalAddition) * time.Second}
+	if !reflect.DeepEqual(waits, expectedWaits) {
+		t.Errorf("Polling should wait %v between polls, instead it waited %v", expectedWaits, waits)
+	}
+}
+
+func TestDeviceCodePollingStopsAtExpiry(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	authParams := &msalbase.AuthParametersInternal{}
+	devCodeResp := &requests.DeviceCodeResponse{ExpiresIn: 1, Interval: 60}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("authorization_pending")).Once()
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", authParams, devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("expired_token")).Once()
+	req := createDeviceCodeRequest(mockWebRequestManager, authParams, func(DeviceCodeResultProvider) {})
+	var wait time.Duration
+	req.after = func(d time.Duration) <-chan time.Time {
+		wait = d
+		return time.After(0)
+	}
+	if _, err := req.waitForTokenResponse(context.Background(), devCodeResult); err == nil {
+		t.Error("Error should not be nil when the device code expires while polling")
+	}
+	if wait > time.Second {
+		t.Errorf("Polling shouldn't wait past the expiry of the device code, instead it waited %v", wait)
+	}
+}
+
+func TestWaitForDeviceCode(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	mockCacheManager := new(requests.MockCacheManager)
+	pca := &PublicClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           mockWebRequestManager,
+			cacheContext:                &CacheContext{cache: mockCacheManager},
+		},
+	}
+	mockWebRequestManager.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	devCodeResp := &requests.DeviceCodeResponse{UserCode: "userCode", DeviceCode: "deviceCode", ExpiresIn: 10}
+	devCodeResult := devCodeResp.ToDeviceCodeResult("clientID", []string{"openid"})
+	mockWebRequestManager.On("GetDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(devCodeResult, nil)
+	tokenResp := &msalbase.TokenResponse{}
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal"), devCodeResult).
+		Return((*msalbase.TokenResponse)(nil), errors.New("authorization_pending")).Once()
+	mockWebRequestManager.On("GetAccessTokenFromDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal"), devCodeResult).
+		Return(tokenResp, nil).Once()
+	mockCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	devCodeParams := CreateAcquireTokenDeviceCodeParameters([]string{"openid"}, nil)
+	deviceCode, err := pca.AcquireDeviceCode(context.Background(), devCodeParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if deviceCode.GetUserCode() != "userCode" {
+		t.Errorf("The device code should have the user code of the authority, instead it has %s", deviceCode.GetUserCode())
+	}
+	mockWebRequestManager.AssertNotCalled(t, "GetAccessTokenFromDeviceCodeResult", mock.Anything, mock.Anything)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := pca.WaitForDeviceCode(ctx, devCodeParams, deviceCode); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetDeviceCodeResult", 1)
+	mockWebRequestManager.AssertNumberOfCalls(t, "GetAccessTokenFromDeviceCodeResult", 2)
+	mockCacheManager.AssertCalled(t, "CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/tokencache"
+)
+
+// CallError is returned when the token endpoint answers with an error.
+// Code holds the OAuth error code, e.g. invalid_grant or interaction_required, and the raw status and body are kept for diagnostics.
+type CallError = msalbase.CallError
+
+// ErrCacheDecryption is returned by CacheContext.DeserializeCache when the CacheCodec can't decrypt the persisted cache,
+// e.g. because it was encrypted with another key. The cache isn't loaded, so it can't be mistaken for an empty cache.
+var ErrCacheDecryption = errors.New("persisted cache couldn't be decrypted")
+
+// ErrUnsupportedCacheVersion is returned by CacheContext.DeserializeCache when the persisted cache was written by a newer version of MSAL
+// with a schema this version can't read. The cache isn't loaded, and the persisted one should be kept for the newer version.
+var ErrUnsupportedCacheVersion = tokencache.ErrUnsupportedCacheVersion
+
+// ErrAccountNotFound is returned by GetAccount when the cache has no account with the home account ID.
+var ErrAccountNotFound = errors.New("account was not found in the cache")
+
+// ErrNoCachedToken is wrapped by the InteractionRequiredError of a silent request when the cache has neither a usable access token
+// nor a refresh token for it, e.g. because the user never signed in. It can be detected with errors.Is,
+// which tells an empty cache apart from a failed request to the authority.
+var ErrNoCachedToken = errors.New("no token found in the cache")
+
+// ErrRefreshTokenMigrationDisabled is returned by ExportRefreshToken and ImportRefreshToken
+// unless SetAllowRefreshTokenMigration turned them on for the client application.
+var ErrRefreshTokenMigrationDisabled = errors.New("refresh token migration isn't allowed for the client application")
+
+// InteractionRequiredError is returned when a token can't be acquired silently,
+// because the cache has neither a valid access token nor a usable refresh token for the account.
+// The user needs to sign in again with one of the interactive flows, e.g. the authorization code flow.
+// When the authority sent a claims challenge, Claims holds it and it needs to be passed to the interactive flow.
+// SubError is the suberror of the authority, e.g. basic_action when a conditional access policy requires MFA (AADSTS50076).
+// CorrelationID is the ID of the silent request, it can be logged to investigate the failure with support.
+type InteractionRequiredError struct {
+	reason        string
+	Claims        string
+	SubError      string
+	CorrelationID string
+	err           error
+}
+
+func (e *InteractionRequiredError) Error() string {
+	return "interaction required: " + e.reason
+}
+
+// Unwrap returns ErrNoCachedToken when the cache had no token for the silent request,
+// or the CallError of the authority when it rejected the cached refresh token or required interaction; nil otherwise
+func (e *InteractionRequiredError) Unwrap() error {
+	return e.err
+}
+
+// AdminConsentRequiredError is returned when the authority answers that the app needs consent the user can't grant,
+// e.g. for permissions only an administrator can consent to (AADSTS65001). Signing in again doesn't help;
+// an administrator of the tenant has to consent for the app. The CallError of the response is wrapped.
+type AdminConsentRequiredError struct {
+	Description   string
+	CorrelationID string
+	err           *CallError
+}
+
+func (e *AdminConsentRequiredError) Error() string {
+	return "admin consent required: " + e.Description
+}
+
+// Unwrap returns the CallError of the response
+func (e *AdminConsentRequiredError) Unwrap() error {
+	return e.err
+}
+
+// ThrottledError is returned instead of sending a request the authority recently throttled.
+// Identical requests, for the same authority, client and scopes, fail with it until RetryAfter.
+type ThrottledError struct {
+	RetryAfter time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return "request is throttled by the authority until " + e.RetryAfter.Format(time.RFC3339)
+}
+
+// AmbiguousAccountError is returned by AcquireTokenSilent when the login hint or the account filter of the parameters
+// matches more than one cached account. Candidates holds the matching accounts, one of them can be set with SetAccount.
+type AmbiguousAccountError struct {
+	Candidates []AccountProvider
+}
+
+func (e *AmbiguousAccountError) Error() string {
+	usernames := make([]string, 0, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		usernames = append(usernames, msalbase.PII(candidate.GetUsername()))
+	}
+	return "more than one cached account matches the silent request: " + strings.Join(usernames, ", ")
+}
@@ -0,0 +1,158 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+const (
+	// defaultCacheLockTimeout is how long an access waits for another process to release the cache file
+	defaultCacheLockTimeout = 10 * time.Second
+	// cacheLockRetryDelay is the wait between attempts to take the lock
+	cacheLockRetryDelay = 50 * time.Millisecond
+	// staleCacheLockAge is the age after which a lock is considered left behind by a process that crashed
+	staleCacheLockAge = time.Minute
+)
+
+// FileCacheAccessor is a CacheAccessor that persists the cache to a file, which processes such as CLI invocations can share.
+// Every access holds an exclusive lock on the file, from reading it in BeforeCacheAccess to writing it in AfterCacheAccess,
+// so concurrent processes don't overwrite each other's tokens. The lock is a lock file next to the cache file, which works on every platform.
+// When the lock can't be taken in time, the access goes on with the cache as it was read but doesn't write the file.
+type FileCacheAccessor struct {
+	file        string
+	lockTimeout time.Duration
+	lock        sync.Mutex
+	// locked holds the tokens of the lock files of the accesses that took the lock, accesses that timed out don't release it
+	locked map[*CacheContext]string
+}
+
+// CreateFileCacheAccessor creates a FileCacheAccessor for the cache file, the file is created when tokens are first written.
+func CreateFileCacheAccessor(file string) *FileCacheAccessor {
+	return &FileCacheAccessor{file: file, lockTimeout: defaultCacheLockTimeout, locked: map[*CacheContext]string{}}
+}
+
+// SetLockTimeout sets how long an access waits for another process to release the cache file; it's 10 seconds by default.
+func (a *FileCacheAccessor) SetLockTimeout(timeout time.Duration) {
+	a.lockTimeout = timeout
+}
+
+func (a *FileCacheAccessor) lockFile() string {
+	return a.file + ".lockfile"
+}
+
+// acquireLock creates the lock file with a token of its own, only one process can create it so whoever does holds the lock until it's removed.
+// It stops waiting for another process to release the lock when ctx is done.
+func (a *FileCacheAccessor) acquireLock(ctx context.Context) (string, error) {
+	id, err := msalbase.NewUUID()
+	if err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("%d-%s", os.Getpid(), id)
+	deadline := time.Now().Add(a.lockTimeout)
+	for {
+		lock, err := os.OpenFile(a.lockFile(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = lock.WriteString(token)
+			if closeErr := lock.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(a.lockFile())
+				return "", err
+			}
+			return token, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		// The stale lock is only removed if no other process replaced it since it was read
+		if info, err := os.Stat(a.lockFile()); err == nil && time.Since(info.ModTime()) > staleCacheLockAge {
+			if staleToken, err := ioutil.ReadFile(a.lockFile()); err == nil {
+				msalbase.GetLogger().Warnf("Removing the cache lock file %s, it was left behind %v ago", a.lockFile(), time.Since(info.ModTime()))
+				a.releaseLock(string(staleToken))
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return "", errors.New("timed out waiting for the lock on the cache file")
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(cacheLockRetryDelay):
+		}
+	}
+}
+
+// releaseLock removes the lock file if it still holds the token, so a lock taken by another process in the meantime is left alone
+func (a *FileCacheAccessor) releaseLock(token string) {
+	content, err := ioutil.ReadFile(a.lockFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			msalbase.GetLogger().Errorf("The cache lock file %s couldn't be read: %v", a.lockFile(), err)
+		}
+		return
+	}
+	if string(content) != token {
+		msalbase.GetLogger().Warnf("The cache lock file %s was taken by another process, it isn't removed", a.lockFile())
+		return
+	}
+	if err := os.Remove(a.lockFile()); err != nil && !os.IsNotExist(err) {
+		msalbase.GetLogger().Errorf("The cache lock file %s couldn't be removed: %v", a.lockFile(), err)
+	}
+}
+
+// BeforeCacheAccess locks the cache file and loads it into the cache, a missing file is an empty cache.
+// It waits for the lock until the lock timeout or until the context of the access is done.
+func (a *FileCacheAccessor) BeforeCacheAccess(context *CacheContext) {
+	if token, err := a.acquireLock(context.Context()); err != nil {
+		msalbase.GetLogger().Warnf("The cache file %s isn't locked, so it won't be written: %v", a.file, err)
+	} else {
+		a.lock.Lock()
+		a.locked[context] = token
+		a.lock.Unlock()
+	}
+	data, err := ioutil.ReadFile(a.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			msalbase.GetLogger().Errorf("The cache file %s couldn't be read: %v", a.file, err)
+		}
+		return
+	}
+	if err := context.DeserializeCache(data); err != nil {
+		msalbase.GetLogger().Errorf("The cache file %s couldn't be loaded: %v", a.file, err)
+	}
+}
+
+// AfterCacheAccess writes the cache to the file when the access changed it, and releases the lock.
+func (a *FileCacheAccessor) AfterCacheAccess(context *CacheContext) {
+	a.lock.Lock()
+	token, locked := a.locked[context]
+	delete(a.locked, context)
+	a.lock.Unlock()
+	if !locked {
+		return
+	}
+	defer a.releaseLock(token)
+	if !context.HasStateChanged() {
+		return
+	}
+	data, err := context.SerializeCache()
+	if err != nil {
+		msalbase.GetLogger().Errorf("The cache couldn't be serialized: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(a.file, data, 0600); err != nil {
+		msalbase.GetLogger().Errorf("The cache file %s couldn't be written: %v", a.file, err)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/tokencache"
+)
+
+func TestFileCacheAccessorConcurrentWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msalcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.json")
+	authParams := &msalbase.AuthParametersInternal{
+		AuthorityInfo:     &msalbase.AuthorityInfo{Host: "login.microsoftonline.com", Tenant: "tenant", AuthorityType: msalbase.MSSTS},
+		ClientID:          "clientID",
+		AuthorizationType: msalbase.AuthorizationTypeClientCredentials,
+	}
+	const writers, writes = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		// Every writer stands for a process, with its own accessor and in-memory cache
+		go func(w int) {
+			defer wg.Done()
+			accessor := CreateFileCacheAccessor(file)
+			cache := tokencache.CreateCacheManager(tokencache.CreateStorageManager())
+			for i := 0; i < writes; i++ {
+				cacheContext := &CacheContext{cache: cache}
+				accessor.BeforeCacheAccess(cacheContext)
+				tokenResponse := &msalbase.TokenResponse{
+					AccessToken:   "secret",
+					GrantedScopes: []string{"scope" + strconv.Itoa(w) + "." + strconv.Itoa(i)},
+					ExpiresOn:     time.Now().Add(time.Hour),
+					ExtExpiresOn:  time.Now().Add(time.Hour),
+				}
+				if _, err := cache.CacheTokenResponse(authParams, tokenResponse); err != nil {
+					t.Errorf("Error should be nil, but it is %v", err)
+				}
+				cacheContext.hasStateChanged = true
+				accessor.AfterCacheAccess(cacheContext)
+			}
+		}(w)
+	}
+	wg.Wait()
+	cache := tokencache.CreateCacheManager(tokencache.CreateStorageManager())
+	reader, readContext := CreateFileCacheAccessor(file), &CacheContext{cache: cache}
+	reader.BeforeCacheAccess(readContext)
+	reader.AfterCacheAccess(readContext)
+	data, err := cache.SerializeCache()
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	var contract struct {
+		AccessToken map[string]interface{}
+	}
+	if err := json.Unmarshal(data, &contract); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if len(contract.AccessToken) != writers*writes {
+		t.Errorf("The file should have the %d access tokens of all the writers, instead it has %d", writers*writes, len(contract.AccessToken))
+	}
+	if _, err := os.Stat(file + ".lockfile"); !os.IsNotExist(err) {
+		t.Errorf("The lock file should be removed after the accesses, instead stat returns %v", err)
+	}
+}
+
+func TestFileCacheAccessorLockTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msalcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.json")
+	if err := ioutil.WriteFile(file+".lockfile", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	accessor := CreateFileCacheAccessor(file)
+	accessor.SetLockTimeout(100 * time.Millisecond)
+	cacheContext := &CacheContext{cache: tokencache.CreateCacheManager(tokencache.CreateStorageManager()), hasStateChanged: true}
+	start := time.Now()
+	accessor.BeforeCacheAccess(cacheContext)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("The access should stop waiting at the timeout, instead it waited %v", time.Since(start))
+	}
+	accessor.AfterCacheAccess(cacheContext)
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("The cache file shouldn't be written without the lock")
+	}
+	if _, err := os.Stat(file + ".lockfile"); err != nil {
+		t.Errorf("The lock of the other process shouldn't be removed, instead stat returns %v", err)
+	}
+}
+
+func TestFileCacheAccessorLockCanceled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msalcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.json")
+	if err := ioutil.WriteFile(file+".lockfile", []byte("otherProcess"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accessor := CreateFileCacheAccessor(file)
+	accessor.SetLockTimeout(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cacheContext := &CacheContext{cache: tokencache.CreateCacheManager(tokencache.CreateStorageManager()), ctx: ctx}
+	start := time.Now()
+	accessor.BeforeCacheAccess(cacheContext)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("The access should stop waiting when its context is done, instead it waited %v", time.Since(start))
+	}
+	if len(accessor.locked) != 0 {
+		t.Error("The access shouldn't hold the lock when its context is done before it's released")
+	}
+}
+
+func TestFileCacheAccessorReleasesOnlyItsLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msalcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cache.json")
+	lockFile := file + ".lockfile"
+	// A lock left behind by a crashed process is replaced
+	if err := ioutil.WriteFile(lockFile, []byte("crashedProcess"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	staleTime := time.Now().Add(-2 * staleCacheLockAge)
+	if err := os.Chtimes(lockFile, staleTime, staleTime); err != nil {
+		t.Fatal(err)
+	}
+	accessor := CreateFileCacheAccessor(file)
+	cacheContext := &CacheContext{cache: tokencache.CreateCacheManager(tokencache.CreateStorageManager())}
+	accessor.BeforeCacheAccess(cacheContext)
+	token, locked := accessor.locked[cacheContext]
+	if !locked {
+		t.Fatal("The stale lock should be replaced by the lock of the access")
+	}
+	if content, err := ioutil.ReadFile(lockFile); err != nil || string(content) != token || !strings.HasPrefix(token, strconv.Itoa(os.Getpid())+"-") {
+		t.Errorf("The lock file should have the token %s with the process ID, instead it has %q and the error is %v", token, content, err)
+	}
+	// Another process that took the lock in the meantime keeps it
+	if err := ioutil.WriteFile(lockFile, []byte("otherProcess"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accessor.AfterCacheAccess(cacheContext)
+	if content, err := ioutil.ReadFile(lockFile); err != nil || string(content) != "otherProcess" {
+		t.Errorf("The lock of the other process shouldn't be removed, instead the lock file has %q and the error is %v", content, err)
+	}
+}
@@ -3,8 +3,11 @@
 
 package msalgo
 
+import "context"
+
 // HTTPManager is an interface representing MSAL's HTTP Client.
+// The context of a request is canceled when the token acquisition it belongs to is canceled or times out.
 type HTTPManager interface {
-	Get(url string, requestHeaders map[string]string) (HTTPManagerResponse, error)
-	Post(url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error)
+	Get(ctx context.Context, url string, requestHeaders map[string]string) (HTTPManagerResponse, error)
+	Post(ctx context.Context, url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error)
 }
@@ -0,0 +1,31 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
+//Logger is an interface for routing MSAL's log messages into an application's own logger.
+//A *logrus.Logger satisfies this interface.
+type Logger interface {
+	Trace(args ...interface{})
+	Tracef(format string, args ...interface{})
+	Info(args ...interface{})
+	Infof(format string, args ...interface{})
+	Warn(args ...interface{})
+	Warnf(format string, args ...interface{})
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+//SetLogger routes MSAL's log messages into the logger; by default nothing is logged.
+//There's one logger for the process, it receives the messages of every client application. Passing nil turns logging off.
+func SetLogger(logger Logger) {
+	msalbase.SetLogger(logger)
+}
+
+//SetPIILogging sets whether usernames, account identifiers and scopes are written to the log; it's off by default, so they're replaced by hashes.
+//Like the logger it's set for the process, turning it on logs the PII of every client application. Secrets are never logged.
+func SetPIILogging(enabled bool) {
+	msalbase.SetPIILoggingEnabled(enabled)
+}
@@ -0,0 +1,89 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/tokencache"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeLogger struct {
+	lines []string
+}
+
+func (l *fakeLogger) Trace(args ...interface{}) { l.lines = append(l.lines, fmt.Sprint(args...)) }
+func (l *fakeLogger) Tracef(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Info(args ...interface{}) { l.lines = append(l.lines, fmt.Sprint(args...)) }
+func (l *fakeLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Warn(args ...interface{}) { l.lines = append(l.lines, fmt.Sprint(args...)) }
+func (l *fakeLogger) Warnf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+func (l *fakeLogger) Error(args ...interface{}) { l.lines = append(l.lines, fmt.Sprint(args...)) }
+func (l *fakeLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestAcquireTokenDoesNotLogPII(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	// The fake ID token isn't signed, this test only covers logging
+	params := &clientApplicationParameters{
+		commonParameters: &applicationCommonParameters{
+			clientID:              "clientID",
+			authorityInfo:         testAuthorityInfo,
+			skipIDTokenValidation: true,
+		},
+	}
+	pca := &PublicClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: params,
+			webRequestManager:           mockWebRequestManager,
+			cacheContext:                &CacheContext{cache: tokencache.CreateCacheManager(tokencache.CreateStorageManager())},
+		},
+	}
+	logger := &fakeLogger{}
+	SetLogger(logger)
+	defer SetLogger(nil)
+	userPassParams := &AcquireTokenUsernamePasswordParameters{
+		commonParameters: tokenCommonParams,
+		username:         "user@contoso.com",
+		password:         "secretPassword",
+	}
+	mockWebRequestManager.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	mockWebRequestManager.On("GetUserRealm", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(&msalbase.UserRealm{AccountType: "Managed"}, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "accessToken",
+		RefreshToken:  "refreshToken",
+		GrantedScopes: []string{"openid"},
+		IDToken:       &msalbase.IDToken{PreferredUsername: "user@contoso.com", RawToken: "idToken"},
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	mockWebRequestManager.On("GetAccessTokenFromUsernamePassword", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(tokenResp, nil)
+	_, err := pca.AcquireTokenByUsernamePassword(context.Background(), userPassParams)
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	if len(logger.lines) == 0 {
+		t.Error("Acquiring a token should be logged")
+	}
+	for _, line := range logger.lines {
+		for _, pii := range []string{"uid.utid", "user@contoso.com", "secretPassword", "accessToken", "refreshToken"} {
+			if strings.Contains(line, pii) {
+				t.Errorf("Log line '%s' contains '%s'", line, pii)
+			}
+		}
+	}
+}
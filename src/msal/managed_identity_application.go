@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+)
+
+// ManagedIdentity is the identity of the Azure resource tokens are requested for, e.g. a VM or an App Service app.
+type ManagedIdentity = msalbase.ManagedIdentity
+
+// CreateSystemAssignedManagedIdentity returns the identity the Azure resource itself is assigned.
+func CreateSystemAssignedManagedIdentity() *ManagedIdentity {
+	return msalbase.CreateSystemAssignedManagedIdentity()
+}
+
+// CreateUserAssignedManagedIdentityFromClientID returns a user-assigned identity of the Azure resource, identified by its client ID.
+func CreateUserAssignedManagedIdentityFromClientID(clientID string) (*ManagedIdentity, error) {
+	return msalbase.CreateUserAssignedManagedIdentity(msalbase.ManagedIdentityClientID, clientID)
+}
+
+// CreateUserAssignedManagedIdentityFromResourceID returns a user-assigned identity of the Azure resource, identified by its ARM resource ID.
+func CreateUserAssignedManagedIdentityFromResourceID(resourceID string) (*ManagedIdentity, error) {
+	return msalbase.CreateUserAssignedManagedIdentity(msalbase.ManagedIdentityResourceID, resourceID)
+}
+
+// CreateUserAssignedManagedIdentityFromObjectID returns a user-assigned identity of the Azure resource, identified by the object ID of its service principal.
+func CreateUserAssignedManagedIdentityFromObjectID(objectID string) (*ManagedIdentity, error) {
+	return msalbase.CreateUserAssignedManagedIdentity(msalbase.ManagedIdentityObjectID, objectID)
+}
+
+// imdsRetryPolicy retries for about a minute, IMDS answers 410 for up to 70 seconds while it's being updated
+var imdsRetryPolicy = RetryPolicy{MaxRetries: 6, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// isIMDSTransientFailure retries what IMDS answers while the identity isn't available yet, besides throttling and server errors.
+// A request without an answer isn't retried, nothing answers it outside of Azure.
+func isIMDSTransientFailure(response HTTPManagerResponse, err error) bool {
+	if err != nil {
+		return false
+	}
+	switch response.GetResponseCode() {
+	case http.StatusNotFound, http.StatusRequestTimeout, http.StatusGone:
+		return true
+	}
+	return isTransientFailure(response, nil)
+}
+
+// ManagedIdentityApplication acquires tokens for the managed identity of the Azure resource the app runs on, without any secret.
+// On App Service and Functions tokens come from the endpoint in the IDENTITY_ENDPOINT environment variable, elsewhere from the instance metadata service.
+// Tokens are cached per identity and resource, so they're reused until they expire.
+// Methods that call the managed identity endpoint take a context, canceling it or passing its deadline aborts the requests they send.
+type ManagedIdentityApplication struct {
+	clientApplication *clientApplication
+	identity          *ManagedIdentity
+}
+
+// CreateManagedIdentityApplication creates a ManagedIdentityApplication instance for the system-assigned or a user-assigned identity.
+func CreateManagedIdentityApplication(identity *ManagedIdentity) (*ManagedIdentityApplication, error) {
+	if identity == nil {
+		identity = CreateSystemAssignedManagedIdentity()
+	}
+	clientApp := createClientApplication(identity.GetCacheClientID(), "")
+	clientApp.retryPolicy = imdsRetryPolicy
+	app := &ManagedIdentityApplication{clientApplication: clientApp, identity: identity}
+	app.SetHTTPManager(createHTTPManager())
+	return app, nil
+}
+
+// SetHTTPManager allows users to use their own implementation of HTTPManager.
+func (app *ManagedIdentityApplication) SetHTTPManager(httpManager HTTPManager) {
+	limitResponseSize(httpManager, &app.clientApplication.maxResponseSize)
+	retryManager := createRetryHTTPManager(createUserAgentHTTPManager(httpManager, &app.clientApplication.userAgent), &app.clientApplication.retryPolicy)
+	retryManager.isTransient = isIMDSTransientFailure
+	retryManager.timeout = &app.clientApplication.httpTimeout
+	app.clientApplication.webRequestManager = createWebRequestManager(retryManager)
+}
+
+// SetRetryPolicy sets how requests that fail with a transient error are retried; by default they are retried for about a minute.
+func (app *ManagedIdentityApplication) SetRetryPolicy(policy RetryPolicy) {
+	app.clientApplication.retryPolicy = policy
+}
+
+// SetUserAgent appends the product token of the app, e.g. "contoso-service/1.2.0", to the User-Agent header of the requests to the managed identity endpoint.
+// The header is "MSAL.Go/<version>" by default.
+func (app *ManagedIdentityApplication) SetUserAgent(userAgent string) {
+	app.clientApplication.userAgent = userAgent
+}
+
+// SetHTTPTimeout bounds every request sent to the managed identity endpoint, by default to 30 seconds; zero turns the bound off.
+// A deadline of the context passed to AcquireTokenByManagedIdentity still applies when it comes sooner.
+func (app *ManagedIdentityApplication) SetHTTPTimeout(timeout time.Duration) {
+	app.clientApplication.httpTimeout = timeout
+}
+
+// SetMaxResponseSize bounds the body of every response read from the managed identity endpoint, by default to 4 MB; zero turns the bound off.
+// A larger body fails the request. The bound doesn't apply to the responses of an HTTPManager set with SetHTTPManager.
+func (app *ManagedIdentityApplication) SetMaxResponseSize(size int64) {
+	app.clientApplication.maxResponseSize = size
+}
+
+// SetHTTPClient sends all of MSAL's requests with the client, for example to use custom timeouts.
+// Passing nil restores the default client.
+func (app *ManagedIdentityApplication) SetHTTPClient(client *http.Client) {
+	app.SetHTTPManager(createHTTPManagerWithClient(client))
+}
+
+// SetCacheAccessor allows users to use an implementation of CacheAccessor to handle cache persistence.
+func (app *ManagedIdentityApplication) SetCacheAccessor(accessor CacheAccessor) {
+	app.clientApplication.cacheAccessor = accessor
+}
+
+// SetCacheCodec sets the codec that encrypts the serialized cache before the CacheAccessor persists it, and decrypts it when it's loaded.
+func (app *ManagedIdentityApplication) SetCacheCodec(codec CacheCodec) {
+	app.clientApplication.cacheCodec = codec
+}
+
+// AcquireTokenByManagedIdentity acquires a token for the resource from the managed identity endpoint, unless a cached one is valid.
+// Users need to create an AcquireTokenManagedIdentityParameters instance and pass it in.
+func (app *ManagedIdentityApplication) AcquireTokenByManagedIdentity(ctx context.Context,
+	managedIdentityParams *AcquireTokenManagedIdentityParameters) (AuthenticationResultProvider, error) {
+	authParams, err := msalbase.CreateAuthParametersInternal(app.identity.GetCacheClientID(), msalbase.CreateManagedIdentityAuthorityInfo())
+	if err != nil {
+		return nil, err
+	}
+	managedIdentityParams.augmentAuthenticationParameters(authParams)
+	authParams.ManagedIdentity = app.identity
+	if result, err := app.clientApplication.acquireTokenFromCache(ctx, authParams); err == nil {
+		return result, nil
+	}
+	req := requests.CreateManagedIdentityRequest(app.clientApplication.webRequestManager, authParams)
+	return app.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+const testManagedIdentityResource = "https://management.azure.com"
+
+func createTestIMDSResponse(accessToken string) *msalHTTPManagerResponse {
+	expiresOn := time.Now().Add(time.Hour).Unix()
+	return &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: fmt.Sprintf(`{"access_token":"%s","expires_in":"3600","expires_on":"%d","resource":"%s","token_type":"Bearer"}`,
+			accessToken, expiresOn, testManagedIdentityResource),
+	}
+}
+
+func getTestIMDSURL(params map[string]string) string {
+	query := url.Values{"api-version": {msalbase.IMDSTokenAPIVersion}, "resource": {testManagedIdentityResource}}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	return msalbase.IMDSTokenEndpoint + "?" + query.Encode()
+}
+
+func TestAcquireTokenByManagedIdentitySystemAssigned(t *testing.T) {
+	app, err := CreateManagedIdentityApplication(CreateSystemAssignedManagedIdentity())
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockHTTPManager := new(mockHTTPManager)
+	app.SetHTTPManager(mockHTTPManager)
+	mockHTTPManager.On("Get", getTestIMDSURL(nil), map[string]string{"Metadata": "true", "User-Agent": testUserAgent}).Return(createTestIMDSResponse("systemToken"), nil)
+	for i := 0; i < 2; i++ {
+		result, err := app.AcquireTokenByManagedIdentity(context.Background(), CreateAcquireTokenManagedIdentityParameters(testManagedIdentityResource+"/.default"))
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		if result.GetAccessToken() != "systemToken" {
+			t.Errorf("Access token should be systemToken, instead it is %v", result.GetAccessToken())
+		}
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestAcquireTokenByManagedIdentityUserAssigned(t *testing.T) {
+	tests := []struct {
+		desc       string
+		identity   func(string) (*ManagedIdentity, error)
+		queryParam string
+	}{
+		{"client ID", CreateUserAssignedManagedIdentityFromClientID, "client_id"},
+		{"resource ID", CreateUserAssignedManagedIdentityFromResourceID, "msi_res_id"},
+		{"object ID", CreateUserAssignedManagedIdentityFromObjectID, "object_id"},
+	}
+	for _, test := range tests {
+		identity, err := test.identity("userAssignedID")
+		if err != nil {
+			t.Fatalf("%s: error should be nil, but it is %v", test.desc, err)
+		}
+		app, _ := CreateManagedIdentityApplication(identity)
+		mockHTTPManager := new(mockHTTPManager)
+		app.SetHTTPManager(mockHTTPManager)
+		expectedURL := getTestIMDSURL(map[string]string{test.queryParam: "userAssignedID"})
+		mockHTTPManager.On("Get", expectedURL, map[string]string{"Metadata": "true", "User-Agent": testUserAgent}).Return(createTestIMDSResponse("userToken"), nil)
+		result, err := app.AcquireTokenByManagedIdentity(context.Background(), CreateAcquireTokenManagedIdentityParameters(testManagedIdentityResource))
+		if err != nil {
+			t.Fatalf("%s: error should be nil, but it is %v", test.desc, err)
+		}
+		if result.GetAccessToken() != "userToken" {
+			t.Errorf("%s: access token should be userToken, instead it is %v", test.desc, result.GetAccessToken())
+		}
+		serialized, _ := app.clientApplication.cacheContext.cache.SerializeCache()
+		if !strings.Contains(string(serialized), `"client_id":"userAssignedID"`) || !strings.Contains(string(serialized), `"realm":"managed_identity"`) {
+			t.Errorf("%s: token should be cached for the identity, instead the cache is %s", test.desc, serialized)
+		}
+	}
+}
+
+func TestAcquireTokenByManagedIdentityRetriesUnavailableIdentity(t *testing.T) {
+	app, _ := CreateManagedIdentityApplication(nil)
+	mockHTTPManager := new(mockHTTPManager)
+	app.SetHTTPManager(mockHTTPManager)
+	app.SetRetryPolicy(RetryPolicy{MaxRetries: 1})
+	gone := &msalHTTPManagerResponse{responseCode: 410, responseData: "IMDS is being updated"}
+	mockHTTPManager.On("Get", getTestIMDSURL(nil), map[string]string{"Metadata": "true", "User-Agent": testUserAgent}).Return(gone, nil).Once()
+	mockHTTPManager.On("Get", getTestIMDSURL(nil), map[string]string{"Metadata": "true", "User-Agent": testUserAgent}).Return(createTestIMDSResponse("systemToken"), nil).Once()
+	result, err := app.AcquireTokenByManagedIdentity(context.Background(), CreateAcquireTokenManagedIdentityParameters(testManagedIdentityResource))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "systemToken" {
+		t.Errorf("Access token should be systemToken, instead it is %v", result.GetAccessToken())
+	}
+}
+
+func TestAcquireTokenByManagedIdentityError(t *testing.T) {
+	app, _ := CreateManagedIdentityApplication(nil)
+	mockHTTPManager := new(mockHTTPManager)
+	app.SetHTTPManager(mockHTTPManager)
+	badRequest := &msalHTTPManagerResponse{
+		responseCode: 400,
+		responseData: `{"error":"invalid_resource","error_description":"the resource isn't known"}`,
+	}
+	mockHTTPManager.On("Get", getTestIMDSURL(nil), map[string]string{"Metadata": "true", "User-Agent": testUserAgent}).Return(badRequest, nil)
+	_, err := app.AcquireTokenByManagedIdentity(context.Background(), CreateAcquireTokenManagedIdentityParameters(testManagedIdentityResource))
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Code != "invalid_resource" {
+		t.Errorf("Error should be the invalid_resource call error, but it is %v", err)
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestAcquireTokenByManagedIdentityAppService(t *testing.T) {
+	const endpoint = "http://127.0.0.1:41741/msi/token"
+	os.Setenv(identityEndpointEnvVar, endpoint)
+	os.Setenv(identityHeaderEnvVar, "secretHeader")
+	defer os.Unsetenv(identityEndpointEnvVar)
+	defer os.Unsetenv(identityHeaderEnvVar)
+	tests := []struct {
+		desc     string
+		identity *ManagedIdentity
+		params   url.Values
+	}{
+		{"system-assigned", CreateSystemAssignedManagedIdentity(), url.Values{}},
+		{"client ID", &ManagedIdentity{IDType: msalbase.ManagedIdentityClientID, ID: "userAssignedID"}, url.Values{"client_id": {"userAssignedID"}}},
+		{"resource ID", &ManagedIdentity{IDType: msalbase.ManagedIdentityResourceID, ID: "userAssignedID"}, url.Values{"mi_res_id": {"userAssignedID"}}},
+		{"object ID", &ManagedIdentity{IDType: msalbase.ManagedIdentityObjectID, ID: "userAssignedID"}, url.Values{"principal_id": {"userAssignedID"}}},
+	}
+	for _, test := range tests {
+		app, _ := CreateManagedIdentityApplication(test.identity)
+		mockHTTPManager := new(mockHTTPManager)
+		app.SetHTTPManager(mockHTTPManager)
+		test.params.Set("api-version", msalbase.AppServiceTokenAPIVersion)
+		test.params.Set("resource", testManagedIdentityResource)
+		expectedURL := endpoint + "?" + test.params.Encode()
+		mockHTTPManager.On("Get", expectedURL, map[string]string{"X-IDENTITY-HEADER": "secretHeader", "User-Agent": testUserAgent}).Return(createTestIMDSResponse("appServiceToken"), nil)
+		for i := 0; i < 2; i++ {
+			result, err := app.AcquireTokenByManagedIdentity(context.Background(), CreateAcquireTokenManagedIdentityParameters(testManagedIdentityResource))
+			if err != nil {
+				t.Fatalf("%s: error should be nil, but it is %v", test.desc, err)
+			}
+			if result.GetAccessToken() != "appServiceToken" {
+				t.Errorf("%s: access token should be appServiceToken, instead it is %v", test.desc, result.GetAccessToken())
+			}
+		}
+		mockHTTPManager.AssertNumberOfCalls(t, "Get", 1)
+	}
+}
+
+func TestManagedIdentitySourceNeedsBothAppServiceVariables(t *testing.T) {
+	os.Setenv(identityEndpointEnvVar, "http://127.0.0.1:41741/msi/token")
+	defer os.Unsetenv(identityEndpointEnvVar)
+	if source := getManagedIdentitySource(); source.endpoint != msalbase.IMDSTokenEndpoint {
+		t.Errorf("IMDS should be used without %s, instead %s is used", identityHeaderEnvVar, source.endpoint)
+	}
+}
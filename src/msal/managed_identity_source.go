@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"os"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// App Service and Functions set these environment variables when the app has a managed identity
+const (
+	identityEndpointEnvVar = "IDENTITY_ENDPOINT"
+	identityHeaderEnvVar   = "IDENTITY_HEADER"
+)
+
+// managedIdentitySource is an endpoint that issues managed identity tokens, each has its own protocol
+type managedIdentitySource struct {
+	endpoint   string
+	apiVersion string
+	// idQueryParams are the query parameters a user-assigned identity is identified with
+	idQueryParams map[msalbase.ManagedIdentityIDType]string
+	headers       map[string]string
+}
+
+// getManagedIdentitySource returns the App Service endpoint when its environment variables are set, otherwise IMDS.
+// The environment is read for every request, App Service sets it before the app starts.
+func getManagedIdentitySource() *managedIdentitySource {
+	endpoint, header := os.Getenv(identityEndpointEnvVar), os.Getenv(identityHeaderEnvVar)
+	if endpoint != "" && header != "" {
+		return &managedIdentitySource{
+			endpoint:   endpoint,
+			apiVersion: msalbase.AppServiceTokenAPIVersion,
+			idQueryParams: map[msalbase.ManagedIdentityIDType]string{
+				msalbase.ManagedIdentityClientID:   "client_id",
+				msalbase.ManagedIdentityResourceID: "mi_res_id",
+				msalbase.ManagedIdentityObjectID:   "principal_id",
+			},
+			headers: map[string]string{msalbase.IdentityHeaderName: header},
+		}
+	}
+	return &managedIdentitySource{
+		endpoint:   msalbase.IMDSTokenEndpoint,
+		apiVersion: msalbase.IMDSTokenAPIVersion,
+		idQueryParams: map[msalbase.ManagedIdentityIDType]string{
+			msalbase.ManagedIdentityClientID:   "client_id",
+			msalbase.ManagedIdentityResourceID: "msi_res_id",
+			msalbase.ManagedIdentityObjectID:   "object_id",
+		},
+		headers: map[string]string{"Metadata": "true"},
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// Metrics is an interface for reporting MSAL's cache lookups and token requests to an application's own metrics,
+// e.g. Prometheus counters and histograms. Its methods are called concurrently by every request, so they need to be safe for concurrent use.
+type Metrics interface {
+	// OnCacheHit is called when a valid access token is found in the cache.
+	OnCacheHit()
+	// OnCacheMiss is called when the cache has no valid access token for a request, e.g. because it expired.
+	OnCacheMiss()
+	// OnTokenRequest is called when a request to a token endpoint finishes, after its retries; success is false when no token was acquired.
+	OnTokenRequest(duration time.Duration, success bool)
+}
+
+// SetMetrics reports cache hits and misses and the duration of token requests to the metrics; by default nothing is reported.
+// There's one Metrics for the process, it's reported to by every client application. Passing nil turns the reporting off.
+func SetMetrics(metrics Metrics) { msalbase.SetMetrics(metrics) }
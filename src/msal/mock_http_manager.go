@@ -3,20 +3,24 @@
 
 package msalgo
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
 
 type mockHTTPManager struct {
 	mock.Mock
 }
 
 //Get mocks the Get method of a HTTPManager
-func (mock *mockHTTPManager) Get(url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+func (mock *mockHTTPManager) Get(ctx context.Context, url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
 	args := mock.Called(url, requestHeaders)
 	return args.Get(0).(HTTPManagerResponse), args.Error(1)
 }
 
 //Post mocks the Post method of a HTTPManager
-func (mock *mockHTTPManager) Post(url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+func (mock *mockHTTPManager) Post(ctx context.Context, url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
 	args := mock.Called(url, body, requestHeaders)
 	return args.Get(0).(HTTPManagerResponse), args.Error(1)
 }
@@ -4,40 +4,67 @@
 package msalgo
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 //msalHTTPManager is a wrapper for http.Client
 type msalHTTPManager struct {
 	client *http.Client
+	//maxResponseSize bounds the bytes read from the body of a response; there's no bound when it's nil or not positive
+	maxResponseSize *int64
 }
 
+//defaultHTTPTimeout bounds each request of a client application, including reading the response, unless SetHTTPTimeout is called
+const defaultHTTPTimeout = 30 * time.Second
+
+//defaultMaxResponseSize bounds the body of each response read by a client application unless SetMaxResponseSize is called,
+//the responses of the authority are a few KB so only a misbehaving host or proxy sends more
+const defaultMaxResponseSize int64 = 4 << 20
+
 // CreateHTTPManager creates a http.Client object and wraps it in a msalHTTPManager
 func createHTTPManager() HTTPManager {
-	tr := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: false,
-		}).DialContext,
+	return createHTTPManagerWithClient(nil)
+}
+
+//createHTTPManagerWithClient wraps the client in a msalHTTPManager, all of MSAL's requests are sent with it
+//If the client is nil, a client with a 30 second dial timeout is used, the requests are bounded by the timeout of the client application
+func createHTTPManagerWithClient(client *http.Client) HTTPManager {
+	if client == nil {
+		tr := &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+				DualStack: false,
+			}).DialContext,
+		}
+		client = &http.Client{Transport: tr}
 	}
-	client := &http.Client{}
-	client.Transport = tr
-	mgr := &msalHTTPManager{client}
+	mgr := &msalHTTPManager{client: client}
 	return mgr
 }
 
+//limitResponseSize bounds the bodies read by the HTTPManager if it's one of MSAL's, custom HTTPManagers read the bodies themselves
+func limitResponseSize(httpManager HTTPManager, maxResponseSize *int64) {
+	if mgr, ok := httpManager.(*msalHTTPManager); ok {
+		mgr.maxResponseSize = maxResponseSize
+	}
+}
+
 func (mgr *msalHTTPManager) performRequest(
 	req *http.Request, requestHeaders map[string]string) (HTTPManagerResponse, error) {
-	log.Info("   HEADERS:")
+	msalbase.GetLogger().Info("   HEADERS:")
 	for k, v := range requestHeaders {
 		req.Header.Add(k, v)
-		log.Infof("     %v: %v", k, v)
+		msalbase.GetLogger().Infof("     %v: %v", k, redactHeaderValue(k, v))
 	}
 
 	resp, err := mgr.client.Do(req)
@@ -45,15 +72,94 @@ func (mgr *msalHTTPManager) performRequest(
 		return nil, err
 	}
 
-	return createHTTPManagerResponse(resp)
+	var maxResponseSize int64
+	if mgr.maxResponseSize != nil {
+		maxResponseSize = *mgr.maxResponseSize
+	}
+	return createHTTPManagerResponse(resp, maxResponseSize)
+}
+
+//secretHeaders are the request headers whose values are never logged, not even when PII logging is enabled
+//X-IDENTITY-HEADER is the secret of the App Service managed identity endpoint
+var secretHeaders = map[string]bool{
+	strings.ToLower(msalbase.IdentityHeaderName): true,
+	"authorization": true,
+	"metadata":      true,
+}
+
+//redactHeaderValue returns the value of the request header to log, the values of other headers may be secrets too,
+//e.g. the headers of the app for instance discovery, so they're treated as PII
+func redactHeaderValue(name string, value string) string {
+	if secretHeaders[strings.ToLower(name)] {
+		return "[redacted]"
+	}
+	return msalbase.PII(value)
+}
+
+//secretFields are the fields of request forms and JSON responses whose values are never logged, not even when PII logging is enabled
+var secretFields = map[string]bool{
+	"client_secret":    true,
+	"client_assertion": true,
+	"assertion":        true,
+	"password":         true,
+	"refresh_token":    true,
+	"code":             true,
+	"code_verifier":    true,
+	"device_code":      true,
+	"access_token":     true,
+	"id_token":         true,
+}
+
+//redactBody returns the body of a request or response to log with its secret fields redacted, the other fields are PII.
+//Bodies that are neither forms nor JSON, e.g. WS-Trust envelopes that carry the password or the SAML assertion, aren't logged
+func redactBody(body string) string {
+	if !msalbase.PIILoggingEnabled() {
+		return "Body hidden, PII logging is disabled"
+	}
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &fields); err == nil {
+			for name := range fields {
+				if secretFields[name] {
+					fields[name] = "[redacted]"
+				}
+			}
+			if redacted, err := json.Marshal(fields); err == nil {
+				return string(redacted)
+			}
+		}
+	} else if !strings.HasPrefix(trimmed, "<") {
+		if form, err := url.ParseQuery(trimmed); err == nil {
+			for name := range form {
+				if secretFields[name] {
+					form[name] = []string{"[redacted]"}
+				}
+			}
+			return form.Encode()
+		}
+	}
+	return fmt.Sprintf("Body of %d bytes hidden, it may contain secrets", len(body))
+}
+
+//redactURL keeps the scheme and host of a URL for logging, the path may contain a username so it's treated as PII
+func redactURL(rawURL string) string {
+	if msalbase.PIILoggingEnabled() {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return msalbase.PII(rawURL)
+	}
+	return u.Scheme + "://" + u.Host + "/" + msalbase.PII(strings.TrimPrefix(u.RequestURI(), "/"))
 }
 
 // Get sends a get request to the appropriate URL
-func (mgr *msalHTTPManager) Get(url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
-	log.Info("<------------------")
-	log.Infof("   GET to %v", url)
-	defer log.Info("------------------>")
-	req, err := http.NewRequest("GET", url, nil)
+func (mgr *msalHTTPManager) Get(ctx context.Context, url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	msalbase.GetLogger().Info("<------------------")
+	msalbase.GetLogger().Infof("   GET to %v", redactURL(url))
+	defer msalbase.GetLogger().Info("------------------>")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -62,12 +168,12 @@ func (mgr *msalHTTPManager) Get(url string, requestHeaders map[string]string) (H
 }
 
 // Post sends a post request to the appropriate URL
-func (mgr *msalHTTPManager) Post(url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
-	log.Info("<------------------")
-	log.Infof("   POST to %v", url)
-	log.Info(body)
-	defer log.Info("------------------>")
-	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+func (mgr *msalHTTPManager) Post(ctx context.Context, url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	msalbase.GetLogger().Info("<------------------")
+	msalbase.GetLogger().Infof("   POST to %v", redactURL(url))
+	msalbase.GetLogger().Info("   " + redactBody(body))
+	defer msalbase.GetLogger().Info("------------------>")
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -4,10 +4,12 @@
 package msalgo
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 )
 
 //msalHTTPManagerResponse is a wrapper for a http.Response
@@ -32,15 +34,27 @@ func (r *msalHTTPManagerResponse) GetHeaders() map[string]string {
 	return r.headers
 }
 
-func createHTTPManagerResponse(resp *http.Response) (HTTPManagerResponse, error) {
+//createHTTPManagerResponse reads the response, a body of more than maxResponseSize bytes is an error unless maxResponseSize isn't positive
+func createHTTPManagerResponse(resp *http.Response, maxResponseSize int64) (HTTPManagerResponse, error) {
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if maxResponseSize > 0 {
+		// One byte more than the limit is read, so a body of exactly the limit isn't mistaken for a larger one
+		reader = io.LimitReader(resp.Body, maxResponseSize+1)
+	}
+	body, err := ioutil.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
+	if maxResponseSize > 0 && int64(len(body)) > maxResponseSize {
+		return nil, fmt.Errorf("the body of the response with status %d is larger than the limit of %d bytes, the limit can be raised with SetMaxResponseSize",
+			resp.StatusCode, maxResponseSize)
+	}
 
-	log.Info("   HTTP Response: " + resp.Status)
-	log.Trace(string(body))
+	msalbase.GetLogger().Info("   HTTP Response: " + resp.Status)
+	if msalbase.PIILoggingEnabled() {
+		msalbase.GetLogger().Trace(redactBody(string(body)))
+	}
 
 	headers := make(map[string]string)
 	for k, v := range resp.Header {
@@ -0,0 +1,242 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// recordingRoundTripper records the URLs of the requests and answers them with an OpenID configuration
+type recordingRoundTripper struct {
+	urls []string
+}
+
+func (rt *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Like http.Transport, a request whose context is done isn't sent
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	rt.urls = append(rt.urls, req.URL.String())
+	body := `{"authorization_endpoint":"https://login.microsoftonline.com/{tenant}/oauth2/v2.0/authorize",` +
+		`"token_endpoint":"https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token",` +
+		`"issuer":"https://login.microsoftonline.com/{tenant}/v2.0"}`
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.microsoftonline.com/httpclient/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	roundTripper := &recordingRoundTripper{}
+	pca.SetHTTPClient(&http.Client{Transport: roundTripper})
+	authCodeURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	authCodeURLParams.State = "state"
+	if _, err := pca.CreateAuthCodeURL(context.Background(), authCodeURLParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/httpclient/v2.0/.well-known/openid-configuration"
+	if len(roundTripper.urls) != 1 || roundTripper.urls[0] != expectedURL {
+		t.Errorf("The request to %s should have been sent with the client, instead the requests were %v", expectedURL, roundTripper.urls)
+	}
+}
+
+func TestCreateHTTPManagerWithNilClient(t *testing.T) {
+	mgr := createHTTPManagerWithClient(nil).(*msalHTTPManager)
+	if mgr.client == nil {
+		t.Fatal("A default client should be created")
+	}
+	// The timeout moved from the default client to the client application, so it also bounds the requests of custom clients
+	client := createClientApplication("clientID", "https://login.microsoftonline.com/common/")
+	retryManager := client.webRequestManager.(*defaultWebRequestManager).httpManager.(*retryHTTPManager)
+	if retryManager.timeout == nil || *retryManager.timeout != defaultHTTPTimeout {
+		t.Errorf("The requests of the client application should time out after %v", defaultHTTPTimeout)
+	}
+}
+
+func TestCreateAuthCodeURLCanceled(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.microsoftonline.com/httpclientcanceled/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	roundTripper := &recordingRoundTripper{}
+	pca.SetHTTPClient(&http.Client{Transport: roundTripper})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	authCodeURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	authCodeURLParams.State = "state"
+	if _, err := pca.CreateAuthCodeURL(ctx, authCodeURLParams); !errors.Is(err, context.Canceled) {
+		t.Errorf("Error should be context.Canceled, instead it is %v", err)
+	}
+	if len(roundTripper.urls) != 0 {
+		t.Errorf("No request should be sent with a canceled context, instead the requests were %v", roundTripper.urls)
+	}
+}
+
+// sizedBodyRoundTripper answers every request with a body of the size
+type sizedBodyRoundTripper struct {
+	size int
+}
+
+func (rt *sizedBodyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(strings.Repeat("a", rt.size))),
+		Request:    req,
+	}, nil
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	secret, err := CreateClientCredentialFromSecret("secret")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	cca, err := CreateConfidentialClientApplication("clientID", "https://login.microsoftonline.com/maxresponsesize/", secret)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if cca.clientApplication.maxResponseSize != defaultMaxResponseSize {
+		t.Errorf("The default limit should be %d bytes, but it is %d", defaultMaxResponseSize, cca.clientApplication.maxResponseSize)
+	}
+	cca.SetRetryPolicy(RetryPolicy{})
+	cca.SetMaxResponseSize(16)
+	cca.SetHTTPClient(&http.Client{Transport: &sizedBodyRoundTripper{size: 17}})
+	_, err = cca.AcquireTokenByClientCredential(context.Background(), CreateAcquireTokenClientCredentialParameters([]string{"scope"}))
+	if err == nil || !strings.Contains(err.Error(), "larger than the limit of 16 bytes") {
+		t.Errorf("The request should fail because the response is larger than the limit, instead the error is %v", err)
+	}
+
+	// MSAL's own HTTPManagers get the limit of the client application when they're set, it's read when the response is
+	manager := createHTTPManagerWithClient(&http.Client{Transport: &sizedBodyRoundTripper{size: 16}}).(*msalHTTPManager)
+	cca.SetHTTPManager(manager)
+	response, err := manager.Get(context.Background(), "https://login.microsoftonline.com/maxresponsesize/", nil)
+	if err != nil {
+		t.Fatalf("A response of exactly the limit should be read, but the error is %v", err)
+	}
+	if len(response.GetResponseData()) != 16 {
+		t.Errorf("The whole body should be read, but %d bytes were", len(response.GetResponseData()))
+	}
+	cca.SetMaxResponseSize(0)
+	manager.client = &http.Client{Transport: &sizedBodyRoundTripper{size: 1 << 10}}
+	if _, err := manager.Get(context.Background(), "https://login.microsoftonline.com/maxresponsesize/", nil); err != nil {
+		t.Errorf("A zero limit should turn the bound off, but the error is %v", err)
+	}
+}
+
+func TestPerformRequestDoesNotLogHeaderValues(t *testing.T) {
+	logger := &fakeLogger{}
+	msalbase.SetLogger(logger)
+	defer msalbase.SetLogger(nil)
+	mgr := createHTTPManagerWithClient(&http.Client{Transport: &recordingRoundTripper{}})
+	headers := map[string]string{"Authorization": "Bearer secretHeaderValue"}
+	if _, err := mgr.Get(context.Background(), "https://login.microsoftonline.com/headers/", headers); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	loggedHeader := false
+	for _, line := range logger.lines {
+		if strings.Contains(line, "secretHeaderValue") {
+			t.Errorf("The header value shouldn't be logged when PII logging is off, but it is in %q", line)
+		}
+		loggedHeader = loggedHeader || strings.Contains(line, "Authorization")
+	}
+	if !loggedHeader {
+		t.Error("The header name should still be logged")
+	}
+}
+
+func TestPerformRequestNeverLogsSecretHeaders(t *testing.T) {
+	logger := &fakeLogger{}
+	msalbase.SetLogger(logger)
+	defer msalbase.SetLogger(nil)
+	msalbase.SetPIILoggingEnabled(true)
+	defer msalbase.SetPIILoggingEnabled(false)
+	mgr := createHTTPManagerWithClient(&http.Client{Transport: &recordingRoundTripper{}})
+	headers := map[string]string{
+		msalbase.IdentityHeaderName: "identityHeaderSecret",
+		"Authorization":             "Bearer authorizationSecret",
+		"Metadata":                  "metadataSecret",
+		"x-client-SKU":              "MSAL.Go",
+	}
+	if _, err := mgr.Get(context.Background(), "https://login.microsoftonline.com/secretheaders/", headers); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	loggedSKU := false
+	for _, line := range logger.lines {
+		for _, secret := range []string{"identityHeaderSecret", "authorizationSecret", "metadataSecret"} {
+			if strings.Contains(line, secret) {
+				t.Errorf("The secret header value shouldn't be logged even with PII logging on, but it is in %q", line)
+			}
+		}
+		loggedSKU = loggedSKU || strings.Contains(line, "MSAL.Go")
+	}
+	if !loggedSKU {
+		t.Error("The values of other headers should be logged when PII logging is on")
+	}
+}
+
+func TestPostNeverLogsSecrets(t *testing.T) {
+	logger := &fakeLogger{}
+	msalbase.SetLogger(logger)
+	defer msalbase.SetLogger(nil)
+	msalbase.SetPIILoggingEnabled(true)
+	defer msalbase.SetPIILoggingEnabled(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"token_type":"Bearer","access_token":"accessTokenSecret","refresh_token":"refreshTokenSecret","id_token":"idTokenSecret","scope":"user.read"}`)
+	}))
+	defer server.Close()
+	form := url.Values{
+		"grant_type":       {"password"},
+		"username":         {"user@contoso.com"},
+		"password":         {"passwordSecret"},
+		"client_secret":    {"clientSecret"},
+		"client_assertion": {"clientAssertionSecret"},
+		"refresh_token":    {"refreshTokenSecret"},
+		"code":             {"codeSecret"},
+		"code_verifier":    {"codeVerifierSecret"},
+	}
+	if _, err := createHTTPManager().Post(context.Background(), server.URL, form.Encode(), map[string]string{}); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	secrets := []string{"passwordSecret", "clientSecret", "clientAssertionSecret", "refreshTokenSecret", "codeSecret", "codeVerifierSecret",
+		"accessTokenSecret", "idTokenSecret"}
+	loggedUsername, loggedScope := false, false
+	for _, line := range logger.lines {
+		for _, secret := range secrets {
+			if strings.Contains(line, secret) {
+				t.Errorf("The secret %s shouldn't be logged even with PII logging on, but it is in %q", secret, line)
+			}
+		}
+		loggedUsername = loggedUsername || strings.Contains(line, "user%40contoso.com")
+		loggedScope = loggedScope || strings.Contains(line, "user.read")
+	}
+	if !loggedUsername || !loggedScope {
+		t.Errorf("The fields that aren't secrets should be logged when PII logging is on, the log is %v", logger.lines)
+	}
+	// The WS-Trust envelope carries the password, so it isn't logged at all
+	logger.lines = nil
+	if _, err := createHTTPManager().Post(context.Background(), server.URL, "<s:Envelope><wsse:Password>soapPasswordSecret</wsse:Password></s:Envelope>", map[string]string{}); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	for _, line := range logger.lines {
+		if strings.Contains(line, "soapPasswordSecret") {
+			t.Errorf("The WS-Trust envelope shouldn't be logged, but it is in %q", line)
+		}
+	}
+}
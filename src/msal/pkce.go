@@ -0,0 +1,30 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// codeVerifierLength is the number of random bytes in a code verifier, which encode to 43 characters
+const codeVerifierLength = 32
+
+// CreateCodeVerifier generates a random PKCE code verifier, as described in https://tools.ietf.org/html/rfc7636#section-4.1.
+// Keep the verifier until the authorization code is redeemed, it's sent along with the code in AcquireTokenByAuthCode.
+func CreateCodeVerifier() (string, error) {
+	b := make([]byte, codeVerifierLength)
+	if err := msalbase.ReadRandom(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateCodeChallenge derives the S256 code challenge for a code verifier, as described in https://tools.ietf.org/html/rfc7636#section-4.2.
+func CreateCodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	mathrand "math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateCodeVerifier(t *testing.T) {
+	verifier, err := CreateCodeVerifier()
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	// RFC 7636 requires 43 to 128 characters from the unreserved URL characters
+	if !regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`).MatchString(verifier) {
+		t.Errorf("Code verifier %v doesn't follow RFC 7636", verifier)
+	}
+	otherVerifier, _ := CreateCodeVerifier()
+	if verifier == otherVerifier {
+		t.Errorf("Code verifiers should be random, instead both are %v", verifier)
+	}
+}
+
+func TestCreateCodeVerifierWithRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	var verifiers []string
+	for i := 0; i < 2; i++ {
+		SetRandomSource(mathrand.New(mathrand.NewSource(42)))
+		verifier, err := CreateCodeVerifier()
+		if err != nil {
+			t.Fatalf("Error should be nil, instead it is %v", err)
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	if verifiers[0] != verifiers[1] {
+		t.Errorf("The same seed should give the same code verifier, instead they are %v and %v", verifiers[0], verifiers[1])
+	}
+}
+
+func TestCreateCodeChallenge(t *testing.T) {
+	expectedChallenge := "rflSG3iRMyhNII-1DTF0a2YPJCZ_bL7DWGP3Mgpw44A"
+	actualChallenge := CreateCodeChallenge("codeVerifierForTestingPurposes0123456789abc")
+	if actualChallenge != expectedChallenge {
+		t.Errorf("Code challenge should be %v, instead it is %v", expectedChallenge, actualChallenge)
+	}
+}
+
+func TestAuthCodeWithPKCE(t *testing.T) {
+	mockWebRequestManager := new(requests.MockWebRequestManager)
+	mockCacheManager := new(requests.MockCacheManager)
+	pca := &PublicClientApplication{
+		clientApplication: &clientApplication{
+			clientApplicationParameters: clientAppParams,
+			webRequestManager:           mockWebRequestManager,
+			cacheContext:                &CacheContext{cache: mockCacheManager},
+		},
+	}
+	mockWebRequestManager.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	verifier, err := CreateCodeVerifier()
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	authCodeURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
+	authCodeURLParams.SetCodeVerifier(verifier)
+	authCodeURLParams.Nonce = "nonce123"
+	url, err := pca.CreateAuthCodeURL(context.Background(), authCodeURLParams)
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	expectedURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&code_challenge=" + CreateCodeChallenge(verifier) +
+		"&code_challenge_method=S256&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
+	if url != expectedURL {
+		t.Errorf("URL should be %v, instead it is %v", expectedURL, url)
+	}
+	authCodeParams := CreateAcquireTokenAuthCodeParameters([]string{"openid"}, "redirect")
+	authCodeParams.Code = "code"
+	authCodeParams.CodeChallenge = verifier
+	tokenResp := &msalbase.TokenResponse{}
+	mockWebRequestManager.On("GetAccessTokenFromAuthCode", mock.AnythingOfType("*msalbase.AuthParametersInternal"),
+		"code", verifier, map[string]string{}).Return(tokenResp, nil)
+	mockCacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp).Return(testAcc, nil)
+	_, err = pca.AcquireTokenByAuthCode(context.Background(), authCodeParams)
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	mockCacheManager.AssertCalled(t, "CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), tokenResp)
+}
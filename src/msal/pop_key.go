@@ -0,0 +1,16 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import "github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+
+// PoPKey is the key pair a proof-of-possession access token is bound to, it's passed to SetProofOfPossession.
+// A resource only accepts the token in an Authorization header signed with the key, so a stolen token can't be replayed.
+type PoPKey = msalbase.PoPKey
+
+// CreatePoPKey generates an ephemeral RSA key pair for proof-of-possession tokens.
+// The key only lives in memory, so tokens cached for it can't be used after the app restarts.
+func CreatePoPKey() (*PoPKey, error) {
+	return msalbase.CreatePoPKey()
+}
@@ -4,12 +4,19 @@
 package msalgo
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
 	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/requests"
 )
 
 // PublicClientApplication is a representation of public client applications.
 // These are apps that run on devices or desktop computers or in a web browser and are not trusted to safely keep application secrets.
 // For more information, visit https://docs.microsoft.com/azure/active-directory/develop/msal-client-applications.
+// Methods that call the authority take a context, canceling it or passing its deadline aborts the requests they send.
 type PublicClientApplication struct {
 	clientApplication *clientApplication
 }
@@ -23,10 +30,124 @@ func CreatePublicClientApplication(clientID string, authority string) (*PublicCl
 	return pca, nil
 }
 
+// CreatePublicClientApplicationForCloud creates a PublicClientApplication instance that signs in users of the tenant in an Azure AD national cloud.
+func CreatePublicClientApplicationForCloud(clientID string, cloud CloudInstance, tenant string) (*PublicClientApplication, error) {
+	clientApp, err := createClientApplicationForCloud(clientID, cloud, tenant)
+	if err != nil {
+		return nil, err
+	}
+	pca := &PublicClientApplication{
+		clientApplication: clientApp,
+	}
+	return pca, nil
+}
+
+// CreatePublicClientApplicationWithCacheAccessor creates a PublicClientApplication instance whose cache is loaded and persisted by the CacheAccessor.
+func CreatePublicClientApplicationWithCacheAccessor(clientID string, authority string, accessor CacheAccessor) (*PublicClientApplication, error) {
+	pca, err := CreatePublicClientApplication(clientID, authority)
+	if err != nil {
+		return nil, err
+	}
+	pca.SetCacheAccessor(accessor)
+	return pca, nil
+}
+
 //SetHTTPManager allows users to use their own implementation of HTTPManager.
 func (pca *PublicClientApplication) SetHTTPManager(httpManager HTTPManager) {
-	webRequestManager := createWebRequestManager(httpManager)
-	pca.clientApplication.webRequestManager = webRequestManager
+	pca.clientApplication.setHTTPManager(httpManager)
+}
+
+//SetRetryPolicy sets how requests that fail with a transient error are retried; by default they are retried twice.
+func (pca *PublicClientApplication) SetRetryPolicy(policy RetryPolicy) {
+	pca.clientApplication.retryPolicy = policy
+}
+
+//SetUserAgent appends the product token of the app, e.g. "contoso-cli/1.2.0", to the User-Agent header of all of MSAL's requests.
+//The header is "MSAL.Go/<version>" by default, so the authority's logs tell the requests of the app apart from other MSAL Go apps.
+func (pca *PublicClientApplication) SetUserAgent(userAgent string) {
+	pca.clientApplication.userAgent = userAgent
+}
+
+//SetHTTPTimeout bounds every request sent to the authority, including reading the response, by default to 30 seconds; zero turns the bound off.
+//A deadline of the context passed to a method still applies when it comes sooner. Retries of a failed request get a new timeout.
+//The device code flow polls with separate requests, so its polling lasts until the device code expires.
+func (pca *PublicClientApplication) SetHTTPTimeout(timeout time.Duration) {
+	pca.clientApplication.httpTimeout = timeout
+}
+
+//SetMaxResponseSize bounds the body of every response read from the authority, by default to 4 MB; zero turns the bound off.
+//A larger body fails the request. The bound doesn't apply to the responses of an HTTPManager set with SetHTTPManager.
+func (pca *PublicClientApplication) SetMaxResponseSize(size int64) {
+	pca.clientApplication.maxResponseSize = size
+}
+
+//SetHTTPClient sends all of MSAL's requests with the client, for example to use a proxy, custom TLS settings or timeouts.
+//Passing nil restores the default client.
+func (pca *PublicClientApplication) SetHTTPClient(client *http.Client) {
+	pca.SetHTTPManager(createHTTPManagerWithClient(client))
+}
+
+//SetValidateAuthority sets whether the authority host is checked against instance discovery before tokens are requested.
+//It is on by default; turn it off only for private clouds whose hosts are explicitly trusted.
+func (pca *PublicClientApplication) SetValidateAuthority(validateAuthority bool) {
+	pca.clientApplication.clientApplicationParameters.setValidateAuthority(validateAuthority)
+}
+
+//SetInstanceDiscovery sets whether the aliases of the authority host are looked up with instance discovery, it is on by default.
+//With it off no instance discovery request is sent, the host is its only alias, so tokens cached under another alias aren't found,
+//and the authority isn't validated; use it for private clouds and environments without access to the discovery endpoint.
+func (pca *PublicClientApplication) SetInstanceDiscovery(enabled bool) {
+	pca.clientApplication.clientApplicationParameters.setInstanceDiscovery(enabled)
+}
+
+//SetInstanceDiscoveryHeaders sets headers that are sent with the instance discovery request, e.g. an Authorization header
+//for a gateway in front of the discovery endpoint of a private cloud. They aren't sent with any other request.
+func (pca *PublicClientApplication) SetInstanceDiscoveryHeaders(headers map[string]string) {
+	pca.clientApplication.clientApplicationParameters.setInstanceDiscoveryHeaders(headers)
+}
+
+//SetKnownAuthorityHosts sets the authority hosts that are trusted when instance discovery is turned off with SetInstanceDiscovery,
+//so the authority is still validated in private clouds without discovery: an authority on any other host is rejected before tokens are requested.
+func (pca *PublicClientApplication) SetKnownAuthorityHosts(hosts []string) {
+	pca.clientApplication.clientApplicationParameters.setKnownAuthorityHosts(hosts)
+}
+
+//SetClientCapabilities declares the capabilities of the app to the authority, e.g. "cp1" for apps that handle claims challenges,
+//so that the authority issues tokens for Continuous Access Evaluation. The capabilities are merged into the claims of every request,
+//together with a claims challenge passed to SetClaims.
+func (pca *PublicClientApplication) SetClientCapabilities(capabilities []string) {
+	pca.clientApplication.clientApplicationParameters.setClientCapabilities(capabilities)
+}
+
+//ValidateAuthority checks the authority of the application with instance discovery and tenant discovery without acquiring a token.
+//It lets configuration problems be found at startup, the error describes everything that's wrong with the authority.
+func (pca *PublicClientApplication) ValidateAuthority(ctx context.Context) error {
+	return pca.clientApplication.validateAuthority(ctx)
+}
+
+//SetAllowRefreshTokenMigration turns on ExportRefreshToken and ImportRefreshToken, which are off by default.
+//They exist only to move users between MSAL and another authentication library; a refresh token is a long-lived credential
+//that lets whoever holds it acquire tokens for the user, so an exported token must be protected like a password and never logged.
+func (pca *PublicClientApplication) SetAllowRefreshTokenMigration(allow bool) {
+	pca.clientApplication.allowRefreshTokenMigration = allow
+}
+
+//SetValidateIDToken sets whether the signature, issuer, audience and lifetime of ID tokens are checked before they're cached.
+//It is on by default; turn it off only when the ID tokens are validated elsewhere.
+func (pca *PublicClientApplication) SetValidateIDToken(validateIDToken bool) {
+	pca.clientApplication.clientApplicationParameters.setValidateIDToken(validateIDToken)
+}
+
+//SetClockSkew sets the difference allowed between the clocks of the authority and the device when the lifetime of ID tokens is checked,
+//so ID tokens that just expired or aren't valid yet within it are still accepted. It's 5 minutes by default.
+func (pca *PublicClientApplication) SetClockSkew(clockSkew time.Duration) {
+	pca.clientApplication.clientApplicationParameters.setClockSkew(clockSkew)
+}
+
+//SetNormalizeCacheEnvironment sets whether tokens are cached under the preferred_cache alias that instance discovery returns for the authority host,
+//instead of the host the request used, so the cache holds the same environment whichever alias of the authority the app is configured with.
+func (pca *PublicClientApplication) SetNormalizeCacheEnvironment(normalize bool) {
+	pca.clientApplication.clientApplicationParameters.setNormalizeCacheEnvironment(normalize)
 }
 
 //SetCacheAccessor allows users to use an implementation of CacheAccessor to handle cache persistence.
@@ -34,49 +155,212 @@ func (pca *PublicClientApplication) SetCacheAccessor(accessor CacheAccessor) {
 	pca.clientApplication.cacheAccessor = accessor
 }
 
+//SetOnRefreshTokenUpdated sets a callback that is called with the account when its refresh token is replaced by a new one during a silent request,
+//e.g. to update a copy of the refresh token kept outside of the cache right away.
+func (pca *PublicClientApplication) SetOnRefreshTokenUpdated(callback func(account AccountProvider)) {
+	pca.clientApplication.onRefreshTokenUpdated = callback
+}
+
+//SetOnRefreshTokenInvalidated sets a callback that is called with the account when the authority rejects its refresh token as revoked or expired,
+//the refresh token has been removed from the cache when it's called.
+func (pca *PublicClientApplication) SetOnRefreshTokenInvalidated(callback func(account AccountProvider)) {
+	pca.clientApplication.onRefreshTokenInvalidated = callback
+}
+
+//SetCacheCallbacks persists the cache with two functions instead of a CacheAccessor, see CreateCallbackCacheAccessor.
+func (pca *PublicClientApplication) SetCacheCallbacks(beforeAccess func() ([]byte, error), afterAccess func(data []byte, changed bool) error) {
+	pca.SetCacheAccessor(CreateCallbackCacheAccessor(beforeAccess, afterAccess))
+}
+
+//SetCacheCodec sets the codec that encrypts the serialized cache before the CacheAccessor persists it, and decrypts it when it's loaded.
+func (pca *PublicClientApplication) SetCacheCodec(codec CacheCodec) {
+	pca.clientApplication.cacheCodec = codec
+}
+
+//Flush makes the CacheAccessor persist the current cache, with the changed flag set, so no tokens are lost when the app exits.
+//It's meant for a graceful shutdown; it does nothing when there's no CacheAccessor.
+func (pca *PublicClientApplication) Flush() error {
+	return pca.clientApplication.flush()
+}
+
+//SetCacheMaxAccounts limits how many accounts the cache holds, when a token is written for one more account
+//the tokens of the least recently used account are evicted. Accounts are used when their tokens are read or written, 0 doesn't limit them.
+func (pca *PublicClientApplication) SetCacheMaxAccounts(maxAccounts int) {
+	pca.clientApplication.cacheContext.cache.SetMaxAccounts(maxAccounts)
+}
+
+//SetTokenExpirationOffset makes the cache treat access tokens as if the offset had already passed, e.g. an hour makes a token that expires
+//within the hour expired, so tests of an app can exercise its refresh paths without waiting. It's meant for tests, by default there's no offset.
+//Unlike SetSkipExpirationBuffer of the parameters it shifts the clock of the cache reads, tokens acquired while it's set are still cached.
+func (pca *PublicClientApplication) SetTokenExpirationOffset(offset time.Duration) {
+	pca.clientApplication.cacheContext.cache.SetTokenExpirationOffset(offset)
+}
+
 // CreateAuthCodeURL creates a URL used to acquire an authorization code. Users need to call CreateAuthorizationCodeURLParameters and pass it in.
-func (pca *PublicClientApplication) CreateAuthCodeURL(authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
-	return pca.clientApplication.createAuthCodeURL(authCodeURLParameters)
+func (pca *PublicClientApplication) CreateAuthCodeURL(ctx context.Context, authCodeURLParameters *AuthorizationCodeURLParameters) (string, error) {
+	return pca.clientApplication.createAuthCodeURL(ctx, authCodeURLParameters)
+}
+
+// AuthCodeURL creates the URL of the authorize endpoint of the authority that the app redirects the user to, for apps that do the redirect themselves.
+// The URL has the client ID of the app and the state, which the redirect back to the app has to match; options set the optional parameters.
+// The code of the redirect is redeemed with AcquireTokenByAuthCode.
+func (pca *PublicClientApplication) AuthCodeURL(ctx context.Context, scopes []string, redirectURI string, state string, options ...AuthCodeURLOption) (string, error) {
+	return pca.clientApplication.authCodeURL(ctx, scopes, redirectURI, state, options)
 }
 
 // AcquireTokenSilent acquires a token from either the cache or using a refresh token
 // Users need to create an AcquireTokenSilentParameters instance and pass it in.
-func (pca *PublicClientApplication) AcquireTokenSilent(
+func (pca *PublicClientApplication) AcquireTokenSilent(ctx context.Context,
 	silentParameters *AcquireTokenSilentParameters) (AuthenticationResultProvider, error) {
 	silentParameters.requestType = requests.RefreshTokenPublic
-	return pca.clientApplication.acquireTokenSilent(silentParameters)
+	return pca.clientApplication.acquireTokenSilent(ctx, silentParameters)
 }
 
 // AcquireTokenByUsernamePassword acquires a security token from the authority, via Username/Password Authentication.
 // Users need to create an AcquireTokenUsernamePasswordParameters instance and pass it in.
 // NOTE: this flow is NOT recommended.
-func (pca *PublicClientApplication) AcquireTokenByUsernamePassword(
+func (pca *PublicClientApplication) AcquireTokenByUsernamePassword(ctx context.Context,
 	usernamePasswordParameters *AcquireTokenUsernamePasswordParameters) (AuthenticationResultProvider, error) {
-	authParams := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
-	usernamePasswordParameters.augmentAuthenticationParameters(authParams)
+	authParams, err := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := usernamePasswordParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
 	req := requests.CreateUsernamePasswordRequest(pca.clientApplication.webRequestManager, authParams)
-	return pca.clientApplication.executeTokenRequestWithCacheWrite(req, authParams)
+	return pca.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
 }
 
 // AcquireTokenByDeviceCode acquires a security token from the authority, by acquiring a device code and using that to acquire the token.
 // Users need to create an AcquireTokenDeviceCodeParameters instance and pass it in.
-func (pca *PublicClientApplication) AcquireTokenByDeviceCode(
+func (pca *PublicClientApplication) AcquireTokenByDeviceCode(ctx context.Context,
 	deviceCodeParameters *AcquireTokenDeviceCodeParameters) (AuthenticationResultProvider, error) {
-	authParams := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
-	deviceCodeParameters.augmentAuthenticationParameters(authParams)
-	req := createDeviceCodeRequest(deviceCodeParameters.cancelCtx, pca.clientApplication.webRequestManager, authParams, deviceCodeParameters.deviceCodeCallback)
-	return pca.clientApplication.executeTokenRequestWithCacheWrite(req, authParams)
+	authParams, err := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := deviceCodeParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	req := createDeviceCodeRequest(pca.clientApplication.webRequestManager, authParams, deviceCodeParameters.deviceCodeCallback)
+	return pca.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
+}
+
+// AcquireDeviceCode requests a device code from the authority without polling for the token, the callback of the parameters isn't called.
+// Show the message of the device code to the user, then call WaitForDeviceCode with the same parameters to get the token.
+func (pca *PublicClientApplication) AcquireDeviceCode(ctx context.Context,
+	deviceCodeParameters *AcquireTokenDeviceCodeParameters) (DeviceCodeResultProvider, error) {
+	authParams, err := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := deviceCodeParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	deviceCodeResult, err := createDeviceCodeRequest(pca.clientApplication.webRequestManager, authParams, nil).requestDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return deviceCodeResult, nil
+}
+
+// WaitForDeviceCode polls the authority until the user authenticates with the device code from AcquireDeviceCode, and returns the token.
+// Polling follows the interval of the device code, slowing down when the authority asks for it, and stops with an error when the device code expires
+// or the context is canceled or its deadline passes, so a context with a timeout bounds how long the user has to authenticate.
+func (pca *PublicClientApplication) WaitForDeviceCode(ctx context.Context,
+	deviceCodeParameters *AcquireTokenDeviceCodeParameters, deviceCode DeviceCodeResultProvider) (AuthenticationResultProvider, error) {
+	deviceCodeResult, ok := deviceCode.(*msalbase.DeviceCodeResult)
+	if !ok {
+		return nil, errors.New("the device code wasn't returned by AcquireDeviceCode")
+	}
+	authParams, err := pca.clientApplication.clientApplicationParameters.createAuthenticationParameters()
+	if err != nil {
+		return nil, err
+	}
+	if err := deviceCodeParameters.augmentAuthenticationParameters(authParams); err != nil {
+		return nil, err
+	}
+	req := createDeviceCodeWaitRequest(pca.clientApplication.webRequestManager, authParams, deviceCodeResult)
+	return pca.clientApplication.executeTokenRequestWithCacheWrite(ctx, req, authParams)
 }
 
 // AcquireTokenByAuthCode is a request to acquire a security token from the authority, using an authorization code.
 // Users need to create an AcquireTokenAuthCodeParameters instance and pass it in.
-func (pca *PublicClientApplication) AcquireTokenByAuthCode(
+func (pca *PublicClientApplication) AcquireTokenByAuthCode(ctx context.Context,
 	authCodeParams *AcquireTokenAuthCodeParameters) (AuthenticationResultProvider, error) {
 	authCodeParams.requestType = requests.AuthCodePublic
-	return pca.clientApplication.acquireTokenByAuthCode(authCodeParams)
+	return pca.clientApplication.acquireTokenByAuthCode(ctx, authCodeParams)
+}
+
+// GetAllAccessTokens lists the access tokens of the cache, including its partitions, to debug cache misses such as tokens cached under another authority host.
+// The secrets of the tokens are left out when redactSecrets is set, which is what support tooling and logs should use.
+func (pca *PublicClientApplication) GetAllAccessTokens(redactSecrets bool) []*CachedAccessToken {
+	return pca.clientApplication.getAllAccessTokens(redactSecrets)
+}
+
+// GetAllRefreshTokens lists the refresh tokens of the cache, including its partitions.
+// The secrets of the tokens are left out when redactSecrets is set, which is what support tooling and logs should use.
+func (pca *PublicClientApplication) GetAllRefreshTokens(redactSecrets bool) []*CachedRefreshToken {
+	return pca.clientApplication.getAllRefreshTokens(redactSecrets)
+}
+
+// Stats counts the accounts, access tokens and refresh tokens of the cache, including its partitions, and estimates their size.
+// It's cheap enough for a health check endpoint; the cache isn't serialized and only the lengths of the secrets are read.
+func (pca *PublicClientApplication) Stats() (*CacheStats, error) {
+	return pca.clientApplication.getStats()
 }
 
-// GetAccounts gets all the accounts in the token cache.
+// GetAccounts gets all the accounts in the token cache, including the accounts of its partitions.
 func (pca *PublicClientApplication) GetAccounts() []AccountProvider {
 	return pca.clientApplication.getAccounts()
 }
+
+// GetAccountsByUsername gets the accounts in the token cache with the username, ignoring case.
+// An account signed in to several tenants is returned once per tenant.
+func (pca *PublicClientApplication) GetAccountsByUsername(username string) []AccountProvider {
+	return pca.clientApplication.getAccountsByUsername(username)
+}
+
+// GetAccountsByTenant gets the accounts in the token cache signed in to the tenant.
+func (pca *PublicClientApplication) GetAccountsByTenant(realm string) []AccountProvider {
+	return pca.clientApplication.getAccountsByTenant(realm)
+}
+
+// GetAccount gets the account with the home account ID from the token cache, e.g. the ID an app stored in the user's session.
+// ErrAccountNotFound is returned if the account isn't in the cache.
+func (pca *PublicClientApplication) GetAccount(ctx context.Context, homeAccountID string) (AccountProvider, error) {
+	return pca.clientApplication.getAccount(ctx, homeAccountID)
+}
+
+// RemoveAccount signs the account out by removing it and all of its tokens from the token cache.
+// Removing an account that isn't in the cache is not an error.
+func (pca *PublicClientApplication) RemoveAccount(ctx context.Context, account AccountProvider) error {
+	return pca.clientApplication.removeAccount(ctx, account)
+}
+
+// DeleteAccessTokens removes the account's cached access tokens that were granted for all of the scopes, e.g. after the consent to an API was revoked.
+// The refresh and ID tokens are kept, so AcquireTokenSilent acquires new access tokens for the scopes. Deleting tokens that aren't in the cache is not an error.
+func (pca *PublicClientApplication) DeleteAccessTokens(ctx context.Context, account AccountProvider, scopes []string) error {
+	return pca.clientApplication.deleteAccessTokens(ctx, account, scopes)
+}
+
+// ExportRefreshToken returns the cached refresh token that AcquireTokenSilent would redeem for the account, to hand it to another library.
+// It returns ErrRefreshTokenMigrationDisabled unless SetAllowRefreshTokenMigration allowed it, and ErrNoCachedToken if the account has none.
+func (pca *PublicClientApplication) ExportRefreshToken(ctx context.Context, account AccountProvider) (string, error) {
+	return pca.clientApplication.exportRefreshToken(ctx, account)
+}
+
+// ImportRefreshToken caches a refresh token that another library acquired for the client ID and the authority of the application,
+// familyID is set when the token was issued to a family of client IDs. The home account ID is the user's object ID and tenant ID joined by a dot.
+// The returned account can be passed to AcquireTokenSilent, which redeems the refresh token.
+// It returns ErrRefreshTokenMigrationDisabled unless SetAllowRefreshTokenMigration allowed it.
+func (pca *PublicClientApplication) ImportRefreshToken(homeAccountID string, refreshToken string, familyID string) (AccountProvider, error) {
+	return pca.clientApplication.importRefreshToken(homeAccountID, refreshToken, familyID)
+}
+
+// ClearCache removes all the accounts and tokens from the token cache, e.g. to sign every user out.
+// Clearing an empty cache is not an error.
+func (pca *PublicClientApplication) ClearCache() error {
+	return pca.clientApplication.clearCache()
+}
@@ -5,6 +5,9 @@ package msalgo
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -37,14 +40,15 @@ var testPCA = &PublicClientApplication{
 func TestCreateAuthCodeURL(t *testing.T) {
 	authCodeURLParams := CreateAuthorizationCodeURLParameters("clientID", "redirect", []string{"openid"})
 	authCodeURLParams.CodeChallenge = "codeChallenge"
+	authCodeURLParams.Nonce = "nonce123"
 	wrm.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
-	url, err := testPCA.CreateAuthCodeURL(authCodeURLParams)
+	url, err := testPCA.CreateAuthCodeURL(context.Background(), authCodeURLParams)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
 	actualURL := "https://login.microsoftonline.com/v2.0/authorize?client_id=clientID&code_challenge=codeChallenge" +
-		"&redirect_uri=redirect&response_type=code&scope=openid"
+		"&nonce=nonce123&redirect_uri=redirect&response_type=code&scope=openid"
 	if !reflect.DeepEqual(actualURL, url) {
 		t.Errorf("URL should be %v, instead it is %v", actualURL, url)
 	}
@@ -59,7 +63,7 @@ func TestAcquireTokenByAuthCode(t *testing.T) {
 	actualTokenResp := &msalbase.TokenResponse{}
 	wrm.On("GetAccessTokenFromAuthCode", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "", "", make(map[string]string)).Return(actualTokenResp, nil)
 	cacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), actualTokenResp).Return(testAcc, nil)
-	_, err := testPCA.AcquireTokenByAuthCode(authCodeParams)
+	_, err := testPCA.AcquireTokenByAuthCode(context.Background(), authCodeParams)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
@@ -80,7 +84,7 @@ func TestAcquireTokenByUsernamePassword(t *testing.T) {
 	actualTokenResp := &msalbase.TokenResponse{}
 	wrm.On("GetAccessTokenFromUsernamePassword", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(actualTokenResp, nil)
 	cacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), actualTokenResp).Return(testAcc, nil)
-	_, err := testPCA.AcquireTokenByUsernamePassword(userPassParams)
+	_, err := testPCA.AcquireTokenByUsernamePassword(context.Background(), userPassParams)
 	if err != nil {
 		t.Errorf("Error should be nil, instead it is %v", err)
 	}
@@ -105,7 +109,6 @@ func TestAcquireTokenByDeviceCode(t *testing.T) {
 	devCodeParams := &AcquireTokenDeviceCodeParameters{
 		commonParameters:   tokenCommonParams,
 		deviceCodeCallback: callback,
-		cancelCtx:          cancelCtx,
 	}
 	wrm.On("GetTenantDiscoveryResponse",
 		"https://login.microsoftonline.com/v2.0/v2.0/.well-known/openid-configuration").Return(tdr, nil)
@@ -115,8 +118,438 @@ func TestAcquireTokenByDeviceCode(t *testing.T) {
 	wrm.On("GetDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal")).Return(devCodeResult, nil)
 	wrm.On("GetAccessTokenFromDeviceCodeResult", mock.AnythingOfType("*msalbase.AuthParametersInternal"), devCodeResult).Return(actualTokenResp, nil)
 	cacheManager.On("CacheTokenResponse", mock.AnythingOfType("*msalbase.AuthParametersInternal"), actualTokenResp).Return(testAcc, nil)
-	_, err := testPCA.AcquireTokenByDeviceCode(devCodeParams)
+	_, err := testPCA.AcquireTokenByDeviceCode(cancelCtx, devCodeParams)
 	if err != nil {
 		t.Errorf("Error should be nil, but it is %v", err)
 	}
 }
+
+func TestRemoveAccount(t *testing.T) {
+	testAccount := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	cacheManager.On("RemoveAccount", testAccount, wrm).Return(nil)
+	err := testPCA.RemoveAccount(context.Background(), testAccount)
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+}
+
+func TestDeleteAccessTokens(t *testing.T) {
+	testAccount := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	cacheManager.On("DeleteAccessTokens", testAccount, []string{"user.read"}, wrm).Return(1, nil)
+	err := testPCA.DeleteAccessTokens(context.Background(), testAccount, []string{"user.read"})
+	if err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+}
+
+func TestSetValidateAuthority(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.private.contoso.com/common/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authorityInfo := pca.clientApplication.clientApplicationParameters.commonParameters.authorityInfo
+	if !authorityInfo.ValidateAuthority {
+		t.Error("Authority validation should be on by default")
+	}
+	pca.SetValidateAuthority(false)
+	if authorityInfo.ValidateAuthority {
+		t.Error("Authority validation should be off")
+	}
+}
+
+func TestCreatePublicClientApplicationForCloud(t *testing.T) {
+	pca, err := CreatePublicClientApplicationForCloud("clientID", AzureUSGovernment, "tenant")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	authorityInfo := pca.clientApplication.clientApplicationParameters.commonParameters.authorityInfo
+	if authorityInfo.Host != "login.microsoftonline.us" {
+		t.Errorf("Login host should be login.microsoftonline.us, but it is %s", authorityInfo.Host)
+	}
+	if authorityInfo.CanonicalAuthorityURI != "https://login.microsoftonline.us/tenant/" {
+		t.Errorf("Authority should be in the US Government cloud, but it is %s", authorityInfo.CanonicalAuthorityURI)
+	}
+	if authorityInfo.Cloud != msalbase.CloudInstanceUSGovernment {
+		t.Errorf("Cloud should be US Government, but it is %+v", authorityInfo.Cloud)
+	}
+	if AzureUSGovernment.GetGraphHost() != "graph.microsoft.us" {
+		t.Errorf("Graph host should be graph.microsoft.us, but it is %s", AzureUSGovernment.GetGraphHost())
+	}
+	if _, err := CreatePublicClientApplicationForCloud("clientID", CloudInstance(100), "tenant"); err == nil {
+		t.Error("Error should not be nil for an unknown cloud instance")
+	}
+}
+
+func TestClearCache(t *testing.T) {
+	cacheManager.On("Clear").Return(nil)
+	if err := testPCA.ClearCache(); err != nil {
+		t.Errorf("Error should be nil, instead it is %v", err)
+	}
+	cacheManager.AssertCalled(t, "Clear")
+}
+
+func TestImportRefreshToken(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://migration.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetValidateAuthority(false)
+	testWrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = testWrm
+	if _, err := pca.ImportRefreshToken("uid.utid", "importedRT", ""); err != ErrRefreshTokenMigrationDisabled {
+		t.Fatalf("Import should be refused until migration is allowed, instead the error is %v", err)
+	}
+	pca.SetAllowRefreshTokenMigration(true)
+	account, err := pca.ImportRefreshToken("uid.utid", "importedRT", "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	testWrm.On("GetAadinstanceDiscoveryResponse", mock.AnythingOfType("*msalbase.AuthorityInfo")).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"migration.contoso.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://migration.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "migratedAT",
+		RefreshToken:  "rotatedRT",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "importedRT", mock.Anything).Return(tokenResp, nil)
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, account)
+	result, err := pca.AcquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "migratedAT" {
+		t.Errorf("The imported refresh token should be redeemed for migratedAT, instead the access token is %s", result.GetAccessToken())
+	}
+	exported, err := pca.ExportRefreshToken(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if exported != "rotatedRT" {
+		t.Errorf("The refresh token returned by the refresh should be exported, instead %s is", exported)
+	}
+	pca.SetAllowRefreshTokenMigration(false)
+	if _, err := pca.ExportRefreshToken(context.Background(), account); err != ErrRefreshTokenMigrationDisabled {
+		t.Errorf("Export should be refused once migration is turned off, instead the error is %v", err)
+	}
+}
+
+func TestAcquireTokenSilentWithHomeAccountID(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://legacy.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetValidateAuthority(false)
+	pca.SetValidateIDToken(false)
+	testWrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = testWrm
+	// A legacy cache with a refresh token and no account
+	legacyCache := []byte(`{"RefreshToken":{"uid.utid-legacy.contoso.com-refreshtoken-clientid--":{"home_account_id":"uid.utid",` +
+		`"environment":"legacy.contoso.com","credential_type":"RefreshToken","client_id":"clientID","secret":"legacyRT"}}}`)
+	if err := pca.clientApplication.cacheContext.cache.DeserializeCache(legacyCache); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if accounts := pca.GetAccounts(); len(accounts) != 0 {
+		t.Fatalf("The legacy cache shouldn't have an account, but it has %d", len(accounts))
+	}
+	testWrm.On("GetAadinstanceDiscoveryResponse", mock.AnythingOfType("*msalbase.AuthorityInfo")).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"legacy.contoso.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://legacy.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	rawIDToken := "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"oid":"uid","tid":"utid","preferred_username":"user@contoso.com"}`)) + ".signature"
+	idToken, err := msalbase.CreateIDToken(rawIDToken)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "migratedAT",
+		RefreshToken:  "rotatedRT",
+		IDToken:       idToken,
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "legacyRT", mock.Anything).Return(tokenResp, nil)
+	homeAccountID := pca.GetAllRefreshTokens(true)[0].HomeAccountID
+	result, err := pca.AcquireTokenSilent(context.Background(), CreateAcquireTokenSilentParametersWithHomeAccountID([]string{"user.read"}, homeAccountID))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "migratedAT" {
+		t.Errorf("The legacy refresh token should be redeemed for migratedAT, instead the access token is %s", result.GetAccessToken())
+	}
+	accounts := pca.GetAccounts()
+	if len(accounts) != 1 || accounts[0].GetHomeAccountID() != "uid.utid" || accounts[0].GetUsername() != "user@contoso.com" {
+		t.Fatalf("The account should be created from the ID token of the response, instead the accounts are %v", accounts)
+	}
+	if result.GetAccount() == nil || result.GetAccount().GetHomeAccountID() != "uid.utid" {
+		t.Errorf("The result should have the created account, instead it has %+v", result.GetAccount())
+	}
+	// The created account is used like any other afterwards
+	if _, err := pca.AcquireTokenSilent(context.Background(), CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, accounts[0])); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenFromRefreshToken", 1)
+}
+
+// slowDiscoveryWebRequestManager answers tenant discovery without going through the mock, whose lock would
+// otherwise order the requests that use the endpoints cache
+type slowDiscoveryWebRequestManager struct {
+	*requests.MockWebRequestManager
+}
+
+func (m slowDiscoveryWebRequestManager) GetTenantDiscoveryResponse(ctx context.Context, openIDConfigurationEndpoint string) (*requests.TenantDiscoveryResponse, error) {
+	// The slow lookups keep the requests from running one after the other
+	time.Sleep(10 * time.Millisecond)
+	return tdr, nil
+}
+
+func TestAcquireTokenSilentConcurrentAuthorities(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://concurrent.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetInstanceDiscovery(false)
+	pca.SetAllowRefreshTokenMigration(true)
+	testWrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = slowDiscoveryWebRequestManager{testWrm}
+	const acquisitions = 16
+	// Refreshes of the same account are serialized, so every request is for its own account
+	var accounts []AccountProvider
+	for i := 0; i < acquisitions; i++ {
+		account, err := pca.ImportRefreshToken(fmt.Sprintf("uid%d.utid", i), "concurrentRT", "")
+		if err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "concurrentAT",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		// Without client info the tokens are cached for the account whose refresh token was redeemed
+		ClientInfo: &msalbase.ClientInfoJSONPayload{},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "concurrentRT", mock.Anything).Return(tokenResp, nil)
+	// Every authority resolves its endpoints, so the requests read and write the endpoints cache at the same time
+	errs := make(chan error, acquisitions)
+	start := make(chan struct{})
+	for i := 0; i < acquisitions; i++ {
+		go func(i int) {
+			<-start
+			silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, accounts[i])
+			silentParams.SetAuthority(fmt.Sprintf("https://concurrent.contoso.com/tenant%d/", i))
+			_, err := pca.AcquireTokenSilent(context.Background(), silentParams)
+			errs <- err
+		}(i)
+	}
+	close(start)
+	for i := 0; i < acquisitions; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Error should be nil, but it is %v", err)
+		}
+	}
+}
+
+func TestAuthenticationResultAccount(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.microsoftonline.com/resultaccount/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetValidateIDToken(false)
+	testWrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetAadinstanceDiscoveryResponse", mock.AnythingOfType("*msalbase.AuthorityInfo")).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"login.microsoftonline.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://login.microsoftonline.com/resultaccount/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	rawIDToken := "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"oid":"uid","tid":"utid","preferred_username":"user@contoso.com"}`)) + ".signature"
+	idToken, err := msalbase.CreateIDToken(rawIDToken)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	tokenResp := &msalbase.TokenResponse{
+		AccessToken:   "secret",
+		RefreshToken:  "refreshSecret",
+		IDToken:       idToken,
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	testWrm.On("GetAccessTokenFromAuthCode", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "code", "", mock.Anything).Return(tokenResp, nil)
+	authCodeParams := CreateAcquireTokenAuthCodeParameters([]string{"user.read"}, "redirect")
+	authCodeParams.Code = "code"
+	result, err := pca.AcquireTokenByAuthCode(context.Background(), authCodeParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	accounts := pca.GetAccounts()
+	if len(accounts) != 1 {
+		t.Fatalf("One account should be cached, instead there are %d", len(accounts))
+	}
+	if result.GetAccount() == nil || result.GetAccount().GetHomeAccountID() != accounts[0].GetHomeAccountID() {
+		t.Errorf("The result should have the cached account %s, instead it has %+v", accounts[0].GetHomeAccountID(), result.GetAccount())
+	}
+	if result.GetRawIDToken() != rawIDToken {
+		t.Errorf("The result should have the raw ID token, instead it has %s", result.GetRawIDToken())
+	}
+	if claims, err := result.GetIDTokenClaims(); err != nil || claims["preferred_username"] != "user@contoso.com" {
+		t.Errorf("The result should have the claims of the ID token, instead they are %v with error %v", claims, err)
+	}
+	silentResult, err := pca.AcquireTokenSilent(context.Background(), CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, accounts[0]))
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if silentResult.GetAccount() == nil || silentResult.GetAccount().GetHomeAccountID() != "uid.utid" {
+		t.Errorf("The cached result should have the cached account, instead it has %+v", silentResult.GetAccount())
+	}
+}
+
+func createRefreshTokenTestClient(t *testing.T) (*PublicClientApplication, *requests.MockWebRequestManager, AccountProvider) {
+	pca, err := CreatePublicClientApplication("clientID", "https://migration.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	pca.SetValidateAuthority(false)
+	pca.SetAllowRefreshTokenMigration(true)
+	testWrm := new(requests.MockWebRequestManager)
+	pca.clientApplication.webRequestManager = testWrm
+	testWrm.On("GetAadinstanceDiscoveryResponse", mock.AnythingOfType("*msalbase.AuthorityInfo")).Return(&requests.InstanceDiscoveryResponse{
+		Metadata: []*requests.InstanceDiscoveryMetadata{{Aliases: []string{"migration.contoso.com"}}},
+	}, nil)
+	testWrm.On("GetTenantDiscoveryResponse",
+		"https://migration.contoso.com/tenant/v2.0/.well-known/openid-configuration").Return(tdr, nil)
+	account, err := pca.ImportRefreshToken("uid.utid", "firstRT", "")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	return pca, testWrm, account
+}
+
+func TestAcquireTokenSilentRotatedRefreshToken(t *testing.T) {
+	pca, testWrm, account := createRefreshTokenTestClient(t)
+	// The refresh response has no client info, the rotated refresh token is still cached for the account
+	rotatedResp := &msalbase.TokenResponse{
+		AccessToken:   "firstAT",
+		RefreshToken:  "rotatedRT",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "firstRT", mock.Anything).Return(rotatedResp, nil).Once()
+	secondResp := &msalbase.TokenResponse{
+		AccessToken:   "secondAT",
+		RefreshToken:  "secondRT",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{UID: "uid", Utid: "utid"},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "rotatedRT", mock.Anything).Return(secondResp, nil).Once()
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, account)
+	if _, err := pca.AcquireTokenSilent(context.Background(), silentParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	exported, err := pca.ExportRefreshToken(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if exported != "rotatedRT" {
+		t.Fatalf("The rotated refresh token should replace the redeemed one, instead the cached refresh token is %s", exported)
+	}
+	// The claims challenge skips the cached access token, so the rotated refresh token is redeemed next
+	silentParams.SetClaims(`{"access_token":{"nbf":{"essential":true}}}`)
+	result, err := pca.AcquireTokenSilent(context.Background(), silentParams)
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if result.GetAccessToken() != "secondAT" {
+		t.Errorf("The rotated refresh token should be redeemed for secondAT, instead the access token is %s", result.GetAccessToken())
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenFromRefreshToken", 2)
+}
+
+func TestOnRefreshTokenUpdated(t *testing.T) {
+	pca, testWrm, account := createRefreshTokenTestClient(t)
+	var updated, invalidated []string
+	pca.SetOnRefreshTokenUpdated(func(account AccountProvider) { updated = append(updated, account.GetHomeAccountID()) })
+	pca.SetOnRefreshTokenInvalidated(func(account AccountProvider) { invalidated = append(invalidated, account.GetHomeAccountID()) })
+	rotatedResp := &msalbase.TokenResponse{
+		AccessToken:   "firstAT",
+		RefreshToken:  "rotatedRT",
+		GrantedScopes: []string{"user.read"},
+		ExpiresOn:     time.Now().Add(time.Hour),
+		ExtExpiresOn:  time.Now().Add(time.Hour),
+		ClientInfo:    &msalbase.ClientInfoJSONPayload{},
+	}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "firstRT", mock.Anything).Return(rotatedResp, nil).Once()
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, account)
+	if _, err := pca.AcquireTokenSilent(context.Background(), silentParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	// The cached access token is returned without a refresh, so the callback isn't called again
+	if _, err := pca.AcquireTokenSilent(context.Background(), silentParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if !reflect.DeepEqual(updated, []string{"uid.utid"}) || len(invalidated) != 0 {
+		t.Errorf("The updated callback should be called once for uid.utid, instead the updated accounts are %v and the invalidated accounts %v", updated, invalidated)
+	}
+}
+
+func TestOnRefreshTokenInvalidated(t *testing.T) {
+	pca, testWrm, account := createRefreshTokenTestClient(t)
+	var updated, invalidated []string
+	pca.SetOnRefreshTokenUpdated(func(account AccountProvider) { updated = append(updated, account.GetHomeAccountID()) })
+	pca.SetOnRefreshTokenInvalidated(func(account AccountProvider) {
+		invalidated = append(invalidated, account.GetHomeAccountID())
+		// The refresh token is already removed, and the cache can be used from the callback
+		if _, err := pca.ExportRefreshToken(context.Background(), account); err != ErrNoCachedToken {
+			t.Errorf("The revoked refresh token should be removed before the callback, instead the export error is %v", err)
+		}
+	})
+	revoked := &CallError{Code: "invalid_grant", Description: "the refresh token was revoked", StatusCode: 400}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "firstRT", mock.Anything).
+		Return((*msalbase.TokenResponse)(nil), revoked)
+	if _, err := pca.AcquireTokenSilent(context.Background(), CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, account)); err == nil {
+		t.Fatal("Error should not be nil when the refresh token was revoked")
+	}
+	if !reflect.DeepEqual(invalidated, []string{"uid.utid"}) || len(updated) != 0 {
+		t.Errorf("The invalidated callback should be called once for uid.utid, instead the invalidated accounts are %v and the updated accounts %v", invalidated, updated)
+	}
+}
+
+func TestAcquireTokenSilentRevokedRefreshToken(t *testing.T) {
+	pca, testWrm, account := createRefreshTokenTestClient(t)
+	revoked := &CallError{Code: "invalid_grant", Description: "the refresh token was revoked", StatusCode: 400}
+	testWrm.On("GetAccessTokenFromRefreshToken", mock.AnythingOfType("*msalbase.AuthParametersInternal"), "firstRT", mock.Anything).
+		Return((*msalbase.TokenResponse)(nil), revoked)
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"user.read"}, account)
+	_, err := pca.AcquireTokenSilent(context.Background(), silentParams)
+	var interactionErr *InteractionRequiredError
+	if !errors.As(err, &interactionErr) {
+		t.Fatalf("Error should be an InteractionRequiredError, instead it is %v", err)
+	}
+	var callErr *CallError
+	if !errors.As(err, &callErr) || callErr.Code != "invalid_grant" {
+		t.Errorf("The InteractionRequiredError should wrap the invalid_grant error, instead it wraps %v", errors.Unwrap(err))
+	}
+	if _, err := pca.ExportRefreshToken(context.Background(), account); err != ErrNoCachedToken {
+		t.Errorf("The revoked refresh token should be removed from the cache, instead the export error is %v", err)
+	}
+	_, err = pca.AcquireTokenSilent(context.Background(), silentParams)
+	if !errors.As(err, &interactionErr) {
+		t.Errorf("Error should be an InteractionRequiredError once the refresh token is removed, instead it is %v", err)
+	}
+	testWrm.AssertNumberOfCalls(t, "GetAccessTokenFromRefreshToken", 1)
+}
@@ -0,0 +1,16 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"io"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// SetRandomSource replaces crypto/rand as the source of the random bytes of PKCE code verifiers, client assertion IDs, PoP keys and nonces,
+// authorization code URL nonces and correlation IDs, e.g. with a seeded reader so tests get deterministic values.
+// There's one source for the process, it's read by every client application. Passing nil restores crypto/rand.
+// Outside of tests it has to be a cryptographically secure source.
+func SetRandomSource(reader io.Reader) { msalbase.SetRandomSource(reader) }
@@ -0,0 +1,157 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// RetryPolicy sets how requests that fail with a transient error are retried.
+// A request is retried when the authority can't be reached or answers with a 429 or 5xx status,
+// other errors such as invalid_grant are returned right away.
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is retried, zero turns retries off.
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry, it doubles with every retry and has jitter added.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait before a retry, including the wait asked for by a Retry-After header.
+	MaxBackoff time.Duration
+}
+
+// defaultRetryPolicy is used by client applications unless SetRetryPolicy is called
+var defaultRetryPolicy = RetryPolicy{MaxRetries: 2, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// retryHTTPManager retries the requests of the wrapped HTTPManager according to the policy
+type retryHTTPManager struct {
+	httpManager HTTPManager
+	policy      *RetryPolicy
+	sleep       func(context.Context, time.Duration) error
+	//isTransient tells which failures are retried, endpoints other than the authority have their own transient failures
+	isTransient func(HTTPManagerResponse, error) bool
+	//timeout bounds every attempt of a request, a deadline of the context that comes sooner still applies; there's no bound when it's nil or not positive
+	timeout *time.Duration
+}
+
+func createRetryHTTPManager(httpManager HTTPManager, policy *RetryPolicy) *retryHTTPManager {
+	return &retryHTTPManager{httpManager: httpManager, policy: policy, sleep: sleepWithContext, isTransient: isTransientFailure}
+}
+
+// sleepWithContext waits for the duration, it returns the error of the context if the context is done first
+func sleepWithContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Get sends a get request, retrying it when it fails with a transient error
+func (m *retryHTTPManager) Get(ctx context.Context, url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	return m.send(ctx, func(ctx context.Context) (HTTPManagerResponse, error) {
+		return m.httpManager.Get(ctx, url, requestHeaders)
+	})
+}
+
+// Post sends a post request, retrying it when it fails with a transient error
+func (m *retryHTTPManager) Post(ctx context.Context, url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	return m.send(ctx, func(ctx context.Context) (HTTPManagerResponse, error) {
+		return m.httpManager.Post(ctx, url, body, requestHeaders)
+	})
+}
+
+//send stops retrying once the context is done, a canceled request isn't a transient failure
+func (m *retryHTTPManager) send(ctx context.Context, request func(context.Context) (HTTPManagerResponse, error)) (HTTPManagerResponse, error) {
+	for retry := 0; ; retry++ {
+		response, err := m.sendAttempt(ctx, request)
+		if retry >= m.policy.MaxRetries || ctx.Err() != nil || !m.isTransient(response, err) {
+			return response, err
+		}
+		var failedResponse HTTPManagerResponse
+		if err == nil {
+			failedResponse = response
+		}
+		delay := m.policy.getBackoff(retry, failedResponse)
+		msalbase.GetLogger().Warnf("Request failed with a transient error, retrying in %v", delay)
+		if err := m.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//sendAttempt sends the request once, the HTTP managers read the whole response so the attempt's context can be canceled once it returns
+func (m *retryHTTPManager) sendAttempt(ctx context.Context, request func(context.Context) (HTTPManagerResponse, error)) (HTTPManagerResponse, error) {
+	if m.timeout == nil || *m.timeout <= 0 {
+		return request(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, *m.timeout)
+	defer cancel()
+	return request(attemptCtx)
+}
+
+// isTransientFailure checks if the request failed to connect, lost its connection or timed out, or was answered with throttling or a server error.
+// Other failures without an answer, e.g. a canceled request, a TLS error or a response over the size limit, aren't transient
+func isTransientFailure(response HTTPManagerResponse, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			return true
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) && netErr.Timeout()
+	}
+	code := response.GetResponseCode()
+	return code == http.StatusTooManyRequests || (code >= 500 && code <= 599)
+}
+
+// getBackoff returns the wait before the retry, a Retry-After header of the response is used when there is one.
+// The response is nil when the request failed without one.
+func (policy *RetryPolicy) getBackoff(retry int, response HTTPManagerResponse) time.Duration {
+	delay, ok := getRetryAfter(response)
+	if !ok {
+		delay = policy.InitialBackoff << uint(retry)
+		if delay > 0 {
+			delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+		}
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	return delay
+}
+
+// getRetryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date
+func getRetryAfter(response HTTPManagerResponse) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	retryAfter, ok := response.GetHeaders()["Retry-After"]
+	if !ok {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,225 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func createTestRetryHTTPManager(httpManager HTTPManager, policy RetryPolicy) (*retryHTTPManager, *[]time.Duration) {
+	delays := &[]time.Duration{}
+	manager := createRetryHTTPManager(httpManager, &policy)
+	manager.sleep = func(ctx context.Context, delay time.Duration) error {
+		*delays = append(*delays, delay)
+		return nil
+	}
+	return manager, delays
+}
+
+func TestRetryHTTPManagerRetriesTransientFailure(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retry/oauth2/v2.0/token"
+	unavailable := &msalHTTPManagerResponse{responseCode: 503}
+	ok := &msalHTTPManagerResponse{responseCode: 200, responseData: `{"access_token":"secret"}`}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(unavailable, nil).Once()
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(ok, nil).Once()
+	manager, delays := createTestRetryHTTPManager(mockHTTPManager, defaultRetryPolicy)
+	response, err := manager.Post(context.Background(), url, "body", testHeaders)
+	if err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if response != ok {
+		t.Errorf("Actual response %+v differs from expected response %+v", response, ok)
+	}
+	if len(*delays) != 1 {
+		t.Errorf("Expected a single retry, but there were %v", len(*delays))
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 2)
+}
+
+func TestRetryHTTPManagerDoesNotRetryClientError(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retry/oauth2/v2.0/token"
+	invalidGrant := &msalHTTPManagerResponse{responseCode: 400, responseData: `{"error":"invalid_grant"}`}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(invalidGrant, nil)
+	manager, delays := createTestRetryHTTPManager(mockHTTPManager, defaultRetryPolicy)
+	response, _ := manager.Post(context.Background(), url, "body", testHeaders)
+	if response != invalidGrant {
+		t.Errorf("Actual response %+v differs from expected response %+v", response, invalidGrant)
+	}
+	if len(*delays) != 0 {
+		t.Errorf("Expected no retries, but there were %v", len(*delays))
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 1)
+}
+
+func TestRetryHTTPManagerGivesUpAfterMaxRetries(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retry/discovery/instance"
+	var noResponse *msalHTTPManagerResponse
+	mockHTTPManager.On("Get", url, testHeaders).Return(noResponse, &net.OpError{Op: "read"})
+	manager, delays := createTestRetryHTTPManager(mockHTTPManager, RetryPolicy{MaxRetries: 3, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second})
+	_, err := manager.Get(context.Background(), url, testHeaders)
+	if err == nil {
+		t.Error("Error should be returned once the retries are used up")
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Get", 4)
+	for i, delay := range *delays {
+		minDelay := time.Second << uint(i)
+		if minDelay > 3*time.Second {
+			minDelay = 3 * time.Second
+		}
+		if delay < minDelay || delay > 3*time.Second {
+			t.Errorf("Retry %v waited %v, expected between %v and %v", i, delay, minDelay, 3*time.Second)
+		}
+	}
+}
+
+func TestRetryHTTPManagerHonorsRetryAfter(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retry/oauth2/v2.0/token"
+	throttled := &msalHTTPManagerResponse{responseCode: 429, headers: map[string]string{"Retry-After": "7"}}
+	ok := &msalHTTPManagerResponse{responseCode: 200}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(throttled, nil).Once()
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(ok, nil).Once()
+	manager, delays := createTestRetryHTTPManager(mockHTTPManager, defaultRetryPolicy)
+	if _, err := manager.Post(context.Background(), url, "body", testHeaders); err != nil {
+		t.Errorf("Error should be nil, but it is %v", err)
+	}
+	if len(*delays) != 1 || (*delays)[0] != 7*time.Second {
+		t.Errorf("Expected a single retry after 7s, but the retries waited %v", *delays)
+	}
+}
+
+func TestRetryHTTPManagerDisabled(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retry/oauth2/v2.0/token"
+	unavailable := &msalHTTPManagerResponse{responseCode: 503}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(unavailable, nil)
+	manager, _ := createTestRetryHTTPManager(mockHTTPManager, RetryPolicy{})
+	response, _ := manager.Post(context.Background(), url, "body", testHeaders)
+	if response != unavailable {
+		t.Errorf("Actual response %+v differs from expected response %+v", response, unavailable)
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 1)
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		retryAfter string
+		ok         bool
+	}{
+		{"120", true},
+		{date, true},
+		{"soon", false},
+	}
+	for _, test := range tests {
+		response := &msalHTTPManagerResponse{headers: map[string]string{"Retry-After": test.retryAfter}}
+		delay, ok := getRetryAfter(response)
+		if ok != test.ok {
+			t.Errorf("Retry-After %v parsed as %v, expected %v", test.retryAfter, ok, test.ok)
+		}
+		if ok && (delay <= 0 || delay > 2*time.Minute) {
+			t.Errorf("Retry-After %v gave an unexpected delay %v", test.retryAfter, delay)
+		}
+	}
+}
+
+func TestRetryHTTPManagerStopsWhenCanceled(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retrycanceled/oauth2/v2.0/token"
+	unavailable := &msalHTTPManagerResponse{responseCode: 503}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return(unavailable, nil)
+	manager := createRetryHTTPManager(mockHTTPManager, &RetryPolicy{MaxRetries: 2, InitialBackoff: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := manager.Post(ctx, url, "body", testHeaders)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Error should be context.Canceled, instead it is %v", err)
+	}
+	if time.Since(start) > time.Minute {
+		t.Errorf("Canceling should stop the backoff, instead it took %v", time.Since(start))
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 1)
+}
+
+func TestRetryHTTPManagerDoesNotRetryDoneContext(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	url := "https://login.microsoftonline.com/retrydone/oauth2/v2.0/token"
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	mockHTTPManager.On("Post", url, "body", testHeaders).Return((*msalHTTPManagerResponse)(nil), refused)
+	manager, delays := createTestRetryHTTPManager(mockHTTPManager, defaultRetryPolicy)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := manager.Post(ctx, url, "body", testHeaders); err != refused {
+		t.Errorf("Actual error is %v, expected error is %v", err, refused)
+	}
+	if len(*delays) != 0 {
+		t.Errorf("Expected no retries once the context is done, but there were %v", len(*delays))
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 1)
+}
+
+func TestIsTransientFailure(t *testing.T) {
+	tests := []struct {
+		desc      string
+		response  HTTPManagerResponse
+		err       error
+		transient bool
+	}{
+		{"dial error", nil, &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, true},
+		{"connection reset", nil, &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}}, true},
+		{"timeout", nil, &url.Error{Op: "Post", Err: context.DeadlineExceeded}, true},
+		{"canceled", nil, &url.Error{Op: "Post", Err: context.Canceled}, false},
+		{"TLS error", nil, &url.Error{Op: "Post", Err: errors.New("x509: certificate signed by unknown authority")}, false},
+		{"other error", nil, errors.New("response is too large"), false},
+		{"throttling", &msalHTTPManagerResponse{responseCode: 429}, nil, true},
+		{"server error", &msalHTTPManagerResponse{responseCode: 503}, nil, true},
+		{"client error", &msalHTTPManagerResponse{responseCode: 400}, nil, false},
+	}
+	for _, test := range tests {
+		if transient := isTransientFailure(test.response, test.err); transient != test.transient {
+			t.Errorf("Transient should be %v for the %s, instead it is %v", test.transient, test.desc, transient)
+		}
+	}
+}
+
+func TestRetryHTTPManagerTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	timeout := 50 * time.Millisecond
+	manager := createRetryHTTPManager(createHTTPManager(), &RetryPolicy{})
+	manager.timeout = &timeout
+	start := time.Now()
+	_, err := manager.Get(context.Background(), server.URL, testHeaders)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Error should be a timeout, instead it is %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("The request should stop at the timeout, instead it took %v", time.Since(start))
+	}
+	// The deadline of the context wins when it comes before the timeout
+	timeout = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = manager.Get(ctx, server.URL, testHeaders)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Error should be context.DeadlineExceeded, instead it is %v", err)
+	}
+}
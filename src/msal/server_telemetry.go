@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// maxTelemetryFailures bounds the failures kept for the last telemetry header, older failures are dropped first
+const maxTelemetryFailures = 10
+
+type telemetryFailure struct {
+	apiID         string
+	correlationID string
+	errorCode     string
+}
+
+// serverTelemetry keeps what the current and last telemetry headers report to AAD, its zero value is ready to use.
+// It's safe for concurrent use.
+type serverTelemetry struct {
+	lock sync.Mutex
+	// lastSuccessfulAPI is the API ID of the last token request that succeeded
+	lastSuccessfulAPI string
+	// failures are the silent token requests that failed since the last success
+	failures []telemetryFailure
+}
+
+// getAPIID identifies the API of a token request in the telemetry headers
+func getAPIID(authParameters *msalbase.AuthParametersInternal) string {
+	return strconv.Itoa(int(authParameters.AuthorizationType))
+}
+
+// getTelemetryErrorCode returns the OAuth error code of a failure, the commas and pipes separating the header fields can't appear in it
+func getTelemetryErrorCode(err error) string {
+	var callErr *CallError
+	if errors.As(err, &callErr) && callErr.Code != "" && !strings.ContainsAny(callErr.Code, ",|") {
+		return callErr.Code
+	}
+	return "unknown_error"
+}
+
+// recordSuccess remembers the API of the successful request, the failures reported before it are cleared
+func (t *serverTelemetry) recordSuccess(authParameters *msalbase.AuthParametersInternal) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.lastSuccessfulAPI = getAPIID(authParameters)
+	t.failures = nil
+}
+
+// recordFailure remembers a failed silent token request, so the next request reports it
+func (t *serverTelemetry) recordFailure(authParameters *msalbase.AuthParametersInternal, err error) {
+	if authParameters.AuthorizationType != msalbase.AuthorizationTypeRefreshTokenExchange {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.failures = append(t.failures, telemetryFailure{
+		apiID:         getAPIID(authParameters),
+		correlationID: authParameters.CorrelationID,
+		errorCode:     getTelemetryErrorCode(err),
+	})
+	if len(t.failures) > maxTelemetryFailures {
+		t.failures = t.failures[len(t.failures)-maxTelemetryFailures:]
+	}
+}
+
+// addHeaders adds the current telemetry header when a request succeeded before,
+// and the last telemetry header when silent requests failed since then
+func (t *serverTelemetry) addHeaders(headers map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.lastSuccessfulAPI != "" {
+		headers[msalbase.CurrentTelemetryHeaderName] = msalbase.TelemetrySchemaVersion + "|" + t.lastSuccessfulAPI + ",0|"
+	}
+	if len(t.failures) == 0 {
+		return
+	}
+	failedRequests := make([]string, 0, 2*len(t.failures))
+	errorCodes := make([]string, 0, len(t.failures))
+	for _, failure := range t.failures {
+		failedRequests = append(failedRequests, failure.apiID, failure.correlationID)
+		errorCodes = append(errorCodes, failure.errorCode)
+	}
+	headers[msalbase.LastTelemetryHeaderName] = strings.Join([]string{
+		msalbase.TelemetrySchemaVersion,
+		"0",
+		strings.Join(failedRequests, ","),
+		strings.Join(errorCodes, ","),
+		"",
+	}, "|")
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLastTelemetryReportsSilentFailures(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{
+		Endpoints:         testAuthorityEndpoints,
+		AuthorizationType: msalbase.AuthorizationTypeRefreshTokenExchange,
+		CorrelationID:     "failedCorrelationID",
+	}
+	failure := &msalHTTPManagerResponse{
+		responseCode: 400,
+		responseData: `{"error":"invalid_grant", "error_description":"the refresh token expired"}`,
+	}
+	success := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`,
+	}
+	var sent []map[string]string
+	recordHeaders := func(args mock.Arguments) { sent = append(sent, args.Get(2).(map[string]string)) }
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Run(recordHeaders).Return(failure, nil).Once()
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Run(recordHeaders).Return(success, nil)
+	if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err == nil {
+		t.Fatal("Error should be returned for the invalid_grant response")
+	}
+	authParams.CorrelationID = "nextCorrelationID"
+	for i := 0; i < 2; i++ {
+		if _, err := wrm.GetAccessTokenFromRefreshToken(context.Background(), authParams, "refreshToken", nil); err != nil {
+			t.Fatalf("Error should be nil, but it is %v", err)
+		}
+	}
+	if telemetry, ok := sent[0][msalbase.LastTelemetryHeaderName]; ok {
+		t.Errorf("The first request shouldn't report failures, instead it sent %s", telemetry)
+	}
+	apiID := strconv.Itoa(int(msalbase.AuthorizationTypeRefreshTokenExchange))
+	expected := "2|0|" + apiID + ",failedCorrelationID|invalid_grant|"
+	if sent[1][msalbase.LastTelemetryHeaderName] != expected {
+		t.Errorf("Last telemetry should be %s, instead it is %s", expected, sent[1][msalbase.LastTelemetryHeaderName])
+	}
+	if telemetry, ok := sent[2][msalbase.LastTelemetryHeaderName]; ok {
+		t.Errorf("The failures should be cleared by the successful request, instead %s was sent", telemetry)
+	}
+}
+
+func TestLastTelemetryIsBounded(t *testing.T) {
+	telemetry := &serverTelemetry{}
+	authParams := &msalbase.AuthParametersInternal{AuthorizationType: msalbase.AuthorizationTypeRefreshTokenExchange}
+	for i := 0; i < maxTelemetryFailures+5; i++ {
+		authParams.CorrelationID = strconv.Itoa(i)
+		telemetry.recordFailure(authParams, errors.New("dial tcp: i/o timeout"))
+	}
+	if len(telemetry.failures) != maxTelemetryFailures {
+		t.Fatalf("%d failures should be kept, instead there are %d", maxTelemetryFailures, len(telemetry.failures))
+	}
+	if telemetry.failures[0].correlationID != "5" || telemetry.failures[0].errorCode != "unknown_error" {
+		t.Errorf("The oldest failures should be dropped, instead the first failure is %v", telemetry.failures[0])
+	}
+	telemetry.recordFailure(&msalbase.AuthParametersInternal{AuthorizationType: msalbase.AuthorizationTypeAuthCode}, errors.New("invalid_grant"))
+	if telemetry.failures[len(telemetry.failures)-1].correlationID != strconv.Itoa(maxTelemetryFailures+4) {
+		t.Error("Failures of interactive requests shouldn't be recorded")
+	}
+}
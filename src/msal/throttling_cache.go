@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// defaultThrottleDuration is how long a request is throttled when the authority answers 429 without a Retry-After header
+const defaultThrottleDuration = 60 * time.Second
+
+// throttlingCache records the requests the authority throttled, so identical requests aren't sent until the throttling window passes.
+// It's safe for concurrent use.
+type throttlingCache struct {
+	lock    sync.Mutex
+	entries map[string]time.Time
+	nowFunc func() time.Time
+}
+
+func createThrottlingCache() *throttlingCache {
+	return &throttlingCache{entries: map[string]time.Time{}, nowFunc: time.Now}
+}
+
+// getThrottleKey returns the signature of a token request, made of its token endpoint, client and scopes
+func getThrottleKey(authParameters *msalbase.AuthParametersInternal) string {
+	var endpoint string
+	if authParameters.Endpoints != nil {
+		endpoint = authParameters.Endpoints.TokenEndpoint
+	}
+	return strings.Join([]string{endpoint, authParameters.ClientID, msalbase.CanonicalScopes(authParameters.Scopes)}, "|")
+}
+
+// checkThrottled returns a ThrottledError if an identical request was throttled and its window hasn't passed
+func (c *throttlingCache) checkThrottled(authParameters *msalbase.AuthParametersInternal) error {
+	if c == nil {
+		return nil
+	}
+	key := getThrottleKey(authParameters)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	retryAfter, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.nowFunc().Before(retryAfter) {
+		delete(c.entries, key)
+		return nil
+	}
+	return &ThrottledError{RetryAfter: retryAfter}
+}
+
+// recordResponse throttles the request if the authority answered 429, or a 5xx with a Retry-After header
+func (c *throttlingCache) recordResponse(authParameters *msalbase.AuthParametersInternal, response HTTPManagerResponse) {
+	if c == nil {
+		return
+	}
+	code := response.GetResponseCode()
+	delay, hasRetryAfter := getRetryAfter(response)
+	isServerError := code >= 500 && code <= 599
+	if code != http.StatusTooManyRequests && !(isServerError && hasRetryAfter) {
+		return
+	}
+	if !hasRetryAfter {
+		delay = defaultThrottleDuration
+	}
+	msalbase.GetLogger().Warnf("The authority throttled the request, identical requests are blocked for %v", delay)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[getThrottleKey(authParameters)] = c.nowFunc().Add(delay)
+}
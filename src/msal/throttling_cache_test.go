@@ -0,0 +1,91 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestThrottledRequestIsBlockedUntilWindowPasses(t *testing.T) {
+	now := time.Now()
+	cache := createThrottlingCache()
+	cache.nowFunc = func() time.Time { return now }
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager, throttlingCache: cache}
+	authParams := &msalbase.AuthParametersInternal{
+		ClientID:  "clientID",
+		Scopes:    []string{"user.read"},
+		Endpoints: testAuthorityEndpoints,
+	}
+	throttled := &msalHTTPManagerResponse{
+		responseCode: 429,
+		responseData: `{"error":"temporarily_unavailable"}`,
+		headers:      map[string]string{"Retry-After": "30"},
+	}
+	ok := &msalHTTPManagerResponse{
+		responseCode: 200,
+		responseData: `{"access_token":"secret", "expires_in":10, "ext_expires_in":10}`,
+	}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(throttled, nil).Once()
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(ok, nil).Once()
+	if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "secret"); err == nil {
+		t.Error("Error should be returned for the throttled response")
+	}
+	_, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "secret")
+	var throttledErr *ThrottledError
+	if !errors.As(err, &throttledErr) {
+		t.Fatalf("Expected a ThrottledError, but the error is %v", err)
+	}
+	if !throttledErr.RetryAfter.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("Actual retry after %v differs from expected %v", throttledErr.RetryAfter, now.Add(30*time.Second))
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 1)
+	otherScopes := &msalbase.AuthParametersInternal{
+		ClientID:  "clientID",
+		Scopes:    []string{"mail.read"},
+		Endpoints: testAuthorityEndpoints,
+	}
+	if err := cache.checkThrottled(otherScopes); err != nil {
+		t.Errorf("Requests for other scopes shouldn't be throttled, but got %v", err)
+	}
+	now = now.Add(30 * time.Second)
+	if _, err := wrm.GetAccessTokenWithClientSecret(context.Background(), authParams, "secret"); err != nil {
+		t.Errorf("Error should be nil after the throttling window, but it is %v", err)
+	}
+	mockHTTPManager.AssertNumberOfCalls(t, "Post", 2)
+}
+
+func TestThrottlingCacheRecordResponse(t *testing.T) {
+	now := time.Now()
+	authParams := &msalbase.AuthParametersInternal{ClientID: "clientID", Endpoints: testAuthorityEndpoints}
+	tests := []struct {
+		response   *msalHTTPManagerResponse
+		retryAfter time.Time
+	}{
+		{&msalHTTPManagerResponse{responseCode: 429}, now.Add(defaultThrottleDuration)},
+		{&msalHTTPManagerResponse{responseCode: 503, headers: map[string]string{"Retry-After": "5"}}, now.Add(5 * time.Second)},
+		{&msalHTTPManagerResponse{responseCode: 503}, time.Time{}},
+		{&msalHTTPManagerResponse{responseCode: 400, headers: map[string]string{"Retry-After": "5"}}, time.Time{}},
+	}
+	for _, test := range tests {
+		cache := createThrottlingCache()
+		cache.nowFunc = func() time.Time { return now }
+		cache.recordResponse(authParams, test.response)
+		err := cache.checkThrottled(authParams)
+		var throttledErr *ThrottledError
+		if test.retryAfter.IsZero() {
+			if err != nil {
+				t.Errorf("Response %v shouldn't throttle the request, but got %v", test.response.responseCode, err)
+			}
+		} else if !errors.As(err, &throttledErr) || !throttledErr.RetryAfter.Equal(test.retryAfter) {
+			t.Errorf("Response %v should throttle the request until %v, but got %v", test.response.responseCode, test.retryAfter, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// Tracer is an interface for recording MSAL's operations as spans of an application's distributed traces, e.g. with OpenTelemetry.
+// StartSpan is passed the context of the operation, spans started with the context it returns are nested in the span.
+// MSAL starts spans for AcquireTokenSilent, instance discovery and requests to the token endpoint.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation started by a Tracer. SetAttribute records the authority, client ID and whether the cache had the token,
+// End is called once with the error the operation failed with, or nil.
+type Span = msalbase.Span
+
+// SetTracer records MSAL's operations as spans started with the tracer; by default no spans are recorded.
+// There's one tracer for the process, it records the operations of every client application. Passing nil turns tracing off.
+func SetTracer(tracer Tracer) { msalbase.SetTracer(tracer) }
@@ -0,0 +1,131 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeSpan struct {
+	name       string
+	parent     *fakeSpan
+	attributes map[string]interface{}
+	ended      bool
+	err        error
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+func (s *fakeSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+type fakeSpanKey struct{}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (tr *fakeTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	span := &fakeSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+	tr.spans = append(tr.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func TestAcquireTokenSilentSpan(t *testing.T) {
+	tracer := &fakeTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+	client, testWrm, testCacheManager := createSilentTestClient()
+	account := msalbase.CreateAccount("hid", "env", "realm", "lid", msalbase.MSSTS, "username")
+	silentParams := CreateAcquireTokenSilentParametersWithAccount([]string{"openid"}, account)
+	at := new(msalbase.MockAccessToken)
+	at.On("GetSecret").Return("secret")
+	at.On("GetExpiresOn").Return("0")
+	at.On("GetScopes").Return("openid")
+	var rt, id *msalbase.MockCredential
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).
+		Return(msalbase.CreateStorageTokenResponse(at, rt, id, account), nil).Once()
+	if _, err := client.acquireTokenSilent(context.Background(), silentParams); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0].name != msalbase.AcquireTokenSilentSpan {
+		t.Fatalf("An AcquireTokenSilent span should be started, instead the spans are %v", tracer.spans)
+	}
+	span := tracer.spans[0]
+	if !span.ended || span.err != nil {
+		t.Errorf("The span should be ended without an error, instead it's ended %v with %v", span.ended, span.err)
+	}
+	expected := map[string]interface{}{
+		msalbase.AuthorityAttribute: testAuthorityInfo.Host,
+		msalbase.ClientIDAttribute:  "clientID",
+		msalbase.CacheHitAttribute:  true,
+	}
+	for key, value := range expected {
+		if span.attributes[key] != value {
+			t.Errorf("Attribute %s should be %v, instead it is %v", key, value, span.attributes[key])
+		}
+	}
+	testCacheManager.On("TryReadCache", mock.AnythingOfType("*msalbase.AuthParametersInternal"), testWrm).
+		Return((*msalbase.StorageTokenResponse)(nil), nil)
+	_, err := client.acquireTokenSilent(context.Background(), silentParams)
+	span = tracer.spans[len(tracer.spans)-1]
+	if !span.ended || span.err != err || span.attributes[msalbase.CacheHitAttribute] != false {
+		t.Errorf("A cache miss should end the span with its error and cache_hit false, instead the span is %+v", span)
+	}
+}
+
+func TestTokenRequestSpanIsNestedInContextSpan(t *testing.T) {
+	tracer := &fakeTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authParams := &msalbase.AuthParametersInternal{
+		Endpoints:     testAuthorityEndpoints,
+		AuthorityInfo: &msalbase.AuthorityInfo{Host: "login.microsoftonline.com", Tenant: "tenant"},
+		ClientID:      "clientID",
+	}
+	failure := &msalHTTPManagerResponse{responseCode: 400, responseData: `{"error":"invalid_client"}`}
+	mockHTTPManager.On("Post", testAuthorityEndpoints.TokenEndpoint, mock.Anything, mock.Anything).Return(failure, nil)
+	ctx, parent := tracer.StartSpan(context.Background(), "operation")
+	_, err := wrm.GetAccessTokenWithClientSecret(ctx, authParams, "csecret")
+	if len(tracer.spans) != 2 || tracer.spans[1].name != msalbase.TokenRequestSpan || tracer.spans[1].parent != parent {
+		t.Fatalf("A token request span nested in the operation should be started, instead the spans are %v", tracer.spans)
+	}
+	span := tracer.spans[1]
+	var callErr *CallError
+	if !span.ended || !errors.As(span.err, &callErr) || span.err != err {
+		t.Errorf("The span should be ended with the error of the request, instead it's ended %v with %v", span.ended, span.err)
+	}
+	if span.attributes[msalbase.AuthorityAttribute] != "login.microsoftonline.com" || span.attributes[msalbase.ClientIDAttribute] != "clientID" {
+		t.Errorf("The span should have the authority and client ID attributes, instead they are %v", span.attributes)
+	}
+}
+
+func TestInstanceDiscoverySpan(t *testing.T) {
+	tracer := &fakeTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+	mockHTTPManager := new(mockHTTPManager)
+	wrm := &defaultWebRequestManager{httpManager: mockHTTPManager}
+	authInfo := &msalbase.AuthorityInfo{Host: "login.microsoftonline.com", Tenant: "tracing"}
+	response := &msalHTTPManagerResponse{responseCode: 200, responseData: `{}`}
+	mockHTTPManager.On("Get", mock.Anything, mock.Anything).Return(response, nil)
+	if _, err := wrm.GetAadinstanceDiscoveryResponse(context.Background(), authInfo); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if len(tracer.spans) != 1 || tracer.spans[0].name != msalbase.InstanceDiscoverySpan || !tracer.spans[0].ended {
+		t.Fatalf("An instance discovery span should be started and ended, instead the spans are %v", tracer.spans)
+	}
+	if tracer.spans[0].attributes[msalbase.AuthorityAttribute] != "login.microsoftonline.com" {
+		t.Errorf("The span should have the authority attribute, instead the attributes are %v", tracer.spans[0].attributes)
+	}
+}
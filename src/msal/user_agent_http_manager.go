@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+)
+
+// userAgentHTTPManager sets the User-Agent header of every request of the wrapped HTTPManager
+type userAgentHTTPManager struct {
+	httpManager HTTPManager
+	//appUserAgent is appended to the product token of the library, it's shared with the client application so it can be set at any time
+	appUserAgent *string
+}
+
+func createUserAgentHTTPManager(httpManager HTTPManager, appUserAgent *string) *userAgentHTTPManager {
+	return &userAgentHTTPManager{httpManager: httpManager, appUserAgent: appUserAgent}
+}
+
+// getUserAgent returns the product token of the library, the one of the SKU and version telemetry headers, followed by the app's
+func (m *userAgentHTTPManager) getUserAgent() string {
+	userAgent := msalbase.ProductHeaderValue + "/" + msalbase.LibraryVersion
+	if m.appUserAgent != nil {
+		if appUserAgent := strings.TrimSpace(*m.appUserAgent); appUserAgent != "" {
+			userAgent += " " + appUserAgent
+		}
+	}
+	return userAgent
+}
+
+// withUserAgent copies the headers, the maps of the callers are sometimes shared between requests
+func (m *userAgentHTTPManager) withUserAgent(requestHeaders map[string]string) map[string]string {
+	headers := make(map[string]string, len(requestHeaders)+1)
+	for k, v := range requestHeaders {
+		headers[k] = v
+	}
+	headers[msalbase.UserAgentHeaderName] = m.getUserAgent()
+	return headers
+}
+
+// Get sends a get request with the User-Agent header
+func (m *userAgentHTTPManager) Get(ctx context.Context, url string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	return m.httpManager.Get(ctx, url, m.withUserAgent(requestHeaders))
+}
+
+// Post sends a post request with the User-Agent header
+func (m *userAgentHTTPManager) Post(ctx context.Context, url string, body string, requestHeaders map[string]string) (HTTPManagerResponse, error) {
+	return m.httpManager.Post(ctx, url, body, m.withUserAgent(requestHeaders))
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package msalgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AzureAD/microsoft-authentication-library-for-go/src/internal/msalbase"
+	"github.com/stretchr/testify/mock"
+)
+
+var testUserAgent = msalbase.ProductHeaderValue + "/" + msalbase.LibraryVersion
+
+func TestUserAgentHTTPManager(t *testing.T) {
+	mockHTTPManager := new(mockHTTPManager)
+	appUserAgent := ""
+	manager := createUserAgentHTTPManager(mockHTTPManager, &appUserAgent)
+	url := "https://login.microsoftonline.com/useragent/oauth2/v2.0/token"
+	requestHeaders := map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"}
+	mockHTTPManager.On("Post", url, "body", map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8", "User-Agent": testUserAgent}).
+		Return(&msalHTTPManagerResponse{responseCode: 200}, nil)
+	if _, err := manager.Post(context.Background(), url, "body", requestHeaders); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	if _, ok := requestHeaders["User-Agent"]; ok {
+		t.Error("The headers of the caller shouldn't be changed")
+	}
+	appUserAgent = "contoso-cli/1.2.0"
+	mockHTTPManager.On("Get", url, map[string]string{"User-Agent": testUserAgent + " contoso-cli/1.2.0"}).
+		Return(&msalHTTPManagerResponse{responseCode: 200}, nil)
+	if _, err := manager.Get(context.Background(), url, nil); err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+}
+
+func TestSetUserAgent(t *testing.T) {
+	pca, err := CreatePublicClientApplication("clientID", "https://login.useragent.contoso.com/tenant/")
+	if err != nil {
+		t.Fatalf("Error should be nil, but it is %v", err)
+	}
+	mockHTTPManager := new(mockHTTPManager)
+	pca.SetHTTPManager(mockHTTPManager)
+	pca.SetRetryPolicy(RetryPolicy{})
+	pca.SetUserAgent("contoso-cli/1.2.0")
+	sentUserAgents := []string{}
+	recordUserAgent := func(args mock.Arguments) {
+		sentUserAgents = append(sentUserAgents, args.Get(1).(map[string]string)["User-Agent"])
+	}
+	mockHTTPManager.On("Get", mock.Anything, mock.Anything).Run(recordUserAgent).
+		Return((*msalHTTPManagerResponse)(nil), errors.New("unreachable"))
+	if err := pca.ValidateAuthority(context.Background()); err == nil {
+		t.Error("Error should not be nil when the authority can't be reached")
+	}
+	if len(sentUserAgents) == 0 {
+		t.Fatal("Instance discovery should have been requested")
+	}
+	for _, userAgent := range sentUserAgents {
+		if userAgent != testUserAgent+" contoso-cli/1.2.0" {
+			t.Errorf("The User-Agent should have the product tokens of MSAL and the app, instead it is %q", userAgent)
+		}
+	}
+}
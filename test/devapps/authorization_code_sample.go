@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,12 +13,19 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// codeVerifier secures the authorization code with PKCE, it's sent when redeeming the code
+var codeVerifier string
+
 func redirectToURL(w http.ResponseWriter, r *http.Request) {
 	// Getting the URL to redirect to acquire the authorization code
 	authCodeURLParams := msalgo.CreateAuthorizationCodeURLParameters(config.ClientID, config.RedirectURI, config.Scopes)
-	authCodeURLParams.CodeChallenge = config.CodeChallenge
+	codeVerifier, err = msalgo.CreateCodeVerifier()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authCodeURLParams.SetCodeVerifier(codeVerifier)
 	authCodeURLParams.State = config.State
-	authURL, err := publicClientApp.CreateAuthCodeURL(authCodeURLParams)
+	authURL, err := publicClientApp.CreateAuthCodeURL(context.Background(), authCodeURLParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -43,8 +51,8 @@ func getToken(w http.ResponseWriter, r *http.Request) {
 	// Getting the access token using the authorization code
 	authCodeParams := msalgo.CreateAcquireTokenAuthCodeParameters(config.Scopes, config.RedirectURI)
 	authCodeParams.Code = code
-	authCodeParams.CodeChallenge = config.CodeChallenge
-	result, err := publicClientApp.AcquireTokenByAuthCode(authCodeParams)
+	authCodeParams.CodeChallenge = codeVerifier
+	result, err := publicClientApp.AcquireTokenByAuthCode(context.Background(), authCodeParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -57,6 +65,7 @@ func acquireByAuthorizationCodePublic() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	msalgo.SetLogger(log.StandardLogger())
 	http.HandleFunc("/", redirectToURL)
 	// The redirect uri set in our app's registration is http://localhost:port/redirect
 	http.HandleFunc("/redirect", getToken)
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -15,7 +16,7 @@ import (
 func tryClientCertificateFlow(confidentialClientApp *msalgo.ConfidentialClientApplication) {
 	certificateParams := msalgo.CreateAcquireTokenClientCredentialParameters(
 		confidentialConfig.Scopes)
-	result, err := confidentialClientApp.AcquireTokenByClientCredential(certificateParams)
+	result, err := confidentialClientApp.AcquireTokenByClientCredential(context.Background(), certificateParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -42,8 +43,9 @@ func acquireTokenClientCertificate() {
 		log.Fatal(err)
 	}
 	confidentialClientApp.SetCacheAccessor(cacheAccessor)
+	msalgo.SetLogger(log.StandardLogger())
 	silentParams := msalgo.CreateAcquireTokenSilentParameters(confidentialConfig.Scopes)
-	result, err := confidentialClientApp.AcquireTokenSilent(silentParams)
+	result, err := confidentialClientApp.AcquireTokenSilent(context.Background(), silentParams)
 	if err != nil {
 		log.Info(err)
 		tryClientCertificateFlow(confidentialClientApp)
@@ -4,6 +4,8 @@
 package main
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 
 	msalgo "github.com/AzureAD/microsoft-authentication-library-for-go/src/msal"
@@ -11,7 +13,7 @@ import (
 
 func tryClientSecretFlow(confidentialClientApp *msalgo.ConfidentialClientApplication) {
 	clientSecretParams := msalgo.CreateAcquireTokenClientCredentialParameters(confidentialConfig.Scopes)
-	result, err := confidentialClientApp.AcquireTokenByClientCredential(clientSecretParams)
+	result, err := confidentialClientApp.AcquireTokenByClientCredential(context.Background(), clientSecretParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -30,8 +32,9 @@ func acquireTokenClientSecret() {
 		log.Fatal(err)
 	}
 	confidentialClientApp.SetCacheAccessor(cacheAccessor)
+	msalgo.SetLogger(log.StandardLogger())
 	silentParams := msalgo.CreateAcquireTokenSilentParameters(confidentialConfig.Scopes)
-	result, err := confidentialClientApp.AcquireTokenSilent(silentParams)
+	result, err := confidentialClientApp.AcquireTokenSilent(context.Background(), silentParams)
 	if err != nil {
 		log.Info(err)
 		tryClientSecretFlow(confidentialClientApp)
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -29,7 +30,7 @@ func redirectToURLConfidential(w http.ResponseWriter, r *http.Request) {
 	)
 	authCodeURLParams.CodeChallenge = confidentialConfig.CodeChallenge
 	authCodeURLParams.State = confidentialConfig.State
-	authURL, err := confidentialClientAuthCode.CreateAuthCodeURL(authCodeURLParams)
+	authURL, err := confidentialClientAuthCode.CreateAuthCodeURL(context.Background(), authCodeURLParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -59,7 +60,7 @@ func getTokenConfidential(w http.ResponseWriter, r *http.Request) {
 	)
 	authCodeParams.CodeChallenge = confidentialConfig.CodeChallenge
 	authCodeParams.Code = code
-	result, err := confidentialClientAuthCode.AcquireTokenByAuthCode(authCodeParams)
+	result, err := confidentialClientAuthCode.AcquireTokenByAuthCode(context.Background(), authCodeParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -88,6 +89,7 @@ func acquireByAuthorizationCodeConfidential() {
 		log.Fatal(err)
 	}
 	confidentialClientAuthCode.SetCacheAccessor(cacheAccessor)
+	msalgo.SetLogger(log.StandardLogger())
 	var userAccount msalgo.AccountProvider
 	accounts := confidentialClientAuthCode.GetAccounts()
 	for _, account := range accounts {
@@ -97,7 +99,7 @@ func acquireByAuthorizationCodeConfidential() {
 	}
 	if userAccount != nil {
 		silentParams := msalgo.CreateAcquireTokenSilentParametersWithAccount(confidentialConfig.Scopes, userAccount)
-		result, err := confidentialClientAuthCode.AcquireTokenSilent(silentParams)
+		result, err := confidentialClientAuthCode.AcquireTokenSilent(context.Background(), silentParams)
 		if err == nil {
 			fmt.Printf("Access token is " + result.GetAccessToken())
 			accessToken = result.GetAccessToken()
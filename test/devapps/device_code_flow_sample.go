@@ -20,11 +20,11 @@ func tryDeviceCodeFlow(publicClientApp *msalgo.PublicClientApplication) {
 	cancelTimeout := 100 //Change this for cancel timeout
 	cancelCtx, cancelFunc := context.WithTimeout(context.Background(), time.Duration(cancelTimeout)*time.Second)
 	defer cancelFunc()
-	deviceCodeParams := msalgo.CreateAcquireTokenDeviceCodeParameters(cancelCtx, config.Scopes, deviceCodeCallback)
+	deviceCodeParams := msalgo.CreateAcquireTokenDeviceCodeParameters(config.Scopes, deviceCodeCallback)
 	resultChannel := make(chan msalgo.AuthenticationResultProvider)
 	errChannel := make(chan error)
 	go func() {
-		result, err := publicClientApp.AcquireTokenByDeviceCode(deviceCodeParams)
+		result, err := publicClientApp.AcquireTokenByDeviceCode(cancelCtx, deviceCodeParams)
 		errChannel <- err
 		resultChannel <- result
 	}()
@@ -43,6 +43,7 @@ func acquireTokenDeviceCode() {
 		log.Fatal(err)
 	}
 	publicClientApp.SetCacheAccessor(cacheAccessor)
+	msalgo.SetLogger(log.StandardLogger())
 	var userAccount msalgo.AccountProvider
 	accounts := publicClientApp.GetAccounts()
 	for _, account := range accounts {
@@ -55,7 +56,7 @@ func acquireTokenDeviceCode() {
 		tryDeviceCodeFlow(publicClientApp)
 	} else {
 		silentParams := msalgo.CreateAcquireTokenSilentParametersWithAccount(config.Scopes, userAccount)
-		result, err := publicClientApp.AcquireTokenSilent(silentParams)
+		result, err := publicClientApp.AcquireTokenSilent(context.Background(), silentParams)
 		if err != nil {
 			log.Info(err)
 			tryDeviceCodeFlow(publicClientApp)
@@ -33,11 +33,15 @@ func (accessor *SampleCacheAccessor) BeforeCacheAccess(context *msalgo.CacheCont
 }
 
 func (accessor *SampleCacheAccessor) AfterCacheAccess(context *msalgo.CacheContext) {
+	// The file only needs to be written when tokens were added to or removed from the cache
+	if !context.HasStateChanged() {
+		return
+	}
 	data, err := context.SerializeCache()
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = ioutil.WriteFile(accessor.file, []byte(data), 0644)
+	err = ioutil.WriteFile(accessor.file, data, 0644)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	msalgo "github.com/AzureAD/microsoft-authentication-library-for-go/src/msal"
@@ -12,7 +13,7 @@ import (
 
 func tryUsernamePasswordFlow(publicClientApp *msalgo.PublicClientApplication) {
 	userNameParams := msalgo.CreateAcquireTokenUsernamePasswordParameters(config.Scopes, config.Username, config.Password)
-	result, err := publicClientApp.AcquireTokenByUsernamePassword(userNameParams)
+	result, err := publicClientApp.AcquireTokenByUsernamePassword(context.Background(), userNameParams)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -28,6 +29,7 @@ func acquireByUsernamePasswordPublic() {
 		log.Fatal(err)
 	}
 	publicClientApp.SetCacheAccessor(cacheAccessor)
+	msalgo.SetLogger(log.StandardLogger())
 	var userAccount msalgo.AccountProvider
 	accounts := publicClientApp.GetAccounts()
 	for _, account := range accounts {
@@ -40,7 +42,7 @@ func acquireByUsernamePasswordPublic() {
 		tryUsernamePasswordFlow(publicClientApp)
 	} else {
 		silentParams := msalgo.CreateAcquireTokenSilentParametersWithAccount(config.Scopes, userAccount)
-		result, err := publicClientApp.AcquireTokenSilent(silentParams)
+		result, err := publicClientApp.AcquireTokenSilent(context.Background(), silentParams)
 		if err != nil {
 			log.Info(err)
 			tryUsernamePasswordFlow(publicClientApp)